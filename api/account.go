@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 
 	db "github.com/Oliver-Zen/simplebank/db/sqlc"
@@ -37,7 +39,16 @@ func (server *Server) createAccount(ctx *gin.Context) {
 		Currency: req.Currency,
 		Balance:  0,
 	}
-	account, err := server.store.CreateAccount(ctx, arg)
+	var account db.Account
+	err := server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		account, err = q.CreateAccount(ctx, arg)
+		return db.AuditChange{
+			Action: db.AuditActionAccountCreate,
+			Target: fmt.Sprintf("account:%d", account.ID),
+			After:  account,
+		}, err
+	})
 	if err != nil { // internal issue (req validated already)
 		// handle DB error(s)
 		if pqErr, ok := err.(*pq.Error); ok {
@@ -72,16 +83,27 @@ func (server *Server) getAccount(ctx *gin.Context) {
 		return
 	}
 
-	account, err := server.store.GetAccount(ctx, req.ID)
+	account, ok := server.getOwnedAccount(ctx, req.ID)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, account)
+}
+
+// `getOwnedAccount` gets an account of the authenticated user.
+// If it doesn't exist or belongs to someone else, it sends the error response and returns false.
+func (server *Server) getOwnedAccount(ctx *gin.Context, id int64) (db.Account, bool) {
+	account, err := server.store.GetAccount(ctx, id)
 	if err != nil { // 2 possible scenarios:
 		// 1) `ID` doesn't exist
 		if err == sql.ErrNoRows {
 			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
+			return account, false
 		}
 		// 2) internal error
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+		return account, false
 	}
 
 	// account := db.Account{} // to understand mockDB: `Times(1)`
@@ -92,10 +114,9 @@ func (server *Server) getAccount(ctx *gin.Context) {
 	if account.Owner != authPayload.Username {
 		err := errors.New("account doesn't belong to the authenticated user")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
-		return
+		return account, false
 	}
-
-	ctx.JSON(http.StatusOK, account)
+	return account, true
 }
 
 type listAccountRequest struct {
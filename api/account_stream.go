@@ -0,0 +1,331 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	// events of the account streams, see `streamAccount`
+	streamEventEntry   = "entry"
+	streamEventBalance = "balance"
+
+	// events buffered per stream, a slower client is resynced from the db
+	streamBufferSize = 64
+	// a comment is sent when nothing happens for so long, so proxies don't close the connection
+	streamKeepAliveInterval = 15 * time.Second
+	// entries replayed per query on resume
+	streamReplayPageSize = 100
+)
+
+// how often a stream authorized by an API key checks it hasn't been revoked since, a var so tests can shorten it
+var streamAPIKeyCheckInterval = time.Minute
+
+// accountEventHub fans out the `db.AccountEvent` notifications to the open streams of each account.
+type accountEventHub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[*accountSubscription]struct{}
+}
+
+// accountSubscription receives the events of an account.
+// If some are lost (the stream was too slow, or the connection to Postgres was lost),
+// `resync` is signaled instead: the stream must read what it missed from the db.
+type accountSubscription struct {
+	events chan db.AccountEvent
+	resync chan struct{}
+}
+
+func newAccountEventHub() *accountEventHub {
+	return &accountEventHub{subscribers: make(map[int64]map[*accountSubscription]struct{})}
+}
+
+// subscribe starts receiving the events of an account, until `unsubscribe` is called.
+func (hub *accountEventHub) subscribe(accountID int64) (sub *accountSubscription, unsubscribe func()) {
+	sub = &accountSubscription{
+		events: make(chan db.AccountEvent, streamBufferSize),
+		resync: make(chan struct{}, 1),
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.subscribers[accountID] == nil {
+		hub.subscribers[accountID] = make(map[*accountSubscription]struct{})
+	}
+	hub.subscribers[accountID][sub] = struct{}{}
+
+	return sub, func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		delete(hub.subscribers[accountID], sub)
+		if len(hub.subscribers[accountID]) == 0 {
+			delete(hub.subscribers, accountID)
+		}
+	}
+}
+
+// publish sends an event to the subscriptions of its account, without ever blocking.
+func (hub *accountEventHub) publish(event db.AccountEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for sub := range hub.subscribers[event.AccountID] {
+		select {
+		case sub.events <- event:
+		default:
+			sub.signalResync()
+		}
+	}
+}
+
+// resyncAll signals every subscription, e.g. when notifications may have been lost.
+func (hub *accountEventHub) resyncAll() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for _, subs := range hub.subscribers {
+		for sub := range subs {
+			sub.signalResync()
+		}
+	}
+}
+
+func (sub *accountSubscription) signalResync() {
+	select {
+	case sub.resync <- struct{}{}:
+	default: // already signaled
+	}
+}
+
+// listenAccountEvents publishes the notifications of `db.AccountEventsChannel` to the hub, until `ctx` is cancelled.
+// It's a worker of the server, with its own connection to Postgres (`LISTEN` needs a dedicated one).
+func (server *Server) listenAccountEvents(ctx context.Context) {
+	listener := pq.NewListener(server.config.DBSource, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("account events listener:", err)
+		}
+	})
+	// `Listen` waits until Postgres is reachable: closing the listener is what unblocks it on shutdown
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer func() {
+		if stop() { // `ctx` wasn't cancelled, the listener is still open
+			listener.Close()
+		}
+	}()
+
+	if err := listener.Listen(db.AccountEventsChannel); err != nil {
+		if ctx.Err() == nil {
+			log.Println("cannot listen to account events:", err)
+		}
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-listener.Notify:
+			if notification == nil {
+				// the connection was re-established, notifications sent meanwhile are lost
+				server.accountEvents.resyncAll()
+				continue
+			}
+			var event db.AccountEvent
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				log.Println("cannot decode account event:", err)
+				continue
+			}
+			server.accountEvents.publish(event)
+		case <-time.After(90 * time.Second):
+			// check the connection is still alive, as recommended by `pq.Listener`
+			go listener.Ping()
+		}
+	}
+}
+
+// `streamAccount` pushes the new entries & balance of an account with Server-Sent Events, as they commit:
+// an "entry" event (its ID is the entry ID) for each new entry, followed by a "balance" event.
+// The current balance is sent first. A client reconnecting with `Last-Event-ID` is sent the entries it missed.
+// The stream ends when its token expires, or when its API key is revoked: the client reconnects with a new one.
+func (server *Server) streamAccount(ctx *gin.Context) {
+	var req getAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var lastEntryID int64
+	if header := ctx.GetHeader("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			err := errors.New("invalid Last-Event-ID")
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		lastEntryID = id
+	}
+
+	account, ok := server.getOwnedAccount(ctx, req.ID)
+	if !ok {
+		return
+	}
+
+	// subscribe before reading the db, so nothing committed in between is missed
+	sub, unsubscribe := server.accountEvents.subscribe(account.ID)
+	defer unsubscribe()
+
+	if lastEntryID == 0 {
+		// only what happens from now on
+		lastEntry, err := server.store.GetLastEntry(ctx, account.ID)
+		if err != nil && err != sql.ErrNoRows {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		lastEntryID = lastEntry.ID
+	}
+
+	// the stream outlives the write timeout of the server
+	http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no") // don't let nginx buffer the events
+	ctx.Status(http.StatusOK)
+
+	// replay what was missed, then the current balance
+	lastEntryID, err := server.replayAccountEvents(ctx, account.ID, lastEntryID)
+	if err != nil {
+		log.Println("cannot replay account events:", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	// the authorization was only checked when the stream started
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var expired <-chan time.Time
+	if !authPayload.ExpiredAt.IsZero() { // API keys may never expire
+		expiry := time.NewTimer(time.Until(authPayload.ExpiredAt))
+		defer expiry.Stop()
+		expired = expiry.C
+	}
+	var checkAPIKey <-chan time.Time
+	apiKey, byAPIKey := ctx.Get(authorizationAPIKeyKey)
+	if byAPIKey {
+		apiKeyCheck := time.NewTicker(streamAPIKeyCheckInterval)
+		defer apiKeyCheck.Stop()
+		checkAPIKey = apiKeyCheck.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-server.streamsCtx.Done(): // shutting down
+			return
+		case <-expired:
+			return
+		case <-checkAPIKey:
+			err = server.checkAPIKeyNotRevoked(ctx, apiKey.(db.ApiKey))
+		case event := <-sub.events:
+			if event.Entry.ID <= lastEntryID {
+				continue // already sent by a replay
+			}
+			err = writeAccountEvent(ctx, event.Entry, event.Balance)
+			lastEntryID = event.Entry.ID
+		case <-sub.resync:
+			lastEntryID, err = server.replayAccountEvents(ctx, account.ID, lastEntryID)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(ctx.Writer, ": keep-alive\n\n")
+			ctx.Writer.Flush()
+		}
+		if err != nil {
+			// the client is gone, the API key was revoked, or the db failed: the client reconnects with `Last-Event-ID`
+			return
+		}
+	}
+}
+
+// checkAPIKeyNotRevoked returns an error if `apiKey` has been revoked, or deleted, since it was verified.
+func (server *Server) checkAPIKeyNotRevoked(ctx context.Context, apiKey db.ApiKey) error {
+	current, err := server.store.GetAPIKeyByPrefix(ctx, apiKey.Prefix)
+	if err != nil {
+		return err
+	}
+	if current.ID != apiKey.ID || current.RevokedAt.Valid {
+		return errors.New("api key has been revoked")
+	}
+	return nil
+}
+
+// replayAccountEvents sends the entries after `lastEntryID`, then the current balance.
+// It returns the ID of the last entry sent.
+func (server *Server) replayAccountEvents(ctx *gin.Context, accountID int64, lastEntryID int64) (int64, error) {
+	for {
+		entries, err := server.store.ListEntriesAfter(ctx, db.ListEntriesAfterParams{
+			AccountID: accountID,
+			AfterID:   lastEntryID,
+			Limit:     streamReplayPageSize,
+		})
+		if err != nil {
+			return lastEntryID, err
+		}
+		for _, entry := range entries {
+			if err := writeStreamEvent(ctx, streamEventEntry, strconv.FormatInt(entry.ID, 10), entry); err != nil {
+				return lastEntryID, err
+			}
+			lastEntryID = entry.ID
+		}
+		if len(entries) < streamReplayPageSize {
+			break
+		}
+	}
+
+	account, err := server.store.GetAccount(ctx, accountID)
+	if err != nil {
+		return lastEntryID, err
+	}
+	return lastEntryID, writeStreamEvent(ctx, streamEventBalance, "", balanceEvent{account.ID, account.Balance})
+}
+
+type balanceEvent struct {
+	AccountID int64 `json:"account_id"`
+	Balance   int64 `json:"balance"`
+}
+
+// writeAccountEvent sends a new entry, and the balance right after it.
+func writeAccountEvent(ctx *gin.Context, entry db.Entry, balance int64) error {
+	err := writeStreamEvent(ctx, streamEventEntry, strconv.FormatInt(entry.ID, 10), entry)
+	if err != nil {
+		return err
+	}
+	return writeStreamEvent(ctx, streamEventBalance, "", balanceEvent{entry.AccountID, balance})
+}
+
+// writeStreamEvent sends one Server-Sent Event, `id` is left out if empty.
+func writeStreamEvent(ctx *gin.Context, event string, id string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(ctx.Writer, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(ctx.Writer, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	ctx.Writer.Flush()
+	return nil
+}
@@ -238,6 +238,23 @@ func TestCreateAccountAPI(t *testing.T) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
+		{
+			name: "ReadOnlyToken",
+			body: gin.H{
+				"currency": account.Currency,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute, token.ScopeAccountsRead)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
 		{
 			name: "InternalError",
 			body: gin.H{
@@ -461,6 +478,7 @@ func randomAccount(owner string) db.Account {
 		Owner:    owner, // add authorization rule
 		Balance:  util.RandomMoney(),
 		Currency: util.RandomCurrency(),
+		Status:   db.AccountStatusActive,
 	}
 }
 
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// `last_used_at` is only refreshed once per interval, so a busy batch job doesn't write on every request
+const apiKeyLastUsedInterval = time.Minute
+
+var errInvalidAPIKey = errors.New("invalid api key")
+
+type createAPIKeyRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+	// what the key may be used for, at most the scopes of the caller's own token; all of them if empty
+	Scopes    []string   `json:"scopes" binding:"omitempty,dive,scope"`
+	ExpiresAt *time.Time `json:"expires_at"` // never expires if not set
+}
+
+// apiKeyResponse never contains the hashed key. `Key` is only returned once, when the key is created.
+type apiKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     apiKey.Scopes,
+		ExpiresAt:  nullTime(apiKey.ExpiresAt),
+		LastUsedAt: nullTime(apiKey.LastUsedAt),
+		RevokedAt:  nullTime(apiKey.RevokedAt),
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// Authorization Rule for Create API Key API: a logged-in user can only create keys for him/herself,
+// with no more scopes than his/her own token. An API key cannot create keys: a leaked key
+// could otherwise mint new ones, outliving its own expiry and revocation.
+func (server *Server) createAPIKey(ctx *gin.Context) {
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, ok := ctx.Get(authorizationAPIKeyKey); ok {
+		err := errors.New("an api key cannot create api keys, use an access token")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		err := errors.New("expires_at must be in the future")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = authPayload.Scopes
+	}
+	if !authPayload.HasScopes(scopes...) {
+		err := errors.New("an api key cannot have more scopes than the token creating it")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	key, prefix, err := util.GenerateAPIKey()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg := db.CreateAPIKeyParams{
+		Owner:     authPayload.Username,
+		Name:      req.Name,
+		Prefix:    prefix,
+		HashedKey: util.HashAPIKey(key),
+		Scopes:    scopes,
+	}
+	if req.ExpiresAt != nil {
+		arg.ExpiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	var apiKey db.ApiKey
+	err = server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		apiKey, err = q.CreateAPIKey(ctx, arg)
+		return db.AuditChange{
+			Action: db.AuditActionAPIKeyCreate,
+			Target: fmt.Sprintf("api_key:%d", apiKey.ID),
+			After:  newAPIKeyResponse(apiKey), // without the hash
+		}, err
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation", "unique_violation":
+				ctx.JSON(http.StatusForbidden, errorResponse(err))
+				return
+			}
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	res := newAPIKeyResponse(apiKey)
+	res.Key = key
+	ctx.JSON(http.StatusOK, res)
+}
+
+type listAPIKeysRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+// Authorization Rule for List API Keys API: a logged-in user can only list his/her own keys.
+func (server *Server) listAPIKeys(ctx *gin.Context) {
+	var req listAPIKeysRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	arg := db.ListAPIKeysParams{
+		Owner:  authPayload.Username,
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	}
+
+	apiKeys, err := server.store.ListAPIKeys(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	res := make([]apiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		res = append(res, newAPIKeyResponse(apiKey))
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+type revokeAPIKeyRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// Authorization Rule for Revoke API Key API: a logged-in user can only revoke his/her own keys.
+// Keys of other users are reported as not found, so their IDs cannot be probed.
+func (server *Server) revokeAPIKey(ctx *gin.Context) {
+	var req revokeAPIKeyRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var apiKey db.ApiKey
+	err := server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		apiKey, err = q.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{
+			ID:    req.ID,
+			Owner: authPayload.Username,
+		})
+		return db.AuditChange{
+			Action: db.AuditActionAPIKeyRevoke,
+			Target: fmt.Sprintf("api_key:%d", apiKey.ID),
+			After:  newAPIKeyResponse(apiKey),
+		}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAPIKeyResponse(apiKey))
+}
+
+// verifyAPIKey checks an API key sent with the `ApiKey` authorization type,
+// and returns it with a payload standing for it, so most handlers don't care how the request was authenticated.
+func verifyAPIKey(ctx context.Context, store db.Store, key string) (db.ApiKey, *token.Payload, error) {
+	prefix, ok := util.ParseAPIKey(key)
+	if !ok {
+		return db.ApiKey{}, nil, errInvalidAPIKey
+	}
+
+	apiKey, err := store.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.ApiKey{}, nil, errInvalidAPIKey
+		}
+		return db.ApiKey{}, nil, err
+	}
+
+	if !util.CheckAPIKey(key, apiKey.HashedKey) {
+		return db.ApiKey{}, nil, errInvalidAPIKey
+	}
+	if apiKey.RevokedAt.Valid {
+		return db.ApiKey{}, nil, errors.New("api key has been revoked")
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt.Valid && now.After(apiKey.ExpiresAt.Time) {
+		return db.ApiKey{}, nil, errors.New("api key has expired")
+	}
+
+	if !apiKey.LastUsedAt.Valid || now.Sub(apiKey.LastUsedAt.Time) > apiKeyLastUsedInterval {
+		// the request must not fail only because the usage couldn't be recorded
+		if err := store.UpdateAPIKeyLastUsed(ctx, apiKey.ID); err != nil {
+			log.Println("cannot update api key last used:", err)
+		}
+	}
+
+	payload := &token.Payload{
+		Username:  apiKey.Owner,
+		Subject:   apiKey.Owner,
+		IssuedAt:  apiKey.CreatedAt,
+		NotBefore: apiKey.CreatedAt,
+		Scopes:    slices.Clone(apiKey.Scopes),
+	}
+	if apiKey.ExpiresAt.Valid {
+		payload.ExpiredAt = apiKey.ExpiresAt.Time
+	}
+	return apiKey, payload, nil
+}
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		scopes        []string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"name":   "batch-job",
+				"scopes": []string{token.ScopeAccountsRead},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+						require.Equal(t, user.Username, arg.Owner)
+						require.Equal(t, "batch-job", arg.Name)
+						require.Equal(t, []string{token.ScopeAccountsRead}, arg.Scopes)
+						require.False(t, arg.ExpiresAt.Valid)
+						return db.ApiKey{
+							ID:        1,
+							Owner:     arg.Owner,
+							Name:      arg.Name,
+							Prefix:    arg.Prefix,
+							HashedKey: arg.HashedKey,
+							Scopes:    arg.Scopes,
+							CreatedAt: time.Now(),
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res apiKeyResponse
+				err := json.NewDecoder(recorder.Body).Decode(&res)
+				require.NoError(t, err)
+
+				// the key is returned once, and never its hash
+				prefix, ok := util.ParseAPIKey(res.Key)
+				require.True(t, ok)
+				require.Equal(t, res.Prefix, prefix)
+				require.NotContains(t, recorder.Body.String(), "hashed_key")
+			},
+		},
+		{
+			name: "MoreScopesThanToken",
+			body: gin.H{
+				"name":   "batch-job",
+				"scopes": []string{token.ScopeTransfersWrite},
+			},
+			scopes: []string{token.ScopeAccountsRead, token.ScopeAPIKeysWrite},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "UnsupportedScope",
+			body: gin.H{
+				"name":   "batch-job",
+				"scopes": []string{"admin"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiresInThePast",
+			body: gin.H{
+				"name":       "batch-job",
+				"expires_at": time.Now().Add(-time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/api_keys", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute, tc.scopes...)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRevokeAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name         string
+		buildStubs   func(store *mockdb.MockStore)
+		expectedCode int
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Eq(db.RevokeAPIKeyParams{ID: 1, Owner: user.Username})).
+					Times(1).
+					Return(db.ApiKey{ID: 1, Owner: user.Username, RevokedAt: sql.NullTime{Time: time.Now(), Valid: true}}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodDelete, "/api_keys/1", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	user, _ := randomUser(t)
+	key, prefix, err := util.GenerateAPIKey()
+	require.NoError(t, err)
+
+	newAPIKey := func() db.ApiKey {
+		return db.ApiKey{
+			ID:         1,
+			Owner:      user.Username,
+			Prefix:     prefix,
+			HashedKey:  util.HashAPIKey(key),
+			Scopes:     []string{token.ScopeAccountsRead},
+			LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			CreatedAt:  time.Now(),
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		key          string
+		buildStubs   func(store *mockdb.MockStore)
+		expectedCode int
+	}{
+		{
+			name: "OK",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(prefix)).
+					Times(1).
+					Return(newAPIKey(), nil)
+				// used a moment ago: not recorded again
+				store.EXPECT().
+					UpdateAPIKeyLastUsed(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "RecordLastUsed",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				apiKey := newAPIKey()
+				apiKey.LastUsedAt = sql.NullTime{}
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					UpdateAPIKeyLastUsed(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "WrongSecret",
+			key:  fmt.Sprintf("sb_%s_%s", prefix, util.RandomString(32)),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(prefix)).
+					Times(1).
+					Return(newAPIKey(), nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "UnknownKey",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(prefix)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "MalformedKey",
+			key:  "not-a-key",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Revoked",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				apiKey := newAPIKey()
+				apiKey.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(prefix)).
+					Times(1).
+					Return(apiKey, nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Expired",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				apiKey := newAPIKey()
+				apiKey.ExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(prefix)).
+					Times(1).
+					Return(apiKey, nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				authMiddleware(server.tokenMaker, server.store),
+				requireScopes(token.ScopeAccountsRead),
+				func(ctx *gin.Context) {
+					authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+					require.Equal(t, user.Username, authPayload.Username)
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			request.Header.Set(authorizationHeaderKey, "ApiKey "+tc.key)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestCreateAPIKeyWithAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	key, prefix, err := util.GenerateAPIKey()
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(prefix)).
+		Times(1).
+		Return(db.ApiKey{
+			ID:         1,
+			Owner:      user.Username,
+			Prefix:     prefix,
+			HashedKey:  util.HashAPIKey(key),
+			Scopes:     token.AllScopes(),
+			LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			CreatedAt:  time.Now(),
+		}, nil)
+	// even a key with every scope cannot mint another one
+	store.EXPECT().
+		CreateAPIKey(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(gin.H{"name": "batch-job"})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/api_keys", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	request.Header.Set(authorizationHeaderKey, "ApiKey "+key)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
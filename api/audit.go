@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+// actor of the events happening before authentication
+const anonymousActor = "anonymous"
+
+// `audit` records an event outside of any change, e.g. a login lockout.
+// Changes are recorded by the store, in the same transaction (see `db.AuditedTx`).
+// A failure is only logged: it must not fail the request being audited.
+func (server *Server) audit(ctx *gin.Context, change db.AuditChange) {
+	event, err := db.NewAuditEvent(ctx, change)
+	if err == nil {
+		_, err = server.store.CreateAuditEvent(ctx, event)
+	}
+	if err != nil {
+		log.Println("cannot write audit event:", err)
+	}
+}
+
+type listAuditEventsRequest struct {
+	Actor    string    `form:"actor"`
+	Target   string    `form:"target"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	PageID   int32     `form:"page_id" binding:"required,min=1"`
+	PageSize int32     `form:"page_size" binding:"required,min=5,max=100"`
+}
+
+// `listAuditEvents` lists the audit log, oldest first, optionally filtered by actor, target & time range [from, to).
+// Only bankers may read it.
+func (server *Server) listAuditEvents(ctx *gin.Context) {
+	var req listAuditEventsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To) {
+		err := errors.New("from must be before to")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	events, err := server.store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Actor:    sql.NullString{String: req.Actor, Valid: req.Actor != ""},
+		Target:   sql.NullString{String: req.Target, Valid: req.Target != ""},
+		FromTime: sql.NullTime{Time: req.From, Valid: !req.From.IsZero()},
+		ToTime:   sql.NullTime{Time: req.To, Valid: !req.To.IsZero()},
+		Limit:    req.PageSize,
+		Offset:   (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
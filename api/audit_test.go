@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditedCreateAccount(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(1).Return(account, nil)
+	// expected before `newTestServer`, so it takes precedence over `allowAuditedTx`
+	store.EXPECT().
+		AuditedTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, fn func(context.Context, db.Querier) (db.AuditChange, error)) error {
+			change, err := fn(ctx, store)
+			require.NoError(t, err)
+			require.Equal(t, db.AuditActionAccountCreate, change.Action)
+			require.Equal(t, fmt.Sprintf("account:%d", account.ID), change.Target)
+			require.Equal(t, account, change.After)
+
+			// who & from where, set by the middlewares
+			metadata := db.AuditMetadataFrom(ctx)
+			require.Equal(t, user.Username, metadata.Actor)
+			require.Equal(t, "request-1", metadata.RequestID)
+			require.Equal(t, "10.0.0.1", metadata.IP)
+			require.Equal(t, "simplebank-test", metadata.UserAgent)
+			return nil
+		})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"currency": "%s"}`, account.Currency)
+	request, err := http.NewRequest(http.MethodPost, "/accounts", strings.NewReader(body))
+	require.NoError(t, err)
+	request.RemoteAddr = "10.0.0.1:1234"
+	request.Header.Set("User-Agent", "simplebank-test")
+	request.Header.Set(requestIDHeaderKey, "request-1")
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "request-1", recorder.Header().Get(requestIDHeaderKey))
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+
+	// generated if the client doesn't send one
+	server.router.ServeHTTP(recorder, request)
+	require.Len(t, recorder.Header().Get(requestIDHeaderKey), 36)
+
+	// and if it's too long
+	recorder = httptest.NewRecorder()
+	request.Header.Set(requestIDHeaderKey, strings.Repeat("x", maxRequestIDLength+1))
+	server.router.ServeHTTP(recorder, request)
+	require.Len(t, recorder.Header().Get(requestIDHeaderKey), 36)
+}
+
+func TestListAuditEventsAPI(t *testing.T) {
+	banker, _ := randomUser(t)
+	banker.Role = db.UserRoleBanker
+	depositor, _ := randomUser(t)
+	depositor.Role = db.UserRoleDepositor
+
+	from := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	to := from.Add(time.Hour)
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		scopes        []string
+		query         url.Values
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: banker,
+			query: url.Values{
+				"actor":     {depositor.Username},
+				"from":      {from.Format(time.RFC3339)},
+				"to":        {to.Format(time.RFC3339)},
+				"page_id":   {"2"},
+				"page_size": {"5"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(banker.Username)).Times(1).Return(banker, nil)
+
+				arg := db.ListAuditEventsParams{
+					Actor:    sql.NullString{String: depositor.Username, Valid: true},
+					FromTime: sql.NullTime{Time: from, Valid: true},
+					ToTime:   sql.NullTime{Time: to, Valid: true},
+					Limit:    5,
+					Offset:   5,
+				}
+				events := []db.AuditEvent{{
+					ID:     6,
+					Actor:  depositor.Username,
+					Action: db.AuditActionAccountCreate,
+					Target: "account:1",
+					Before: json.RawMessage("null"),
+					After:  json.RawMessage(`{"id":1}`),
+				}}
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, got db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+						require.True(t, arg.FromTime.Time.Equal(got.FromTime.Time))
+						require.True(t, arg.ToTime.Time.Equal(got.ToTime.Time))
+						got.FromTime.Time, got.ToTime.Time = arg.FromTime.Time, arg.ToTime.Time
+						require.Equal(t, arg, got)
+						return events, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var events []db.AuditEvent
+				err := json.NewDecoder(recorder.Body).Decode(&events)
+				require.NoError(t, err)
+				require.Len(t, events, 1)
+				require.JSONEq(t, `{"id":1}`, string(events[0].After))
+			},
+		},
+		{
+			name:  "NotABanker",
+			user:  depositor,
+			query: url.Values{"page_id": {"1"}, "page_size": {"5"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(depositor.Username)).Times(1).Return(depositor, nil)
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "MissingScope",
+			user:   banker,
+			scopes: []string{token.ScopeAccountsRead},
+			query:  url.Values{"page_id": {"1"}, "page_size": {"5"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidTimeRange",
+			user: banker,
+			query: url.Values{
+				"from":      {to.Format(time.RFC3339)},
+				"to":        {from.Format(time.RFC3339)},
+				"page_id":   {"1"},
+				"page_size": {"5"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(banker.Username)).Times(1).Return(banker, nil)
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/audit_events?"+tc.query.Encode(), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, time.Minute, tc.scopes...)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAuditMetadataDefaultsToSystem(t *testing.T) {
+	event, err := db.NewAuditEvent(context.Background(), db.AuditChange{
+		Action: db.AuditActionAccountStatus,
+		Target: "account:1",
+		Before: map[string]string{"status": db.AccountStatusActive},
+		After:  map[string]string{"status": db.AccountStatusFrozen},
+	})
+	require.NoError(t, err)
+	require.Equal(t, db.SystemActor, event.Actor)
+	require.JSONEq(t, `{"status":"active"}`, string(event.Before))
+	require.JSONEq(t, `{"status":"frozen"}`, string(event.After))
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	readinessTimeout = 2 * time.Second
+	readinessOK      = "ok"
+	readinessFailing = "failing"
+)
+
+// `healthz` is the liveness probe: if the process can answer HTTP, it's alive.
+// It intentionally doesn't touch the DB, so a DB outage doesn't get the container restarted.
+func (server *Server) healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": readinessOK})
+}
+
+type readinessResponse struct {
+	Status           string            `json:"status"`
+	Checks           map[string]string `json:"checks"`
+	MigrationVersion int64             `json:"migration_version"`
+}
+
+// `readyz` is the readiness probe: the server only receives traffic when all of its dependencies work.
+// It returns 503 Service Unavailable as soon as one check fails or the server is shutting down.
+func (server *Server) readyz(ctx *gin.Context) {
+	res := readinessResponse{
+		Status: readinessOK,
+		Checks: map[string]string{},
+	}
+	// the probe is unauthenticated: the details, e.g. DB hosts or driver errors, are only logged
+	fail := func(check string, message string, err error) {
+		log.Printf("readiness check %s failed: %v", check, err)
+		res.Status = readinessFailing
+		res.Checks[check] = message
+	}
+
+	if server.shuttingDown.Load() {
+		fail("server", "server is shutting down", errors.New("graceful shutdown started"))
+	} else {
+		res.Checks["server"] = readinessOK
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	if err := server.store.Ping(checkCtx); err != nil {
+		fail("database", "database unavailable", err)
+	} else {
+		res.Checks["database"] = readinessOK
+	}
+
+	version, dirty, err := server.store.MigrationVersion(checkCtx)
+	switch {
+	case err != nil:
+		fail("migration", "database unavailable", err)
+	case dirty:
+		fail("migration", "schema out of date", fmt.Errorf("migration version %d is dirty", version))
+	case version < int64(server.schemaVersion):
+		fail("migration", "schema out of date", fmt.Errorf("migration version %d is behind %d", version, server.schemaVersion))
+	default:
+		res.Checks["migration"] = readinessOK
+	}
+	res.MigrationVersion = version
+
+	if err := server.checkTokenMaker(); err != nil {
+		fail("token_maker", "token maker unavailable", err)
+	} else {
+		res.Checks["token_maker"] = readinessOK
+	}
+
+	if res.Status != readinessOK {
+		ctx.JSON(http.StatusServiceUnavailable, res)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// checkTokenMaker makes sure the token maker can still create & verify a token.
+// A verify-only maker has no private key to create one with: it is ready as long as it was created.
+func (server *Server) checkTokenMaker() error {
+	accessToken, err := server.tokenMaker.CreateToken("readyz", time.Minute)
+	if errors.Is(err, token.ErrNoPrivateKey) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = server.tokenMaker.VerifyToken(accessToken)
+	return err
+}
+
+type versionResponse struct {
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// `version` reports which build is running, injected through ldflags.
+func (server *Server) version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, versionResponse{
+		GitCommit: util.GitCommit,
+		BuildTime: util.BuildTime,
+		GoVersion: util.GoVersion(),
+	})
+}
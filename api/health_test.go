@@ -0,0 +1,201 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/db/migration"
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthzAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().Ping(gomock.Any()).Times(0) // liveness must not depend on the DB
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestReadyzAPI(t *testing.T) {
+	latestVersion, err := migration.LatestVersion()
+	require.NoError(t, err)
+	version := int64(latestVersion)
+
+	testCases := []struct {
+		name          string
+		shuttingDown  bool
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(version, false, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				res := requireBodyReadiness(t, recorder.Body)
+				require.Equal(t, readinessOK, res.Status)
+				require.Equal(t, version, res.MigrationVersion)
+				require.Equal(t, readinessOK, res.Checks["token_maker"])
+			},
+		},
+		{
+			name: "DatabaseDown",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(sql.ErrConnDone)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(int64(0), false, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				// the driver error is logged, not returned to the unauthenticated caller
+				require.NotContains(t, recorder.Body.String(), sql.ErrConnDone.Error())
+
+				res := requireBodyReadiness(t, recorder.Body)
+				require.Equal(t, readinessFailing, res.Status)
+				require.Equal(t, "database unavailable", res.Checks["database"])
+				require.Equal(t, "database unavailable", res.Checks["migration"])
+			},
+		},
+		{
+			name: "DirtyMigration",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(version, true, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				res := requireBodyReadiness(t, recorder.Body)
+				require.Equal(t, "schema out of date", res.Checks["migration"])
+			},
+		},
+		{
+			name: "MigrationBehind",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(version-1, false, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				res := requireBodyReadiness(t, recorder.Body)
+				require.Equal(t, "schema out of date", res.Checks["migration"])
+			},
+		},
+		{
+			name:         "ShuttingDown",
+			shuttingDown: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(version, false, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				res := requireBodyReadiness(t, recorder.Body)
+				require.NotEqual(t, readinessOK, res.Checks["server"])
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.shuttingDown.Store(tc.shuttingDown)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCheckTokenMakerVerifyOnly(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "key.pub.pem")
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	require.NoError(t, err)
+
+	for _, name := range []string{tokenMakerPasetoPublic, tokenMakerJWTEdDSA} {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			server.tokenMaker, err = newTokenMaker(util.Config{
+				TokenMaker:       name,
+				TokenKeys:        "key:" + keyPath,
+				TokenActiveKeyID: "key",
+			})
+			require.NoError(t, err)
+
+			// a service only checking tokens is ready without being able to create one
+			_, err = server.tokenMaker.CreateToken("readyz", time.Minute)
+			require.ErrorIs(t, err, token.ErrNoPrivateKey)
+			require.NoError(t, server.checkTokenMaker())
+		})
+	}
+}
+
+func TestVersionAPI(t *testing.T) {
+	server := newTestServer(t, nil)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/version", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var res versionResponse
+	err = json.NewDecoder(recorder.Body).Decode(&res)
+	require.NoError(t, err)
+	require.Equal(t, util.GitCommit, res.GitCommit)
+	require.Equal(t, util.BuildTime, res.BuildTime)
+	require.NotEmpty(t, res.GoVersion)
+}
+
+func requireBodyReadiness(t *testing.T, body io.Reader) readinessResponse {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var res readinessResponse
+	err = json.Unmarshal(data, &res)
+	require.NoError(t, err)
+	return res
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/gin-gonic/gin"
+)
+
+type createHoldRequest struct {
+	AccountID   int64  `json:"account_id" binding:"required,min=1"` // the funds are held on this account
+	ToAccountID int64  `json:"to_account_id" binding:"required,min=1"`
+	Amount      int64  `json:"amount" binding:"required,gt=0"`
+	Currency    string `json:"currency" binding:"required,currency"`
+	Description string `json:"description" binding:"max=255"`
+	// HOLD_DEFAULT_DURATION from now if not set, at most HOLD_MAX_DURATION
+	ExpiresAt *time.Time `json:"expires_at"`
+	// step-up verification, required from TRANSFER_MFA_THRESHOLD
+	TOTPCode string `json:"totp_code" binding:"omitempty,numeric,len=6"`
+}
+
+type holdResponse struct {
+	ID             int64      `json:"id"`
+	AccountID      int64      `json:"account_id"`
+	ToAccountID    int64      `json:"to_account_id"`
+	Amount         int64      `json:"amount"`
+	Description    string     `json:"description"`
+	Status         string     `json:"status"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CapturedAmount int64      `json:"captured_amount"`
+	TransferID     *int64     `json:"transfer_id"`
+	ReleasedAt     *time.Time `json:"released_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newHoldResponse(hold db.Hold) holdResponse {
+	res := holdResponse{
+		ID:             hold.ID,
+		AccountID:      hold.AccountID,
+		ToAccountID:    hold.ToAccountID,
+		Amount:         hold.Amount,
+		Description:    hold.Description,
+		Status:         hold.Status,
+		ExpiresAt:      hold.ExpiresAt,
+		CapturedAmount: hold.CapturedAmount,
+		ReleasedAt:     nullTime(hold.ReleasedAt),
+		CreatedAt:      hold.CreatedAt,
+	}
+	if hold.TransferID.Valid {
+		res.TransferID = &hold.TransferID.Int64
+	}
+	return res
+}
+
+// Authorization Rule for Create Hold API: A logged-in user can only hold funds on his/her own account,
+// with the same rules as the Transfer Money API for the transfer made when the hold is captured.
+func (server *Server) createHold(ctx *gin.Context) {
+	var req createHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(server.config.HoldDefaultDuration)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) || expiresAt.After(now.Add(server.config.HoldMaxDuration)) {
+		err := fmt.Errorf("expires_at must be in the future, within %s", server.config.HoldMaxDuration)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	account, status, err := server.checkTransfer(ctx, authPayload.Username, req.AccountID, req.ToAccountID, req.Currency)
+	if err != nil {
+		ctx.JSON(status, errorResponse(err))
+		return
+	}
+	if requiresApproval(account, req.Amount) {
+		ctx.JSON(http.StatusForbidden, errorResponse(errApprovalRequired(account)))
+		return
+	}
+
+	threshold := server.config.TransferMFAThreshold
+	if threshold > 0 && req.Amount >= threshold {
+		if !server.stepUp(ctx, authPayload.Username, req.TOTPCode, fmt.Sprintf("transfers of %d or more", threshold)) {
+			return
+		}
+	}
+
+	hold, err := server.store.CreateHoldTx(ctx, db.CreateHoldParams{
+		AccountID:   req.AccountID,
+		ToAccountID: req.ToAccountID,
+		Amount:      req.Amount,
+		Description: req.Description,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+type holdRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// Authorization Rule for Get Hold API: A logged-in user can only get the holds of his/her own accounts.
+func (server *Server) getHold(ctx *gin.Context) {
+	var req holdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hold, ok := server.getOwnedHold(ctx, req.ID)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+type captureHoldRequest struct {
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"` // the whole hold if not set
+}
+
+type captureHoldResponse struct {
+	Hold     holdResponse        `json:"hold"`
+	Transfer db.TransferTxResult `json:"transfer"`
+}
+
+// Authorization Rule for Capture Hold API: A logged-in user can only capture the holds of his/her own accounts.
+// The amount captured is transferred, the rest of the hold is released.
+func (server *Server) captureHold(ctx *gin.Context) {
+	var uri holdRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req captureHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) { // the body is optional
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hold, ok := server.getOwnedHold(ctx, uri.ID)
+	if !ok {
+		return
+	}
+	if req.Amount == 0 {
+		req.Amount = hold.Amount
+	}
+
+	// the accounts may have been frozen since the hold was created
+	account, err := server.store.GetAccount(ctx, hold.AccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, status, err := server.checkTransfer(ctx, authPayload.Username, hold.AccountID, hold.ToAccountID, account.Currency); err != nil {
+		ctx.JSON(status, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.CaptureHoldTx(ctx, db.CaptureHoldTxParams{
+		ID:     hold.ID,
+		Amount: req.Amount,
+	})
+	if err != nil {
+		server.holdErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, captureHoldResponse{
+		Hold:     newHoldResponse(result.Hold),
+		Transfer: result.Transfer,
+	})
+}
+
+// Authorization Rule for Void Hold API: A logged-in user can only void the holds of his/her own accounts.
+func (server *Server) voidHold(ctx *gin.Context) {
+	var req holdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hold, ok := server.getOwnedHold(ctx, req.ID)
+	if !ok {
+		return
+	}
+
+	hold, err := server.store.VoidHoldTx(ctx, hold.ID)
+	if err != nil {
+		server.holdErrorResponse(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+// getOwnedHold returns a hold, if it's on an account of the authenticated user.
+// Like `getOwnedAccount`, it writes the error response and returns false otherwise.
+func (server *Server) getOwnedHold(ctx *gin.Context, id int64) (db.Hold, bool) {
+	hold, err := server.store.GetHold(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return hold, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return hold, false
+	}
+
+	_, ok := server.getOwnedAccount(ctx, hold.AccountID)
+	return hold, ok
+}
+
+// holdErrorResponse responds to an error capturing or voiding a hold.
+func (server *Server) holdErrorResponse(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, db.ErrHoldNotActive):
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+	case errors.Is(err, db.ErrCaptureExceedsHold):
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+	case errors.Is(err, db.ErrInsufficientFunds):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+	case errors.Is(err, db.ErrTxConflict):
+		ctx.Header("Retry-After", "1")
+		ctx.JSON(http.StatusServiceUnavailable, errorResponse(err))
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+}
+
+// expireHolds releases the holds past their expiration every HOLD_SWEEP_INTERVAL, until `ctx` is cancelled.
+func (server *Server) expireHolds(ctx context.Context) {
+	server.sweep(ctx, server.config.HoldSweepInterval, "holds", server.store.ExpireHoldsTx)
+}
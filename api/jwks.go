@@ -0,0 +1,25 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/gin-gonic/gin"
+)
+
+// `jwks` publishes the public keys of an asymmetric token maker,
+// so other services can verify access tokens without holding any secret.
+// Symmetric makers have nothing to publish: 404 Not Found.
+func (server *Server) jwks(ctx *gin.Context) {
+	maker, ok := server.tokenMaker.(token.PublicKeyMaker)
+	if !ok {
+		err := errors.New("the token maker is symmetric: there is no public key")
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+		return
+	}
+
+	// verifiers may cache the keys for a while: a new key must be published before it becomes active
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, maker.JWKS())
+}
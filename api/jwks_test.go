@@ -0,0 +1,78 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// writeEd25519Key writes a new PKCS #8 private key in a temporary PEM file and returns its path.
+func writeEd25519Key(t *testing.T) string {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestJWKSAPI(t *testing.T) {
+	for _, tokenMaker := range []string{tokenMakerPasetoPublic, tokenMakerJWTEdDSA} {
+		t.Run(tokenMaker, func(t *testing.T) {
+			config := util.Config{
+				TokenMaker:          tokenMaker,
+				TokenKeys:           "key1:" + writeEd25519Key(t) + ",key2:" + writeEd25519Key(t),
+				TokenActiveKeyID:    "key2",
+				TokenRetiredKeyIDs:  "key1",
+				AccessTokenDuration: time.Minute,
+			}
+			server, err := NewServer(config, nil)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var jwks token.JWKS
+			err = json.NewDecoder(recorder.Body).Decode(&jwks)
+			require.NoError(t, err)
+
+			// retired keys are not published
+			require.Len(t, jwks.Keys, 1)
+			require.Equal(t, "key2", jwks.Keys[0].KeyID)
+			require.Equal(t, "OKP", jwks.Keys[0].KeyType)
+			require.Equal(t, "Ed25519", jwks.Keys[0].Curve)
+			require.NotEmpty(t, jwks.Keys[0].X)
+		})
+	}
+}
+
+func TestJWKSAPISymmetric(t *testing.T) {
+	server := newTestServer(t, nil)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
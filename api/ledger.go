@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+type verifyLedgerRequest struct {
+	AccountID int64 `form:"account_id" binding:"omitempty,min=1"` // every account if empty
+}
+
+type verifyLedgerResponse struct {
+	Intact bool             `json:"intact"`
+	Breaks []db.LedgerBreak `json:"breaks"`
+}
+
+// `verifyLedger` walks the hash chain of the entries of each account, and reports the first broken link of each.
+// Only bankers may run it: it reads every entry.
+func (server *Server) verifyLedger(ctx *gin.Context) {
+	var req verifyLedgerRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	breaks, err := server.store.VerifyLedger(ctx, db.VerifyLedgerParams{AccountID: req.AccountID})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verifyLedgerResponse{
+		Intact: len(breaks) == 0,
+		Breaks: breaks,
+	})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyLedgerAPI(t *testing.T) {
+	banker, _ := randomUser(t)
+	banker.Role = db.UserRoleBanker
+	depositor, _ := randomUser(t)
+
+	brk := db.LedgerBreak{AccountID: 7, EntryID: 42, Reason: "hash doesn't match the entry's contents"}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Intact",
+			user: banker,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(banker.Username)).Times(1).Return(banker, nil)
+				store.EXPECT().
+					VerifyLedger(gomock.Any(), gomock.Eq(db.VerifyLedgerParams{})).
+					Times(1).
+					Return([]db.LedgerBreak{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"intact": true, "breaks": []}`, recorder.Body.String())
+			},
+		},
+		{
+			name:  "Broken",
+			user:  banker,
+			query: fmt.Sprintf("?account_id=%d", brk.AccountID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(banker.Username)).Times(1).Return(banker, nil)
+				store.EXPECT().
+					VerifyLedger(gomock.Any(), gomock.Eq(db.VerifyLedgerParams{AccountID: brk.AccountID})).
+					Times(1).
+					Return([]db.LedgerBreak{brk}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res verifyLedgerResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.False(t, res.Intact)
+				require.Equal(t, []db.LedgerBreak{brk}, res.Breaks)
+			},
+		},
+		{
+			name:  "InvalidAccountID",
+			user:  banker,
+			query: "?account_id=-1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(banker.Username)).Times(1).Return(banker, nil)
+				store.EXPECT().VerifyLedger(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotABanker",
+			user: depositor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(depositor.Username)).Times(1).Return(depositor, nil)
+				store.EXPECT().VerifyLedger(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/ledger/verify"+tc.query, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
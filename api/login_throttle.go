@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLoginFailureWindow   = 15 * time.Minute
+	defaultLoginLockoutDuration = 15 * time.Minute
+	maxLoginFailureDelay        = 5 * time.Second
+)
+
+var (
+	// the same error for unknown usernames & wrong passwords, so it cannot be used to find out which users exist
+	errInvalidCredentials = errors.New("incorrect username or password")
+	errLoginLocked        = errors.New("too many failed logins, try again later")
+)
+
+func loginUsernameKey(username string) string {
+	return "user:" + username
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// the attempts of a two-step login are counted per challenge token too, see `maxMFAChallengeAttempts`
+func loginChallengeKey(challenge *token.Payload) string {
+	return "challenge:" + challenge.ID.String()
+}
+
+// loginThrottled returns true if failed logins are tracked.
+func (server *Server) loginThrottled() bool {
+	return server.config.LoginMaxFailures > 0
+}
+
+// checkLoginLockout writes a 429 Too Many Requests response and returns false
+// if the username or the client IP is locked out.
+func (server *Server) checkLoginLockout(ctx *gin.Context, username string) bool {
+	var lockedUntil time.Time
+	for _, key := range []string{loginUsernameKey(username), loginIPKey(ctx.ClientIP())} {
+		failure, err := server.store.GetLoginFailure(ctx, key)
+		if err != nil {
+			if err == sql.ErrNoRows { // no recent failure
+				continue
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return false
+		}
+		if failure.LockedUntil.Valid && failure.LockedUntil.Time.After(lockedUntil) {
+			lockedUntil = failure.LockedUntil.Time
+		}
+	}
+
+	retryAfter := time.Until(lockedUntil)
+	if retryAfter <= 0 {
+		return true
+	}
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	ctx.JSON(http.StatusTooManyRequests, errorResponse(errLoginLocked))
+	return false
+}
+
+// loginFailed answers a failed login with 401 Unauthorized.
+// The failure is counted for the username & the client IP, and the response is delayed
+// progressively, the longer the more failures there were.
+func (server *Server) loginFailed(ctx *gin.Context, username string) {
+	server.authenticationFailed(ctx, username, http.StatusUnauthorized, errInvalidCredentials)
+}
+
+// mfaFailed answers an invalid second factor with `status`. It counts as a failed login of the username,
+// so the codes cannot be guessed one after the other, by the two-step login nor by step-up authentication.
+func (server *Server) mfaFailed(ctx *gin.Context, username string, status int) {
+	server.authenticationFailed(ctx, username, status, errInvalidMFACode)
+}
+
+func (server *Server) authenticationFailed(ctx *gin.Context, username string, status int, err error) {
+	if server.loginThrottled() {
+		failures, err := server.recordLoginFailure(ctx, username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		sleep(ctx.Request.Context(), loginFailureDelay(server.config.LoginFailureDelay, failures))
+	}
+	ctx.JSON(status, errorResponse(err))
+}
+
+// recordLoginFailure counts a failed login, and locks the username or the client IP once it reaches its limit.
+// It returns the highest number of failures counted.
+func (server *Server) recordLoginFailure(ctx *gin.Context, username string) (int32, error) {
+	window := server.config.LoginFailureWindow
+	if window == 0 {
+		window = defaultLoginFailureWindow
+	}
+	lockout := server.config.LoginLockoutDuration
+	if lockout == 0 {
+		lockout = defaultLoginLockoutDuration
+	}
+
+	limits := []struct {
+		key         string
+		maxFailures int32
+	}{
+		{loginUsernameKey(username), server.config.LoginMaxFailures},
+		{loginIPKey(ctx.ClientIP()), server.config.LoginMaxFailuresPerIP},
+	}
+
+	var failures int32
+	for _, limit := range limits {
+		failure, err := server.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+			Key:         limit.key,
+			WindowStart: time.Now().Add(-window),
+		})
+		if err != nil {
+			return 0, err
+		}
+		failures = max(failures, failure.Failures)
+
+		if limit.maxFailures > 0 && failure.Failures >= limit.maxFailures {
+			failure, err = server.store.LockLogin(ctx, db.LockLoginParams{
+				Key:         limit.key,
+				LockedUntil: time.Now().Add(lockout),
+			})
+			if err != nil {
+				return 0, err
+			}
+			server.audit(ctx, db.AuditChange{
+				Action: db.AuditActionLoginLockout,
+				Target: limit.key,
+				After:  failure,
+			})
+		}
+	}
+	return failures, nil
+}
+
+// resetLoginFailures forgets the failures of the username after a successful login, with its second factor if any.
+// The failures of the client IP are kept, otherwise logging into one's own account would reset them.
+func (server *Server) resetLoginFailures(ctx *gin.Context, username string) error {
+	if !server.loginThrottled() {
+		return nil
+	}
+	return server.store.ResetLoginFailures(ctx, loginUsernameKey(username))
+}
+
+// loginFailureDelay doubles `base` for each previous failure, up to `maxLoginFailureDelay`.
+func loginFailureDelay(base time.Duration, failures int32) time.Duration {
+	delay := base
+	for i := int32(1); i < failures && delay < maxLoginFailureDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxLoginFailureDelay)
+}
+
+// sleep waits for `duration`, or until `ctx` is done, e.g. the client disconnected.
+func sleep(ctx context.Context, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
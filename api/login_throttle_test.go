@@ -0,0 +1,393 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testLoginMaxFailures = 3
+
+func newThrottledTestServer(t *testing.T, store db.Store) *Server {
+	config := util.Config{
+		TokenSymmetricKey:     util.RandomString(32),
+		AccessTokenDuration:   time.Minute,
+		LoginMaxFailures:      testLoginMaxFailures,
+		LoginMaxFailuresPerIP: 10 * testLoginMaxFailures,
+		LoginFailureWindow:    time.Minute,
+		LoginLockoutDuration:  time.Minute,
+	}
+
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	allowAuditedTx(store)
+	allowAccessTokens(store)
+	return server
+}
+
+func TestLoginThrottleAPI(t *testing.T) {
+	user, password := randomUser(t)
+	userKey := loginUsernameKey(user.Username)
+
+	// no failure of the username or the IP yet
+	noLockout := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetLoginFailure(gomock.Any(), gomock.Any()).
+			Times(2).
+			Return(db.LoginFailure{}, sql.ErrNoRows)
+	}
+
+	testCases := []struct {
+		name          string
+		password      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				noLockout(store)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(userKey)).Times(1).Return(nil)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Any()).Times(1).Return(db.TotpSecret{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "FailureRecorded",
+			password: "incorrect",
+			buildStubs: func(store *mockdb.MockStore) {
+				noLockout(store)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ any, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						return db.LoginFailure{Key: arg.Key, Failures: 1, LastFailedAt: time.Now()}, nil
+					})
+				store.EXPECT().LockLogin(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "LockedAfterMaxFailures",
+			password: "incorrect",
+			buildStubs: func(store *mockdb.MockStore) {
+				noLockout(store)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ any, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						failures := int32(testLoginMaxFailures)
+						if arg.Key != userKey { // the IP is still far from its limit
+							failures = 1
+						}
+						return db.LoginFailure{Key: arg.Key, Failures: failures, LastFailedAt: time.Now()}, nil
+					})
+				store.EXPECT().
+					LockLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.LockLoginParams) (db.LoginFailure, error) {
+						require.Equal(t, userKey, arg.Key)
+						require.WithinDuration(t, time.Now().Add(time.Minute), arg.LockedUntil, time.Second)
+						return db.LoginFailure{Key: arg.Key, LockedUntil: sql.NullTime{Time: arg.LockedUntil, Valid: true}}, nil
+					})
+				store.EXPECT().
+					CreateAuditEvent(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateAuditEventParams) (db.AuditEvent, error) {
+						require.Equal(t, anonymousActor, arg.Actor)
+						require.Equal(t, db.AuditActionLoginLockout, arg.Action)
+						require.Equal(t, userKey, arg.Target)
+						return db.AuditEvent{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "LockedOut",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(userKey)).
+					Times(1).
+					Return(db.LoginFailure{
+						Key:         userKey,
+						LockedUntil: sql.NullTime{Time: time.Now().Add(30 * time.Second), Valid: true},
+					}, nil)
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Not(userKey)).
+					Times(1).
+					Return(db.LoginFailure{}, sql.ErrNoRows)
+				// even the right password is rejected
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.Equal(t, "30", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name:     "LockoutExpired",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{
+						LockedUntil: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+					}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(userKey)).Times(1).Return(nil)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Any()).Times(1).Return(db.TotpSecret{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newThrottledTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"username": user.Username, "password": tc.password})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginFailureDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	require.Zero(t, loginFailureDelay(0, 5))
+	require.Equal(t, base, loginFailureDelay(base, 1))
+	require.Equal(t, 4*base, loginFailureDelay(base, 3))
+	require.Equal(t, maxLoginFailureDelay, loginFailureDelay(base, 100))
+}
+
+func TestLoginMFAThrottleAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	userKey := loginUsernameKey(user.Username)
+
+	noLockout := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetLoginFailure(gomock.Any(), gomock.Any()).
+			Times(2).
+			Return(db.LoginFailure{}, sql.ErrNoRows)
+	}
+
+	testCases := []struct {
+		name          string
+		validCode     bool
+		buildStubs    func(store *mockdb.MockStore, totpSecret db.TotpSecret)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			validCode: true,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				noLockout(store)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LoginFailure{Failures: 1}, nil)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totpSecret, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+				// the failures of the password step are only forgotten now
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(userKey)).Times(1).Return(nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "FailureRecorded",
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				noLockout(store)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totpSecret, nil)
+				// the challenge attempt, then the failure of the username & the IP
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(3).
+					DoAndReturn(func(_ any, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						return db.LoginFailure{Key: arg.Key, Failures: 1, LastFailedAt: time.Now()}, nil
+					})
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "LockedOut",
+			validCode: true,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(userKey)).
+					Times(1).
+					Return(db.LoginFailure{
+						Key:         userKey,
+						LockedUntil: sql.NullTime{Time: time.Now().Add(30 * time.Second), Valid: true},
+					}, nil)
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Not(userKey)).
+					Times(1).
+					Return(db.LoginFailure{}, sql.ErrNoRows)
+				// even the right code is not checked
+				store.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newThrottledTestServer(t, store)
+			server.config.TOTPEncryptionKey = util.RandomString(util.EncryptionKeySize)
+			totpSecret, secret := randomTOTPSecret(t, server, user.Username)
+			tc.buildStubs(store, totpSecret)
+
+			challengeToken, err := server.tokenMaker.CreateToken(user.Username, time.Minute, token.ScopeMFAChallenge)
+			require.NoError(t, err)
+			step := util.TOTPStep(time.Now())
+			if !tc.validCode {
+				step += 100 // the code of 50 minutes later
+			}
+			code := util.TOTPCode(secret, step)
+
+			data, err := json.Marshal(gin.H{"challenge_token": challengeToken, "code": code})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestChangePasswordThrottleAPI(t *testing.T) {
+	user, password := randomUser(t)
+	userKey := loginUsernameKey(user.Username)
+
+	testCases := []struct {
+		name            string
+		currentPassword string
+		buildStubs      func(store *mockdb.MockStore)
+		expectedCode    int
+	}{
+		{
+			name:            "FailureRecorded",
+			currentPassword: "incorrect",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{}, sql.ErrNoRows)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ any, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						return db.LoginFailure{Key: arg.Key, Failures: 1, LastFailedAt: time.Now()}, nil
+					})
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:            "LockedOut",
+			currentPassword: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(userKey)).
+					Times(1).
+					Return(db.LoginFailure{
+						Key:         userKey,
+						LockedUntil: sql.NullTime{Time: time.Now().Add(30 * time.Second), Valid: true},
+					}, nil)
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Not(userKey)).
+					Times(1).
+					Return(db.LoginFailure{}, sql.ErrNoRows)
+				// even the right password is rejected
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusTooManyRequests,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			tc.buildStubs(store)
+
+			server := newThrottledTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"current_password": tc.currentPassword, "new_password": "N3w-" + util.RandomString(8)})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPut, "/users/password", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
@@ -1,24 +1,60 @@
 package api
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
 
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
 	db "github.com/Oliver-Zen/simplebank/db/sqlc"
 	"github.com/Oliver-Zen/simplebank/util"
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 )
 
+// allowAuditedTx lets handlers run `AuditedTx` on the mock store: `fn` runs against the mock itself,
+// so tests expect the queries made within the transaction, not the audit event.
+func allowAuditedTx(store db.Store) {
+	mock, ok := store.(*mockdb.MockStore)
+	if !ok {
+		return
+	}
+	mock.EXPECT().
+		AuditedTx(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(context.Context, db.Querier) (db.AuditChange, error)) error {
+			_, err := fn(ctx, mock)
+			return err
+		})
+}
+
+// allowAccessTokens lets the tokens of `addAuthorization` through `authMiddleware`:
+// the password of every user is taken as never changed.
+func allowAccessTokens(store db.Store) {
+	mock, ok := store.(*mockdb.MockStore)
+	if !ok {
+		return
+	}
+	mock.EXPECT().
+		GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(time.Time{}, nil)
+}
+
 func newTestServer(t *testing.T, store db.Store) *Server {
 	config := util.Config{
-		TokenSymmetricKey:   util.RandomString(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:        util.RandomString(32),
+		AccessTokenDuration:      time.Minute,
+		TransferApprovalDuration: time.Hour,
+		PasswordMinLength:        6,
 	}
 
 	server, err := NewServer(config, store)
 	require.NoError(t, err)
+	allowAuditedTx(store)
+	allowAccessTokens(store)
 	
 	return server
 }
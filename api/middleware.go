@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
 	"github.com/Oliver-Zen/simplebank/token"
 	"github.com/gin-gonic/gin"
 )
@@ -14,14 +17,16 @@ import (
 const (
 	authorizationHeaderKey = "authorization"
 	authorizationTypeBearer = "bearer"
+	authorizationTypeAPIKey = "apikey" // personal API keys of server-to-server integrations
 	authorizationPayloadKey = "authorization_payload"
+	authorizationAPIKeyKey  = "authorization_api_key" // the db.ApiKey, when authorized by one
 )
 
 // authMiddleware validates the Authorization header in the HTTP request.
-// It ensures the header exists, has a valid "Bearer" token format, and verifies the token.
+// It ensures the header exists, has a valid "Bearer" token or "ApiKey" format, and verifies the token or key.
 // If the token is valid, the payload is stored in the context for downstream handlers.
 // Otherwise, it aborts the request with a 401 Unauthorized status.
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+func authMiddleware(tokenMaker token.Maker, store db.Store) gin.HandlerFunc {
 	// return an anonymous middleware func
 	return func(ctx *gin.Context) {
 		
@@ -48,25 +53,107 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		}
 		
 		// Validate Authorization Type
+		// parse & verfiy the token, or the API key
+		var payload *token.Payload
+		var err error
 		authorizationType := strings.ToLower(fields[0])
-		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type %s: ", authorizationType)
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err)) // 401 unauthorized status
-			return
+		switch authorizationType {
+		case authorizationTypeBearer:
+			accessToken := fields[1]
+			payload, err = tokenMaker.VerifyToken(accessToken) // VerifyToken returns a pointer to token.Payload
+		case authorizationTypeAPIKey:
+			var apiKey db.ApiKey
+			apiKey, payload, err = verifyAPIKey(ctx, store, fields[1])
+			if err == nil {
+				ctx.Set(authorizationAPIKeyKey, apiKey)
+			}
+		default:
+			err = fmt.Errorf("unsupported authorization type %s: ", authorizationType)
 		}
-		
-		// parse & verfiy the token
-		accessToken := fields[1]
-		payload, err := tokenMaker.VerifyToken(accessToken) // VerifyToken returns a pointer to token.Payload
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err)) // 401 unauthorized status
 			return
 		}
+
+		// the intermediate token of a two-step login is not an access token
+		if payload.HasScopes(token.ScopeMFAChallenge) {
+			err := errors.New("a challenge token cannot be used as an access token")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+
+		if authorizationType == authorizationTypeBearer {
+			if err := checkTokenNotRevoked(ctx, store, payload); err != nil {
+				status := http.StatusInternalServerError
+				if err == errTokenRevoked {
+					status = http.StatusUnauthorized
+				}
+				ctx.AbortWithStatusJSON(status, errorResponse(err))
+				return
+			}
+		}
 		
 		// store `payload` in the context, before passing it to next handler
 		ctx.Set(authorizationPayloadKey, payload)
+		setAuditActor(ctx, payload.Username)
 		
 		// forward the request to the next handler
 		ctx.Next()
 	}
 }
+
+var errTokenRevoked = errors.New("token is no longer valid, log in again")
+
+// checkTokenNotRevoked rejects an access token issued before the user's password last changed,
+// so changing a leaked password also logs out whoever holds the old tokens.
+// API keys need no such check, they are revoked along with the password change.
+func checkTokenNotRevoked(ctx context.Context, store db.Store, payload *token.Payload) error {
+	passwordChangedAt, err := store.GetUserPasswordChangedAt(ctx, payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errTokenRevoked
+		}
+		return err
+	}
+	if payload.IssuedAt.Before(passwordChangedAt) {
+		return errTokenRevoked
+	}
+	return nil
+}
+
+// requireScopes must follow `authMiddleware`: it only lets the request through if the token grants all `scopes`.
+// Otherwise, it aborts the request with a 403 Forbidden status,
+// e.g. a read-only token of a dashboard cannot move money.
+func requireScopes(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if !authPayload.HasScopes(scopes...) {
+			err := fmt.Errorf("token is missing a required scope: %s", strings.Join(scopes, ", "))
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// requireRole must follow `authMiddleware`: it only lets the request through if the user has `role`.
+// Otherwise, it aborts the request with a 403 Forbidden status.
+// Unlike scopes, the role is looked up at each request, so a revoked role takes effect immediately.
+func requireRole(store db.Store, role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		user, err := store.GetUser(ctx, authPayload.Username)
+		if err != nil && err != sql.ErrNoRows {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if err == sql.ErrNoRows || user.Role != role {
+			err := fmt.Errorf("only a %s can access this resource", role)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -1,18 +1,22 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"net/http/httptest" // Package for testing HTTP servers and handlers
 	"testing"
 	"time"
 
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
 	"github.com/Oliver-Zen/simplebank/token"
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 )
 
 // addAuthorization creates a new access token and add it to the authorization header of the [request]
+// The token grants all scopes, unless `scopes` are given.
 func addAuthorization(
 	t *testing.T,
 	request *http.Request,
@@ -20,11 +24,15 @@ func addAuthorization(
 	authorizationType string,
 	username string,
 	duration time.Duration,
+	scopes ...string,
 ) {
-	token, err := tokenMaker.CreateToken(username, duration)
+	if len(scopes) == 0 {
+		scopes = token.AllScopes()
+	}
+	accessToken, err := tokenMaker.CreateToken(username, duration, scopes...)
 	require.NoError(t, err)
 	
-	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
+	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, accessToken)
 	// set the healder of the request
 	request.Header.Set(authorizationHeaderKey, authorizationHeader)
 }
@@ -114,13 +122,16 @@ func TestAuthMiddleware(t *testing.T) {
 		tc := testCases[i]
 
 		t.Run(tc.name, func(t *testing.T) {
-			server := newTestServer(t, nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockStore(ctrl))
 
 			// add API route and handler just for test
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				authMiddleware(server.tokenMaker), // middleware
+				authMiddleware(server.tokenMaker, server.store), // middleware
 				func(ctx *gin.Context) { // handler func
 					ctx.JSON(http.StatusOK, gin.H{})
 				},
@@ -138,3 +149,132 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthMiddlewarePasswordChanged(t *testing.T) {
+	username := "user"
+
+	testCases := []struct {
+		name         string
+		buildStubs   func(store *mockdb.MockStore)
+		expectedCode int
+	}{
+		{
+			name: "ChangedBeforeTokenIssued",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(username)).
+					Times(1).
+					Return(time.Now().Add(-time.Minute), nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "ChangedAfterTokenIssued",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(username)).
+					Times(1).
+					Return(time.Now().Add(time.Minute), nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(username)).
+					Times(1).
+					Return(time.Time{}, sql.ErrNoRows)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(username)).
+					Times(1).
+					Return(time.Time{}, sql.ErrConnDone)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				authMiddleware(server.tokenMaker, server.store),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestRequireScopesMiddleware(t *testing.T) {
+	testCases := []struct {
+		name         string
+		scopes       []string
+		expectedCode int
+	}{
+		{
+			name:         "OK",
+			scopes:       []string{token.ScopeAccountsRead, token.ScopeTransfersWrite},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "MissingScope",
+			scopes:       []string{token.ScopeAccountsRead},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				authMiddleware(server.tokenMaker, server.store),
+				requireScopes(token.ScopeTransfersWrite),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute, tc.scopes...)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
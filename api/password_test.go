@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// newArgon2TestServer hashes passwords with argon2id, cheap enough for tests,
+// and requires passwords of 10 characters out of 3 classes.
+func newArgon2TestServer(t *testing.T, store db.Store) *Server {
+	config := util.Config{
+		TokenSymmetricKey:           util.RandomString(32),
+		AccessTokenDuration:         time.Minute,
+		PasswordHasher:              util.PasswordHasherArgon2id,
+		PasswordArgon2Memory:        1024,
+		PasswordArgon2Iterations:    1,
+		PasswordArgon2Parallelism:   1,
+		PasswordMinLength:           10,
+		PasswordMinCharacterClasses: 3,
+	}
+
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	allowAuditedTx(store)
+	allowAccessTokens(store)
+	return server
+}
+
+func TestChangePasswordAPI(t *testing.T) {
+	user, password := randomUser(t)
+	newPassword := "N3w-" + util.RandomString(8)
+
+	testCases := []struct {
+		name         string
+		body         gin.H
+		buildStubs   func(store *mockdb.MockStore)
+		expectedCode int
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UpdateUserPasswordParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.True(t, strings.HasPrefix(arg.HashedPassword, "$argon2id$"))
+						require.NoError(t, util.CheckPassword(newPassword, arg.HashedPassword))
+
+						updated := user
+						updated.HashedPassword = arg.HashedPassword
+						updated.PasswordChangedAt = time.Now()
+						return updated, nil
+					})
+				store.EXPECT().RevokeUserAPIKeys(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(int64(2), nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "IncorrectCurrentPassword",
+			body: gin.H{
+				"current_password": "incorrect",
+				"new_password":     newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RevokeUserAPIKeys(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "WeakPassword",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     "abcdefghijk",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RevokeUserAPIKeys(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "SamePassword",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RevokeUserAPIKeys(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newArgon2TestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPut, "/users/password", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestChangePasswordRequiresScope(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodPut, "/users/password", strings.NewReader("{}"))
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, util.RandomOwner(), time.Minute, token.ScopeAccountsRead)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	// hashed with bcrypt by `randomUser`, the server now uses argon2id
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().
+		RehashUserPassword(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.RehashUserPasswordParams) (int64, error) {
+			require.Equal(t, user.Username, arg.Username)
+			require.Equal(t, user.HashedPassword, arg.OldHashedPassword)
+			require.True(t, strings.HasPrefix(arg.NewHashedPassword, "$argon2id$"))
+			require.NoError(t, util.CheckPassword(password, arg.NewHashedPassword))
+			return 1, nil
+		})
+	store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Any()).Times(1).Return(db.TotpSecret{}, nil)
+
+	server := newArgon2TestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/ratelimit"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+var errRateLimited = errors.New("rate limit exceeded, try again later")
+
+// rateLimitPolicies are the policies of the route groups.
+type rateLimitPolicies struct {
+	public        ratelimit.Policy // keyed by client IP
+	authenticated ratelimit.Policy // keyed by username
+	transfers     ratelimit.Policy // keyed by username, on top of `authenticated`
+	clientIP      ratelimit.Policy // keyed by client IP, before `authenticated`
+}
+
+func newRateLimitPolicies(config util.Config) (policies rateLimitPolicies, err error) {
+	policies.public, err = ratelimit.ParsePolicy(config.RateLimitPublic)
+	if err != nil {
+		return policies, fmt.Errorf("RATE_LIMIT_PUBLIC: %w", err)
+	}
+	policies.authenticated, err = ratelimit.ParsePolicy(config.RateLimitAuthenticated)
+	if err != nil {
+		return policies, fmt.Errorf("RATE_LIMIT_AUTHENTICATED: %w", err)
+	}
+	policies.transfers, err = ratelimit.ParsePolicy(config.RateLimitTransfers)
+	if err != nil {
+		return policies, fmt.Errorf("RATE_LIMIT_TRANSFERS: %w", err)
+	}
+	policies.clientIP, err = ratelimit.ParsePolicy(config.RateLimitClientIP)
+	if err != nil {
+		return policies, fmt.Errorf("RATE_LIMIT_CLIENT_IP: %w", err)
+	}
+	return policies, nil
+}
+
+// rateLimitMiddleware limits the requests of each client to `policy`, `key` tells the clients apart.
+// `name` separates the buckets of different route groups.
+// It sets the X-RateLimit-* headers, and aborts with 429 Too Many Requests & Retry-After once the limit is reached.
+func rateLimitMiddleware(store ratelimit.Store, name string, policy ratelimit.Policy, key func(ctx *gin.Context) string) gin.HandlerFunc {
+	if !policy.Enabled() {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		result, err := store.Take(ctx, name+":"+key(ctx), policy)
+		if err != nil {
+			// fail open: an unavailable store must not take the whole API down
+			log.Println("cannot check rate limit:", err)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("X-RateLimit-Reset", seconds(result.ResetAfter))
+		if !result.Allowed {
+			ctx.Header("Retry-After", seconds(result.RetryAfter))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(errRateLimited))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// clientIPKey tells anonymous clients apart, by the IP found through TRUSTED_PROXIES.
+func clientIPKey(ctx *gin.Context) string {
+	return ctx.ClientIP()
+}
+
+// usernameKey tells authenticated clients apart, it must follow `authMiddleware`.
+func usernameKey(ctx *gin.Context) string {
+	return ctx.MustGet(authorizationPayloadKey).(*token.Payload).Username
+}
+
+// seconds formats a duration for the headers, rounded up to whole seconds.
+func seconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitedTestServer(t *testing.T, store *mockdb.MockStore, public string, authenticated string) *Server {
+	config := util.Config{
+		TokenSymmetricKey:      util.RandomString(32),
+		AccessTokenDuration:    time.Minute,
+		RateLimitPublic:        public,
+		RateLimitAuthenticated: authenticated,
+	}
+
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	allowAuditedTx(store)
+	allowAccessTokens(store)
+	return server
+}
+
+func TestPublicRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newRateLimitedTestServer(t, store, "2/1m", "")
+
+	// invalid requests count too, and they never reach the store
+	login := func(remoteAddr string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/users/login", strings.NewReader("{}"))
+		require.NoError(t, err)
+		request.RemoteAddr = remoteAddr
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for _, remaining := range []string{"1", "0"} {
+		recorder := login("10.0.0.1:1234")
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Equal(t, "2", recorder.Header().Get("X-RateLimit-Limit"))
+		require.Equal(t, remaining, recorder.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	recorder := login("10.0.0.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "0", recorder.Header().Get("X-RateLimit-Remaining"))
+	require.Equal(t, "30", recorder.Header().Get("Retry-After"))
+	require.Equal(t, "60", recorder.Header().Get("X-RateLimit-Reset"))
+
+	// another client IP
+	recorder = login("10.0.0.2:1234")
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestAuthenticatedRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newRateLimitedTestServer(t, store, "", "1/1m")
+
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+
+	listAccounts := func(username string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		// missing pagination: rejected before the store
+		request, err := http.NewRequest(http.MethodGet, "/accounts", nil)
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, username, time.Minute)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	require.Equal(t, http.StatusBadRequest, listAccounts(user1.Username).Code)
+	require.Equal(t, http.StatusTooManyRequests, listAccounts(user1.Username).Code)
+
+	// limited per user, even from the same IP
+	require.Equal(t, http.StatusBadRequest, listAccounts(user2.Username).Code)
+}
+
+func TestInvalidRateLimit(t *testing.T) {
+	config := util.Config{
+		TokenSymmetricKey: util.RandomString(32),
+		RateLimitPublic:   "60 per minute",
+	}
+
+	_, err := NewServer(config, nil)
+	require.ErrorContains(t, err, "RATE_LIMIT_PUBLIC")
+}
+
+func TestClientIPRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server, err := NewServer(util.Config{
+		TokenSymmetricKey: util.RandomString(32),
+		RateLimitClientIP: "1/1m",
+	}, store)
+	require.NoError(t, err)
+
+	// bad credentials are limited before they're looked up,
+	// and a forged X-Forwarded-For doesn't make another client
+	badToken := func(forwardedFor string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/accounts", nil)
+		require.NoError(t, err)
+		request.RemoteAddr = "10.0.0.1:1234"
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		request.Header.Set(authorizationHeaderKey, "bearer invalid")
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	require.Equal(t, http.StatusUnauthorized, badToken("1.2.3.4").Code)
+	require.Equal(t, http.StatusTooManyRequests, badToken("5.6.7.8").Code)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server, err := NewServer(util.Config{
+		TokenSymmetricKey: util.RandomString(32),
+		RateLimitPublic:   "1/1m",
+		TrustedProxies:    []string{"10.0.0.0/8"},
+	}, store)
+	require.NoError(t, err)
+
+	login := func(forwardedFor string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/users/login", strings.NewReader("{}"))
+		require.NoError(t, err)
+		request.RemoteAddr = "10.0.0.1:1234"
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// behind a trusted proxy, the clients are told apart by X-Forwarded-For
+	require.Equal(t, http.StatusBadRequest, login("1.2.3.4").Code)
+	require.Equal(t, http.StatusTooManyRequests, login("1.2.3.4").Code)
+	require.Equal(t, http.StatusBadRequest, login("5.6.7.8").Code)
+
+	_, err = NewServer(util.Config{
+		TokenSymmetricKey: util.RandomString(32),
+		TrustedProxies:    []string{"not an ip"},
+	}, store)
+	require.ErrorContains(t, err, "TRUSTED_PROXIES")
+}
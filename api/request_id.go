@@ -0,0 +1,45 @@
+package api
+
+import (
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeaderKey = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// requestIDMiddleware identifies each request with the X-Request-ID header, generated unless the client
+// (or a proxy) sends one, and echoed in the response.
+// It sets the `db.AuditMetadata` of the request: the actor is anonymous until `authMiddleware` authenticates it.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeaderKey)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+		ctx.Header(requestIDHeaderKey, requestID)
+
+		setAuditMetadata(ctx, db.AuditMetadata{
+			Actor:     anonymousActor,
+			RequestID: requestID,
+			IP:        ctx.ClientIP(),
+			UserAgent: ctx.Request.UserAgent(),
+		})
+		ctx.Next()
+	}
+}
+
+// setAuditActor records the authenticated user as the actor of the changes made by the request.
+func setAuditActor(ctx *gin.Context, username string) {
+	metadata := db.AuditMetadataFrom(ctx.Request.Context())
+	metadata.Actor = username
+	setAuditMetadata(ctx, metadata)
+}
+
+// the metadata is set in the request's context, so the store finds it through `ctx` (see `ContextWithFallback`)
+func setAuditMetadata(ctx *gin.Context, metadata db.AuditMetadata) {
+	ctx.Request = ctx.Request.WithContext(db.WithAuditMetadata(ctx.Request.Context(), metadata))
+}
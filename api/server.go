@@ -1,14 +1,26 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/Oliver-Zen/simplebank/db/migration"
 	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/ratelimit"
 	"github.com/Oliver-Zen/simplebank/token"
 	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/Oliver-Zen/simplebank/webhook"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // `Server` servers HTTP requests for our banking service.
@@ -17,56 +29,303 @@ type Server struct {
 	store      db.Store
 	tokenMaker token.Maker
 	router     *gin.Engine
+
+	// new & changed passwords are hashed by `passwordHasher`, once they pass `passwordPolicy`
+	passwordHasher    util.PasswordHasher
+	passwordPolicy    util.PasswordPolicy
+	dummyPasswordHash func() string
+	httpServer        *http.Server
+
+	// token buckets of the rate limits, in memory: each replica limits on its own
+	rateLimiter ratelimit.Store
+	rateLimits  rateLimitPolicies
+
+	// resolves the hosts of the webhooks registered, which must be public
+	webhookResolver webhook.Resolver
+
+	// the schema version this build expects, checked by `/readyz`
+	schemaVersion uint
+
+	// set once graceful shutdown starts, so `/readyz` stops advertising the server
+	shuttingDown atomic.Bool
+
+	// balance changes pushed to the account streams, and their end on shutdown
+	// (`http.Server.Shutdown` doesn't wait for long-lived responses to end by themselves)
+	accountEvents *accountEventHub
+	streamsCtx    context.Context
+	stopStreams   context.CancelFunc
+
+	// background workers started by `runWorker`, drained on shutdown
+	workerCtx   context.Context
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
 }
 
 // `NewServer` creaes a new HTTP server and setup routing.
 func NewServer(config util.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
+	if config.TOTPEncryptionKey != "" && len(config.TOTPEncryptionKey) != util.EncryptionKeySize {
+		return nil, fmt.Errorf("invalid TOTP encryption key size: must be exactly %d characters", util.EncryptionKeySize)
+	}
+	passwordHasher, err := util.NewPasswordHasher(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create password hasher: %w", err)
+	}
+	passwordPolicy, err := util.NewPasswordPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create password policy: %w", err)
+	}
+	rateLimits, err := newRateLimitPolicies(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit: %w", err)
+	}
+	schemaVersion, err := migration.LatestVersion()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migration version: %w", err)
+	}
+
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:          config,
+		store:           store,
+		tokenMaker:      tokenMaker,
+		passwordHasher:  passwordHasher,
+		passwordPolicy:  passwordPolicy,
+		rateLimiter:     ratelimit.NewMemoryStore(),
+		rateLimits:      rateLimits,
+		schemaVersion:   schemaVersion,
+		webhookResolver: net.DefaultResolver,
 	}
+	server.dummyPasswordHash = sync.OnceValue(server.newDummyPasswordHash)
+	server.workerCtx, server.stopWorkers = context.WithCancel(context.Background())
+	server.streamsCtx, server.stopStreams = context.WithCancel(context.Background())
+	server.accountEvents = newAccountEventHub()
 
 	// register our custom validator with Gin.
 	// `binding.Validator.Engine()` gets the current validator engine the gin is using.
 	// `(*validator.Validate)` converts output to a validator.Validate pointer so we can access its methods.
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", validCurrency)
+		v.RegisterValidation("scope", validScope)
+	}
+
+	err = server.setupRouter()
+	if err != nil {
+		return nil, err
 	}
 
-	server.setupRouter()
+	// push the balance changes committed by any replica to the account streams
+	if config.DBSource != "" {
+		server.runWorker(server.listenAccountEvents)
+	}
+	// deliver the events of the outbox to webhooks
+	if config.WebhookDispatchInterval > 0 {
+		server.runWorker(webhook.NewDispatcher(store, config).Run)
+	}
+	// give the funds of expired holds back
+	if config.HoldSweepInterval > 0 {
+		server.runWorker(server.expireHolds)
+	}
+	// expire the transfers nobody approved in time
+	if config.TransferApprovalSweepInterval > 0 {
+		server.runWorker(server.expireTransferApprovals)
+	}
 	return server, nil
 }
 
 // SetupRouter adds routes to `router`.
-func (server *Server) setupRouter() {
+func (server *Server) setupRouter() error {
 	router := gin.Default()
+	// `ctx.ClientIP()` only believes the X-Forwarded-For of our own proxies: the per-IP rate limits,
+	// login lockouts & audit events would be keyed by whatever clients send otherwise
+	if err := router.SetTrustedProxies(server.config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	// let `ctx.Value` fall back to `ctx.Request.Context()`, so `*gin.Context` carries the request span into the store
+	router.ContextWithFallback = true
+
+	// start a span for every HTTP request, continuing the caller's trace if `traceparent` is sent
+	router.Use(otelgin.Middleware(server.tracingServiceName(), otelgin.WithFilter(skipProbes)))
+	// identify each request, and who makes it for the audit log
+	router.Use(requestIDMiddleware())
 
 	// "register new API in the server to route request to handler"
-	
-	// create user & login user don't need authorization
-	router.POST("/users", server.createUser)
-	router.POST("/users/login", server.loginUser)
-
-	// all other APIs must be protected by authMiddlware
-	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
-	
-	authRoutes.POST("/accounts", server.createAccount) // last func is the real handlers, others are middleware
-	authRoutes.GET("/accounts/:id", server.getAccount) // `:` tells Gin `id` is a URI parameter
-	authRoutes.GET("/accounts", server.listAccount)
-	authRoutes.POST("/transfers", server.createTransfer)
+
+	// probes & build info, no authorization
+	router.GET("/healthz", server.healthz)
+	router.GET("/readyz", server.readyz)
+	router.GET("/version", server.version)
+	router.GET("/.well-known/jwks.json", server.jwks)
+
+	// create user & login user don't need authorization, they are rate limited per client IP
+	publicRoutes := router.Group("/").Use(rateLimitMiddleware(server.rateLimiter, "public", server.rateLimits.public, clientIPKey))
+	publicRoutes.POST("/users", server.createUser)
+	publicRoutes.POST("/users/login", server.loginUser)
+	publicRoutes.POST("/users/login/mfa", server.loginUserMFA)
+
+	// all other APIs must be protected by authMiddlware, and are rate limited per user
+	authRoutes := router.Group("/").Use(
+		// floods of bad credentials are limited before `authMiddleware` looks them up
+		rateLimitMiddleware(server.rateLimiter, "client_ip", server.rateLimits.clientIP, clientIPKey),
+		authMiddleware(server.tokenMaker, server.store),
+		rateLimitMiddleware(server.rateLimiter, "authenticated", server.rateLimits.authenticated, usernameKey),
+	)
+	transfersRateLimit := rateLimitMiddleware(server.rateLimiter, "transfers", server.rateLimits.transfers, usernameKey)
+
+	// each route declares the scopes the token must grant
+	authRoutes.POST("/accounts", requireScopes(token.ScopeAccountsWrite), server.createAccount) // last func is the real handlers, others are middleware
+	authRoutes.GET("/accounts/:id", requireScopes(token.ScopeAccountsRead), server.getAccount)  // `:` tells Gin `id` is a URI parameter
+	authRoutes.GET("/accounts", requireScopes(token.ScopeAccountsRead), server.listAccount)
+	authRoutes.GET("/accounts/:id/stream", requireScopes(token.ScopeAccountsRead), server.streamAccount)
+	authRoutes.GET("/accounts/:id/statements/:month", requireScopes(token.ScopeAccountsRead), server.getStatement)
+	authRoutes.PUT("/accounts/:id/approval_policy", requireScopes(token.ScopeAccountsWrite), server.updateApprovalPolicy)
+	authRoutes.GET("/approval_policy_changes/pending", requireScopes(token.ScopeAccountsRead), server.listPendingApprovalPolicyChanges)
+	authRoutes.POST("/approval_policy_changes/:id/approve", requireScopes(token.ScopeAccountsWrite), server.approveApprovalPolicyChange)
+	authRoutes.POST("/transfers", requireScopes(token.ScopeTransfersWrite), transfersRateLimit, server.createTransfer)
+	authRoutes.GET("/transfers", requireScopes(token.ScopeAccountsRead), server.listTransfers)
+	authRoutes.GET("/transfers/pending", requireScopes(token.ScopeAccountsRead), server.listPendingTransfers)
+	authRoutes.POST("/transfers/:id/approve", requireScopes(token.ScopeTransfersWrite), transfersRateLimit, server.approveTransfer)
+	authRoutes.POST("/transfers/:id/reject", requireScopes(token.ScopeTransfersWrite), server.rejectTransfer)
+	authRoutes.POST("/holds", requireScopes(token.ScopeTransfersWrite), transfersRateLimit, server.createHold)
+	authRoutes.GET("/holds/:id", requireScopes(token.ScopeAccountsRead), server.getHold)
+	authRoutes.POST("/holds/:id/capture", requireScopes(token.ScopeTransfersWrite), transfersRateLimit, server.captureHold)
+	authRoutes.POST("/holds/:id/void", requireScopes(token.ScopeTransfersWrite), server.voidHold)
+	authRoutes.POST("/transfers/batch", requireScopes(token.ScopeTransfersWrite), transfersRateLimit, server.createBatchTransfer)
+	authRoutes.POST("/transfers/import", requireScopes(token.ScopeTransfersWrite), transfersRateLimit, server.importTransfers)
+	authRoutes.POST("/api_keys", requireScopes(token.ScopeAPIKeysWrite), server.createAPIKey)
+	authRoutes.GET("/api_keys", requireScopes(token.ScopeAPIKeysRead), server.listAPIKeys)
+	authRoutes.DELETE("/api_keys/:id", requireScopes(token.ScopeAPIKeysWrite), server.revokeAPIKey)
+	authRoutes.PUT("/users/password", requireScopes(token.ScopeUsersWrite), server.changePassword)
+	authRoutes.POST("/webhooks", requireScopes(token.ScopeWebhooksWrite), server.createWebhook)
+	authRoutes.GET("/webhooks", requireScopes(token.ScopeWebhooksRead), server.listWebhooks)
+	authRoutes.DELETE("/webhooks/:id", requireScopes(token.ScopeWebhooksWrite), server.deleteWebhook)
+	authRoutes.GET("/webhooks/:id/deliveries", requireScopes(token.ScopeWebhooksRead), server.listWebhookDeliveries)
+	authRoutes.GET("/audit_events", requireScopes(token.ScopeAuditRead), requireRole(server.store, db.UserRoleBanker), server.listAuditEvents)
+	authRoutes.GET("/ledger/verify", requireScopes(token.ScopeAuditRead), requireRole(server.store, db.UserRoleBanker), server.verifyLedger)
+	authRoutes.POST("/users/totp", requireScopes(token.ScopeUsersWrite), server.enrollTOTP)
+	authRoutes.POST("/users/totp/confirm", requireScopes(token.ScopeUsersWrite), server.confirmTOTP)
 
 	server.router = router
+
+	// WHY not `router.Run`? It has no timeouts, and we need the `http.Server` to shut it down gracefully
+	server.httpServer = &http.Server{
+		Handler:           router,
+		ReadTimeout:       server.config.HTTPReadTimeout,
+		ReadHeaderTimeout: server.config.HTTPReadHeaderTimeout,
+		WriteTimeout:      server.config.HTTPWriteTimeout,
+		IdleTimeout:       server.config.HTTPIdleTimeout,
+	}
+	server.httpServer.RegisterOnShutdown(server.stopStreams)
+	return nil
 }
 
-// `Start` runs the HTTP server on a specific address
+// `Start` runs the HTTP server on a specific address.
+// It blocks until the server fails or `Shutdown` is called; after `Shutdown` it returns nil.
 func (server *Server) Start(address string) error {
 	// WHY make `Start` public? <- `router`is private
-	return server.router.Run(address)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return server.serve(listener)
+}
+
+func (server *Server) serve(listener net.Listener) error {
+	err := server.httpServer.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) { // closed by `Shutdown`, not a failure
+		return nil
+	}
+	return err
+}
+
+// `Shutdown` gracefully stops the server:
+// (1) `/readyz` starts failing, while requests are still accepted for SHUTDOWN_DRAIN_DELAY,
+// (2) no new connections are accepted and in-flight requests are drained,
+// (3) background workers are cancelled and waited for.
+// If `ctx` expires first, the remaining work is abandoned: the requests and the workers not drained
+// are reported separately, both wrapping the context error.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.shuttingDown.Store(true)
+
+	if delay := server.config.ShutdownDrainDelay; delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	var errs []error
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cannot drain in-flight requests: %w", err))
+	}
+
+	server.stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		server.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("cannot drain background workers: %w", ctx.Err()))
+	}
+	return errors.Join(errs...)
+}
+
+// runWorker runs `worker` in the background until the server shuts down.
+// `worker` must return soon after its context is cancelled.
+func (server *Server) runWorker(worker func(ctx context.Context)) {
+	server.workers.Add(1)
+	go func() {
+		defer server.workers.Done()
+		worker(server.workerCtx)
+	}()
+}
+
+// how many rows a sweeper processes per transaction
+const sweepBatchSize = 100
+
+// sweep runs `fn` every `interval` until `ctx` is cancelled, as many times in a row as it processes a full batch.
+// Every replica runs the sweepers: `fn` must skip the rows being processed by the others.
+func (server *Server) sweep(ctx context.Context, interval time.Duration, what string, fn func(context.Context, int32) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := fn(ctx, sweepBatchSize)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("cannot expire %s: %v", what, err)
+			}
+			if err != nil || n < sweepBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (server *Server) tracingServiceName() string {
+	if server.config.TracingServiceName == "" {
+		return "simplebank"
+	}
+	return server.config.TracingServiceName
+}
+
+// skipProbes keeps the (very frequent) liveness & readiness probes out of the traces.
+func skipProbes(request *http.Request) bool {
+	return request.URL.Path != "/healthz" && request.URL.Path != "/readyz"
 }
 
 func errorResponse(err error) gin.H {
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerGracefulShutdown(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	// a slow in-flight request must be finished, not killed
+	started := make(chan struct{})
+	server.router.GET("/slow", func(ctx *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	// a background worker must be cancelled & waited for
+	workerDone := false
+	server.runWorker(func(ctx context.Context) {
+		<-ctx.Done()
+		workerDone = true
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.serve(listener)
+	}()
+
+	responseCode := make(chan int, 1)
+	go func() {
+		res, err := http.Get(fmt.Sprintf("http://%s/slow", listener.Addr()))
+		if err != nil {
+			responseCode <- 0
+			return
+		}
+		defer res.Body.Close()
+		responseCode <- res.StatusCode
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	require.NoError(t, err)
+	require.True(t, server.shuttingDown.Load())
+	require.True(t, workerDone)
+
+	require.Equal(t, http.StatusOK, <-responseCode)
+	require.NoError(t, <-serverErr) // `http.ErrServerClosed` is not reported as an error
+
+	// no new connections are accepted
+	_, err = http.Get(fmt.Sprintf("http://%s/healthz", listener.Addr()))
+	require.Error(t, err)
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	// a worker ignoring cancellation cannot block shutdown forever
+	release := make(chan struct{})
+	defer close(release)
+	server.runWorker(func(ctx context.Context) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestServerShutdownDrainDelay(t *testing.T) {
+	server := newTestServer(t, nil)
+	server.config.ShutdownDrainDelay = 200 * time.Millisecond
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.serve(listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(ctx)
+	}()
+	require.Eventually(t, server.shuttingDown.Load, time.Second, time.Millisecond)
+
+	// the load balancer may still route requests until it sees `/readyz` failing
+	res, err := http.Get(fmt.Sprintf("http://%s/healthz", listener.Addr()))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	require.NoError(t, <-shutdownErr)
+}
+
+func TestServerShutdownReportsEachDrain(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	server.router.GET("/stuck", func(ctx *gin.Context) {
+		close(started)
+		<-release
+	})
+	server.runWorker(func(ctx context.Context) {
+		<-release
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.serve(listener)
+	go http.Get(fmt.Sprintf("http://%s/stuck", listener.Addr()))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "cannot drain in-flight requests")
+	require.ErrorContains(t, err, "cannot drain background workers")
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/statement"
+	"github.com/gin-gonic/gin"
+)
+
+// A statement is only stored once its month is over, plus this delay:
+// transfers committing right at the end of the month are then sure to be in it.
+// It's never generated again afterwards, so it doesn't change even if the ledger does.
+const statementCachingDelay = time.Hour
+
+// formats of the statements, see `getStatementRequest`
+const (
+	statementFormatCSV = "csv"
+	statementFormatPDF = "pdf"
+)
+
+type getStatementRequest struct {
+	ID    int64  `uri:"id" binding:"required,min=1"`
+	Month string `uri:"month" binding:"required"` // yyyy-mm, in UTC
+}
+
+type getStatementQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv pdf"` // pdf by default
+}
+
+// Authorization Rule for Get Statement API: a logged-in user can only get the statements of accounts that he/she owns.
+func (server *Server) getStatement(ctx *gin.Context) {
+	var req getStatementRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var query getStatementQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if query.Format == "" {
+		query.Format = statementFormatPDF
+	}
+
+	month, err := time.Parse(statement.MonthFormat, req.Month)
+	if err != nil {
+		err := errors.New("month must be formatted as yyyy-mm")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	from, to := statement.MonthRange(month)
+	now := time.Now()
+	if from.After(now) {
+		err := errors.New("month hasn't started yet")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, ok := server.getOwnedAccount(ctx, req.ID)
+	if !ok {
+		return
+	}
+
+	closed := now.After(to.Add(statementCachingDelay))
+	var data []byte
+	if closed {
+		stored, err := server.store.GetStatement(ctx, db.GetStatementParams{
+			AccountID: account.ID,
+			Month:     from,
+		})
+		switch {
+		case err == nil:
+			data = stored.Pdf
+			if query.Format == statementFormatCSV {
+				data = stored.Csv
+			}
+		case err != sql.ErrNoRows:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	if data == nil {
+		data, err = server.generateStatement(ctx, account, from, to, closed, query.Format)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	contentType := "application/pdf"
+	if query.Format == statementFormatCSV {
+		contentType = "text/csv"
+	}
+	filename := statement.Statement{AccountID: account.ID, Month: from}.Filename(query.Format)
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// generateStatement renders the statement of `account` for the month [from, to) in `format`.
+// The statement of a closed month is stored, in both formats.
+func (server *Server) generateStatement(ctx *gin.Context, account db.Account, from, to time.Time, closed bool, format string) ([]byte, error) {
+	openingBalance, err := server.store.GetAccountBalanceAt(ctx, db.GetAccountBalanceAtParams{
+		AccountID: account.ID,
+		At:        from,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := server.store.ListStatementEntries(ctx, db.ListStatementEntriesParams{
+		AccountID: account.ID,
+		FromTime:  from,
+		ToTime:    to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := statement.New(account, from, openingBalance, entries)
+	csv, err := stmt.CSV()
+	if err != nil {
+		return nil, err
+	}
+	pdf, err := stmt.PDF()
+	if err != nil {
+		return nil, err
+	}
+
+	if closed {
+		err = server.store.CreateStatement(ctx, db.CreateStatementParams{
+			AccountID:      account.ID,
+			Month:          from,
+			OpeningBalance: stmt.OpeningBalance,
+			ClosingBalance: stmt.ClosingBalance,
+			TotalCredits:   stmt.TotalCredits,
+			TotalDebits:    stmt.TotalDebits,
+			Csv:            csv,
+			Pdf:            pdf,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if format == statementFormatCSV {
+		return csv, nil
+	}
+	return pdf, nil
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/statement"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStatementAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	otherAccount := randomAccount(util.RandomOwner())
+
+	// a closed month, and the current one
+	closedFrom, closedTo := statement.MonthRange(time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC))
+	currentFrom, currentTo := statement.MonthRange(time.Now().UTC())
+
+	entries := []db.ListStatementEntriesRow{
+		{
+			ID:                    1,
+			Amount:                100,
+			CreatedAt:             closedFrom.Add(time.Hour),
+			TransferID:            sql.NullInt64{Int64: 10, Valid: true},
+			CounterpartyAccountID: otherAccount.ID,
+			CounterpartyOwner:     otherAccount.Owner,
+		},
+	}
+	stored := db.Statement{
+		AccountID: account.ID,
+		Month:     closedFrom,
+		Csv:       []byte("stored csv"),
+		Pdf:       []byte("%PDF-1.4 stored"),
+	}
+
+	testCases := []struct {
+		name          string
+		accountID     int64
+		month         string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "StoredPDF",
+			accountID: account.ID,
+			month:     "2024-05",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					GetStatement(gomock.Any(), gomock.Eq(db.GetStatementParams{AccountID: account.ID, Month: closedFrom})).
+					Times(1).
+					Return(stored, nil)
+				store.EXPECT().ListStatementEntries(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateStatement(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "application/pdf", recorder.Header().Get("Content-Type"))
+				require.Equal(t, fmt.Sprintf(`attachment; filename="statement-%d-2024-05.pdf"`, account.ID), recorder.Header().Get("Content-Disposition"))
+				require.Equal(t, stored.Pdf, recorder.Body.Bytes())
+			},
+		},
+		{
+			name:      "StoredCSV",
+			accountID: account.ID,
+			month:     "2024-05",
+			query:     "?format=csv",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetStatement(gomock.Any(), gomock.Any()).Times(1).Return(stored, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "text/csv", recorder.Header().Get("Content-Type"))
+				require.Equal(t, stored.Csv, recorder.Body.Bytes())
+			},
+		},
+		{
+			name:      "ClosedMonthGeneratedAndStored",
+			accountID: account.ID,
+			month:     "2024-05",
+			query:     "?format=csv",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetStatement(gomock.Any(), gomock.Any()).Times(1).Return(db.Statement{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetAccountBalanceAt(gomock.Any(), gomock.Eq(db.GetAccountBalanceAtParams{AccountID: account.ID, At: closedFrom})).
+					Times(1).
+					Return(int64(50), nil)
+				store.EXPECT().
+					ListStatementEntries(gomock.Any(), gomock.Eq(db.ListStatementEntriesParams{AccountID: account.ID, FromTime: closedFrom, ToTime: closedTo})).
+					Times(1).
+					Return(entries, nil)
+				store.EXPECT().
+					CreateStatement(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateStatementParams) error {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.Equal(t, closedFrom, arg.Month)
+						require.Equal(t, int64(50), arg.OpeningBalance)
+						require.Equal(t, int64(150), arg.ClosingBalance)
+						require.Equal(t, int64(100), arg.TotalCredits)
+						require.Zero(t, arg.TotalDebits)
+						require.NotEmpty(t, arg.Csv)
+						require.NotEmpty(t, arg.Pdf)
+						return nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), otherAccount.Owner)
+				require.Contains(t, recorder.Body.String(), "closing balance,,,,,150,")
+			},
+		},
+		{
+			name:      "CurrentMonthNotStored",
+			accountID: account.ID,
+			month:     currentFrom.Format(statement.MonthFormat),
+			query:     "?format=pdf",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetStatement(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().GetAccountBalanceAt(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), nil)
+				store.EXPECT().
+					ListStatementEntries(gomock.Any(), gomock.Eq(db.ListStatementEntriesParams{AccountID: account.ID, FromTime: currentFrom, ToTime: currentTo})).
+					Times(1).
+					Return([]db.ListStatementEntriesRow{}, nil)
+				store.EXPECT().CreateStatement(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "application/pdf", recorder.Header().Get("Content-Type"))
+				require.Contains(t, recorder.Body.String(), "%PDF-1.4")
+			},
+		},
+		{
+			name:      "FutureMonth",
+			accountID: account.ID,
+			month:     currentTo.Format(statement.MonthFormat),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidMonth",
+			accountID: account.ID,
+			month:     "2024-13",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidFormat",
+			accountID: account.ID,
+			month:     "2024-05",
+			query:     "?format=xlsx",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			accountID: otherAccount.ID,
+			month:     "2024-05",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(otherAccount.ID)).Times(1).Return(otherAccount, nil)
+				store.EXPECT().GetStatement(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "NotFound",
+			accountID: account.ID,
+			month:     "2024-05",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/statements/%s%s", tc.accountID, tc.month, tc.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+)
+
+// Supported values of `TOKEN_MAKER`
+const (
+	tokenMakerPaseto       = "paseto"        // v2.local, symmetric
+	tokenMakerJWT          = "jwt"           // HS256, symmetric
+	tokenMakerPasetoPublic = "paseto_public" // v4.public, Ed25519
+	tokenMakerJWTEdDSA     = "jwt_eddsa"
+	tokenMakerJWTRS256     = "jwt_rs256"
+)
+
+// newTokenMaker creates the token maker selected by TOKEN_MAKER, with the keys of the config.
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	opts := []token.Option{
+		token.WithIssuer(config.TokenIssuer),
+		token.WithAudience(splitList(config.TokenAudience)...),
+		token.WithLeeway(config.TokenLeeway),
+	}
+
+	switch config.TokenMaker {
+	case "", tokenMakerPaseto, tokenMakerJWT:
+		keyring, err := newTokenKeyring(config, false)
+		if err != nil {
+			return nil, err
+		}
+		if config.TokenMaker == tokenMakerJWT {
+			return token.NewJWTMakerWithKeyring(keyring, opts...)
+		}
+		return token.NewPasetoMakerWithKeyring(keyring, opts...)
+
+	case tokenMakerPasetoPublic, tokenMakerJWTEdDSA, tokenMakerJWTRS256:
+		keyring, err := newTokenKeyring(config, true)
+		if err != nil {
+			return nil, err
+		}
+		switch config.TokenMaker {
+		case tokenMakerJWTEdDSA:
+			return token.NewJWTPublicKeyMaker(token.JWTAlgorithmEdDSA, keyring, opts...)
+		case tokenMakerJWTRS256:
+			return token.NewJWTPublicKeyMaker(token.JWTAlgorithmRS256, keyring, opts...)
+		}
+		return token.NewPasetoPublicMaker(keyring, opts...)
+	}
+	return nil, fmt.Errorf("unsupported token maker: %s", config.TokenMaker)
+}
+
+// newTokenKeyring parses TOKEN_KEYS ("<kid>:<secret>,<kid>:<secret>").
+// Symmetric makers fall back to TOKEN_SYMMETRIC_KEY when no key is configured.
+// For asymmetric makers, each value is the path of a PEM file:
+// a private key to sign tokens, or a public key to only verify them.
+func newTokenKeyring(config util.Config, asymmetric bool) (*token.Keyring, error) {
+	if strings.TrimSpace(config.TokenKeys) == "" {
+		if asymmetric {
+			return nil, fmt.Errorf("TOKEN_KEYS is required by token maker %s", config.TokenMaker)
+		}
+		return token.NewKeyring(token.DefaultKeyID, token.Key{
+			ID:     token.DefaultKeyID,
+			Secret: []byte(config.TokenSymmetricKey),
+		})
+	}
+
+	retiredKeyIDs := splitList(config.TokenRetiredKeyIDs)
+
+	var keys []token.Key
+	for i, item := range splitList(config.TokenKeys) {
+		keyID, value, ok := strings.Cut(item, ":")
+		if !ok {
+			// without a `:`, the item may be the secret itself, so only its position is reported
+			return nil, fmt.Errorf("invalid token key #%d of TOKEN_KEYS: must be <kid>:<secret>", i+1)
+		}
+
+		key := token.Key{ID: keyID, Secret: []byte(value)}
+		if asymmetric {
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read token key %q: %w", keyID, err)
+			}
+			key, err = token.ParsePEMKey(keyID, data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		key.Retired = slices.Contains(retiredKeyIDs, keyID)
+		keys = append(keys, key)
+	}
+
+	return token.NewKeyring(config.TokenActiveKeyID, keys...)
+}
+
+// splitList splits a comma-separated config value, ignoring blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
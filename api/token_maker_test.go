@@ -0,0 +1,124 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokenMaker(t *testing.T) {
+	for _, name := range []string{"", tokenMakerPaseto, tokenMakerJWT} {
+		config := util.Config{
+			TokenMaker:        name,
+			TokenSymmetricKey: util.RandomString(32),
+		}
+
+		maker, err := newTokenMaker(config)
+		require.NoError(t, err, name)
+
+		accessToken, err := maker.CreateToken(util.RandomOwner(), time.Minute)
+		require.NoError(t, err)
+		_, err = maker.VerifyToken(accessToken)
+		require.NoError(t, err)
+	}
+
+	_, err := newTokenMaker(util.Config{TokenMaker: "unknown", TokenSymmetricKey: util.RandomString(32)})
+	require.Error(t, err)
+}
+
+func TestNewTokenMakerKeyRotation(t *testing.T) {
+	oldSecret := util.RandomString(32)
+	newSecret := util.RandomString(32)
+
+	oldMaker, err := newTokenMaker(util.Config{
+		TokenKeys:        "old:" + oldSecret,
+		TokenActiveKeyID: "old",
+	})
+	require.NoError(t, err)
+
+	oldToken, err := oldMaker.CreateToken(util.RandomOwner(), time.Minute)
+	require.NoError(t, err)
+
+	// after rotation, tokens signed with the previous key are still accepted
+	rotatedMaker, err := newTokenMaker(util.Config{
+		TokenKeys:        "old:" + oldSecret + ", new:" + newSecret,
+		TokenActiveKeyID: "new",
+	})
+	require.NoError(t, err)
+	_, err = rotatedMaker.VerifyToken(oldToken)
+	require.NoError(t, err)
+
+	// until the previous key is retired
+	retiredMaker, err := newTokenMaker(util.Config{
+		TokenKeys:          "old:" + oldSecret + ",new:" + newSecret,
+		TokenActiveKeyID:   "new",
+		TokenRetiredKeyIDs: "old",
+	})
+	require.NoError(t, err)
+	_, err = retiredMaker.VerifyToken(oldToken)
+	require.ErrorIs(t, err, token.ErrInvalidToken)
+
+	// malformed keys & unknown active key are rejected
+	_, err = newTokenMaker(util.Config{TokenKeys: oldSecret, TokenActiveKeyID: "old"})
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), oldSecret)
+	_, err = newTokenMaker(util.Config{TokenKeys: "old:" + oldSecret, TokenActiveKeyID: "new"})
+	require.Error(t, err)
+}
+
+func TestNewAsymmetricTokenMaker(t *testing.T) {
+	keyPath := writeEd25519Key(t)
+
+	for _, name := range []string{tokenMakerPasetoPublic, tokenMakerJWTEdDSA} {
+		maker, err := newTokenMaker(util.Config{
+			TokenMaker:       name,
+			TokenKeys:        "key:" + keyPath,
+			TokenActiveKeyID: "key",
+		})
+		require.NoError(t, err, name)
+
+		accessToken, err := maker.CreateToken(util.RandomOwner(), time.Minute)
+		require.NoError(t, err)
+		_, err = maker.VerifyToken(accessToken)
+		require.NoError(t, err)
+	}
+
+	// RS256 needs an RSA key
+	_, err := newTokenMaker(util.Config{TokenMaker: tokenMakerJWTRS256, TokenKeys: "key:" + keyPath, TokenActiveKeyID: "key"})
+	require.Error(t, err)
+
+	// no fallback to TOKEN_SYMMETRIC_KEY
+	_, err = newTokenMaker(util.Config{TokenMaker: tokenMakerPasetoPublic, TokenSymmetricKey: util.RandomString(32)})
+	require.Error(t, err)
+
+	_, err = newTokenMaker(util.Config{TokenMaker: tokenMakerPasetoPublic, TokenKeys: "key:/does/not/exist.pem", TokenActiveKeyID: "key"})
+	require.Error(t, err)
+}
+
+func TestNewTokenMakerClaims(t *testing.T) {
+	config := util.Config{
+		TokenSymmetricKey: util.RandomString(32),
+		TokenIssuer:       "simplebank",
+		TokenAudience:     "simplebank-api, admin",
+	}
+	maker, err := newTokenMaker(config)
+	require.NoError(t, err)
+
+	accessToken, err := maker.CreateToken(util.RandomOwner(), time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(accessToken)
+	require.NoError(t, err)
+	require.Equal(t, config.TokenIssuer, payload.Issuer)
+	require.Equal(t, []string{"simplebank-api", "admin"}, payload.Audience)
+
+	// a token of another environment sharing the key is rejected
+	config.TokenIssuer = "simplebank-staging"
+	otherMaker, err := newTokenMaker(config)
+	require.NoError(t, err)
+	_, err = otherMaker.VerifyToken(accessToken)
+	require.ErrorIs(t, err, token.ErrInvalidIssuer)
+}
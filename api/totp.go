@@ -0,0 +1,367 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	recoveryCodeCount           = 10
+	defaultMFAChallengeDuration = 5 * time.Minute
+	// the codes one challenge token can try, whether failed logins are throttled or not
+	maxMFAChallengeAttempts = 5
+)
+
+var (
+	errTOTPNotConfigured  = errors.New("two-factor authentication is not configured on this server")
+	errInvalidMFACode     = errors.New("invalid two-factor authentication code")
+	errMFAChallengeUsedUp = errors.New("too many attempts with this challenge token, log in again")
+)
+
+type enrollTOTPResponse struct {
+	Secret     string `json:"secret"`      // to type into the authenticator app
+	OTPAuthURI string `json:"otpauth_uri"` // to show as a QR code
+}
+
+// `enrollTOTP` starts enabling TOTP for the logged-in user: it generates a new secret.
+// TOTP is only enabled once `confirmTOTP` receives a first valid code, until then enrollment can be restarted.
+func (server *Server) enrollTOTP(ctx *gin.Context) {
+	if server.config.TOTPEncryptionKey == "" {
+		ctx.JSON(http.StatusNotImplemented, errorResponse(errTOTPNotConfigured))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	encryptedSecret, err := server.encryptTOTPSecret(authPayload.Username, secret)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		_, err := q.CreateTOTPSecret(ctx, db.CreateTOTPSecretParams{
+			Username:        authPayload.Username,
+			EncryptedSecret: encryptedSecret,
+		})
+		// the secret is left out, even encrypted
+		return db.AuditChange{
+			Action: db.AuditActionTOTPEnroll,
+			Target: "user:" + authPayload.Username,
+		}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows { // the upsert doesn't touch a confirmed secret
+			err := errors.New("two-factor authentication is already enabled")
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret:     util.EncodeTOTPSecret(secret),
+		OTPAuthURI: util.TOTPURI(server.config.TOTPIssuer, authPayload.Username, secret),
+	})
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,numeric,len=6"`
+}
+
+type confirmTOTPResponse struct {
+	// only shown once, each code can be used once to log in without the authenticator
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// `confirmTOTP` enables TOTP once the user proves the authenticator works, and returns the recovery codes.
+func (server *Server) confirmTOTP(ctx *gin.Context) {
+	var req confirmTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if server.config.TOTPEncryptionKey == "" {
+		ctx.JSON(http.StatusNotImplemented, errorResponse(errTOTPNotConfigured))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	totpSecret, err := server.store.GetTOTPSecret(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := errors.New("two-factor authentication enrollment has not been started")
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if totpSecret.ConfirmedAt.Valid {
+		err := errors.New("two-factor authentication is already enabled")
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+		return
+	}
+
+	secret, err := server.decryptTOTPSecret(totpSecret)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	step, ok := util.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidMFACode))
+		return
+	}
+
+	recoveryCodes, err := util.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	hashedCodes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashedCodes[i] = util.HashRecoveryCode(code)
+	}
+
+	_, err = server.store.ConfirmTOTPTx(ctx, db.ConfirmTOTPTxParams{
+		Username:            authPayload.Username,
+		Step:                step,
+		HashedRecoveryCodes: hashedCodes,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows { // confirmed concurrently
+			err := errors.New("two-factor authentication is already enabled")
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTOTPResponse{RecoveryCodes: recoveryCodes})
+}
+
+type loginChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"` // to send to `/users/login/mfa` with the second factor
+}
+
+// `loginChallenge` answers the first step of a two-step login, once the password is verified.
+func (server *Server) loginChallenge(ctx *gin.Context, user db.User) {
+	duration := server.config.MFAChallengeDuration
+	if duration == 0 {
+		duration = defaultMFAChallengeDuration
+	}
+
+	challengeToken, err := server.tokenMaker.CreateToken(user.Username, duration, token.ScopeMFAChallenge)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, loginChallengeResponse{
+		MFARequired:    true,
+		ChallengeToken: challengeToken,
+	})
+}
+
+type loginUserMFARequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// either a code of the authenticator app, or one of the recovery codes
+	Code         string   `json:"code" binding:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string   `json:"recovery_code" binding:"required_without=Code"`
+	Scopes       []string `json:"scopes" binding:"omitempty,dive,scope"`
+}
+
+// `loginUserMFA` is the second step of a two-step login: it exchanges the challenge token
+// and a second factor for an access token. An invalid code counts as a failed login,
+// and a challenge token only allows `maxMFAChallengeAttempts` codes.
+func (server *Server) loginUserMFA(ctx *gin.Context) {
+	var req loginUserMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	challenge, err := server.tokenMaker.VerifyToken(req.ChallengeToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if !challenge.HasScopes(token.ScopeMFAChallenge) {
+		err := errors.New("not a challenge token")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if server.loginThrottled() && !server.checkLoginLockout(ctx, challenge.Username) {
+		return
+	}
+	// counted before the code is checked, so concurrent attempts cannot get past the limit
+	attempts, err := server.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         loginChallengeKey(challenge),
+		WindowStart: challenge.IssuedAt,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if attempts.Failures > maxMFAChallengeAttempts {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errMFAChallengeUsedUp))
+		return
+	}
+
+	if req.Code != "" {
+		err = server.verifyTOTP(ctx, challenge.Username, req.Code)
+	} else {
+		err = server.useRecoveryCode(ctx, challenge.Username, req.RecoveryCode)
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidMFACode) {
+			server.mfaFailed(ctx, challenge.Username, http.StatusUnauthorized)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.resetLoginFailures(ctx, challenge.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, challenge.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.loginResponse(ctx, user, req.Scopes)
+}
+
+// `stepUp` requires a TOTP code for sensitive operations, e.g. high-value transfers.
+// It writes a 403 Forbidden response and returns false if the code is missing or invalid,
+// an invalid code counting as a failed login like in `loginUserMFA`.
+func (server *Server) stepUp(ctx *gin.Context, username string, code string, operation string) bool {
+	enabled, err := server.totpEnabled(ctx, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if !enabled {
+		err := fmt.Errorf("two-factor authentication must be enabled for %s", operation)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return false
+	}
+	if code == "" {
+		err := fmt.Errorf("totp_code is required for %s", operation)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return false
+	}
+
+	if server.loginThrottled() && !server.checkLoginLockout(ctx, username) {
+		return false
+	}
+	err = server.verifyTOTP(ctx, username, code)
+	if err != nil {
+		if errors.Is(err, errInvalidMFACode) {
+			server.mfaFailed(ctx, username, http.StatusForbidden)
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	return true
+}
+
+// totpEnabled returns true if the user has confirmed a TOTP enrollment.
+func (server *Server) totpEnabled(ctx context.Context, username string) (bool, error) {
+	totpSecret, err := server.store.GetTOTPSecret(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return totpSecret.ConfirmedAt.Valid, nil
+}
+
+// verifyTOTP checks a TOTP code of the user. Each code is only accepted once, even within its period.
+func (server *Server) verifyTOTP(ctx context.Context, username string, code string) error {
+	totpSecret, err := server.store.GetTOTPSecret(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errInvalidMFACode
+		}
+		return err
+	}
+	if !totpSecret.ConfirmedAt.Valid {
+		return errInvalidMFACode
+	}
+
+	secret, err := server.decryptTOTPSecret(totpSecret)
+	if err != nil {
+		return err
+	}
+	step, ok := util.ValidateTOTP(secret, code, time.Now())
+	if !ok || step <= totpSecret.LastUsedStep {
+		return errInvalidMFACode
+	}
+
+	// the DB re-checks the step, so 2 concurrent requests cannot both use the same code
+	rows, err := server.store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+		Username: username,
+		Step:     step,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errInvalidMFACode
+	}
+	return nil
+}
+
+// useRecoveryCode consumes one of the recovery codes of the user.
+func (server *Server) useRecoveryCode(ctx context.Context, username string, code string) error {
+	rows, err := server.store.UseTOTPRecoveryCode(ctx, db.UseTOTPRecoveryCodeParams{
+		Username:   username,
+		HashedCode: util.HashRecoveryCode(code),
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errInvalidMFACode
+	}
+	return nil
+}
+
+// the username is bound to the ciphertext, so a secret copied to another user cannot be decrypted
+func (server *Server) encryptTOTPSecret(username string, secret []byte) ([]byte, error) {
+	return util.Encrypt([]byte(server.config.TOTPEncryptionKey), secret, []byte(username))
+}
+
+func (server *Server) decryptTOTPSecret(totpSecret db.TotpSecret) ([]byte, error) {
+	secret, err := util.Decrypt([]byte(server.config.TOTPEncryptionKey), totpSecret.EncryptedSecret, []byte(totpSecret.Username))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt totp secret: %w", err)
+	}
+	return secret, nil
+}
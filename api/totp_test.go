@@ -0,0 +1,580 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testTransferMFAThreshold = 1000
+
+func newTOTPTestServer(t *testing.T, store db.Store) *Server {
+	config := util.Config{
+		TokenSymmetricKey:    util.RandomString(32),
+		AccessTokenDuration:  time.Minute,
+		TOTPEncryptionKey:    util.RandomString(util.EncryptionKeySize),
+		TOTPIssuer:           "Simple Bank",
+		TransferMFAThreshold: testTransferMFAThreshold,
+	}
+
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	allowAuditedTx(store)
+	allowAccessTokens(store)
+	return server
+}
+
+// randomTOTPSecret returns a confirmed TOTP enrollment of the user, encrypted with the key of `server`.
+func randomTOTPSecret(t *testing.T, server *Server, username string) (db.TotpSecret, []byte) {
+	secret, err := util.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	encryptedSecret, err := server.encryptTOTPSecret(username, secret)
+	require.NoError(t, err)
+
+	return db.TotpSecret{
+		Username:        username,
+		EncryptedSecret: encryptedSecret,
+		ConfirmedAt:     sql.NullTime{Time: time.Now(), Valid: true},
+		LastUsedStep:    util.TOTPStep(time.Now()) - 10,
+		CreatedAt:       time.Now(),
+	}, secret
+}
+
+func TestEnrollTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		disabled      bool
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateTOTPSecretParams) (db.TotpSecret, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.NotEmpty(t, arg.EncryptedSecret)
+						return db.TotpSecret{Username: arg.Username, EncryptedSecret: arg.EncryptedSecret}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res enrollTOTPResponse
+				err := json.NewDecoder(recorder.Body).Decode(&res)
+				require.NoError(t, err)
+				require.NotEmpty(t, res.Secret)
+				require.Contains(t, res.OTPAuthURI, "otpauth://totp/")
+				require.Contains(t, res.OTPAuthURI, "secret="+res.Secret)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TotpSecret{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "NotConfigured",
+			disabled: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotImplemented, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTOTPTestServer(t, store)
+			if tc.disabled {
+				server = newTestServer(t, store)
+			}
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestConfirmTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		validCode     bool
+		buildStubs    func(store *mockdb.MockStore, totpSecret db.TotpSecret)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			validCode: true,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(totpSecret, nil)
+				store.EXPECT().
+					ConfirmTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ConfirmTOTPTxParams) (db.TotpSecret, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Len(t, arg.HashedRecoveryCodes, recoveryCodeCount)
+						return totpSecret, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res confirmTOTPResponse
+				err := json.NewDecoder(recorder.Body).Decode(&res)
+				require.NoError(t, err)
+				require.Len(t, res.RecoveryCodes, recoveryCodeCount)
+			},
+		},
+		{
+			name: "InvalidCode",
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(totpSecret, nil)
+				store.EXPECT().
+					ConfirmTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "NotEnrolled",
+			validCode: true,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.TotpSecret{}, sql.ErrNoRows)
+				store.EXPECT().
+					ConfirmTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newTOTPTestServer(t, store)
+
+			// a pending enrollment
+			totpSecret, secret := randomTOTPSecret(t, server, user.Username)
+			totpSecret.ConfirmedAt = sql.NullTime{}
+			totpSecret.LastUsedStep = 0
+			tc.buildStubs(store, totpSecret)
+
+			code := util.TOTPCode(secret, util.TOTPStep(time.Now()))
+			if !tc.validCode {
+				code = util.TOTPCode(secret, util.TOTPStep(time.Now())+100)
+			}
+
+			data, err := json.Marshal(gin.H{"code": code})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/users/totp/confirm", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserMFAAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	// the attempt is counted for the challenge token, before the code is checked
+	challengeAttempt := func(store *mockdb.MockStore, attempts int32) {
+		store.EXPECT().
+			RecordLoginFailure(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ any, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+				require.True(t, strings.HasPrefix(arg.Key, "challenge:"))
+				return db.LoginFailure{Key: arg.Key, Failures: attempts}, nil
+			})
+	}
+
+	testCases := []struct {
+		name          string
+		body          func(challengeToken string, secret []byte) gin.H
+		buildStubs    func(store *mockdb.MockStore, totpSecret db.TotpSecret)
+		checkResponse func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: func(challengeToken string, secret []byte) gin.H {
+				return gin.H{
+					"challenge_token": challengeToken,
+					"code":            util.TOTPCode(secret, util.TOTPStep(time.Now())),
+				}
+			},
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				challengeAttempt(store, 1)
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(totpSecret, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				payload := requireBodyAccessToken(t, tokenMaker, recorder.Body)
+				require.Equal(t, user.Username, payload.Username)
+				require.False(t, payload.HasScopes(token.ScopeMFAChallenge))
+			},
+		},
+		{
+			name: "ReplayedCode",
+			body: func(challengeToken string, secret []byte) gin.H {
+				return gin.H{
+					"challenge_token": challengeToken,
+					"code":            util.TOTPCode(secret, util.TOTPStep(time.Now())),
+				}
+			},
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				challengeAttempt(store, 1)
+				totpSecret.LastUsedStep = util.TOTPStep(time.Now()) + 1
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(totpSecret, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			body: func(challengeToken string, secret []byte) gin.H {
+				return gin.H{
+					"challenge_token": challengeToken,
+					"recovery_code":   "abcde-fghij",
+				}
+			},
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				challengeAttempt(store, 1)
+				arg := db.UseTOTPRecoveryCodeParams{
+					Username:   user.Username,
+					HashedCode: util.HashRecoveryCode("abcde-fghij"),
+				}
+				store.EXPECT().
+					UseTOTPRecoveryCode(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "UsedRecoveryCode",
+			body: func(challengeToken string, secret []byte) gin.H {
+				return gin.H{
+					"challenge_token": challengeToken,
+					"recovery_code":   "abcde-fghij",
+				}
+			},
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				challengeAttempt(store, 1)
+				store.EXPECT().
+					UseTOTPRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ChallengeUsedUp",
+			body: func(challengeToken string, secret []byte) gin.H {
+				return gin.H{
+					"challenge_token": challengeToken,
+					"code":            util.TOTPCode(secret, util.TOTPStep(time.Now())),
+				}
+			},
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				challengeAttempt(store, maxMFAChallengeAttempts+1)
+				// even the right code is rejected
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "AccessTokenAsChallenge",
+			body: func(challengeToken string, secret []byte) gin.H {
+				return gin.H{
+					"challenge_token": "",
+					"code":            util.TOTPCode(secret, util.TOTPStep(time.Now())),
+				}
+			},
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newTOTPTestServer(t, store)
+			totpSecret, secret := randomTOTPSecret(t, server, user.Username)
+
+			// first step: the password is correct, but TOTP is enabled
+			store.EXPECT().
+				GetUser(gomock.Any(), gomock.Eq(user.Username)).
+				Times(1).
+				Return(user, nil)
+			store.EXPECT().
+				GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+				Times(1).
+				Return(totpSecret, nil)
+
+			data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var challenge loginChallengeResponse
+			err = json.NewDecoder(recorder.Body).Decode(&challenge)
+			require.NoError(t, err)
+			require.True(t, challenge.MFARequired)
+			require.NotContains(t, recorder.Body.String(), "access_token")
+
+			// second step
+			tc.buildStubs(store, totpSecret)
+
+			body := tc.body(challenge.ChallengeToken, secret)
+			if body["challenge_token"] == "" {
+				body["challenge_token"], err = server.tokenMaker.CreateToken(user.Username, time.Minute, token.AllScopes()...)
+				require.NoError(t, err)
+			}
+			data, err = json.Marshal(body)
+			require.NoError(t, err)
+
+			recorder = httptest.NewRecorder()
+			request, err = http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(data))
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, server.tokenMaker, recorder)
+		})
+	}
+}
+
+func TestChallengeTokenIsNotAnAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListAccounts(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := newTOTPTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/accounts?page_id=1&page_size=5", nil)
+	require.NoError(t, err)
+
+	// all scopes, and the challenge one
+	scopes := append(token.AllScopes(), token.ScopeMFAChallenge)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, util.RandomOwner(), time.Minute, scopes...)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestTransferStepUpAPI(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	testCases := []struct {
+		name         string
+		amount       int64
+		withCode     bool
+		buildStubs   func(store *mockdb.MockStore, totpSecret db.TotpSecret)
+		expectedCode int
+	}{
+		{
+			name:     "OK",
+			amount:   testTransferMFAThreshold,
+			withCode: true,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				// `stepUp` checks the enrollment, then `verifyTOTP` the code
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user1.Username)).Times(2).Return(totpSecret, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "BelowThreshold",
+			amount: testTransferMFAThreshold - 1,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "MissingCode",
+			amount: testTransferMFAThreshold,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user1.Username)).Times(1).Return(totpSecret, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:     "NotEnrolled",
+			amount:   testTransferMFAThreshold,
+			withCode: true,
+			buildStubs: func(store *mockdb.MockStore, totpSecret db.TotpSecret) {
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user1.Username)).Times(1).Return(db.TotpSecret{}, sql.ErrNoRows)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newTOTPTestServer(t, store)
+			totpSecret, secret := randomTOTPSecret(t, server, user1.Username)
+
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+			tc.buildStubs(store, totpSecret)
+
+			body := gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          tc.amount,
+				"currency":        util.USD,
+			}
+			if tc.withCode {
+				body["totp_code"] = util.TOTPCode(secret, util.TOTPStep(time.Now()))
+			}
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
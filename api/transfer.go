@@ -1,10 +1,12 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	db "github.com/Oliver-Zen/simplebank/db/sqlc"
 	"github.com/Oliver-Zen/simplebank/token"
@@ -17,10 +19,19 @@ type transferRequest struct {
 	Amount        int64 `json:"amount" binding:"required,gt=0"`
 	// Currency      string `json:"currency" binding:"required,oneof=USD EUR CAD"` // be careful of usage (no sapce!)
 	Currency string `json:"currency" binding:"required,currency"` // be careful of usage (no sapce!)
+	// step-up verification, required from TRANSFER_MFA_THRESHOLD
+	TOTPCode string `json:"totp_code" binding:"omitempty,numeric,len=6"`
+	// optional: what the transfer is for, shown on the entries of both accounts
+	Description string `json:"description" binding:"max=255"`
+	// optional: the client's own ID of the payment, a second transfer with the same one is rejected
+	ExternalReference string `json:"external_reference" binding:"max=64"`
+	// optional: key-value pairs of the client, stored with the transfer
+	Metadata map[string]string `json:"metadata" binding:"max=20,dive,keys,max=40,endkeys,max=500"`
 }
 
 // WHY `ctx`? In Gin, every HandlerFunc has `*Context` as input.
 // Authorization Rule for Transfer Money API: A logged-in user can only send money from his/her own account
+// Above the approval threshold of the from account, the transfer waits for an approver instead (202), see `approveTransfer`.
 func (server *Server) createTransfer(ctx *gin.Context) {
 	var req transferRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil { // bad request
@@ -28,30 +39,49 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		return
 	}
 
-	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
-	if !valid {
-		return
-	}
-
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if fromAccount.Owner != authPayload.Username {
-		err := errors.New("from_account doesn't belong to the authenticated user")
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	fromAccount, status, err := server.checkTransfer(ctx, authPayload.Username, req.FromAccountID, req.ToAccountID, req.Currency)
+	if err != nil {
+		ctx.JSON(status, errorResponse(err))
 		return
 	}
 
-	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
-	if !valid {
-		return
+	threshold := server.config.TransferMFAThreshold
+	if threshold > 0 && req.Amount >= threshold {
+		if !server.stepUp(ctx, authPayload.Username, req.TOTPCode, fmt.Sprintf("transfers of %d or more", threshold)) {
+			return
+		}
 	}
 
 	arg := db.TransferTxParams{
-		FromAccountID: req.FromAccountID,
-		ToAccountID:   req.ToAccountID,
-		Amount:        req.Amount,
+		FromAccountID:     req.FromAccountID,
+		ToAccountID:       req.ToAccountID,
+		Amount:            req.Amount,
+		ExternalReference: req.ExternalReference,
+		Description:       req.Description,
+		Metadata:          req.Metadata,
 	}
+	if requiresApproval(fromAccount, req.Amount) {
+		server.requestTransfer(ctx, arg, authPayload.Username)
+		return
+	}
+
 	result, err := server.store.TransferTx(ctx, arg)
 	if err != nil { // internal issue (req validated already)
+		if errors.Is(err, db.ErrDuplicateTransfer) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		// still deadlocked after all retries, the client may simply try again
+		if errors.Is(err, db.ErrTxConflict) {
+			ctx.Header("Retry-After", "1")
+			ctx.JSON(http.StatusServiceUnavailable, errorResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
@@ -59,24 +89,84 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, result)
 }
 
+type listTransfersRequest struct {
+	AccountID int64  `form:"account_id" binding:"required,min=1"`
+	Search    string `form:"q" binding:"max=200"` // full-text search of the descriptions, e.g. `rent -deposit`
+	PageID    int32  `form:"page_id" binding:"required,min=1"`
+	PageSize  int32  `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+// Authorization Rule for List Transfers API: A logged-in user can only list the transfers of accounts that he/she owns.
+// The transfers are listed latest first, in and out of the account.
+func (server *Server) listTransfers(ctx *gin.Context) {
+	var req listTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, ok := server.getOwnedAccount(ctx, req.AccountID)
+	if !ok {
+		return
+	}
+
+	transfers, err := server.store.ListAccountTransfers(ctx, db.ListAccountTransfersParams{
+		AccountID: account.ID,
+		Search:    strings.TrimSpace(req.Search),
+		Limit:     req.PageSize,
+		Offset:    (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, transfers)
+}
+
+// checkTransfer applies the rules of a transfer between 2 accounts in `currency`, made by `username`:
+// both accounts must be valid, see `checkAccount`, and the from account must belong to `username`.
+// It returns the from account, and the status to respond with if the transfer isn't allowed.
+func (server *Server) checkTransfer(ctx context.Context, username string, fromAccountID, toAccountID int64, currency string) (db.Account, int, error) {
+	fromAccount, status, err := server.checkAccount(ctx, fromAccountID, currency)
+	if err != nil {
+		return fromAccount, status, err
+	}
+	if fromAccount.Owner != username {
+		return fromAccount, http.StatusUnauthorized, errors.New("from_account doesn't belong to the authenticated user")
+	}
+	_, status, err = server.checkAccount(ctx, toAccountID, currency)
+	return fromAccount, status, err
+}
+
 // `validAccount` is a custom params validator.
 // `validAccount` checks if an account with an specific ID exists and its currency matches the input currency.
 func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
+	account, status, err := server.checkAccount(ctx, accountID, currency)
+	if err != nil {
+		ctx.JSON(status, errorResponse(err))
+		return account, false
+	}
+	return account, true
+}
+
+// checkAccount is `validAccount` without the response: it returns the status to respond with if the account isn't valid.
+func (server *Server) checkAccount(ctx context.Context, accountID int64, currency string) (db.Account, int, error) {
 	account, err := server.store.GetAccount(ctx, accountID)
 	if err != nil {
 		if err == sql.ErrNoRows { // `ID` doesn't exist
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return account, false
+			return account, http.StatusNotFound, err
 		}
-
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err)) // internal error
-		return account, false
+		return account, http.StatusInternalServerError, err // internal error
 	}
 
 	if account.Currency != currency { // currency mistach, bad request
 		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", accountID, account.Currency, currency)
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return account, false
+		return account, http.StatusBadRequest, err
 	}
-	return account, true
+
+	if account.Status == db.AccountStatusFrozen { // frozen by an operator, e.g. during an incident
+		err := fmt.Errorf("account [%d] is frozen", accountID)
+		return account, http.StatusForbidden, err
+	}
+	return account, http.StatusOK, nil
 }
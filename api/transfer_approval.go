@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// how long a transfer or a policy change waits for an approver, when TRANSFER_APPROVAL_DURATION isn't set
+const defaultTransferApprovalDuration = 72 * time.Hour
+
+// approvalExpiresAt is when a request created now stops waiting for an approver.
+func (server *Server) approvalExpiresAt() time.Time {
+	duration := server.config.TransferApprovalDuration
+	if duration <= 0 {
+		duration = defaultTransferApprovalDuration
+	}
+	return time.Now().Add(duration)
+}
+
+// requiresApproval reports whether a transfer of `amount` from `account` must wait for one of its approvers.
+func requiresApproval(account db.Account, amount int64) bool {
+	return account.ApprovalThreshold > 0 && amount > account.ApprovalThreshold
+}
+
+// errApprovalRequired is returned by the APIs moving money without a transfer request, e.g. batches,
+// so they can't be used to get around the approval threshold.
+func errApprovalRequired(account db.Account) error {
+	return fmt.Errorf("transfers above %d from account [%d] need an approval, use POST /transfers",
+		account.ApprovalThreshold, account.ID)
+}
+
+// requestTransfer creates a transfer pending approval, made by `username`, and responds with it.
+func (server *Server) requestTransfer(ctx *gin.Context, arg db.TransferTxParams, username string) {
+	result, err := server.store.RequestTransferTx(ctx, db.RequestTransferTxParams{
+		TransferTxParams: arg,
+		RequestedBy:      username,
+		ExpiresAt:        server.approvalExpiresAt(),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrDuplicateTransfer) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// accepted, but no money moved yet
+	ctx.JSON(http.StatusAccepted, result)
+}
+
+type approvalPolicyRequest struct {
+	// transfers above the threshold need an approval, 0 disables it
+	Threshold int64    `json:"threshold" binding:"min=0"`
+	Approvers []string `json:"approvers" binding:"max=20,dive,required,alphanum"`
+}
+
+type approvalPolicyResponse struct {
+	AccountID int64    `json:"account_id"`
+	Threshold int64    `json:"threshold"`
+	Approvers []string `json:"approvers"`
+}
+
+// Authorization Rule for Update Approval Policy API: A logged-in user can only set the policy of accounts that he/she owns.
+// The approvers replace the previous ones. The transfers already pending keep waiting for the new approvers.
+// A policy that doesn't strictly strengthen the current one, e.g. a higher or no threshold or other approvers,
+// would let the owner get around its approvers: it waits for one of them, see `approveApprovalPolicyChange`.
+func (server *Server) updateApprovalPolicy(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req approvalPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	slices.Sort(req.Approvers)
+	req.Approvers = slices.Compact(req.Approvers)
+	if req.Approvers == nil {
+		req.Approvers = []string{}
+	}
+	if req.Threshold > 0 && len(req.Approvers) == 0 {
+		err := errors.New("approvers are required when the threshold is set")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, ok := server.getOwnedAccount(ctx, uri.ID)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	res := approvalPolicyResponse{AccountID: account.ID, Threshold: req.Threshold, Approvers: req.Approvers}
+	var change *db.ApprovalPolicyChange
+	err := server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		before, err := getApprovalPolicy(ctx, q, account)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		// a newer policy replaces the change still waiting for an approver
+		err = q.DeleteApprovalPolicyChange(ctx, account.ID)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+
+		if !strengthensApprovalPolicy(before, res) {
+			pending, err := q.CreateApprovalPolicyChange(ctx, db.CreateApprovalPolicyChangeParams{
+				AccountID:   account.ID,
+				Threshold:   req.Threshold,
+				Approvers:   req.Approvers,
+				RequestedBy: authPayload.Username,
+				ExpiresAt:   server.approvalExpiresAt(),
+			})
+			if err != nil {
+				return db.AuditChange{}, err
+			}
+			change = &pending
+			return db.AuditChange{
+				Action: db.AuditActionAccountApproversRequest,
+				Target: fmt.Sprintf("account:%d", account.ID),
+				Before: before,
+				After:  pending,
+			}, nil
+		}
+
+		err = applyApprovalPolicy(ctx, q, res)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		return db.AuditChange{
+			Action: db.AuditActionAccountApprovers,
+			Target: fmt.Sprintf("account:%d", account.ID),
+			Before: before,
+			After:  res,
+		}, nil
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			err := errors.New("approvers must be existing users")
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if change != nil {
+		// accepted, but the policy doesn't change until an approver approves it
+		ctx.JSON(http.StatusAccepted, change)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// getApprovalPolicy returns the current approval policy of `account`, its approvers sorted.
+func getApprovalPolicy(ctx context.Context, q db.Querier, account db.Account) (approvalPolicyResponse, error) {
+	approvers, err := q.ListAccountApprovers(ctx, account.ID)
+	if err != nil {
+		return approvalPolicyResponse{}, err
+	}
+	policy := approvalPolicyResponse{AccountID: account.ID, Threshold: account.ApprovalThreshold, Approvers: []string{}}
+	for _, approver := range approvers {
+		policy.Approvers = append(policy.Approvers, approver.Username)
+	}
+	slices.Sort(policy.Approvers) // the order of the database may not be Go's
+	return policy, nil
+}
+
+// strengthensApprovalPolicy reports whether `after` can replace `before` without an approver:
+// when there is no policy yet, or when `after` only lowers the threshold, keeping it on and the same approvers.
+func strengthensApprovalPolicy(before, after approvalPolicyResponse) bool {
+	if before.Threshold == 0 {
+		return true
+	}
+	return after.Threshold > 0 && after.Threshold <= before.Threshold && slices.Equal(before.Approvers, after.Approvers)
+}
+
+// applyApprovalPolicy sets the threshold and replaces the approvers of the account of `policy`.
+func applyApprovalPolicy(ctx context.Context, q db.Querier, policy approvalPolicyResponse) error {
+	_, err := q.UpdateAccountApprovalThreshold(ctx, db.UpdateAccountApprovalThresholdParams{
+		ID:                policy.AccountID,
+		ApprovalThreshold: policy.Threshold,
+	})
+	if err != nil {
+		return err
+	}
+	err = q.DeleteAccountApprovers(ctx, policy.AccountID)
+	if err != nil {
+		return err
+	}
+	for _, username := range policy.Approvers {
+		_, err = q.CreateAccountApprover(ctx, db.CreateAccountApproverParams{
+			AccountID: policy.AccountID,
+			Username:  username,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errApprovalPolicyChangeExpired is returned when approving a change of an approval policy after its deadline.
+var errApprovalPolicyChangeExpired = errors.New("approval policy change expired, request it again")
+
+// Authorization Rule for Approve Approval Policy Change API: A logged-in user can only approve the changes of
+// accounts he/she is a current approver of, and not the changes he/she requested. The policy changes once approved.
+func (server *Server) approveApprovalPolicyChange(ctx *gin.Context) {
+	var req transferURIRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var res approvalPolicyResponse
+	err := server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		change, err := q.GetApprovalPolicyChangeForUpdate(ctx, req.ID)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		if !change.ExpiresAt.After(time.Now()) {
+			return db.AuditChange{}, errApprovalPolicyChangeExpired
+		}
+		if change.RequestedBy == authPayload.Username {
+			return db.AuditChange{}, db.ErrSelfApproval
+		}
+		_, err = q.GetAccountApprover(ctx, db.GetAccountApproverParams{
+			AccountID: change.AccountID,
+			Username:  authPayload.Username,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.AuditChange{}, db.ErrNotApprover
+		}
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+
+		account, err := q.GetAccount(ctx, change.AccountID)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		before, err := getApprovalPolicy(ctx, q, account)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		res = approvalPolicyResponse{AccountID: change.AccountID, Threshold: change.Threshold, Approvers: change.Approvers}
+		err = applyApprovalPolicy(ctx, q, res)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		err = q.DeleteApprovalPolicyChange(ctx, change.AccountID)
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		return db.AuditChange{
+			Action: db.AuditActionAccountApprovers,
+			Target: fmt.Sprintf("account:%d", change.AccountID),
+			Before: before,
+			After:  res,
+		}, nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errApprovalPolicyChangeExpired):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		case errors.Is(err, sql.ErrNoRows), errors.Is(err, db.ErrNotApprover), errors.Is(err, db.ErrSelfApproval):
+			server.transferApprovalErrorResponse(ctx, err)
+		default:
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+				err := errors.New("approvers must be existing users")
+				ctx.JSON(http.StatusBadRequest, errorResponse(err))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+// List Pending Approval Policy Changes API: the changes the logged-in user can approve, oldest first.
+func (server *Server) listPendingApprovalPolicyChanges(ctx *gin.Context) {
+	var req listPendingTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	changes, err := server.store.ListPendingApprovalPolicyChanges(ctx, db.ListPendingApprovalPolicyChangesParams{
+		Username: authPayload.Username,
+		Limit:    req.PageSize,
+		Offset:   (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, changes)
+}
+
+type listPendingTransfersRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+// List Pending Transfers API: the transfers the logged-in user can approve or reject, oldest first.
+func (server *Server) listPendingTransfers(ctx *gin.Context) {
+	var req listPendingTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	transfers, err := server.store.ListPendingTransfersForApprover(ctx, db.ListPendingTransfersForApproverParams{
+		Username: authPayload.Username,
+		Limit:    req.PageSize,
+		Offset:   (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, transfers)
+}
+
+type transferURIRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// Authorization Rule for Approve Transfer API: A logged-in user can only approve the transfers of accounts
+// he/she is an approver of, and not the transfers he/she requested. The money moves once approved.
+func (server *Server) approveTransfer(ctx *gin.Context) {
+	var req transferURIRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	transfer, err := server.store.GetTransfer(ctx, req.ID)
+	if err != nil {
+		server.transferApprovalErrorResponse(ctx, err)
+		return
+	}
+
+	// only an approver learns whether the accounts can still be used
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	_, err = server.store.GetAccountApprover(ctx, db.GetAccountApproverParams{
+		AccountID: transfer.FromAccountID,
+		Username:  authPayload.Username,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = db.ErrNotApprover
+		}
+		server.transferApprovalErrorResponse(ctx, err)
+		return
+	}
+
+	// the accounts may have been frozen since the transfer was requested
+	fromAccount, err := server.store.GetAccount(ctx, transfer.FromAccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if _, valid := server.validAccount(ctx, transfer.FromAccountID, fromAccount.Currency); !valid {
+		return
+	}
+	if _, valid := server.validAccount(ctx, transfer.ToAccountID, fromAccount.Currency); !valid {
+		return
+	}
+
+	result, err := server.store.ApproveTransferTx(ctx, db.DecideTransferTxParams{
+		ID:       transfer.ID,
+		Approver: authPayload.Username,
+	})
+	if err != nil {
+		server.transferApprovalErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// Authorization Rule for Reject Transfer API: the same as for the Approve Transfer API. No money moves.
+func (server *Server) rejectTransfer(ctx *gin.Context) {
+	var req transferURIRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	result, err := server.store.RejectTransferTx(ctx, db.DecideTransferTxParams{
+		ID:       req.ID,
+		Approver: authPayload.Username,
+	})
+	if err != nil {
+		server.transferApprovalErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// transferApprovalErrorResponse responds to an error approving or rejecting a transfer.
+func (server *Server) transferApprovalErrorResponse(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+	case errors.Is(err, db.ErrNotApprover), errors.Is(err, db.ErrSelfApproval):
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+	case errors.Is(err, db.ErrTransferNotPending):
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+	case errors.Is(err, db.ErrInsufficientFunds):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+	case errors.Is(err, db.ErrTxConflict):
+		ctx.Header("Retry-After", "1")
+		ctx.JSON(http.StatusServiceUnavailable, errorResponse(err))
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+}
+
+// expireTransferApprovals expires the transfers still pending after their deadline
+// every TRANSFER_APPROVAL_SWEEP_INTERVAL, until `ctx` is cancelled.
+func (server *Server) expireTransferApprovals(ctx context.Context) {
+	server.sweep(ctx, server.config.TransferApprovalSweepInterval, "transfer approvals", server.store.ExpireTransferApprovalsTx)
+}
@@ -0,0 +1,565 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApproveTransferAPI(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+	approver, _ := randomUser(t)
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+	frozenAccount2 := account2
+	frozenAccount2.Status = db.AccountStatusFrozen
+
+	transfer := db.Transfer{
+		ID:            util.RandomInt(1, 1000),
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.RandomMoney() + 1,
+		Status:        db.TransferStatusPendingApproval,
+	}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: approver,
+			buildStubs: func(store *mockdb.MockStore) {
+				approved := transfer
+				approved.Status = db.TransferStatusCompleted
+
+				store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().
+					GetAccountApprover(gomock.Any(), gomock.Eq(db.GetAccountApproverParams{AccountID: account1.ID, Username: approver.Username})).
+					Times(1).
+					Return(db.AccountApprover{AccountID: account1.ID, Username: approver.Username}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(2).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					ApproveTransferTx(gomock.Any(), gomock.Eq(db.DecideTransferTxParams{ID: transfer.ID, Approver: approver.Username})).
+					Times(1).
+					Return(db.ApproveTransferTxResult{TransferTxResult: db.TransferTxResult{Transfer: approved}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res db.ApproveTransferTxResult
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Equal(t, db.TransferStatusCompleted, res.Transfer.Status)
+			},
+		},
+		{
+			name: "SelfApproval",
+			user: user1,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().
+					GetAccountApprover(gomock.Any(), gomock.Eq(db.GetAccountApproverParams{AccountID: account1.ID, Username: user1.Username})).
+					Times(1).
+					Return(db.AccountApprover{AccountID: account1.ID, Username: user1.Username}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(2).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					ApproveTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApproveTransferTxResult{}, db.ErrSelfApproval)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotApprover",
+			user: user2,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().
+					GetAccountApprover(gomock.Any(), gomock.Eq(db.GetAccountApproverParams{AccountID: account1.ID, Username: user2.Username})).
+					Times(1).
+					Return(db.AccountApprover{}, sql.ErrNoRows)
+				// nothing about the accounts is disclosed
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ApproveTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotPending",
+			user: approver,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().
+					GetAccountApprover(gomock.Any(), gomock.Eq(db.GetAccountApproverParams{AccountID: account1.ID, Username: approver.Username})).
+					Times(1).
+					Return(db.AccountApprover{AccountID: account1.ID, Username: approver.Username}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(2).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					ApproveTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApproveTransferTxResult{}, fmt.Errorf("%w: expired", db.ErrTransferNotPending))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "FrozenToAccount",
+			user: approver,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(transfer, nil)
+				store.EXPECT().
+					GetAccountApprover(gomock.Any(), gomock.Eq(db.GetAccountApproverParams{AccountID: account1.ID, Username: approver.Username})).
+					Times(1).
+					Return(db.AccountApprover{AccountID: account1.ID, Username: approver.Username}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(2).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(frozenAccount2, nil)
+				store.EXPECT().ApproveTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			user: approver,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).Return(db.Transfer{}, sql.ErrNoRows)
+				store.EXPECT().ApproveTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/transfers/%d/approve", transfer.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRejectTransferAPI(t *testing.T) {
+	approver, _ := randomUser(t)
+	transferID := util.RandomInt(1, 1000)
+	arg := db.DecideTransferTxParams{ID: transferID, Approver: approver.Username}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RejectTransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.TransferApprovalTxResult{Transfer: db.Transfer{ID: transferID, Status: db.TransferStatusRejected}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res db.TransferApprovalTxResult
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Equal(t, db.TransferStatusRejected, res.Transfer.Status)
+			},
+		},
+		{
+			name: "NotPending",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RejectTransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.TransferApprovalTxResult{}, fmt.Errorf("%w: completed", db.ErrTransferNotPending))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RejectTransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.TransferApprovalTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/transfers/%d/reject", transferID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, approver.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateApprovalPolicyAPI(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+	account := randomAccount(user1.Username)
+	guarded := account
+	guarded.ApprovalThreshold = 500
+	guardedApprovers := []db.AccountApprover{{AccountID: account.ID, Username: user2.Username}}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: user1,
+			body: gin.H{"threshold": 1000, "approvers": []string{user2.Username, user2.Username}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return([]db.AccountApprover{}, nil)
+				store.EXPECT().DeleteApprovalPolicyChange(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().
+					UpdateAccountApprovalThreshold(gomock.Any(), gomock.Eq(db.UpdateAccountApprovalThresholdParams{ID: account.ID, ApprovalThreshold: 1000})).
+					Times(1)
+				store.EXPECT().DeleteAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().
+					CreateAccountApprover(gomock.Any(), gomock.Eq(db.CreateAccountApproverParams{AccountID: account.ID, Username: user2.Username})).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res approvalPolicyResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Equal(t, int64(1000), res.Threshold)
+				require.Equal(t, []string{user2.Username}, res.Approvers)
+			},
+		},
+		{
+			name: "UnknownApprover",
+			user: user1,
+			body: gin.H{"threshold": 1000, "approvers": []string{"nobody"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return([]db.AccountApprover{}, nil)
+				store.EXPECT().DeleteApprovalPolicyChange(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().UpdateAccountApprovalThreshold(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().DeleteAccountApprovers(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().
+					CreateAccountApprover(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AccountApprover{}, &pq.Error{Code: "23503"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "StrongerAppliesNow",
+			user: user1,
+			body: gin.H{"threshold": 100, "approvers": []string{user2.Username}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(guarded, nil)
+				store.EXPECT().ListAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(guardedApprovers, nil)
+				store.EXPECT().DeleteApprovalPolicyChange(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().CreateApprovalPolicyChange(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					UpdateAccountApprovalThreshold(gomock.Any(), gomock.Eq(db.UpdateAccountApprovalThresholdParams{ID: account.ID, ApprovalThreshold: 100})).
+					Times(1)
+				store.EXPECT().DeleteAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().CreateAccountApprover(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "WeakerNeedsApproval",
+			user: user1,
+			body: gin.H{"threshold": 0},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(guarded, nil)
+				store.EXPECT().ListAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(guardedApprovers, nil)
+				store.EXPECT().DeleteApprovalPolicyChange(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().
+					CreateApprovalPolicyChange(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateApprovalPolicyChangeParams) (db.ApprovalPolicyChange, error) {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.Zero(t, arg.Threshold)
+						require.Empty(t, arg.Approvers)
+						require.Equal(t, user1.Username, arg.RequestedBy)
+						require.True(t, arg.ExpiresAt.After(time.Now()))
+						return db.ApprovalPolicyChange{ID: 1, AccountID: arg.AccountID, RequestedBy: arg.RequestedBy}, nil
+					})
+				store.EXPECT().UpdateAccountApprovalThreshold(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DeleteAccountApprovers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+			},
+		},
+		{
+			name: "OtherApproversNeedApproval",
+			user: user1,
+			body: gin.H{"threshold": 100, "approvers": []string{user1.Username}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(guarded, nil)
+				store.EXPECT().ListAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(guardedApprovers, nil)
+				store.EXPECT().DeleteApprovalPolicyChange(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().CreateApprovalPolicyChange(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().UpdateAccountApprovalThreshold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+			},
+		},
+		{
+			name: "ThresholdWithoutApprovers",
+			user: user1,
+			body: gin.H{"threshold": 1000},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			user: user2,
+			body: gin.H{"threshold": 0},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().UpdateAccountApprovalThreshold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/accounts/%d/approval_policy", account.ID)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestApproveApprovalPolicyChangeAPI(t *testing.T) {
+	owner, _ := randomUser(t)
+	approver, _ := randomUser(t)
+	other, _ := randomUser(t)
+	account := randomAccount(owner.Username)
+	account.ApprovalThreshold = 500
+
+	change := db.ApprovalPolicyChange{
+		ID:          util.RandomInt(1, 1000),
+		AccountID:   account.ID,
+		Threshold:   0,
+		Approvers:   []string{},
+		RequestedBy: owner.Username,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	expired := change
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: approver,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetApprovalPolicyChangeForUpdate(gomock.Any(), gomock.Eq(change.ID)).Times(1).Return(change, nil)
+				store.EXPECT().
+					GetAccountApprover(gomock.Any(), gomock.Eq(db.GetAccountApproverParams{AccountID: account.ID, Username: approver.Username})).
+					Times(1)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().
+					UpdateAccountApprovalThreshold(gomock.Any(), gomock.Eq(db.UpdateAccountApprovalThresholdParams{ID: account.ID, ApprovalThreshold: 0})).
+					Times(1)
+				store.EXPECT().DeleteAccountApprovers(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+				store.EXPECT().DeleteApprovalPolicyChange(gomock.Any(), gomock.Eq(account.ID)).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res approvalPolicyResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Zero(t, res.Threshold)
+			},
+		},
+		{
+			name: "SelfApproval",
+			user: owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetApprovalPolicyChangeForUpdate(gomock.Any(), gomock.Eq(change.ID)).Times(1).Return(change, nil)
+				store.EXPECT().UpdateAccountApprovalThreshold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotApprover",
+			user: other,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetApprovalPolicyChangeForUpdate(gomock.Any(), gomock.Eq(change.ID)).Times(1).Return(change, nil)
+				store.EXPECT().
+					GetAccountApprover(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AccountApprover{}, sql.ErrNoRows)
+				store.EXPECT().UpdateAccountApprovalThreshold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "Expired",
+			user: approver,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetApprovalPolicyChangeForUpdate(gomock.Any(), gomock.Eq(change.ID)).Times(1).Return(expired, nil)
+				store.EXPECT().UpdateAccountApprovalThreshold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			user: approver,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApprovalPolicyChangeForUpdate(gomock.Any(), gomock.Eq(change.ID)).
+					Times(1).
+					Return(db.ApprovalPolicyChange{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/approval_policy_changes/%d/approve", change.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestApprovalExpiresAt(t *testing.T) {
+	server := newTestServer(t, nil)
+	require.WithinDuration(t, time.Now().Add(time.Hour), server.approvalExpiresAt(), time.Second)
+
+	// an unset duration must not create requests already expired
+	server.config.TransferApprovalDuration = 0
+	require.WithinDuration(t, time.Now().Add(defaultTransferApprovalDuration), server.approvalExpiresAt(), time.Second)
+}
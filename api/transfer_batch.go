@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/gin-gonic/gin"
+)
+
+type batchTransferItemRequest struct {
+	ToAccountID int64  `json:"to_account_id" binding:"required,min=1"`
+	Amount      int64  `json:"amount" binding:"required,gt=0"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+type batchTransferRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	Mode          string `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort"` // all_or_nothing by default
+	// a batch runs in a single transaction holding the locks of all its accounts, it must stay short
+	Items []batchTransferItemRequest `json:"items" binding:"required,min=1,max=1000,dive"`
+	// step-up verification, required when the total of the batch is from TRANSFER_MFA_THRESHOLD
+	TOTPCode string `json:"totp_code" binding:"omitempty,numeric,len=6"`
+}
+
+// batchRejectedResponse tells which items made an all-or-nothing batch fail.
+type batchRejectedResponse struct {
+	Error string                       `json:"error"`
+	Items []db.BatchTransferItemResult `json:"items"`
+}
+
+// Authorization Rule for Batch Transfer API: A logged-in user can only send money from his/her own account.
+// Each item gets a result, in the order of the request. An item failing fails the whole batch in the
+// all_or_nothing mode (422, nothing is transferred), and only itself in the best_effort mode (200).
+func (server *Server) createBatchTransfer(ctx *gin.Context) {
+	var req batchTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = db.BatchModeAllOrNothing
+	}
+
+	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if fromAccount.Owner != authPayload.Username {
+		err := errors.New("from_account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	arg := db.BatchTransferTxParams{
+		FromAccountID: req.FromAccountID,
+		Currency:      req.Currency,
+		Mode:          req.Mode,
+		Items:         make([]db.BatchTransferItem, len(req.Items)),
+	}
+	var total int64
+	for i, item := range req.Items {
+		arg.Items[i] = db.BatchTransferItem{ToAccountID: item.ToAccountID, Amount: item.Amount, Description: item.Description}
+		// a total wrapping around negative would skip the thresholds below
+		if item.Amount > math.MaxInt64-total {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("total amount of the batch is too large")))
+			return
+		}
+		total += item.Amount
+	}
+
+	// a batch can't be used to split a large payment under the thresholds
+	if requiresApproval(fromAccount, total) {
+		ctx.JSON(http.StatusForbidden, errorResponse(errApprovalRequired(fromAccount)))
+		return
+	}
+	threshold := server.config.TransferMFAThreshold
+	if threshold > 0 && total >= threshold {
+		if !server.stepUp(ctx, authPayload.Username, req.TOTPCode, fmt.Sprintf("transfers of %d or more", threshold)) {
+			return
+		}
+	}
+
+	result, err := server.store.BatchTransferTx(ctx, arg)
+	if err != nil {
+		if errors.Is(err, db.ErrBatchRejected) {
+			ctx.JSON(http.StatusUnprocessableEntity, batchRejectedResponse{Error: err.Error(), Items: result.Items})
+			return
+		}
+		if errors.Is(err, db.ErrTxConflict) {
+			ctx.Header("Retry-After", "1")
+			ctx.JSON(http.StatusServiceUnavailable, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
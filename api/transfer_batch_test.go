@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTransferAPI(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+
+	items := []gin.H{
+		{"to_account_id": account2.ID, "amount": 10},
+		{"to_account_id": account2.ID + 1, "amount": 20},
+	}
+	arg := db.BatchTransferTxParams{
+		FromAccountID: account1.ID,
+		Currency:      util.USD,
+		Mode:          db.BatchModeAllOrNothing,
+		Items: []db.BatchTransferItem{
+			{ToAccountID: account2.ID, Amount: 10},
+			{ToAccountID: account2.ID + 1, Amount: 20},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": items},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().
+					BatchTransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.BatchTransferTxResult{FromAccount: account1, Succeeded: 2}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res db.BatchTransferTxResult
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Equal(t, 2, res.Succeeded)
+			},
+		},
+		{
+			name: "BestEffort",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "mode": db.BatchModeBestEffort, "items": items},
+			buildStubs: func(store *mockdb.MockStore) {
+				bestEffort := arg
+				bestEffort.Mode = db.BatchModeBestEffort
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().
+					BatchTransferTx(gomock.Any(), gomock.Eq(bestEffort)).
+					Times(1).
+					Return(db.BatchTransferTxResult{Succeeded: 1, Failed: 1}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Rejected",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": items},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().
+					BatchTransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.BatchTransferTxResult{
+						Items: []db.BatchTransferItemResult{
+							{ToAccountID: account2.ID, Amount: 10, Status: db.BatchItemStatusSkipped},
+							{ToAccountID: account2.ID + 1, Amount: 20, Status: db.BatchItemStatusFailed, Error: "not found"},
+						},
+						Failed: 1,
+					}, db.ErrBatchRejected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+
+				var res batchRejectedResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Len(t, res.Items, 2)
+				require.Equal(t, db.BatchItemStatusFailed, res.Items[1].Status)
+			},
+		},
+		{
+			name: "TxConflict",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": items},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().
+					BatchTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.BatchTransferTxResult{}, fmt.Errorf("%w after 3 attempt(s)", db.ErrTxConflict))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Equal(t, "1", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			user: user2,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": items},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "FromAccountNotFound",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": items},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "NoItems",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": []gin.H{}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidItemAmount",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": []gin.H{{"to_account_id": account2.ID, "amount": -1}}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TotalOverflow",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "items": []gin.H{
+				{"to_account_id": account2.ID, "amount": int64(1) << 62},
+				{"to_account_id": account2.ID, "amount": int64(1) << 62},
+			}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidMode",
+			user: user1,
+			body: gin.H{"from_account_id": account1.ID, "currency": util.USD, "mode": "some", "items": items},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers/batch", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
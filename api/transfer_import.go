@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/transferimport"
+	"github.com/gin-gonic/gin"
+)
+
+// plenty for `transferimport.MaxRows` rows
+const maxTransferImportSize = 1 << 20
+
+// importTransfersRequest is a multipart form, the CSV being its `file`.
+type importTransfersRequest struct {
+	DryRun bool `form:"dry_run"` // only check the rows
+	// step-up verification, required to import rows from TRANSFER_MFA_THRESHOLD
+	TOTPCode string `form:"totp_code" binding:"omitempty,numeric,len=6"`
+}
+
+type importTransfersResponse struct {
+	DryRun  bool                       `json:"dry_run"`
+	Summary map[string]int             `json:"summary"` // rows per status
+	Rows    []transferimport.RowResult `json:"rows"`
+}
+
+// Authorization Rule for Import Transfers API: every row follows the rules of the Transfer Money API,
+// so a logged-in user can only send money from his/her own accounts.
+// The result of each row is returned: a dry run checks them all, without making any transfer.
+func (server *Server) importTransfers(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxTransferImportSize)
+
+	var req importTransfersRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	defer file.Close()
+
+	rows, err := transferimport.Parse(file)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid csv: %w", err)))
+		return
+	}
+
+	// like a batch, a file can't be used to split a large payment under the thresholds:
+	// they apply to the total of the rows from each account
+	totals := importTotals(rows)
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	threshold := server.config.TransferMFAThreshold
+	if !req.DryRun && threshold > 0 {
+		for _, total := range totals {
+			if total >= threshold {
+				if !server.stepUp(ctx, authPayload.Username, req.TOTPCode, fmt.Sprintf("transfers of %d or more", threshold)) {
+					return
+				}
+				break
+			}
+		}
+	}
+
+	importer := transferimport.Importer{
+		Store: server.store,
+		Check: func(ctx context.Context, row transferimport.Row) error {
+			fromAccount, _, err := server.checkTransfer(ctx, authPayload.Username, row.FromAccountID, row.ToAccountID, row.Currency)
+			if err != nil {
+				return err
+			}
+			if requiresApproval(fromAccount, totals[row.FromAccountID]) {
+				return errApprovalRequired(fromAccount)
+			}
+			return nil
+		},
+	}
+	results, err := importer.Import(ctx, rows, req.DryRun)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, importTransfersResponse{
+		DryRun:  req.DryRun,
+		Summary: transferimport.Summary(results),
+		Rows:    results,
+	})
+}
+
+// importTotals sums the amounts of the rows per from account.
+// A total too large for an int64 is capped, so it can't wrap around under the thresholds.
+func importTotals(rows []transferimport.Row) map[int64]int64 {
+	totals := make(map[int64]int64)
+	for _, row := range rows {
+		total := totals[row.FromAccountID]
+		if row.Amount > math.MaxInt64-total {
+			totals[row.FromAccountID] = math.MaxInt64
+			continue
+		}
+		totals[row.FromAccountID] = total + row.Amount
+	}
+	return totals
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/transferimport"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportTransfersAPI(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	// a valid row, then one sending from an account of another user
+	csv := fmt.Sprintf("from_account_id,to_account_id,amount,currency,reference\n%d,%d,10,USD,pay-1\n%d,%d,10,USD,pay-2\n",
+		account1.ID, account2.ID, account2.ID, account1.ID)
+
+	expectChecks := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetTransferByExternalReference(gomock.Any(), gomock.Any()).
+			Times(2).
+			Return(db.Transfer{}, sql.ErrNoRows)
+		store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+		store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(2).Return(account2, nil)
+	}
+
+	testCases := []struct {
+		name          string
+		fields        map[string]string
+		file          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "DryRun",
+			fields: map[string]string{"dry_run": "true"},
+			file:   csv,
+			buildStubs: func(store *mockdb.MockStore) {
+				expectChecks(store)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res importTransfersResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.True(t, res.DryRun)
+				require.Len(t, res.Rows, 2)
+				require.Equal(t, transferimport.StatusValid, res.Rows[0].Status)
+				require.Equal(t, transferimport.StatusInvalid, res.Rows[1].Status)
+				require.Contains(t, res.Rows[1].Error, "doesn't belong")
+			},
+		},
+		{
+			name: "Commit",
+			file: csv,
+			buildStubs: func(store *mockdb.MockStore) {
+				expectChecks(store)
+				arg := db.TransferTxParams{
+					FromAccountID:     account1.ID,
+					ToAccountID:       account2.ID,
+					Amount:            10,
+					ExternalReference: "pay-1",
+				}
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.TransferTxResult{Transfer: db.Transfer{ID: 1}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res importTransfersResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.False(t, res.DryRun)
+				require.Equal(t, 1, res.Summary[transferimport.StatusTransferred])
+				require.Equal(t, 1, res.Summary[transferimport.StatusInvalid])
+			},
+		},
+		{
+			name: "SplitUnderApprovalThreshold",
+			// each row is under the threshold, not their total
+			file: fmt.Sprintf("from_account_id,to_account_id,amount,currency,reference\n%d,%d,10,USD,pay-1\n%d,%d,10,USD,pay-2\n",
+				account1.ID, account2.ID, account1.ID, account2.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				approvedAccount1 := account1
+				approvedAccount1.ApprovalThreshold = 15
+
+				store.EXPECT().
+					GetTransferByExternalReference(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.Transfer{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(2).Return(approvedAccount1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(2).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res importTransfersResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Equal(t, 2, res.Summary[transferimport.StatusInvalid])
+				require.Contains(t, res.Rows[0].Error, "need an approval")
+			},
+		},
+		{
+			name: "InvalidCSV",
+			file: "from,to\n1,2\n",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferByExternalReference(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NoFile",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferByExternalReference(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			for name, value := range tc.fields {
+				require.NoError(t, writer.WriteField(name, value))
+			}
+			if tc.file != "" {
+				part, err := writer.CreateFormFile("file", "transfers.csv")
+				require.NoError(t, err)
+				_, err = part.Write([]byte(tc.file))
+				require.NoError(t, err)
+			}
+			require.NoError(t, writer.Close())
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers/import", &body)
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestImportTotals(t *testing.T) {
+	rows := []transferimport.Row{
+		{FromAccountID: 1, Amount: 10},
+		{FromAccountID: 2, Amount: 5},
+		{FromAccountID: 1, Amount: 20},
+		{FromAccountID: 3, Amount: math.MaxInt64},
+		{FromAccountID: 3, Amount: 1},
+	}
+
+	totals := importTotals(rows)
+	require.Equal(t, map[int64]int64{1: 30, 2: 5, 3: math.MaxInt64}, totals)
+}
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
 	"github.com/Oliver-Zen/simplebank/util"
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
@@ -13,7 +17,7 @@ import (
 
 type createUserRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"` // the length is up to passwordPolicy
 	FullName string `json:"full_name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 }
@@ -45,7 +49,12 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := util.HashPassword(req.Password)
+	if err := server.passwordPolicy.Validate(req.Password, req.Username); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashedPassword, err := server.passwordHasher.Hash(req.Password)
 	// HOW `CreateUser(gomock.Any(), gomock.Any())` weaken the test? (2)
 	// hashedPassword, err = util.HashPassword("xyz") // an invalid password
 	// expect fail (but pass before using custom gomock matcher)
@@ -64,7 +73,16 @@ func (server *Server) createUser(ctx *gin.Context) {
 	// HOW `CreateUser(gomock.Any(), gomock.Any())` weaken the test? (1)
 	// arg = db.CreateUserParams{} // expect fail (but pass before using custom gomock matcher)
 
-	user, err := server.store.CreateUser(ctx, arg)
+	var user db.User
+	err = server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		user, err = q.CreateUser(ctx, arg)
+		return db.AuditChange{
+			Action: db.AuditActionUserCreate,
+			Target: "user:" + user.Username,
+			After:  newUserResponse(user), // without the password hash
+		}, err
+	})
 	if err != nil { // internal issue (req validated already)
 		// handle DB error(s)
 		if pqErr, ok := err.(*pq.Error); ok {
@@ -88,11 +106,14 @@ func (server *Server) createUser(ctx *gin.Context) {
 
 type loginUserRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"` // checked against the hash only, the policy may have changed since
+	// restrict the token, e.g. ["accounts:read"] for a read-only client; all scopes if empty
+	Scopes []string `json:"scopes" binding:"omitempty,dive,scope"`
 }
 
 type loginUserResponse struct {
 	AccessToken string       `json:"access_token" binding:"required,alphanum"`
+	Scopes      []string     `json:"scopes"`
 	User        userResponse `json:"user" binding:"required,min=6"`
 }
 
@@ -106,26 +127,64 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
+	if server.loginThrottled() && !server.checkLoginLockout(ctx, req.Username) {
+		return
+	}
+
 	user, err := server.store.GetUser(ctx, req.Username)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusBadRequest, errorResponse(err))
-			return
-		}
+	if err != nil && err != sql.ErrNoRows {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	userExists := err == nil
 
 	// check password
-	err = util.CheckPassword(req.Password, user.HashedPassword)
+	// an unknown username is checked against a dummy hash: it must take as long as a wrong password
+	hashedPassword := user.HashedPassword
+	if !userExists {
+		hashedPassword = server.dummyPasswordHash()
+	}
+	err = util.CheckPassword(req.Password, hashedPassword)
+	if err != nil || !userExists {
+		server.loginFailed(ctx, req.Username)
+		return
+	}
+
+	server.rehashPassword(ctx, user, req.Password)
+
+	// two-step login: the password is correct, now the second factor is needed
+	mfaEnabled, err := server.totpEnabled(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if mfaEnabled {
+		// the failures are only forgotten once the second factor is verified too,
+		// otherwise the password would reset the failed codes
+		server.loginChallenge(ctx, user)
+		return
+	}
+
+	err = server.resetLoginFailures(ctx, user.Username)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
+	server.loginResponse(ctx, user, req.Scopes)
+	// return // redundent return statement
+}
+
+// `loginResponse` creates the access token of an authenticated user, with all scopes unless `scopes` are requested.
+func (server *Server) loginResponse(ctx *gin.Context, user db.User, scopes []string) {
+	if len(scopes) == 0 {
+		scopes = token.AllScopes()
+	}
+
 	accessToken, err := server.tokenMaker.CreateToken(
 		user.Username,
 		server.config.AccessTokenDuration,
+		scopes...,
 	)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
@@ -134,8 +193,117 @@ func (server *Server) loginUser(ctx *gin.Context) {
 
 	res := loginUserResponse{
 		AccessToken: accessToken,
+		Scopes:      scopes,
 		User:        newUserResponse(user),
 	}
 	ctx.JSON(http.StatusOK, res)
-	// return // redundent return statement
+}
+
+// `rehashPassword` upgrades the hash of a password just verified, if it was made by an older algorithm or cost.
+// A failure is only logged: the login itself succeeded, the upgrade is tried again at the next login.
+func (server *Server) rehashPassword(ctx *gin.Context, user db.User, password string) {
+	if !server.passwordHasher.NeedsRehash(user.HashedPassword) {
+		return
+	}
+
+	hashedPassword, err := server.passwordHasher.Hash(password)
+	if err != nil {
+		log.Println("cannot rehash password:", err)
+		return
+	}
+	_, err = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+		Username:          user.Username,
+		OldHashedPassword: user.HashedPassword,
+		NewHashedPassword: hashedPassword,
+	})
+	if err != nil {
+		log.Println("cannot rehash password:", err)
+	}
+}
+
+// `newDummyPasswordHash` hashes a random password with the current hasher,
+// to check unknown usernames against (see `loginUser`).
+func (server *Server) newDummyPasswordHash() string {
+	hashedPassword, err := server.passwordHasher.Hash(util.RandomString(16))
+	if err != nil {
+		log.Println("cannot hash dummy password:", err)
+	}
+	return hashedPassword
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"` // the length is up to passwordPolicy
+}
+
+// `changePassword` replaces the password of the logged-in user, the current password must be provided.
+// The access tokens issued before and the API keys of the user stop working.
+func (server *Server) changePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// a stolen token alone is not enough to take over the account,
+	// and guesses of the current password count as failed logins
+	if server.loginThrottled() && !server.checkLoginLockout(ctx, user.Username) {
+		return
+	}
+	err = util.CheckPassword(req.CurrentPassword, user.HashedPassword)
+	if err != nil {
+		server.authenticationFailed(ctx, user.Username, http.StatusForbidden, errors.New("current password is incorrect"))
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		err := errors.New("new password must be different from the current password")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := server.passwordPolicy.Validate(req.NewPassword, user.Username); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashedPassword, err := server.passwordHasher.Hash(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	before := newUserResponse(user)
+	err = server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		user, err = q.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+			Username:       user.Username,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		// whoever knew the old password may have created keys with it
+		_, err = q.RevokeUserAPIKeys(ctx, user.Username)
+		return db.AuditChange{
+			Action: db.AuditActionUserPasswordChange,
+			Target: "user:" + user.Username,
+			Before: before,
+			After:  newUserResponse(user),
+		}, err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
 }
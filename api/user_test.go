@@ -13,6 +13,7 @@ import (
 
 	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
 	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
 	"github.com/Oliver-Zen/simplebank/util"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -183,6 +184,23 @@ func TestCreateUserAPI(t *testing.T) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
+		{
+			name: "CommonPassword",
+			body: gin.H{
+				"username":  user.Username,
+				"password":  "password123",
+				"full_name": user.FullName,
+				"email":     user.Email,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -213,6 +231,170 @@ func TestCreateUserAPI(t *testing.T) {
 	}
 }
 
+func TestLoginUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+	shortUser, _ := randomUser(t)
+	shortHashedPassword, err := util.HashPassword("a1B!")
+	require.NoError(t, err)
+	shortUser.HashedPassword = shortHashedPassword
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				// no TOTP enrolled: the access token is issued right away
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.TotpSecret{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				payload := requireBodyAccessToken(t, tokenMaker, recorder.Body)
+				require.Equal(t, user.Username, payload.Username)
+				require.ElementsMatch(t, token.AllScopes(), payload.Scopes)
+			},
+		},
+		{
+			// chosen under a policy allowing shorter passwords: the login only checks the hash
+			name: "ShortPassword",
+			body: gin.H{
+				"username": shortUser.Username,
+				"password": "a1B!",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(shortUser.Username)).
+					Times(1).
+					Return(shortUser, nil)
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(shortUser.Username)).
+					Times(1).
+					Return(db.TotpSecret{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ReadOnlyScopes",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+				"scopes":   []string{token.ScopeAccountsRead},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				// no TOTP enrolled: the access token is issued right away
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.TotpSecret{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				payload := requireBodyAccessToken(t, tokenMaker, recorder.Body)
+				require.Equal(t, []string{token.ScopeAccountsRead}, payload.Scopes)
+				require.False(t, payload.HasScopes(token.ScopeTransfersWrite))
+			},
+		},
+		{
+			name: "UnsupportedScope",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+				"scopes":   []string{"admin"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "IncorrectPassword",
+			body: gin.H{
+				"username": user.Username,
+				"password": "incorrect",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.JSONEq(t, `{"error":"incorrect username or password"}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: gin.H{
+				"username": "notfound",
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq("notfound")).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, recorder *httptest.ResponseRecorder) {
+				// the same response as a wrong password
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.JSONEq(t, `{"error":"incorrect username or password"}`, recorder.Body.String())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := "/users/login"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, server.tokenMaker, recorder)
+		})
+	}
+}
+
 func randomUser(t *testing.T) (user db.User, password string) {
 	password = util.RandomString(6)
 	hashedPassword, err := util.HashPassword(password)
@@ -241,3 +423,15 @@ func requireBodyMatchUser(t *testing.T, body *bytes.Buffer, user db.User) {
 	require.Equal(t, user.Email, gotUser.Email)
 	require.Empty(t, gotUser.HashedPassword)
 }
+
+// requireBodyAccessToken verifies the access token of a login response and returns its payload.
+func requireBodyAccessToken(t *testing.T, tokenMaker token.Maker, body *bytes.Buffer) *token.Payload {
+	var res loginUserResponse
+	err := json.NewDecoder(body).Decode(&res)
+	require.NoError(t, err)
+
+	payload, err := tokenMaker.VerifyToken(res.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, res.Scopes, payload.Scopes)
+	return payload
+}
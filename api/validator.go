@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/Oliver-Zen/simplebank/token"
 	"github.com/Oliver-Zen/simplebank/util"
 	"github.com/go-playground/validator/v10"
 )
@@ -12,3 +13,10 @@ var validCurrency validator.Func = func(fieldLevel validator.FieldLevel) bool {
 	}
 	return false
 }
+
+var validScope validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if scope, ok := fieldLevel.Field().Interface().(string); ok {
+		return token.IsSupportedScope(scope)
+	}
+	return false
+}
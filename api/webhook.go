@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/token"
+	"github.com/Oliver-Zen/simplebank/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type createWebhookRequest struct {
+	URL string `json:"url" binding:"required,url,max=2048"`
+}
+
+// webhookResponse only contains the signing secret once, when the webhook is created.
+type webhookResponse struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWebhookResponse(hook db.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        hook.ID,
+		URL:       hook.Url,
+		CreatedAt: hook.CreatedAt,
+	}
+}
+
+// Authorization Rule for Create Webhook API: a logged-in user can only register webhooks for him/herself,
+// they receive the events of his/her accounts.
+func (server *Server) createWebhook(ctx *gin.Context) {
+	var req createWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	// the dispatcher checks the addresses again when it connects, see `webhook.NewDispatcher`
+	if err := webhook.CheckURL(ctx, server.webhookResolver, req.URL); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var hook db.Webhook
+	err = server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		hook, err = q.CreateWebhook(ctx, db.CreateWebhookParams{
+			Owner:  authPayload.Username,
+			Url:    req.URL,
+			Secret: secret,
+		})
+		return db.AuditChange{
+			Action: db.AuditActionWebhookCreate,
+			Target: fmt.Sprintf("webhook:%d", hook.ID),
+			After:  newWebhookResponse(hook), // without the secret
+		}, err
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation":
+				ctx.JSON(http.StatusForbidden, errorResponse(err))
+				return
+			}
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	res := newWebhookResponse(hook)
+	res.Secret = hook.Secret
+	ctx.JSON(http.StatusOK, res)
+}
+
+type listWebhooksRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+// Authorization Rule for List Webhooks API: a logged-in user can only list his/her own webhooks.
+func (server *Server) listWebhooks(ctx *gin.Context) {
+	var req listWebhooksRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	hooks, err := server.store.ListWebhooks(ctx, db.ListWebhooksParams{
+		Owner:  authPayload.Username,
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	res := make([]webhookResponse, 0, len(hooks))
+	for _, hook := range hooks {
+		res = append(res, newWebhookResponse(hook))
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+type webhookIDRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// Authorization Rule for Delete Webhook API: a logged-in user can only delete his/her own webhooks.
+// Webhooks of other users are reported as not found, so their IDs cannot be probed.
+func (server *Server) deleteWebhook(ctx *gin.Context) {
+	var req webhookIDRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var hook db.Webhook
+	err := server.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		hook, err = q.DeleteWebhook(ctx, db.DeleteWebhookParams{
+			ID:    req.ID,
+			Owner: authPayload.Username,
+		})
+		return db.AuditChange{
+			Action: db.AuditActionWebhookDelete,
+			Target: fmt.Sprintf("webhook:%d", req.ID),
+			Before: newWebhookResponse(hook),
+		}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newWebhookResponse(hook))
+}
+
+type listWebhookDeliveriesRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=100"`
+}
+
+type webhookDeliveryResponse struct {
+	ID               int64      `json:"id"`
+	EventID          int64      `json:"event_id"`
+	Status           string     `json:"status"`
+	Attempts         int32      `json:"attempts"`
+	NextAttemptAt    *time.Time `json:"next_attempt_at"` // only while pending
+	LastResponseCode *int32     `json:"last_response_code"`
+	LastError        *string    `json:"last_error"`
+	DeliveredAt      *time.Time `json:"delivered_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+func newWebhookDeliveryResponse(delivery db.WebhookDelivery) webhookDeliveryResponse {
+	res := webhookDeliveryResponse{
+		ID:          delivery.ID,
+		EventID:     delivery.EventID,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		DeliveredAt: nullTime(delivery.DeliveredAt),
+		CreatedAt:   delivery.CreatedAt,
+	}
+	if delivery.Status == db.DeliveryStatusPending {
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastResponseCode.Valid {
+		res.LastResponseCode = &delivery.LastResponseCode.Int32
+	}
+	if delivery.LastError.Valid {
+		res.LastError = &delivery.LastError.String
+	}
+	return res
+}
+
+// Authorization Rule for List Webhook Deliveries API: a logged-in user can only read the log of his/her own webhooks.
+func (server *Server) listWebhookDeliveries(ctx *gin.Context) {
+	var uri webhookIDRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req listWebhookDeliveriesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	hook, err := server.store.GetWebhook(ctx, uri.ID)
+	if err == nil && hook.Owner != authPayload.Username {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	deliveries, err := server.store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Limit:     req.PageSize,
+		Offset:    (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	res := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		res = append(res, newWebhookDeliveryResponse(delivery))
+	}
+	ctx.JSON(http.StatusOK, res)
+}
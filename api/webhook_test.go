@@ -0,0 +1,314 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/Oliver-Zen/simplebank/webhook"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func randomWebhook(owner string) db.Webhook {
+	return db.Webhook{
+		ID:        util.RandomInt(1, 1000),
+		Owner:     owner,
+		Url:       "https://example.com/hooks/" + util.RandomString(6),
+		Secret:    "whsec_" + util.RandomString(32),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func TestCreateWebhookAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	hook := randomWebhook(user.Username)
+
+	testCases := []struct {
+		name          string
+		url           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			url:  hook.Url,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateWebhook(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateWebhookParams) (db.Webhook, error) {
+						require.Equal(t, user.Username, arg.Owner)
+						require.Equal(t, hook.Url, arg.Url)
+						require.True(t, strings.HasPrefix(arg.Secret, "whsec_"))
+						hook.Secret = arg.Secret
+						return hook, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res webhookResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Equal(t, hook.ID, res.ID)
+				require.Equal(t, hook.Secret, res.Secret) // returned once
+			},
+		},
+		{
+			name: "UnsupportedScheme",
+			url:  "ftp://example.com/hooks",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "PlainHTTP",
+			url:  "http://example.com/hooks",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalAddress",
+			url:  "https://metadata.internal/latest/meta-data",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), webhook.ErrForbiddenAddress.Error())
+			},
+		},
+		{
+			name: "InvalidURL",
+			url:  "not a url",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.webhookResolver = testResolver{
+				"example.com":       {{IP: net.ParseIP("93.184.216.34")}},
+				"metadata.internal": {{IP: net.ParseIP("169.254.169.254")}},
+			}
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(createWebhookRequest{URL: tc.url})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListWebhooksAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	hook := randomWebhook(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListWebhooks(gomock.Any(), gomock.Eq(db.ListWebhooksParams{Owner: user.Username, Limit: 5, Offset: 0})).
+		Times(1).
+		Return([]db.Webhook{hook}, nil)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/webhooks?page_id=1&page_size=5", nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NotContains(t, recorder.Body.String(), hook.Secret)
+}
+
+func TestDeleteWebhookAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	hook := randomWebhook(user.Username)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteWebhook(gomock.Any(), gomock.Eq(db.DeleteWebhookParams{ID: hook.ID, Owner: user.Username})).
+					Times(1).
+					Return(hook, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			// including webhooks of other users
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteWebhook(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Webhook{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("/webhooks/%d", hook.ID), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListWebhookDeliveriesAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	hook := randomWebhook(user.Username)
+	otherHook := randomWebhook(util.RandomOwner())
+
+	delivery := db.WebhookDelivery{
+		ID:               util.RandomInt(1, 1000),
+		WebhookID:        hook.ID,
+		EventID:          util.RandomInt(1, 1000),
+		Status:           db.DeliveryStatusPending,
+		Attempts:         1,
+		NextAttemptAt:    time.Now().UTC().Add(time.Minute).Truncate(time.Second),
+		LastResponseCode: sql.NullInt32{Int32: http.StatusServiceUnavailable, Valid: true},
+		LastError:        sql.NullString{String: "unexpected response status 503", Valid: true},
+	}
+
+	testCases := []struct {
+		name          string
+		webhookID     int64
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			webhookID: hook.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetWebhook(gomock.Any(), gomock.Eq(hook.ID)).Times(1).Return(hook, nil)
+				store.EXPECT().
+					ListWebhookDeliveries(gomock.Any(), gomock.Eq(db.ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: 5, Offset: 0})).
+					Times(1).
+					Return([]db.WebhookDelivery{delivery}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res []webhookDeliveryResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				require.Len(t, res, 1)
+				require.Equal(t, int32(http.StatusServiceUnavailable), *res[0].LastResponseCode)
+				require.Equal(t, delivery.NextAttemptAt, res[0].NextAttemptAt.UTC())
+				require.Nil(t, res[0].DeliveredAt)
+			},
+		},
+		{
+			name:      "NotOwner",
+			webhookID: otherHook.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetWebhook(gomock.Any(), gomock.Eq(otherHook.ID)).Times(1).Return(otherHook, nil)
+				store.EXPECT().ListWebhookDeliveries(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/webhooks/%d/deliveries?page_id=1&page_size=5", tc.webhookID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// testResolver resolves the hosts of the webhooks without DNS.
+type testResolver map[string][]net.IPAddr
+
+func (resolver testResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := resolver[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
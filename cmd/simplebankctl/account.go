@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+)
+
+func (c *cli) runAccount(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return c.createAccount(ctx, args[1:])
+	case "list":
+		return c.listAccounts(ctx, args[1:])
+	case "show":
+		return c.showAccount(ctx, args[1:])
+	case "freeze":
+		return c.setAccountStatus(ctx, args[1:], db.AccountStatusFrozen)
+	case "unfreeze":
+		return c.setAccountStatus(ctx, args[1:], db.AccountStatusActive)
+	}
+	return errUsage
+}
+
+func (c *cli) createAccount(ctx context.Context, args []string) error {
+	flags := newFlagSet("account create")
+	owner := flags.String("owner", "", "username of the owner")
+	currency := flags.String("currency", "", "currency of the account, e.g. USD")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *owner == "" {
+		return errors.New("-owner is required")
+	}
+	if !util.IsSupportedCurrency(*currency) {
+		return fmt.Errorf("unsupported currency: %q", *currency)
+	}
+
+	var account db.Account
+	err := c.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		account, err = q.CreateAccount(ctx, db.CreateAccountParams{
+			Owner:    *owner,
+			Currency: *currency,
+			Balance:  0,
+		})
+		return db.AuditChange{
+			Action: db.AuditActionAccountCreate,
+			Target: fmt.Sprintf("account:%d", account.ID),
+			After:  account,
+		}, err
+	})
+	if err != nil {
+		return err
+	}
+	return c.printAccount(account)
+}
+
+func (c *cli) listAccounts(ctx context.Context, args []string) error {
+	flags := newFlagSet("account list")
+	owner := flags.String("owner", "", "username of the owner")
+	pageID := flags.Int("page-id", 1, "page to list, starting at 1")
+	pageSize := flags.Int("page-size", 50, "accounts per page")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *owner == "" {
+		return errors.New("-owner is required")
+	}
+	if *pageID < 1 || *pageSize < 1 {
+		return errors.New("-page-id and -page-size must be positive")
+	}
+
+	accounts, err := c.store.ListAccounts(ctx, db.ListAccountsParams{
+		Owner:  *owner,
+		Limit:  int32(*pageSize),
+		Offset: int32((*pageID - 1) * *pageSize),
+	})
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(accounts))
+	for _, account := range accounts {
+		rows = append(rows, accountRow(account))
+	}
+	return c.print(accounts, accountHeader, rows)
+}
+
+func (c *cli) showAccount(ctx context.Context, args []string) error {
+	flags := newFlagSet("account show")
+	id := flags.Int64("id", 0, "account ID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	account, err := c.getAccount(ctx, *id)
+	if err != nil {
+		return err
+	}
+	return c.printAccount(account)
+}
+
+// setAccountStatus implements `account freeze` & `account unfreeze`.
+func (c *cli) setAccountStatus(ctx context.Context, args []string, status string) error {
+	flags := newFlagSet("account " + status)
+	id := flags.Int64("id", 0, "account ID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// look it up first for a friendly "not found"
+	before, err := c.getAccount(ctx, *id)
+	if err != nil {
+		return err
+	}
+
+	var account db.Account
+	err = c.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		account, err = q.UpdateAccountStatus(ctx, db.UpdateAccountStatusParams{
+			ID:     *id,
+			Status: status,
+		})
+		return db.AuditChange{
+			Action: db.AuditActionAccountStatus,
+			Target: fmt.Sprintf("account:%d", *id),
+			Before: before,
+			After:  account,
+		}, err
+	})
+	if err != nil {
+		return err
+	}
+	return c.printAccount(account)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+)
+
+func (c *cli) runLedger(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "reconcile":
+		return c.reconcileLedger(ctx)
+	case "verify":
+		return c.verifyLedger(ctx, args[1:])
+	}
+	return errUsage
+}
+
+// reconcileLedger lists the accounts whose balance differs from the sum of their entries.
+// An empty list means the ledger is consistent.
+func (c *cli) reconcileLedger(ctx context.Context) error {
+	mismatches, err := c.store.ReconcileLedger(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		rows = append(rows, []string{
+			fmt.Sprint(mismatch.ID),
+			mismatch.Owner,
+			mismatch.Currency,
+			fmt.Sprint(mismatch.Balance),
+			fmt.Sprint(mismatch.EntriesTotal),
+			fmt.Sprint(mismatch.Balance - mismatch.EntriesTotal),
+		})
+	}
+	return c.print(mismatches, []string{"ID", "OWNER", "CURRENCY", "BALANCE", "ENTRIES TOTAL", "DIFFERENCE"}, rows)
+}
+
+// verifyLedger walks the hash chain of the entries of each account, and lists the first broken link of each.
+// It fails if any chain is broken, so it can run from a cron job.
+func (c *cli) verifyLedger(ctx context.Context, args []string) error {
+	flags := newFlagSet("ledger verify")
+	accountID := flags.Int64("account-id", 0, "account to verify, every account if 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	breaks, err := c.store.VerifyLedger(ctx, db.VerifyLedgerParams{AccountID: *accountID})
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(breaks))
+	for _, brk := range breaks {
+		rows = append(rows, []string{fmt.Sprint(brk.AccountID), fmt.Sprint(brk.EntryID), brk.Reason})
+	}
+	if err := c.print(breaks, []string{"ACCOUNT ID", "ENTRY ID", "REASON"}, rows); err != nil {
+		return err
+	}
+
+	if len(breaks) > 0 {
+		return fmt.Errorf("the entries of %d account(s) were tampered with", len(breaks))
+	}
+	return nil
+}
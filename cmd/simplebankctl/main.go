@@ -0,0 +1,135 @@
+// simplebankctl is the command-line admin tool for operators.
+// It talks to the database directly through `db.Store`, so it doesn't need a running server.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+
+	_ "github.com/lib/pq" // For database/sql package to use the driver (`pq`) internally to connect to PostgreSQL.
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+)
+
+const usage = `usage: simplebankctl [-config dir] [-o table|json] <command> [flags]
+
+commands:
+  user create        -username -full-name -email  with the initial password on stdin
+  user reset-password -username  with the new password on stdin
+  user set-role      -username -role
+  account create     -owner -currency
+  account list       -owner [-page-size] [-page-id]
+  account show       -id
+  account freeze     -id
+  account unfreeze   -id
+  transfer           -from -to -amount -currency
+  transfer import    -file [-dry-run]  from a CSV of from_account_id,to_account_id,amount,currency,reference
+  ledger reconcile   list accounts whose balance doesn't match their entries
+  ledger verify      [-account-id]  check the hash chains of the entries`
+
+var errUsage = errors.New(usage)
+
+// cli holds what every subcommand needs.
+type cli struct {
+	store  db.Store
+	in     io.Reader // passwords are read from it, never from the flags: they'd show in `ps` & the shell history
+	out    io.Writer
+	format string // "table" or "json"
+
+	// passwords set by operators follow the same rules as the API's
+	passwordHasher util.PasswordHasher
+	passwordPolicy util.PasswordPolicy
+}
+
+func main() {
+	flags := flag.NewFlagSet("simplebankctl", flag.ExitOnError)
+	configPath := flags.String("config", ".", "directory containing app.env")
+	format := flags.String("o", formatTable, "output format: table or json")
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	flags.Parse(os.Args[1:])
+
+	config, err := util.LoadConfig(*configPath)
+	if err != nil {
+		fail(fmt.Errorf("cannot load config: %w", err))
+	}
+
+	passwordHasher, err := util.NewPasswordHasher(config)
+	if err != nil {
+		fail(fmt.Errorf("cannot create password hasher: %w", err))
+	}
+	passwordPolicy, err := util.NewPasswordPolicy(config)
+	if err != nil {
+		fail(fmt.Errorf("cannot create password policy: %w", err))
+	}
+
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		fail(fmt.Errorf("cannot connect to the db: %w", err))
+	}
+
+	c := &cli{
+		store:          db.NewStore(conn),
+		in:             os.Stdin,
+		out:            os.Stdout,
+		format:         *format,
+		passwordHasher: passwordHasher,
+		passwordPolicy: passwordPolicy,
+	}
+	// changes made by operators are audited under their OS user
+	ctx := db.WithAuditMetadata(context.Background(), db.AuditMetadata{Actor: operator()})
+	err = c.run(ctx, flags.Args())
+	conn.Close() // `os.Exit` in `fail` skips deferred calls
+	if err != nil {
+		fail(err)
+	}
+}
+
+// operator names the actor of the audit events, e.g. "simplebankctl:alice".
+func operator() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return "simplebankctl:" + name
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
+
+// run dispatches `args` (without the global flags) to a subcommand.
+func (c *cli) run(ctx context.Context, args []string) error {
+	if c.format != formatTable && c.format != formatJSON {
+		return fmt.Errorf("unsupported output format: %s", c.format)
+	}
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "user":
+		return c.runUser(ctx, args[1:])
+	case "account":
+		return c.runAccount(ctx, args[1:])
+	case "transfer":
+		return c.transfer(ctx, args[1:])
+	case "ledger":
+		return c.runLedger(ctx, args[1:])
+	}
+	return errUsage
+}
+
+// newFlagSet creates the flags of a subcommand; parse errors are returned instead of exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	return flags
+}
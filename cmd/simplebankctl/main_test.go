@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestCLI(store db.Store, format string) (*cli, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &cli{
+		store:          store,
+		out:            out,
+		format:         format,
+		passwordHasher: util.BcryptHasher{Cost: bcrypt.MinCost},
+	}, out
+}
+
+// expectAuditedTx runs the transaction against the mock itself and checks the audited action.
+func expectAuditedTx(t *testing.T, store *mockdb.MockStore, action string) *gomock.Call {
+	return store.EXPECT().
+		AuditedTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(context.Context, db.Querier) (db.AuditChange, error)) error {
+			change, err := fn(ctx, store)
+			if err == nil {
+				require.Equal(t, action, change.Action)
+			}
+			return err
+		})
+}
+
+func randomAccount(currency string) db.Account {
+	return db.Account{
+		ID:        util.RandomInt(1, 1000),
+		Owner:     util.RandomOwner(),
+		Balance:   util.RandomMoney(),
+		Currency:  currency,
+		Status:    db.AccountStatusActive,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func TestAccountShow(t *testing.T) {
+	account := randomAccount(util.USD)
+
+	testCases := []struct {
+		name        string
+		format      string
+		buildStubs  func(store *mockdb.MockStore)
+		checkOutput func(t *testing.T, out *bytes.Buffer, err error)
+	}{
+		{
+			name:   "Table",
+			format: formatTable,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			checkOutput: func(t *testing.T, out *bytes.Buffer, err error) {
+				require.NoError(t, err)
+				require.Contains(t, out.String(), "OWNER")
+				require.Contains(t, out.String(), account.Owner)
+			},
+		},
+		{
+			name:   "JSON",
+			format: formatJSON,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			checkOutput: func(t *testing.T, out *bytes.Buffer, err error) {
+				require.NoError(t, err)
+
+				var gotAccount db.Account
+				err = json.Unmarshal(out.Bytes(), &gotAccount)
+				require.NoError(t, err)
+				require.Equal(t, account, gotAccount)
+			},
+		},
+		{
+			name:   "NotFound",
+			format: formatTable,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkOutput: func(t *testing.T, out *bytes.Buffer, err error) {
+				require.ErrorContains(t, err, "not found")
+				require.Empty(t, out.String())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			c, out := newTestCLI(store, tc.format)
+			err := c.run(context.Background(), []string{"account", "show", "-id", fmt.Sprint(account.ID)})
+			tc.checkOutput(t, out, err)
+		})
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	amount := int64(10)
+	account1 := randomAccount(util.USD)
+	account2 := randomAccount(util.USD)
+	account3 := randomAccount(util.EUR)
+	frozenAccount := randomAccount(util.USD)
+	frozenAccount.Status = db.AccountStatusFrozen
+
+	testCases := []struct {
+		name        string
+		args        []string
+		buildStubs  func(store *mockdb.MockStore)
+		checkOutput func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			args: []string{"-from", fmt.Sprint(account1.ID), "-to", fmt.Sprint(account2.ID), "-amount", "10", "-currency", util.USD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+
+				arg := db.TransferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        amount,
+				}
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.TransferTxResult{
+					FromAccount: account1,
+					ToAccount:   account2,
+				}, nil)
+			},
+			checkOutput: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "CurrencyMismatch",
+			args: []string{"-from", fmt.Sprint(account1.ID), "-to", fmt.Sprint(account3.ID), "-amount", "10", "-currency", util.USD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account3.ID)).Times(1).Return(account3, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkOutput: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "currency mismatch")
+			},
+		},
+		{
+			name: "FrozenAccount",
+			args: []string{"-from", fmt.Sprint(frozenAccount.ID), "-to", fmt.Sprint(account2.ID), "-amount", "10", "-currency", util.USD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(frozenAccount.ID)).Times(1).Return(frozenAccount, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkOutput: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "frozen")
+			},
+		},
+		{
+			name: "InvalidAmount",
+			args: []string{"-from", fmt.Sprint(account1.ID), "-to", fmt.Sprint(account2.ID), "-amount", "-10", "-currency", util.USD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkOutput: func(t *testing.T, err error) {
+				require.Error(t, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			c, _ := newTestCLI(store, formatTable)
+			err := c.run(context.Background(), append([]string{"transfer"}, tc.args...))
+			tc.checkOutput(t, err)
+		})
+	}
+}
+
+func TestTransferImport(t *testing.T) {
+	account1 := randomAccount(util.USD)
+	account2 := randomAccount(util.USD)
+	account3 := randomAccount(util.EUR)
+
+	path := filepath.Join(t.TempDir(), "transfers.csv")
+	csv := fmt.Sprintf("from_account_id,to_account_id,amount,currency,reference\n%d,%d,10,USD,pay-1\n",
+		account1.ID, account2.ID)
+	require.NoError(t, os.WriteFile(path, []byte(csv), 0o600))
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetTransferByExternalReference(gomock.Any(), gomock.Any()).AnyTimes().Return(db.Transfer{}, sql.ErrNoRows)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).AnyTimes().Return(account1, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).AnyTimes().Return(account2, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account3.ID)).AnyTimes().Return(account3, nil)
+
+	// a dry run makes no transfer
+	c, out := newTestCLI(store, formatTable)
+	err := c.run(context.Background(), []string{"transfer", "import", "-file", path, "-dry-run"})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "valid")
+
+	arg := db.TransferTxParams{
+		FromAccountID:     account1.ID,
+		ToAccountID:       account2.ID,
+		Amount:            10,
+		ExternalReference: "pay-1",
+	}
+	store.EXPECT().TransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.TransferTxResult{Transfer: db.Transfer{ID: 5}}, nil)
+	c, out = newTestCLI(store, formatTable)
+	err = c.run(context.Background(), []string{"transfer", "import", "-file", path})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "transferred")
+
+	// an invalid row fails the command, after listing every row
+	csv = fmt.Sprintf("from_account_id,to_account_id,amount,currency,reference\n%d,%d,10,USD,pay-2\n",
+		account1.ID, account3.ID)
+	require.NoError(t, os.WriteFile(path, []byte(csv), 0o600))
+	c, out = newTestCLI(store, formatTable)
+	err = c.run(context.Background(), []string{"transfer", "import", "-file", path, "-dry-run"})
+	require.ErrorContains(t, err, "1 of 1 rows")
+	require.Contains(t, out.String(), "currency mismatch")
+}
+
+func TestLedgerReconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	mismatch := db.ReconcileLedgerRow{
+		ID:           1,
+		Owner:        util.RandomOwner(),
+		Currency:     util.USD,
+		Balance:      100,
+		EntriesTotal: 90,
+	}
+	store.EXPECT().ReconcileLedger(gomock.Any()).Times(1).Return([]db.ReconcileLedgerRow{mismatch}, nil)
+
+	c, out := newTestCLI(store, formatJSON)
+	err := c.run(context.Background(), []string{"ledger", "reconcile"})
+	require.NoError(t, err)
+
+	var mismatches []db.ReconcileLedgerRow
+	err = json.Unmarshal(out.Bytes(), &mismatches)
+	require.NoError(t, err)
+	require.Equal(t, []db.ReconcileLedgerRow{mismatch}, mismatches)
+}
+
+func TestLedgerVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	brk := db.LedgerBreak{AccountID: 1, EntryID: 3, Reason: "hash doesn't match the entry's contents"}
+	store.EXPECT().VerifyLedger(gomock.Any(), gomock.Eq(db.VerifyLedgerParams{})).Times(1).Return([]db.LedgerBreak{}, nil)
+	store.EXPECT().VerifyLedger(gomock.Any(), gomock.Eq(db.VerifyLedgerParams{AccountID: 1})).Times(1).Return([]db.LedgerBreak{brk}, nil)
+
+	c, _ := newTestCLI(store, formatTable)
+	err := c.run(context.Background(), []string{"ledger", "verify"})
+	require.NoError(t, err)
+
+	// a broken chain fails the command, after listing the break
+	c, out := newTestCLI(store, formatTable)
+	err = c.run(context.Background(), []string{"ledger", "verify", "-account-id", "1"})
+	require.Error(t, err)
+	require.Contains(t, out.String(), brk.Reason)
+}
+
+func TestCreateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomOwner()
+	password := "N3w-" + util.RandomString(8)
+
+	store := mockdb.NewMockStore(ctrl)
+	expectAuditedTx(t, store, db.AuditActionUserCreate).Times(1)
+	store.EXPECT().
+		CreateUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateUserParams) (db.User, error) {
+			require.Equal(t, username, arg.Username)
+			require.NoError(t, util.CheckPassword(password, arg.HashedPassword))
+			return db.User{Username: username, FullName: arg.FullName, Email: arg.Email}, nil
+		})
+
+	c, out := newTestCLI(store, formatJSON)
+	c.in = strings.NewReader(password + "\n")
+	err := c.run(context.Background(), []string{"user", "create", "-username", username, "-full-name", "Alice", "-email", "alice@example.com"})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), username)
+
+	// no password on stdin
+	c.in = strings.NewReader("")
+	err = c.run(context.Background(), []string{"user", "create", "-username", username, "-full-name", "Alice", "-email", "alice@example.com"})
+	require.ErrorContains(t, err, "stdin")
+}
+
+func TestResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomOwner()
+	password := "N3w-" + util.RandomString(8)
+
+	store := mockdb.NewMockStore(ctrl)
+	expectAuditedTx(t, store, db.AuditActionUserPasswordReset).Times(1)
+	store.EXPECT().
+		UpdateUserPassword(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.UpdateUserPasswordParams) (db.User, error) {
+			require.Equal(t, username, arg.Username)
+			require.NoError(t, util.CheckPassword(password, arg.HashedPassword))
+			return db.User{Username: username, HashedPassword: arg.HashedPassword}, nil
+		})
+	store.EXPECT().RevokeUserAPIKeys(gomock.Any(), gomock.Eq(username)).Times(1).Return(int64(1), nil)
+
+	c, out := newTestCLI(store, formatJSON)
+	c.passwordPolicy, _ = util.NewPasswordPolicy(util.Config{PasswordMinLength: 10})
+
+	c.in = strings.NewReader(password + "\n")
+	err := c.run(context.Background(), []string{"user", "reset-password", "-username", username})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), username)
+	require.NotContains(t, out.String(), "hashed_password")
+
+	// rejected by the policy before reaching the store
+	c.in = strings.NewReader("short\n")
+	err = c.run(context.Background(), []string{"user", "reset-password", "-username", username})
+	require.Error(t, err)
+
+	// the password is never taken from the flags
+	c.in = strings.NewReader("")
+	err = c.run(context.Background(), []string{"user", "reset-password", "-username", username, "-password", password})
+	require.Error(t, err)
+	c.in = strings.NewReader("")
+	err = c.run(context.Background(), []string{"user", "reset-password", "-username", username})
+	require.ErrorContains(t, err, "stdin")
+}
+
+func TestAccountFreeze(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	account := randomAccount(util.USD)
+	frozen := account
+	frozen.Status = db.AccountStatusFrozen
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+	expectAuditedTx(t, store, db.AuditActionAccountStatus).Times(1)
+	store.EXPECT().
+		UpdateAccountStatus(gomock.Any(), gomock.Eq(db.UpdateAccountStatusParams{ID: account.ID, Status: db.AccountStatusFrozen})).
+		Times(1).
+		Return(frozen, nil)
+
+	c, out := newTestCLI(store, formatJSON)
+	err := c.run(context.Background(), []string{"account", "freeze", "-id", fmt.Sprint(account.ID)})
+	require.NoError(t, err)
+
+	var got db.Account
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	require.Equal(t, db.AccountStatusFrozen, got.Status)
+}
+
+func TestSetRole(t *testing.T) {
+	user := db.User{Username: util.RandomOwner(), Role: db.UserRoleDepositor}
+	banker := user
+	banker.Role = db.UserRoleBanker
+
+	testCases := []struct {
+		name       string
+		args       []string
+		buildStubs func(store *mockdb.MockStore)
+		checkErr   func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			args: []string{"-username", user.Username, "-role", db.UserRoleBanker},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				expectAuditedTx(t, store, db.AuditActionUserRoleChange).Times(1)
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Eq(db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleBanker})).
+					Times(1).
+					Return(banker, nil)
+			},
+			checkErr: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "UserNotFound",
+			args: []string{"-username", user.Username, "-role", db.UserRoleBanker},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().AuditedTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkErr: func(t *testing.T, err error) {
+				require.EqualError(t, err, fmt.Sprintf("user %s not found", user.Username))
+			},
+		},
+		{
+			name: "UnsupportedRole",
+			args: []string{"-username", user.Username, "-role", "admin"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AuditedTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkErr: func(t *testing.T, err error) {
+				require.Error(t, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			c, _ := newTestCLI(store, formatJSON)
+			err := c.run(context.Background(), append([]string{"user", "set-role"}, tc.args...))
+			tc.checkErr(t, err)
+		})
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	c, _ := newTestCLI(nil, formatTable)
+
+	err := c.run(context.Background(), []string{"unknown"})
+	require.ErrorIs(t, err, errUsage)
+
+	c.format = "yaml"
+	err = c.run(context.Background(), []string{"ledger", "reconcile"})
+	require.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+)
+
+// Supported output formats of `-o`
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// print writes `v` as indented JSON, or `rows` as an aligned table under `header`.
+func (c *cli) print(v any, header []string, rows [][]string) error {
+	if c.format == formatJSON {
+		encoder := json.NewEncoder(c.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+var accountHeader = []string{"ID", "OWNER", "BALANCE", "CURRENCY", "STATUS", "CREATED AT"}
+
+func accountRow(account db.Account) []string {
+	return []string{
+		fmt.Sprint(account.ID),
+		account.Owner,
+		fmt.Sprint(account.Balance),
+		account.Currency,
+		account.Status,
+		account.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func (c *cli) printAccount(account db.Account) error {
+	return c.print(account, accountHeader, [][]string{accountRow(account)})
+}
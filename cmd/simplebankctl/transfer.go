@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/transferimport"
+)
+
+// transfer moves money between any 2 accounts, e.g. to correct a failed payment during an incident.
+// It applies the same checks as the `POST /transfers` API, except the ownership of the from account and its approval threshold.
+func (c *cli) transfer(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "import" {
+		return c.importTransfers(ctx, args[1:])
+	}
+
+	flags := newFlagSet("transfer")
+	fromAccountID := flags.Int64("from", 0, "ID of the account to send money from")
+	toAccountID := flags.Int64("to", 0, "ID of the account to send money to")
+	amount := flags.Int64("amount", 0, "amount to transfer, must be positive")
+	currency := flags.String("currency", "", "currency of both accounts, e.g. USD")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *amount <= 0 {
+		return errors.New("-amount must be positive")
+	}
+	if *fromAccountID == *toAccountID {
+		return errors.New("-from and -to must be different accounts")
+	}
+
+	if err := c.validAccount(ctx, *fromAccountID, *currency); err != nil {
+		return err
+	}
+	if err := c.validAccount(ctx, *toAccountID, *currency); err != nil {
+		return err
+	}
+
+	result, err := c.store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: *fromAccountID,
+		ToAccountID:   *toAccountID,
+		Amount:        *amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.print(result, accountHeader, [][]string{
+		accountRow(result.FromAccount),
+		accountRow(result.ToAccount),
+	})
+}
+
+// importTransfers makes the transfers of a CSV file, with the same checks as `transfer`.
+// Rows whose reference was already imported are skipped, so a file can be imported again after a failure.
+// It fails if any row is invalid or failed, after printing the result of every row.
+func (c *cli) importTransfers(ctx context.Context, args []string) error {
+	flags := newFlagSet("transfer import")
+	path := flags.String("file", "", "path of the CSV file, - for stdin")
+	dryRun := flags.Bool("dry-run", false, "only check the rows, don't make any transfer")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("-file is required")
+	}
+
+	var file io.Reader = os.Stdin
+	if *path != "-" {
+		f, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
+	}
+	rows, err := transferimport.Parse(file)
+	if err != nil {
+		return fmt.Errorf("invalid csv: %w", err)
+	}
+
+	importer := transferimport.Importer{
+		Store: c.store,
+		Check: func(ctx context.Context, row transferimport.Row) error {
+			if err := c.validAccount(ctx, row.FromAccountID, row.Currency); err != nil {
+				return err
+			}
+			return c.validAccount(ctx, row.ToAccountID, row.Currency)
+		},
+	}
+	results, err := importer.Import(ctx, rows, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	tableRows := make([][]string, 0, len(results))
+	for _, result := range results {
+		transferID := ""
+		if result.Transfer != nil {
+			transferID = fmt.Sprint(result.Transfer.ID)
+		}
+		tableRows = append(tableRows, []string{fmt.Sprint(result.Line), result.Reference, result.Status, transferID, result.Error})
+	}
+	err = c.print(results, []string{"LINE", "REFERENCE", "STATUS", "TRANSFER", "ERROR"}, tableRows)
+	if err != nil {
+		return err
+	}
+
+	summary := transferimport.Summary(results)
+	if failed := summary[transferimport.StatusInvalid] + summary[transferimport.StatusFailed]; failed > 0 {
+		return fmt.Errorf("%d of %d rows are invalid or failed", failed, len(results))
+	}
+	return nil
+}
+
+// validAccount mirrors `Server.validAccount` of the API.
+func (c *cli) validAccount(ctx context.Context, accountID int64, currency string) error {
+	account, err := c.getAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if account.Currency != currency {
+		return fmt.Errorf("account [%d] currency mismatch: %s vs %s", accountID, account.Currency, currency)
+	}
+	if account.Status == db.AccountStatusFrozen {
+		return fmt.Errorf("account [%d] is frozen", accountID)
+	}
+	return nil
+}
+
+func (c *cli) getAccount(ctx context.Context, accountID int64) (db.Account, error) {
+	account, err := c.store.GetAccount(ctx, accountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return account, fmt.Errorf("account [%d] not found", accountID)
+	}
+	return account, err
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+)
+
+func (c *cli) runUser(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return c.createUser(ctx, args[1:])
+	case "reset-password":
+		return c.resetPassword(ctx, args[1:])
+	case "set-role":
+		return c.setUserRole(ctx, args[1:])
+	}
+	return errUsage
+}
+
+// userOutput leaves out the hashed password
+type userOutput struct {
+	Username  string    `json:"username"`
+	FullName  string    `json:"full_name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createUser creates a user, with the initial password read from the first line of stdin.
+func (c *cli) createUser(ctx context.Context, args []string) error {
+	flags := newFlagSet("user create")
+	username := flags.String("username", "", "username (alphanumeric)")
+	fullName := flags.String("full-name", "", "full name")
+	email := flags.String("email", "", "email address")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" || *fullName == "" || *email == "" {
+		return errors.New("-username, -full-name and -email are required")
+	}
+	password, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := c.hashPassword(password, *username)
+	if err != nil {
+		return err
+	}
+
+	var user db.User
+	err = c.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		user, err = q.CreateUser(ctx, db.CreateUserParams{
+			Username:       *username,
+			HashedPassword: hashedPassword,
+			FullName:       *fullName,
+			Email:          *email,
+		})
+		return db.AuditChange{
+			Action: db.AuditActionUserCreate,
+			Target: "user:" + *username,
+			After:  newUserOutput(user),
+		}, err
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.printUser(user)
+}
+
+// resetPassword sets a new password, e.g. for a user locked out of their account.
+// The password is the first line of stdin, e.g. piped from a secret manager.
+func (c *cli) resetPassword(ctx context.Context, args []string) error {
+	flags := newFlagSet("user reset-password")
+	username := flags.String("username", "", "username")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+	password, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := c.hashPassword(password, *username)
+	if err != nil {
+		return err
+	}
+
+	var user db.User
+	err = c.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		user, err = q.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+			Username:       *username,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return db.AuditChange{}, err
+		}
+		// the access tokens issued before stop working too, see `password_changed_at`
+		_, err = q.RevokeUserAPIKeys(ctx, *username)
+		return db.AuditChange{
+			Action: db.AuditActionUserPasswordReset,
+			Target: "user:" + *username,
+			After:  newUserOutput(user), // the hash is left out of the log too
+		}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user %s not found", *username)
+		}
+		return err
+	}
+	return c.printUser(user)
+}
+
+// setUserRole grants or revokes a role, e.g. `banker` to query the audit log.
+func (c *cli) setUserRole(ctx context.Context, args []string) error {
+	flags := newFlagSet("user set-role")
+	username := flags.String("username", "", "username")
+	role := flags.String("role", "", "role: depositor or banker")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+	if *role != db.UserRoleDepositor && *role != db.UserRoleBanker {
+		return fmt.Errorf("unsupported role: %q", *role)
+	}
+
+	before, err := c.store.GetUser(ctx, *username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user %s not found", *username)
+		}
+		return err
+	}
+
+	var user db.User
+	err = c.store.AuditedTx(ctx, func(ctx context.Context, q db.Querier) (db.AuditChange, error) {
+		var err error
+		user, err = q.UpdateUserRole(ctx, db.UpdateUserRoleParams{
+			Username: *username,
+			Role:     *role,
+		})
+		return db.AuditChange{
+			Action: db.AuditActionUserRoleChange,
+			Target: "user:" + *username,
+			Before: newUserOutput(before),
+			After:  newUserOutput(user),
+		}, err
+	})
+	if err != nil {
+		return err
+	}
+	return c.printUser(user)
+}
+
+// readPassword reads a password from the first line of `c.in`.
+func (c *cli) readPassword() (string, error) {
+	line, err := bufio.NewReader(c.in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("cannot read the password from stdin: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("the password must be given on stdin")
+	}
+	return password, nil
+}
+
+// hashPassword checks the password policy, then hashes the password.
+func (c *cli) hashPassword(password string, username string) (string, error) {
+	if err := c.passwordPolicy.Validate(password, username); err != nil {
+		return "", err
+	}
+	return c.passwordHasher.Hash(password)
+}
+
+func newUserOutput(user db.User) userOutput {
+	return userOutput{
+		Username:  user.Username,
+		FullName:  user.FullName,
+		Email:     user.Email,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func (c *cli) printUser(user db.User) error {
+	return c.print(newUserOutput(user),
+		[]string{"USERNAME", "FULL NAME", "EMAIL", "ROLE", "CREATED AT"},
+		[][]string{{user.Username, user.FullName, user.Email, user.Role, user.CreatedAt.Format(time.RFC3339)}},
+	)
+}
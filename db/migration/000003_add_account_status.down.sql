@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_status_check";
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "status";
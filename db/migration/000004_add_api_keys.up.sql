@@ -0,0 +1,20 @@
+-- personal API keys for server-to-server integrations,
+-- only a SHA-256 hash of the key is stored, the prefix is used to look it up
+CREATE TABLE "api_keys" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "prefix" varchar UNIQUE NOT NULL,
+  "hashed_key" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL DEFAULT '{}',
+  "expires_at" timestamptz,
+  "last_used_at" timestamptz,
+  "revoked_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "api_keys" ("owner");
+
+ALTER TABLE "api_keys" ADD CONSTRAINT "owner_name_key" UNIQUE ("owner", "name");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
@@ -0,0 +1,25 @@
+-- TOTP two-factor authentication, the secret is encrypted by the server (AES-GCM) before it's stored
+CREATE TABLE "totp_secrets" (
+  "username" varchar PRIMARY KEY,
+  "encrypted_secret" bytea NOT NULL,
+  -- NULL until the user proves the authenticator works by sending a first code
+  "confirmed_at" timestamptz,
+  -- the last accepted time step, a code can only be used once
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "totp_secrets" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+-- single-use codes to log in when the authenticator is lost, only a SHA-256 hash is stored
+CREATE TABLE "totp_recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "hashed_code" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "totp_recovery_codes" ("username");
+
+ALTER TABLE "totp_recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
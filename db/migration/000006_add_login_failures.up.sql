@@ -0,0 +1,9 @@
+-- failed logins, counted per username ("user:<username>") and per client IP ("ip:<ip>"),
+-- unknown usernames are tracked too, so a lockout doesn't reveal which users exist
+CREATE TABLE "login_failures" (
+  "key" varchar PRIMARY KEY,
+  -- failures since the window started, reset by a lockout or a successful login
+  "failures" int NOT NULL DEFAULT 0,
+  "last_failed_at" timestamptz NOT NULL DEFAULT (now()),
+  "locked_until" timestamptz
+);
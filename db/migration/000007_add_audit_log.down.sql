@@ -0,0 +1,11 @@
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_role_check";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "role";
+
+DROP TRIGGER IF EXISTS "audit_events_no_truncate" ON "audit_events";
+
+DROP TRIGGER IF EXISTS "audit_events_append_only" ON "audit_events";
+
+DROP TABLE IF EXISTS "audit_events";
+
+DROP FUNCTION IF EXISTS reject_audit_event_change();
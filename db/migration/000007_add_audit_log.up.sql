@@ -0,0 +1,41 @@
+-- security-relevant events, e.g. login lockouts: who did what from where,
+-- with the state before & after the change (JSON null if there's none)
+CREATE TABLE "audit_events" (
+  "id" bigserial PRIMARY KEY,
+  -- username, or "anonymous" before authentication
+  "actor" varchar NOT NULL,
+  "action" varchar NOT NULL,
+  "target" varchar NOT NULL,
+  "ip" varchar NOT NULL,
+  "user_agent" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "request_id" varchar NOT NULL DEFAULT '',
+  "before" jsonb NOT NULL DEFAULT 'null'::jsonb,
+  "after" jsonb NOT NULL DEFAULT 'null'::jsonb
+);
+
+CREATE INDEX ON "audit_events" ("actor");
+
+CREATE INDEX ON "audit_events" ("target");
+
+CREATE INDEX ON "audit_events" ("created_at");
+
+-- the audit log is append-only: events can be inserted, never modified nor removed
+CREATE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_events_append_only"
+  BEFORE UPDATE OR DELETE ON "audit_events"
+  FOR EACH ROW EXECUTE FUNCTION reject_audit_event_change();
+
+CREATE TRIGGER "audit_events_no_truncate"
+  BEFORE TRUNCATE ON "audit_events"
+  FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_event_change();
+
+-- bankers are the employees allowed to read the audit log
+ALTER TABLE "users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'depositor';
+
+ALTER TABLE "users" ADD CONSTRAINT "users_role_check" CHECK ("role" IN ('depositor', 'banker'));
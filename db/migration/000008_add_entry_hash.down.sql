@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "entries_account_id_id_idx";
+
+ALTER TABLE "entries"
+  DROP COLUMN IF EXISTS "hash",
+  DROP COLUMN IF EXISTS "prev_hash";
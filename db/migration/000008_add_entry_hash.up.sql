@@ -0,0 +1,40 @@
+-- each entry is chained to the previous entry of its account:
+-- hash = sha256("<account_id>|<amount>|<created_at in unix microseconds>|<hex of prev_hash>")
+-- prev_hash is NULL for the first entry of an account
+ALTER TABLE "entries"
+  ADD COLUMN "prev_hash" bytea,
+  ADD COLUMN "hash" bytea;
+
+-- chain the existing entries, the same way the store does for new ones (see `db.EntryHash`)
+DO $$
+DECLARE
+  e record;
+  prev bytea;
+  last_account_id bigint;
+BEGIN
+  FOR e IN SELECT * FROM "entries" ORDER BY "account_id", "id" LOOP
+    IF last_account_id IS DISTINCT FROM e.account_id THEN
+      prev := NULL;
+      last_account_id := e.account_id;
+    END IF;
+
+    UPDATE "entries" SET
+      "prev_hash" = prev,
+      "hash" = sha256(convert_to(format('%s|%s|%s|%s',
+        e.account_id,
+        e.amount,
+        -- exact, unlike `extract(epoch ...)` which is a double precision
+        extract(epoch FROM date_trunc('second', e.created_at))::bigint * 1000000
+          + extract(microseconds FROM e.created_at)::bigint % 1000000,
+        coalesce(encode(prev, 'hex'), '')
+      ), 'UTF8'))
+    WHERE "id" = e.id
+    RETURNING "hash" INTO prev;
+  END LOOP;
+END;
+$$;
+
+ALTER TABLE "entries" ALTER COLUMN "hash" SET NOT NULL;
+
+-- to find the last entry of an account, and walk its chain
+CREATE INDEX ON "entries" ("account_id", "id");
@@ -0,0 +1,56 @@
+-- events written in the same transaction as the change they describe (the outbox pattern),
+-- then fanned out to the webhooks of `owner` by the dispatcher
+CREATE TABLE "outbox_events" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "event_type" varchar NOT NULL,
+  "payload" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "dispatched_at" timestamptz
+);
+
+-- the events left to fan out
+CREATE INDEX ON "outbox_events" ("id") WHERE "dispatched_at" IS NULL;
+
+-- URLs registered by users to receive the events of their accounts,
+-- `secret` signs the deliveries so the receiver can check they come from us
+CREATE TABLE "webhooks" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "url" varchar NOT NULL,
+  "secret" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "webhooks" ("owner");
+
+ALTER TABLE "webhooks" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+-- one delivery per event & webhook, retried with backoff until it succeeds or runs out of attempts
+CREATE TABLE "webhook_deliveries" (
+  "id" bigserial PRIMARY KEY,
+  "webhook_id" bigint NOT NULL,
+  "event_id" bigint NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "attempts" int NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+  "last_response_code" int,
+  "last_error" varchar,
+  "delivered_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "webhook_deliveries" ("webhook_id", "id");
+
+-- the deliveries due
+CREATE INDEX ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+
+ALTER TABLE "webhook_deliveries" ADD CONSTRAINT "webhook_deliveries_status_check"
+  CHECK ("status" IN ('pending', 'succeeded', 'failed'));
+
+ALTER TABLE "webhook_deliveries" ADD CONSTRAINT "webhook_event_key" UNIQUE ("webhook_id", "event_id");
+
+-- the delivery log goes away with its webhook
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("webhook_id") REFERENCES "webhooks" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("event_id") REFERENCES "outbox_events" ("id");
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "statements";
+
+DROP FUNCTION IF EXISTS reject_statement_change();
+
+DROP INDEX IF EXISTS "entries_account_id_created_at_idx";
+
+ALTER TABLE "entries" DROP COLUMN IF EXISTS "transfer_id";
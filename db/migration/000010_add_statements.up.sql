@@ -0,0 +1,42 @@
+-- the transfer an entry belongs to, for its counterparty; NULL for entries made without a transfer
+-- (not covered by the entry hash, which predates it)
+ALTER TABLE "entries" ADD COLUMN "transfer_id" bigint;
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+-- the entries of a transfer used to be created in its transaction, with the same `now()`
+UPDATE "entries" e SET "transfer_id" = t.id
+FROM "transfers" t
+WHERE e.created_at = t.created_at AND (
+  (e.account_id = t.from_account_id AND e.amount = -t.amount) OR
+  (e.account_id = t.to_account_id AND e.amount = t.amount)
+);
+
+-- to list the entries of an account by month
+CREATE INDEX ON "entries" ("account_id", "created_at");
+
+-- monthly statements, cached once the month is closed: the same statement is always downloaded
+CREATE TABLE "statements" (
+  "account_id" bigint NOT NULL,
+  "month" date NOT NULL, -- 1st day of the month
+  "opening_balance" bigint NOT NULL,
+  "closing_balance" bigint NOT NULL,
+  "total_credits" bigint NOT NULL,
+  "total_debits" bigint NOT NULL,
+  "csv" bytea NOT NULL,
+  "pdf" bytea NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "month")
+);
+
+ALTER TABLE "statements" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+CREATE FUNCTION reject_statement_change() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'statements are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "statements_immutable"
+  BEFORE UPDATE ON "statements"
+  FOR EACH ROW EXECUTE FUNCTION reject_statement_change();
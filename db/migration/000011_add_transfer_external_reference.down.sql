@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "transfers_from_account_id_external_reference_key";
+
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "external_reference";
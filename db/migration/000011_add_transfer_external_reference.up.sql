@@ -0,0 +1,7 @@
+-- the reference of the payment instruction a transfer was imported from, '' if it wasn't
+ALTER TABLE "transfers" ADD COLUMN "external_reference" varchar NOT NULL DEFAULT '';
+
+-- importing the same instruction twice must not pay it twice
+CREATE UNIQUE INDEX "transfers_from_account_id_external_reference_key"
+  ON "transfers" ("from_account_id", "external_reference")
+  WHERE "external_reference" <> '';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "transfers_description_search_idx";
+
+ALTER TABLE "entries" DROP COLUMN IF EXISTS "description";
+
+ALTER TABLE "transfers"
+  DROP COLUMN IF EXISTS "metadata",
+  DROP COLUMN IF EXISTS "description";
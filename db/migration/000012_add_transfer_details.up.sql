@@ -0,0 +1,10 @@
+-- what a transfer is for, copied onto its entries
+ALTER TABLE "transfers" ADD COLUMN "description" varchar NOT NULL DEFAULT '';
+
+-- key-value pairs of the client, e.g. its own invoice ID
+ALTER TABLE "transfers" ADD COLUMN "metadata" jsonb NOT NULL DEFAULT '{}';
+
+ALTER TABLE "entries" ADD COLUMN "description" varchar NOT NULL DEFAULT '';
+
+-- full-text search of the transfer history, the queries must use the same expression
+CREATE INDEX "transfers_description_search_idx" ON "transfers" USING GIN (to_tsvector('english', "description"));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "holds";
+
+ALTER TABLE "accounts"
+  DROP COLUMN IF EXISTS "available_balance",
+  DROP COLUMN IF EXISTS "held_amount";
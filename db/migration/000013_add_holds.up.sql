@@ -0,0 +1,35 @@
+-- funds reserved by active holds: they can't be held again, see `available_balance`
+ALTER TABLE "accounts" ADD COLUMN "held_amount" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "accounts" ADD COLUMN "available_balance" bigint NOT NULL GENERATED ALWAYS AS ("balance" - "held_amount") STORED;
+
+-- funds reserved on an account for a later transfer to `to_account_id` (authorize),
+-- until the transfer is made (capture), the hold is released (void), or it expires
+CREATE TABLE "holds" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "description" varchar NOT NULL DEFAULT '',
+  "status" varchar NOT NULL DEFAULT 'active',
+  "expires_at" timestamptz NOT NULL,
+  "captured_amount" bigint NOT NULL DEFAULT 0,
+  "transfer_id" bigint,
+  "released_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "holds" ADD CONSTRAINT "holds_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "holds" ADD CONSTRAINT "holds_status_check" CHECK ("status" IN ('active', 'captured', 'voided', 'expired'));
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+CREATE INDEX ON "holds" ("account_id");
+
+-- the holds left for the sweeper to expire
+CREATE INDEX ON "holds" ("expires_at") WHERE "status" = 'active';
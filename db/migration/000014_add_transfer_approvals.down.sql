@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "transfer_approvals";
+
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "status";
+
+DROP TABLE IF EXISTS "approval_policy_changes";
+
+DROP TABLE IF EXISTS "account_approvers";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "approval_threshold";
@@ -0,0 +1,57 @@
+-- transfers above the threshold of their from account wait for the approval of one of its approvers, 0 disables it
+ALTER TABLE "accounts" ADD COLUMN "approval_threshold" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_approval_threshold_check" CHECK ("approval_threshold" >= 0);
+
+CREATE TABLE "account_approvers" (
+  "account_id" bigint NOT NULL,
+  "username" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "username")
+);
+
+ALTER TABLE "account_approvers" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "account_approvers" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE INDEX ON "account_approvers" ("username");
+
+-- a change to an active approval policy, waiting for the approval of one of the current approvers:
+-- the owner requesting transfers can't weaken the policy alone. One per account, a new one replaces it
+CREATE TABLE "approval_policy_changes" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint UNIQUE NOT NULL,
+  "threshold" bigint NOT NULL,
+  "approvers" varchar[] NOT NULL,
+  "requested_by" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "approval_policy_changes" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "approval_policy_changes" ADD FOREIGN KEY ("requested_by") REFERENCES "users" ("username");
+
+-- a pending transfer moves no money until it's approved, it has no entries before then
+ALTER TABLE "transfers" ADD COLUMN "status" varchar NOT NULL DEFAULT 'completed';
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_status_check" CHECK ("status" IN ('completed', 'pending_approval', 'rejected', 'expired'));
+
+-- the approval of a transfer, requested when it was created above the threshold of its from account
+CREATE TABLE "transfer_approvals" (
+  "transfer_id" bigint PRIMARY KEY,
+  "requested_by" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "decided_by" varchar,
+  "decided_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "transfer_approvals" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "transfer_approvals" ADD FOREIGN KEY ("requested_by") REFERENCES "users" ("username");
+
+ALTER TABLE "transfer_approvals" ADD FOREIGN KEY ("decided_by") REFERENCES "users" ("username");
+
+-- the pending transfers left for the sweeper to expire
+CREATE INDEX ON "transfer_approvals" ("expires_at") WHERE "decided_at" IS NULL;
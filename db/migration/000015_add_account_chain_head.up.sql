@@ -0,0 +1,10 @@
+-- the hash of the last entry of each account, the head of its chain (see `db.EntryHash`):
+-- the chain must end there, so removing the last entries of an account breaks it too
+ALTER TABLE "accounts" ADD COLUMN "last_entry_hash" bytea;
+
+UPDATE "accounts" a SET "last_entry_hash" = (
+  SELECT e."hash" FROM "entries" e
+  WHERE e."account_id" = a."id"
+  ORDER BY e."id" DESC
+  LIMIT 1
+);
@@ -0,0 +1,3 @@
+-- the version of the hash of each entry (see `db.EntryHashV2`): version 2 covers the transfer & description too,
+-- the entries hashed before keep version 1, so their chains still verify
+ALTER TABLE "entries" ADD COLUMN "hash_version" smallint NOT NULL DEFAULT 1;
@@ -0,0 +1,126 @@
+// Package migration embeds the SQL schema migrations into the binary
+// and applies them with golang-migrate, so no external `migrate` CLI is needed.
+package migration
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres" // register the `postgresql://` database driver
+	_ "github.com/golang-migrate/migrate/v4/source/file"       // register the `file://` source driver
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// WHY embed here? `//go:embed` cannot reach parent directories, so it must live next to the .sql files.
+//
+//go:embed *.sql
+var migrationFS embed.FS
+
+// ErrDirty is returned when the last migration failed halfway.
+// The schema must be fixed by hand, then forced to a clean version.
+var ErrDirty = errors.New("database is in a dirty migration state")
+
+// Migrator applies schema migrations to a database.
+type Migrator struct {
+	migrate *migrate.Migrate
+}
+
+// New creates a Migrator for the database at `dbSource`.
+// Migrations are read from `migrationURL` (e.g. "file://db/migration"),
+// or from the ones embedded in the binary if `migrationURL` is empty.
+func New(migrationURL string, dbSource string) (*Migrator, error) {
+	var (
+		m   *migrate.Migrate
+		err error
+	)
+	if migrationURL == "" {
+		source, sourceErr := iofs.New(migrationFS, ".")
+		if sourceErr != nil {
+			return nil, fmt.Errorf("cannot read embedded migrations: %w", sourceErr)
+		}
+		m, err = migrate.NewWithSourceInstance("iofs", source, dbSource)
+	} else {
+		m, err = migrate.New(migrationURL, dbSource)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create migrator: %w", err)
+	}
+	return &Migrator{migrate: m}, nil
+}
+
+// Up applies all pending migrations. Being already up to date is not an error.
+func (migrator *Migrator) Up() error {
+	if err := migrator.checkDirty(); err != nil {
+		return err
+	}
+	return ignoreNoChange(migrator.migrate.Up())
+}
+
+// Steps applies `n` up migrations, or reverts `-n` migrations if `n` is negative.
+func (migrator *Migrator) Steps(n int) error {
+	if err := migrator.checkDirty(); err != nil {
+		return err
+	}
+	return ignoreNoChange(migrator.migrate.Steps(n))
+}
+
+// Version returns the currently applied version, 0 if no migration has been applied yet.
+func (migrator *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = migrator.migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return
+}
+
+// Close releases the source & database connections of the migrator.
+func (migrator *Migrator) Close() error {
+	sourceErr, dbErr := migrator.migrate.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+func (migrator *Migrator) checkDirty() error {
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d", ErrDirty, version)
+	}
+	return nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+// LatestVersion returns the highest migration version embedded in the binary,
+// i.e. the schema version the queries of this build expect.
+func LatestVersion() (uint, error) {
+	source, err := iofs.New(migrationFS, ".")
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
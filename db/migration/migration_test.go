@@ -0,0 +1,18 @@
+package migration
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestVersion(t *testing.T) {
+	files, err := fs.Glob(migrationFS, "*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	version, err := LatestVersion()
+	require.NoError(t, err)
+	require.Equal(t, uint(len(files)), version) // versions are numbered 1, 2, 3, ...
+}
@@ -37,4 +37,57 @@ RETURNING *;
 
 -- name: DeleteAccount :exec
 DELETE FROM accounts
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: UpdateAccountLastEntryHash :exec
+-- the head of the chain of entries of the account, see `createChainedEntry`
+UPDATE accounts
+  set last_entry_hash = sqlc.arg(last_entry_hash)
+WHERE id = sqlc.arg(id);
+
+-- name: UpdateAccountStatus :one
+UPDATE accounts
+  set status = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ReconcileLedger :many
+-- every account whose balance doesn't equal the sum of its entries
+SELECT
+  a.id,
+  a.owner,
+  a.currency,
+  a.balance,
+  COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;
+
+-- name: ListAccountIDs :many
+-- every account, a page at a time
+SELECT id FROM accounts
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: LockAccounts :many
+-- locks the accounts in ID order, so transactions locking overlapping sets of accounts can't deadlock;
+-- missing IDs are left out
+SELECT * FROM accounts
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+ORDER BY id
+FOR NO KEY UPDATE;
+
+-- name: AddAccountHeldAmount :one
+UPDATE accounts
+  set held_amount = held_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateAccountApprovalThreshold :one
+UPDATE accounts
+  set approval_threshold = $2
+WHERE id = $1
+RETURNING *;
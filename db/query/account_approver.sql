@@ -0,0 +1,53 @@
+-- name: CreateAccountApprover :one
+INSERT INTO account_approvers (
+  account_id,
+  username
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: GetAccountApprover :one
+SELECT * FROM account_approvers
+WHERE account_id = $1 AND username = $2 LIMIT 1;
+
+-- name: ListAccountApprovers :many
+SELECT * FROM account_approvers
+WHERE account_id = $1
+ORDER BY username;
+
+-- name: DeleteAccountApprovers :exec
+DELETE FROM account_approvers
+WHERE account_id = $1;
+
+-- name: CreateApprovalPolicyChange :one
+INSERT INTO approval_policy_changes (
+  account_id,
+  threshold,
+  approvers,
+  requested_by,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetApprovalPolicyChangeForUpdate :one
+SELECT * FROM approval_policy_changes
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: DeleteApprovalPolicyChange :exec
+-- the pending change of an account, replaced or applied
+DELETE FROM approval_policy_changes
+WHERE account_id = $1;
+
+-- name: ListPendingApprovalPolicyChanges :many
+-- the changes `username` can approve, oldest first: not expired, and not requested by `username`
+SELECT c.* FROM approval_policy_changes c
+JOIN account_approvers a ON a.account_id = c.account_id
+WHERE
+    a.username = sqlc.arg(username) AND
+    c.expires_at > now() AND
+    c.requested_by <> sqlc.arg(username)
+ORDER BY c.id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
@@ -0,0 +1,40 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  owner,
+  name,
+  prefix,
+  hashed_key,
+  scopes,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT * FROM api_keys
+WHERE prefix = $1 LIMIT 1;
+
+-- name: ListAPIKeys :many
+SELECT * FROM api_keys
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: RevokeAPIKey :one
+-- only the owner can revoke a key, revoking twice keeps the first timestamp
+UPDATE api_keys
+  set revoked_at = COALESCE(revoked_at, now())
+WHERE id = $1 AND owner = $2
+RETURNING *;
+
+-- name: UpdateAPIKeyLastUsed :exec
+UPDATE api_keys
+  set last_used_at = now()
+WHERE id = $1;
+
+-- name: RevokeUserAPIKeys :execrows
+-- all the keys of a user whose password changed, the ones already revoked keep their timestamp
+UPDATE api_keys
+  set revoked_at = now()
+WHERE owner = $1 AND revoked_at IS NULL;
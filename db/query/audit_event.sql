@@ -0,0 +1,24 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+  actor,
+  action,
+  target,
+  request_id,
+  ip,
+  user_agent,
+  before,
+  after
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: ListAuditEvents :many
+-- every filter is optional
+SELECT * FROM audit_events
+WHERE (sqlc.narg(actor)::varchar IS NULL OR actor = sqlc.narg(actor))
+  AND (sqlc.narg(target)::varchar IS NULL OR target = sqlc.narg(target))
+  AND (sqlc.narg(from_time)::timestamptz IS NULL OR created_at >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamptz IS NULL OR created_at < sqlc.narg(to_time))
+ORDER BY id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
@@ -1,9 +1,16 @@
 -- name: CreateEntry :one
+-- use `createChainedEntry` rather than this query, it computes the hashes
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  prev_hash,
+  hash,
+  created_at,
+  transfer_id,
+  description,
+  hash_version
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: GetEntry :one
@@ -15,4 +22,42 @@ SELECT * FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2
-OFFSET $3;
\ No newline at end of file
+OFFSET $3;
+
+-- name: GetLastEntry :one
+SELECT * FROM entries
+WHERE account_id = $1
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ListEntriesAfter :many
+-- a page of the chain of an account, for its verification
+SELECT * FROM entries
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: GetAccountBalanceAt :one
+-- the balance of an account right before `at`, from its entries
+SELECT COALESCE(SUM(amount), 0)::bigint FROM entries
+WHERE account_id = $1 AND created_at < sqlc.arg(at);
+
+-- name: ListStatementEntries :many
+-- the entries of an account in [from_time, to_time), with the other account of their transfer
+SELECT
+  e.id,
+  e.amount,
+  e.created_at,
+  e.transfer_id,
+  e.description,
+  COALESCE(a.id, 0)::bigint AS counterparty_account_id,
+  COALESCE(a.owner, '')::varchar AS counterparty_owner
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+LEFT JOIN accounts a ON a.id = CASE WHEN t.from_account_id = e.account_id THEN t.to_account_id ELSE t.from_account_id END
+WHERE e.account_id = sqlc.arg(account_id) AND e.created_at >= sqlc.arg(from_time) AND e.created_at < sqlc.arg(to_time)
+ORDER BY e.id;
+
+-- name: NotifyAccountEvent :exec
+-- delivered to the listeners of `channel` once the transaction commits
+SELECT pg_notify(sqlc.arg(channel)::text, sqlc.arg(payload)::text);
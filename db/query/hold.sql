@@ -0,0 +1,38 @@
+-- name: CreateHold :one
+INSERT INTO holds (
+  account_id,
+  to_account_id,
+  amount,
+  description,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetHold :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: ReleaseHold :one
+-- `captured_amount` & `transfer_id` are only set by a capture
+UPDATE holds
+SET
+  status = sqlc.arg(status),
+  captured_amount = sqlc.arg(captured_amount),
+  transfer_id = sqlc.narg(transfer_id),
+  released_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ClaimExpiredHolds :many
+-- holds being released by another transaction are skipped, they're locked until it ends
+SELECT * FROM holds
+WHERE status = 'active' AND expires_at <= now()
+ORDER BY expires_at
+LIMIT sqlc.arg('limit')
+FOR UPDATE SKIP LOCKED;
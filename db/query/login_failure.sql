@@ -0,0 +1,30 @@
+-- name: GetLoginFailure :one
+SELECT * FROM login_failures
+WHERE key = $1 LIMIT 1;
+
+-- name: RecordLoginFailure :one
+-- counting starts over if the last failure is older than `window_start`
+INSERT INTO login_failures (
+  key,
+  failures
+) VALUES (
+  sqlc.arg(key), 1
+)
+ON CONFLICT (key) DO UPDATE
+  set failures = CASE
+        WHEN login_failures.last_failed_at < sqlc.arg(window_start) THEN 1
+        ELSE login_failures.failures + 1
+      END,
+      last_failed_at = now()
+RETURNING *;
+
+-- name: LockLogin :one
+UPDATE login_failures
+  set locked_until = sqlc.arg(locked_until)::timestamptz,
+      failures = 0
+WHERE key = sqlc.arg(key)
+RETURNING *;
+
+-- name: ResetLoginFailures :exec
+DELETE FROM login_failures
+WHERE key = $1;
@@ -0,0 +1,21 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (
+  owner,
+  event_type,
+  payload
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+-- the oldest events left to fan out, skipping those another dispatcher is fanning out
+SELECT * FROM outbox_events
+WHERE dispatched_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventDispatched :exec
+UPDATE outbox_events
+  set dispatched_at = now()
+WHERE id = $1;
@@ -0,0 +1,18 @@
+-- name: CreateStatement :exec
+-- a statement generated concurrently is kept, they're the same
+INSERT INTO statements (
+  account_id,
+  month,
+  opening_balance,
+  closing_balance,
+  total_credits,
+  total_debits,
+  csv,
+  pdf
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+) ON CONFLICT (account_id, month) DO NOTHING;
+
+-- name: GetStatement :one
+SELECT * FROM statements
+WHERE account_id = $1 AND month = $2 LIMIT 1;
@@ -0,0 +1,48 @@
+-- name: CreateTOTPSecret :one
+-- (re-)start an enrollment, unless TOTP is already enabled: then no row is returned
+INSERT INTO totp_secrets (
+  username,
+  encrypted_secret
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (username) DO UPDATE
+  set encrypted_secret = EXCLUDED.encrypted_secret,
+      last_used_step = 0,
+      created_at = now()
+  WHERE totp_secrets.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetTOTPSecret :one
+SELECT * FROM totp_secrets
+WHERE username = $1 LIMIT 1;
+
+-- name: ConfirmTOTPSecret :one
+UPDATE totp_secrets
+  set confirmed_at = now()
+WHERE username = $1 AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseTOTPStep :execrows
+-- 0 rows if the step (or a later one) has already been used, i.e. the code is replayed
+UPDATE totp_secrets
+  set last_used_step = sqlc.arg(step)
+WHERE username = sqlc.arg(username) AND last_used_step < sqlc.arg(step);
+
+-- name: CreateTOTPRecoveryCode :one
+INSERT INTO totp_recovery_codes (
+  username,
+  hashed_code
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: DeleteTOTPRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE username = $1;
+
+-- name: UseTOTPRecoveryCode :execrows
+-- 0 rows if the code doesn't exist or has already been used
+UPDATE totp_recovery_codes
+  set used_at = now()
+WHERE username = $1 AND hashed_code = $2 AND used_at IS NULL;
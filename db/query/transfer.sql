@@ -2,15 +2,22 @@
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  external_reference,
+  description,
+  metadata
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6
 ) RETURNING *;
 
 -- name: GetTransfer :one
 SELECT * FROM transfers
 WHERE id = $1 LIMIT 1;
 
+-- name: GetTransferByExternalReference :one
+SELECT * FROM transfers
+WHERE from_account_id = $1 AND external_reference = $2 LIMIT 1;
+
 -- name: ListTransfers :many
 SELECT * FROM transfers
 WHERE 
@@ -18,4 +25,40 @@ WHERE
     to_account_id = $2
 ORDER BY id
 LIMIT $3
-OFFSET $4;
\ No newline at end of file
+OFFSET $4;
+
+-- name: ListAccountTransfers :many
+-- the transfer history of an account, latest first;
+-- `search` is a web search query on the descriptions (e.g. `rent -deposit`), ignored if empty
+SELECT * FROM transfers
+WHERE
+    (from_account_id = sqlc.arg(account_id) OR to_account_id = sqlc.arg(account_id)) AND
+    (sqlc.arg(search)::text = '' OR to_tsvector('english', description) @@ websearch_to_tsquery('english', sqlc.arg(search)::text))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: CreatePendingTransfer :one
+-- a transfer waiting for approval, see `CreateTransferApproval`
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  external_reference,
+  description,
+  metadata,
+  status
+) VALUES (
+  $1, $2, $3, $4, $5, $6, 'pending_approval'
+) RETURNING *;
+
+-- name: GetTransferForUpdate :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: UpdateTransferStatus :one
+UPDATE transfers
+  set status = $2
+WHERE id = $1
+RETURNING *;
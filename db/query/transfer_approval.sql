@@ -0,0 +1,44 @@
+-- name: CreateTransferApproval :one
+INSERT INTO transfer_approvals (
+  transfer_id,
+  requested_by,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: GetTransferApproval :one
+SELECT * FROM transfer_approvals
+WHERE transfer_id = $1 LIMIT 1;
+
+-- name: DecideTransferApproval :one
+-- `decided_by` is NULL when the approval expired
+UPDATE transfer_approvals
+SET
+  decided_by = sqlc.narg(decided_by),
+  decided_at = now()
+WHERE transfer_id = sqlc.arg(transfer_id)
+RETURNING *;
+
+-- name: ListPendingTransfersForApprover :many
+-- the transfers `username` can approve, oldest first: not expired, and not requested by `username`
+SELECT t.* FROM transfers t
+JOIN transfer_approvals ta ON ta.transfer_id = t.id
+JOIN account_approvers aa ON aa.account_id = t.from_account_id
+WHERE
+    aa.username = sqlc.arg(username) AND
+    t.status = 'pending_approval' AND
+    ta.expires_at > now() AND
+    ta.requested_by <> sqlc.arg(username)
+ORDER BY t.id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: ClaimExpiredTransferApprovals :many
+-- the transfers being approved or rejected by another transaction are skipped, they're locked until it ends
+SELECT ta.* FROM transfer_approvals ta
+JOIN transfers t ON t.id = ta.transfer_id
+WHERE ta.decided_at IS NULL AND ta.expires_at <= now()
+ORDER BY ta.expires_at
+LIMIT sqlc.arg('limit')
+FOR UPDATE OF t, ta SKIP LOCKED;
@@ -10,4 +10,29 @@ INSERT INTO users (
 
 -- name: GetUser :one
 SELECT * FROM users
-WHERE username = $1 LIMIT 1;
\ No newline at end of file
+WHERE username = $1 LIMIT 1;
+
+-- name: GetUserPasswordChangedAt :one
+-- access tokens issued before are no longer accepted
+SELECT password_changed_at FROM users
+WHERE username = $1 LIMIT 1;
+
+-- name: UpdateUserPassword :one
+-- a new password, chosen by the user or reset by an operator
+UPDATE users
+  set hashed_password = $2,
+      password_changed_at = now()
+WHERE username = $1
+RETURNING *;
+
+-- name: RehashUserPassword :execrows
+-- the same password hashed with the current algorithm, skipped if the password changed meanwhile
+UPDATE users
+  set hashed_password = sqlc.arg(new_hashed_password)
+WHERE username = sqlc.arg(username) AND hashed_password = sqlc.arg(old_hashed_password);
+
+-- name: UpdateUserRole :one
+UPDATE users
+  set role = $2
+WHERE username = $1
+RETURNING *;
@@ -0,0 +1,68 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (
+  owner,
+  url,
+  secret
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: DeleteWebhook :one
+-- only the owner can delete a webhook, its deliveries are deleted with it
+DELETE FROM webhooks
+WHERE id = $1 AND owner = $2
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1 LIMIT 1;
+
+-- name: CreateWebhookDeliveries :execrows
+-- an event is delivered to every webhook of its owner
+INSERT INTO webhook_deliveries (
+  webhook_id,
+  event_id
+)
+SELECT id, sqlc.arg(event_id)::bigint FROM webhooks
+WHERE owner = sqlc.arg(owner)
+ON CONFLICT (webhook_id, event_id) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+-- the deliveries due, leased until `lease_until` so no other dispatcher picks them up meanwhile
+UPDATE webhook_deliveries d
+  set next_attempt_at = sqlc.arg(lease_until)
+FROM webhooks w, outbox_events e
+WHERE d.webhook_id = w.id AND d.event_id = e.id AND d.id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending' AND next_attempt_at <= now()
+  ORDER BY next_attempt_at
+  LIMIT sqlc.arg('limit')
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.attempts, w.url, w.secret, e.id AS event_id, e.event_type, e.payload, e.created_at AS event_created_at;
+
+-- name: UpdateWebhookDelivery :one
+UPDATE webhook_deliveries
+  set status = $2,
+      attempts = $3,
+      next_attempt_at = $4,
+      last_response_code = $5,
+      last_error = $6,
+      delivered_at = $7
+WHERE id = $1
+RETURNING *;
+
+-- name: ListWebhookDeliveries :many
+-- the delivery log of a webhook, newest first
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2
+OFFSET $3;
@@ -0,0 +1,7 @@
+package db
+
+// Possible values of `accounts.status`, see the `accounts_status_check` constraint.
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen" // cannot send nor receive money
+)
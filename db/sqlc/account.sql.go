@@ -7,13 +7,15 @@ package db
 
 import (
 	"context"
+
+	"github.com/lib/pq"
 )
 
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
   set balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash
 `
 
 type AddAccountBalanceParams struct {
@@ -30,6 +32,41 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
+	)
+	return i, err
+}
+
+const addAccountHeldAmount = `-- name: AddAccountHeldAmount :one
+UPDATE accounts
+  set held_amount = held_amount + $1
+WHERE id = $2
+RETURNING id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash
+`
+
+type AddAccountHeldAmountParams struct {
+	Amount int64 `json:"amount"`
+	ID     int64 `json:"id"`
+}
+
+func (q *Queries) AddAccountHeldAmount(ctx context.Context, arg AddAccountHeldAmountParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, addAccountHeldAmount, arg.Amount, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
 	)
 	return i, err
 }
@@ -41,7 +78,7 @@ INSERT INTO accounts (
   currency
 ) VALUES (
   $1, $2, $3
-) RETURNING id, owner, balance, currency, created_at
+) RETURNING id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash
 `
 
 type CreateAccountParams struct {
@@ -59,6 +96,11 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
 	)
 	return i, err
 }
@@ -74,7 +116,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash FROM accounts
 WHERE id = $1 LIMIT 1
 `
 
@@ -87,12 +129,17 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash FROM accounts
 WHERE id = $1 LIMIT 1
 FOR NO KEY UPDATE
 `
@@ -106,12 +153,53 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
 	)
 	return i, err
 }
 
+const listAccountIDs = `-- name: ListAccountIDs :many
+SELECT id FROM accounts
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListAccountIDsParams struct {
+	AfterID int64 `json:"after_id"`
+	Limit   int32 `json:"limit"`
+}
+
+// every account, a page at a time
+func (q *Queries) ListAccountIDs(ctx context.Context, arg ListAccountIDsParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountIDs, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash FROM accounts
 WHERE owner = $1 -- add Authorization Rule
 ORDER BY id
 LIMIT $2
@@ -139,6 +227,106 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.Status,
+			&i.HeldAmount,
+			&i.AvailableBalance,
+			&i.ApprovalThreshold,
+			&i.LastEntryHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAccounts = `-- name: LockAccounts :many
+SELECT id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash FROM accounts
+WHERE id = ANY($1::bigint[])
+ORDER BY id
+FOR NO KEY UPDATE
+`
+
+// locks the accounts in ID order, so transactions locking overlapping sets of accounts can't deadlock;
+// missing IDs are left out
+func (q *Queries) LockAccounts(ctx context.Context, ids []int64) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, lockAccounts, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.Status,
+			&i.HeldAmount,
+			&i.AvailableBalance,
+			&i.ApprovalThreshold,
+			&i.LastEntryHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reconcileLedger = `-- name: ReconcileLedger :many
+SELECT
+  a.id,
+  a.owner,
+  a.currency,
+  a.balance,
+  COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id
+`
+
+type ReconcileLedgerRow struct {
+	ID           int64  `json:"id"`
+	Owner        string `json:"owner"`
+	Currency     string `json:"currency"`
+	Balance      int64  `json:"balance"`
+	EntriesTotal int64  `json:"entries_total"`
+}
+
+// every account whose balance doesn't equal the sum of its entries
+func (q *Queries) ReconcileLedger(ctx context.Context) ([]ReconcileLedgerRow, error) {
+	rows, err := q.db.QueryContext(ctx, reconcileLedger)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ReconcileLedgerRow{}
+	for rows.Next() {
+		var i ReconcileLedgerRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Currency,
+			&i.Balance,
+			&i.EntriesTotal,
 		); err != nil {
 			return nil, err
 		}
@@ -157,7 +345,7 @@ const updateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
   set balance = $2
 WHERE id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash
 `
 
 type UpdateAccountParams struct {
@@ -174,6 +362,88 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
+	)
+	return i, err
+}
+
+const updateAccountApprovalThreshold = `-- name: UpdateAccountApprovalThreshold :one
+UPDATE accounts
+  set approval_threshold = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash
+`
+
+type UpdateAccountApprovalThresholdParams struct {
+	ID                int64 `json:"id"`
+	ApprovalThreshold int64 `json:"approval_threshold"`
+}
+
+func (q *Queries) UpdateAccountApprovalThreshold(ctx context.Context, arg UpdateAccountApprovalThresholdParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountApprovalThreshold, arg.ID, arg.ApprovalThreshold)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
+	)
+	return i, err
+}
+
+const updateAccountLastEntryHash = `-- name: UpdateAccountLastEntryHash :exec
+UPDATE accounts
+  set last_entry_hash = $1
+WHERE id = $2
+`
+
+type UpdateAccountLastEntryHashParams struct {
+	LastEntryHash []byte `json:"last_entry_hash"`
+	ID            int64  `json:"id"`
+}
+
+// the head of the chain of entries of the account, see `createChainedEntry`
+func (q *Queries) UpdateAccountLastEntryHash(ctx context.Context, arg UpdateAccountLastEntryHashParams) error {
+	_, err := q.db.ExecContext(ctx, updateAccountLastEntryHash, arg.LastEntryHash, arg.ID)
+	return err
+}
+
+const updateAccountStatus = `-- name: UpdateAccountStatus :one
+UPDATE accounts
+  set status = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, status, held_amount, available_balance, approval_threshold, last_entry_hash
+`
+
+type UpdateAccountStatusParams struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+func (q *Queries) UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountStatus, arg.ID, arg.Status)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.HeldAmount,
+		&i.AvailableBalance,
+		&i.ApprovalThreshold,
+		&i.LastEntryHash,
 	)
 	return i, err
 }
@@ -0,0 +1,213 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: account_approver.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const createAccountApprover = `-- name: CreateAccountApprover :one
+INSERT INTO account_approvers (
+  account_id,
+  username
+) VALUES (
+  $1, $2
+) RETURNING account_id, username, created_at
+`
+
+type CreateAccountApproverParams struct {
+	AccountID int64  `json:"account_id"`
+	Username  string `json:"username"`
+}
+
+func (q *Queries) CreateAccountApprover(ctx context.Context, arg CreateAccountApproverParams) (AccountApprover, error) {
+	row := q.db.QueryRowContext(ctx, createAccountApprover, arg.AccountID, arg.Username)
+	var i AccountApprover
+	err := row.Scan(&i.AccountID, &i.Username, &i.CreatedAt)
+	return i, err
+}
+
+const createApprovalPolicyChange = `-- name: CreateApprovalPolicyChange :one
+INSERT INTO approval_policy_changes (
+  account_id,
+  threshold,
+  approvers,
+  requested_by,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING id, account_id, threshold, approvers, requested_by, expires_at, created_at
+`
+
+type CreateApprovalPolicyChangeParams struct {
+	AccountID   int64     `json:"account_id"`
+	Threshold   int64     `json:"threshold"`
+	Approvers   []string  `json:"approvers"`
+	RequestedBy string    `json:"requested_by"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateApprovalPolicyChange(ctx context.Context, arg CreateApprovalPolicyChangeParams) (ApprovalPolicyChange, error) {
+	row := q.db.QueryRowContext(ctx, createApprovalPolicyChange,
+		arg.AccountID,
+		arg.Threshold,
+		pq.Array(arg.Approvers),
+		arg.RequestedBy,
+		arg.ExpiresAt,
+	)
+	var i ApprovalPolicyChange
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Threshold,
+		pq.Array(&i.Approvers),
+		&i.RequestedBy,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteAccountApprovers = `-- name: DeleteAccountApprovers :exec
+DELETE FROM account_approvers
+WHERE account_id = $1
+`
+
+func (q *Queries) DeleteAccountApprovers(ctx context.Context, accountID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteAccountApprovers, accountID)
+	return err
+}
+
+const deleteApprovalPolicyChange = `-- name: DeleteApprovalPolicyChange :exec
+DELETE FROM approval_policy_changes
+WHERE account_id = $1
+`
+
+// the pending change of an account, replaced or applied
+func (q *Queries) DeleteApprovalPolicyChange(ctx context.Context, accountID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteApprovalPolicyChange, accountID)
+	return err
+}
+
+const getAccountApprover = `-- name: GetAccountApprover :one
+SELECT account_id, username, created_at FROM account_approvers
+WHERE account_id = $1 AND username = $2 LIMIT 1
+`
+
+type GetAccountApproverParams struct {
+	AccountID int64  `json:"account_id"`
+	Username  string `json:"username"`
+}
+
+func (q *Queries) GetAccountApprover(ctx context.Context, arg GetAccountApproverParams) (AccountApprover, error) {
+	row := q.db.QueryRowContext(ctx, getAccountApprover, arg.AccountID, arg.Username)
+	var i AccountApprover
+	err := row.Scan(&i.AccountID, &i.Username, &i.CreatedAt)
+	return i, err
+}
+
+const getApprovalPolicyChangeForUpdate = `-- name: GetApprovalPolicyChangeForUpdate :one
+SELECT id, account_id, threshold, approvers, requested_by, expires_at, created_at FROM approval_policy_changes
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetApprovalPolicyChangeForUpdate(ctx context.Context, id int64) (ApprovalPolicyChange, error) {
+	row := q.db.QueryRowContext(ctx, getApprovalPolicyChangeForUpdate, id)
+	var i ApprovalPolicyChange
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Threshold,
+		pq.Array(&i.Approvers),
+		&i.RequestedBy,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAccountApprovers = `-- name: ListAccountApprovers :many
+SELECT account_id, username, created_at FROM account_approvers
+WHERE account_id = $1
+ORDER BY username
+`
+
+func (q *Queries) ListAccountApprovers(ctx context.Context, accountID int64) ([]AccountApprover, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountApprovers, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AccountApprover{}
+	for rows.Next() {
+		var i AccountApprover
+		if err := rows.Scan(&i.AccountID, &i.Username, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingApprovalPolicyChanges = `-- name: ListPendingApprovalPolicyChanges :many
+SELECT c.id, c.account_id, c.threshold, c.approvers, c.requested_by, c.expires_at, c.created_at FROM approval_policy_changes c
+JOIN account_approvers a ON a.account_id = c.account_id
+WHERE
+    a.username = $1 AND
+    c.expires_at > now() AND
+    c.requested_by <> $1
+ORDER BY c.id
+LIMIT $3
+OFFSET $2
+`
+
+type ListPendingApprovalPolicyChangesParams struct {
+	Username string `json:"username"`
+	Offset   int32  `json:"offset"`
+	Limit    int32  `json:"limit"`
+}
+
+// the changes `username` can approve, oldest first: not expired, and not requested by `username`
+func (q *Queries) ListPendingApprovalPolicyChanges(ctx context.Context, arg ListPendingApprovalPolicyChangesParams) ([]ApprovalPolicyChange, error) {
+	rows, err := q.db.QueryContext(ctx, listPendingApprovalPolicyChanges, arg.Username, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ApprovalPolicyChange{}
+	for rows.Next() {
+		var i ApprovalPolicyChange
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Threshold,
+			pq.Array(&i.Approvers),
+			&i.RequestedBy,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
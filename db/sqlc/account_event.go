@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// AccountEventsChannel is the Postgres channel of `AccountEvent` notifications, see `LISTEN`.
+const AccountEventsChannel = "account_events"
+
+// AccountEvent is a new entry of an account, with the balance right after it.
+// It's sent with `NOTIFY`, so it's only received once the transaction commits.
+type AccountEvent struct {
+	AccountID int64 `json:"account_id"`
+	Balance   int64 `json:"balance"`
+	Entry     Entry `json:"entry"`
+}
+
+// publishAccountEvent tells the listeners of `AccountEventsChannel` about a new entry of `account`.
+// `account` must be the account updated by the same transaction, so its balance includes the entry.
+func publishAccountEvent(ctx context.Context, q *Queries, account Account, entry Entry) error {
+	// well under the 8000 bytes limit of a notification payload
+	payload, err := json.Marshal(AccountEvent{
+		AccountID: account.ID,
+		Balance:   account.Balance,
+		Entry:     entry,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot encode account event: %w", err)
+	}
+	return q.NotifyAccountEvent(ctx, NotifyAccountEventParams{
+		Channel: AccountEventsChannel,
+		Payload: string(payload),
+	})
+}
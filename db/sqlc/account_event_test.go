@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTxNotifiesAccountEvents(t *testing.T) {
+	config, err := util.LoadConfig("../../")
+	require.NoError(t, err)
+
+	listener := pq.NewListener(config.DBSource, time.Second, time.Minute, nil)
+	defer listener.Close()
+	// `Listen` waits until Postgres is reachable, closing the listener makes it fail instead
+	timer := time.AfterFunc(5*time.Second, func() { listener.Close() })
+	err = listener.Listen(AccountEventsChannel)
+	require.True(t, timer.Stop(), "cannot connect to listen to account events")
+	require.NoError(t, err)
+
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	// other tests may transfer at the same time, only the events of these accounts are checked
+	events := map[int64]AccountEvent{}
+	timeout := time.After(5 * time.Second)
+	for len(events) < 2 {
+		select {
+		case notification := <-listener.Notify:
+			require.NotNil(t, notification)
+			var event AccountEvent
+			require.NoError(t, json.Unmarshal([]byte(notification.Extra), &event))
+			if event.AccountID == account1.ID || event.AccountID == account2.ID {
+				events[event.AccountID] = event
+			}
+		case <-timeout:
+			t.Fatal("account events not received")
+		}
+	}
+
+	require.Equal(t, result.FromEntry.ID, events[account1.ID].Entry.ID)
+	require.Equal(t, result.FromAccount.Balance, events[account1.ID].Balance)
+	require.Equal(t, result.ToEntry.ID, events[account2.ID].Entry.ID)
+	require.Equal(t, result.ToAccount.Balance, events[account2.ID].Balance)
+}
@@ -14,7 +14,7 @@ func createRandomAccount(t *testing.T) Account {
 	user := createRandomUser(t)
 	arg := CreateAccountParams{
 		Owner:    user.Username,
-		Balance:  util.RandomMoney(),
+		Balance:  util.RandomInt(100, 1000), // enough for the transfers of the tests
 		Currency: util.RandomCurrency(),
 	}
 
@@ -102,3 +102,44 @@ func TestListAccounts(t *testing.T) {
 		require.Equal(t, lastAccount.Owner, account.Owner)
 	}
 }
+
+func TestUpdateAccountStatus(t *testing.T) {
+	account1 := createRandomAccount(t)
+	require.Equal(t, AccountStatusActive, account1.Status)
+
+	account2, err := testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:     account1.ID,
+		Status: AccountStatusFrozen,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.Equal(t, AccountStatusFrozen, account2.Status)
+
+	_, err = testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:     account1.ID,
+		Status: "invalid",
+	})
+	require.Error(t, err) // violates `accounts_status_check`
+}
+
+func TestReconcileLedger(t *testing.T) {
+	// random accounts are created with a balance but no entries, so they must be reported
+	account := createRandomAccount(t)
+	for account.Balance == 0 {
+		account = createRandomAccount(t)
+	}
+
+	rows, err := testQueries.ReconcileLedger(context.Background())
+	require.NoError(t, err)
+
+	found := false
+	for _, row := range rows {
+		require.NotEqual(t, row.Balance, row.EntriesTotal)
+		if row.ID == account.ID {
+			found = true
+			require.Equal(t, account.Balance, row.Balance)
+			require.Zero(t, row.EntriesTotal)
+		}
+	}
+	require.True(t, found)
+}
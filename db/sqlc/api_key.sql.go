@@ -0,0 +1,188 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: api_key.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+const createAPIKey = `-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  owner,
+  name,
+  prefix,
+  hashed_key,
+  scopes,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING id, owner, name, prefix, hashed_key, scopes, expires_at, last_used_at, revoked_at, created_at
+`
+
+type CreateAPIKeyParams struct {
+	Owner     string       `json:"owner"`
+	Name      string       `json:"name"`
+	Prefix    string       `json:"prefix"`
+	HashedKey string       `json:"hashed_key"`
+	Scopes    []string     `json:"scopes"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+}
+
+func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createAPIKey,
+		arg.Owner,
+		arg.Name,
+		arg.Prefix,
+		arg.HashedKey,
+		pq.Array(arg.Scopes),
+		arg.ExpiresAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Name,
+		&i.Prefix,
+		&i.HashedKey,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAPIKeyByPrefix = `-- name: GetAPIKeyByPrefix :one
+SELECT id, owner, name, prefix, hashed_key, scopes, expires_at, last_used_at, revoked_at, created_at FROM api_keys
+WHERE prefix = $1 LIMIT 1
+`
+
+func (q *Queries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getAPIKeyByPrefix, prefix)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Name,
+		&i.Prefix,
+		&i.HashedKey,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAPIKeys = `-- name: ListAPIKeys :many
+SELECT id, owner, name, prefix, hashed_key, scopes, expires_at, last_used_at, revoked_at, created_at FROM api_keys
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListAPIKeysParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+func (q *Queries) ListAPIKeys(ctx context.Context, arg ListAPIKeysParams) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, listAPIKeys, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ApiKey{}
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Name,
+			&i.Prefix,
+			&i.HashedKey,
+			pq.Array(&i.Scopes),
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :one
+UPDATE api_keys
+  set revoked_at = COALESCE(revoked_at, now())
+WHERE id = $1 AND owner = $2
+RETURNING id, owner, name, prefix, hashed_key, scopes, expires_at, last_used_at, revoked_at, created_at
+`
+
+type RevokeAPIKeyParams struct {
+	ID    int64  `json:"id"`
+	Owner string `json:"owner"`
+}
+
+// only the owner can revoke a key, revoking twice keeps the first timestamp
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, revokeAPIKey, arg.ID, arg.Owner)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Name,
+		&i.Prefix,
+		&i.HashedKey,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const revokeUserAPIKeys = `-- name: RevokeUserAPIKeys :execrows
+UPDATE api_keys
+  set revoked_at = now()
+WHERE owner = $1 AND revoked_at IS NULL
+`
+
+// all the keys of a user whose password changed, the ones already revoked keep their timestamp
+func (q *Queries) RevokeUserAPIKeys(ctx context.Context, owner string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserAPIKeys, owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateAPIKeyLastUsed = `-- name: UpdateAPIKeyLastUsed :exec
+UPDATE api_keys
+  set last_used_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) UpdateAPIKeyLastUsed(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, updateAPIKeyLastUsed, id)
+	return err
+}
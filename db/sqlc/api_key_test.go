@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomAPIKey(t *testing.T, owner string) ApiKey {
+	key, prefix, err := util.GenerateAPIKey()
+	require.NoError(t, err)
+
+	arg := CreateAPIKeyParams{
+		Owner:     owner,
+		Name:      util.RandomOwner(),
+		Prefix:    prefix,
+		HashedKey: util.HashAPIKey(key),
+		Scopes:    []string{"accounts:read"},
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	apiKey, err := testQueries.CreateAPIKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, apiKey)
+
+	require.Equal(t, arg.Owner, apiKey.Owner)
+	require.Equal(t, arg.Name, apiKey.Name)
+	require.Equal(t, arg.Prefix, apiKey.Prefix)
+	require.Equal(t, arg.HashedKey, apiKey.HashedKey)
+	require.Equal(t, arg.Scopes, apiKey.Scopes)
+	require.WithinDuration(t, arg.ExpiresAt.Time, apiKey.ExpiresAt.Time, time.Second)
+	require.False(t, apiKey.LastUsedAt.Valid)
+	require.False(t, apiKey.RevokedAt.Valid)
+	require.NotZero(t, apiKey.CreatedAt)
+
+	return apiKey
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	createRandomAPIKey(t, createRandomUser(t).Username)
+}
+
+func TestGetAPIKeyByPrefix(t *testing.T) {
+	apiKey1 := createRandomAPIKey(t, createRandomUser(t).Username)
+	apiKey2, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey1.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, apiKey1.ID, apiKey2.ID)
+	require.Equal(t, apiKey1.HashedKey, apiKey2.HashedKey)
+}
+
+func TestListAPIKeys(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomAPIKey(t, user.Username)
+	}
+
+	apiKeys, err := testQueries.ListAPIKeys(context.Background(), ListAPIKeysParams{
+		Owner:  user.Username,
+		Limit:  5,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 3)
+	for _, apiKey := range apiKeys {
+		require.Equal(t, user.Username, apiKey.Owner)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey1 := createRandomAPIKey(t, user.Username)
+
+	// only the owner can revoke it
+	_, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{
+		ID:    apiKey1.ID,
+		Owner: createRandomUser(t).Username,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	apiKey2, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{
+		ID:    apiKey1.ID,
+		Owner: user.Username,
+	})
+	require.NoError(t, err)
+	require.True(t, apiKey2.RevokedAt.Valid)
+
+	// revoking twice keeps the first timestamp
+	apiKey3, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{
+		ID:    apiKey1.ID,
+		Owner: user.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, apiKey2.RevokedAt.Time, apiKey3.RevokedAt.Time)
+}
+
+func TestUpdateAPIKeyLastUsed(t *testing.T) {
+	apiKey1 := createRandomAPIKey(t, createRandomUser(t).Username)
+
+	err := testQueries.UpdateAPIKeyLastUsed(context.Background(), apiKey1.ID)
+	require.NoError(t, err)
+
+	apiKey2, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey1.Prefix)
+	require.NoError(t, err)
+	require.True(t, apiKey2.LastUsedAt.Valid)
+	require.WithinDuration(t, time.Now(), apiKey2.LastUsedAt.Time, time.Second)
+}
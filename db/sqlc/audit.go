@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// audited actions, recorded in `audit_events.action`
+const (
+	AuditActionUserCreate              = "user.create"
+	AuditActionUserPasswordChange      = "user.password_change"
+	AuditActionUserPasswordReset       = "user.password_reset"
+	AuditActionUserRoleChange          = "user.role_change"
+	AuditActionAccountCreate           = "account.create"
+	AuditActionAccountStatus           = "account.status_change"
+	AuditActionTransferCreate          = "transfer.create"
+	AuditActionTransferRequest         = "transfer.request"
+	AuditActionTransferApprove         = "transfer.approve"
+	AuditActionTransferReject          = "transfer.reject"
+	AuditActionTransferExpire          = "transfer.expire"
+	AuditActionAccountApprovers        = "account.approvers_change"
+	AuditActionAccountApproversRequest = "account.approvers_change_request"
+	AuditActionAPIKeyCreate            = "api_key.create"
+	AuditActionAPIKeyRevoke            = "api_key.revoke"
+	AuditActionWebhookCreate           = "webhook.create"
+	AuditActionWebhookDelete           = "webhook.delete"
+	AuditActionTOTPEnroll              = "totp.enroll"
+	AuditActionTOTPConfirm             = "totp.confirm"
+	AuditActionLoginLockout            = "login.lockout"
+	AuditActionHoldCreate              = "hold.create"
+	AuditActionHoldCapture             = "hold.capture"
+	AuditActionHoldVoid                = "hold.void"
+	AuditActionHoldExpire              = "hold.expire"
+)
+
+// SystemActor is the actor of changes made without `AuditMetadata`, e.g. by background jobs.
+const SystemActor = "system"
+
+// AuditMetadata tells who made a change, and from where.
+// It's set in the context of each request, so the store can record it with the change.
+type AuditMetadata struct {
+	Actor     string
+	RequestID string
+	IP        string
+	UserAgent string
+}
+
+type auditMetadataKey struct{}
+
+// WithAuditMetadata returns a copy of `ctx` carrying `metadata`.
+func WithAuditMetadata(ctx context.Context, metadata AuditMetadata) context.Context {
+	return context.WithValue(ctx, auditMetadataKey{}, metadata)
+}
+
+// AuditMetadataFrom returns the metadata carried by `ctx`, with `SystemActor` if there's none.
+func AuditMetadataFrom(ctx context.Context) AuditMetadata {
+	metadata, ok := ctx.Value(auditMetadataKey{}).(AuditMetadata)
+	if !ok || metadata.Actor == "" {
+		metadata.Actor = SystemActor
+	}
+	return metadata
+}
+
+// AuditChange is a change to record in the audit log.
+// `Before` & `After` are encoded as JSON, so they must not contain secrets (e.g. password hashes).
+type AuditChange struct {
+	Action string
+	Target string // e.g. "account:42"
+	Before any    // nil for creations
+	After  any    // nil for deletions
+}
+
+// NewAuditEvent builds the audit event of `change`, made by the actor of `ctx`.
+func NewAuditEvent(ctx context.Context, change AuditChange) (CreateAuditEventParams, error) {
+	metadata := AuditMetadataFrom(ctx)
+	before, err := json.Marshal(change.Before)
+	if err != nil {
+		return CreateAuditEventParams{}, fmt.Errorf("cannot encode audit state: %w", err)
+	}
+	after, err := json.Marshal(change.After)
+	if err != nil {
+		return CreateAuditEventParams{}, fmt.Errorf("cannot encode audit state: %w", err)
+	}
+
+	return CreateAuditEventParams{
+		Actor:     metadata.Actor,
+		Action:    change.Action,
+		Target:    change.Target,
+		RequestID: metadata.RequestID,
+		Ip:        metadata.IP,
+		UserAgent: metadata.UserAgent,
+		Before:    before,
+		After:     after,
+	}, nil
+}
+
+// AuditedTx runs `fn` within a database transaction, and records the change it returns in the same transaction:
+// either both the change & its audit event are committed, or neither.
+// Like with `execTx`, `fn` may run again if the transaction is retried.
+func (store *SQLStore) AuditedTx(ctx context.Context, fn func(context.Context, Querier) (AuditChange, error)) error {
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		change, err := fn(ctx, q)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, q, change)
+	})
+	return err
+}
+
+func recordAuditEvent(ctx context.Context, q *Queries, change AuditChange) error {
+	event, err := NewAuditEvent(ctx, change)
+	if err != nil {
+		return err
+	}
+	_, err = q.CreateAuditEvent(ctx, event)
+	return err
+}
@@ -0,0 +1,127 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: audit_event.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+  actor,
+  action,
+  target,
+  request_id,
+  ip,
+  user_agent,
+  before,
+  after
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, actor, action, target, ip, user_agent, created_at, request_id, before, after
+`
+
+type CreateAuditEventParams struct {
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	RequestID string          `json:"request_id"`
+	Ip        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	row := q.db.QueryRowContext(ctx, createAuditEvent,
+		arg.Actor,
+		arg.Action,
+		arg.Target,
+		arg.RequestID,
+		arg.Ip,
+		arg.UserAgent,
+		arg.Before,
+		arg.After,
+	)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Actor,
+		&i.Action,
+		&i.Target,
+		&i.Ip,
+		&i.UserAgent,
+		&i.CreatedAt,
+		&i.RequestID,
+		&i.Before,
+		&i.After,
+	)
+	return i, err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, actor, action, target, ip, user_agent, created_at, request_id, before, after FROM audit_events
+WHERE ($1::varchar IS NULL OR actor = $1)
+  AND ($2::varchar IS NULL OR target = $2)
+  AND ($3::timestamptz IS NULL OR created_at >= $3)
+  AND ($4::timestamptz IS NULL OR created_at < $4)
+ORDER BY id
+LIMIT $6
+OFFSET $5
+`
+
+type ListAuditEventsParams struct {
+	Actor    sql.NullString `json:"actor"`
+	Target   sql.NullString `json:"target"`
+	FromTime sql.NullTime   `json:"from_time"`
+	ToTime   sql.NullTime   `json:"to_time"`
+	Offset   int32          `json:"offset"`
+	Limit    int32          `json:"limit"`
+}
+
+// every filter is optional
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEvents,
+		arg.Actor,
+		arg.Target,
+		arg.FromTime,
+		arg.ToTime,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Actor,
+			&i.Action,
+			&i.Target,
+			&i.Ip,
+			&i.UserAgent,
+			&i.CreatedAt,
+			&i.RequestID,
+			&i.Before,
+			&i.After,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
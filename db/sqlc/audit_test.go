@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditedTx(t *testing.T) {
+	user := createRandomUser(t)
+	ctx := WithAuditMetadata(context.Background(), AuditMetadata{
+		Actor:     user.Username,
+		RequestID: util.RandomString(12),
+		IP:        "10.0.0.1",
+		UserAgent: "simplebank-test",
+	})
+
+	store := NewStore(testDB)
+
+	var account Account
+	err := store.AuditedTx(ctx, func(ctx context.Context, q Querier) (AuditChange, error) {
+		var err error
+		account, err = q.CreateAccount(ctx, CreateAccountParams{
+			Owner:    user.Username,
+			Currency: util.USD,
+		})
+		return AuditChange{Action: AuditActionAccountCreate, Target: "account:test", After: account}, err
+	})
+	require.NoError(t, err)
+
+	events, err := testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor: sql.NullString{String: user.Username, Valid: true},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	event := events[0]
+	require.Equal(t, AuditActionAccountCreate, event.Action)
+	require.Equal(t, "account:test", event.Target)
+	require.Equal(t, AuditMetadataFrom(ctx).RequestID, event.RequestID)
+	require.Equal(t, "10.0.0.1", event.Ip)
+	require.Equal(t, "simplebank-test", event.UserAgent)
+	require.JSONEq(t, "null", string(event.Before))
+	require.Contains(t, string(event.After), user.Username)
+}
+
+func TestAuditedTxRollback(t *testing.T) {
+	user := createRandomUser(t)
+	ctx := WithAuditMetadata(context.Background(), AuditMetadata{Actor: user.Username})
+
+	store := NewStore(testDB)
+
+	// a failed change leaves no event behind
+	errFailed := errors.New("failed")
+	err := store.AuditedTx(ctx, func(ctx context.Context, q Querier) (AuditChange, error) {
+		_, err := q.CreateAccount(ctx, CreateAccountParams{Owner: user.Username, Currency: util.EUR})
+		require.NoError(t, err)
+		return AuditChange{}, errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	accounts, err := testQueries.ListAccounts(context.Background(), ListAccountsParams{Owner: user.Username, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+
+	events, err := testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor: sql.NullString{String: user.Username, Valid: true},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestAuditEventsAreAppendOnly(t *testing.T) {
+	event, err := testQueries.CreateAuditEvent(context.Background(), CreateAuditEventParams{
+		Actor:  SystemActor,
+		Action: AuditActionAccountStatus,
+		Target: "account:" + util.RandomString(6),
+		Before: []byte("null"),
+		After:  []byte("null"),
+	})
+	require.NoError(t, err)
+
+	_, err = testDB.Exec("UPDATE audit_events SET actor = 'mallory' WHERE id = $1", event.ID)
+	require.Error(t, err)
+
+	_, err = testDB.Exec("DELETE FROM audit_events WHERE id = $1", event.ID)
+	require.Error(t, err)
+
+	_, err = testDB.Exec("TRUNCATE audit_events")
+	require.Error(t, err)
+}
+
+func TestListAuditEventsFilters(t *testing.T) {
+	target := "account:" + util.RandomString(6)
+	for i := 0; i < 3; i++ {
+		_, err := testQueries.CreateAuditEvent(context.Background(), CreateAuditEventParams{
+			Actor:  util.RandomOwner(),
+			Action: AuditActionAccountStatus,
+			Target: target,
+			Before: []byte("null"),
+			After:  []byte("null"),
+		})
+		require.NoError(t, err)
+	}
+
+	events, err := testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Target: sql.NullString{String: target, Valid: true},
+		Limit:  2,
+		Offset: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	for _, event := range events {
+		require.Equal(t, target, event.Target)
+	}
+	require.Less(t, events[0].ID, events[1].ID)
+}
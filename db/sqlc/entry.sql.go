@@ -7,36 +7,83 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createEntry = `-- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  prev_hash,
+  hash,
+  created_at,
+  transfer_id,
+  description,
+  hash_version
 ) VALUES (
-  $1, $2
-) RETURNING id, account_id, amount, created_at
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, account_id, amount, created_at, prev_hash, hash, transfer_id, description, hash_version
 `
 
 type CreateEntryParams struct {
-	AccountID int64 `json:"account_id"`
-	Amount    int64 `json:"amount"`
+	AccountID   int64         `json:"account_id"`
+	Amount      int64         `json:"amount"`
+	PrevHash    []byte        `json:"prev_hash"`
+	Hash        []byte        `json:"hash"`
+	CreatedAt   time.Time     `json:"created_at"`
+	TransferID  sql.NullInt64 `json:"transfer_id"`
+	Description string        `json:"description"`
+	HashVersion int16         `json:"hash_version"`
 }
 
+// use `createChainedEntry` rather than this query, it computes the hashes
 func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createEntry,
+		arg.AccountID,
+		arg.Amount,
+		arg.PrevHash,
+		arg.Hash,
+		arg.CreatedAt,
+		arg.TransferID,
+		arg.Description,
+		arg.HashVersion,
+	)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.PrevHash,
+		&i.Hash,
+		&i.TransferID,
+		&i.Description,
+		&i.HashVersion,
 	)
 	return i, err
 }
 
+const getAccountBalanceAt = `-- name: GetAccountBalanceAt :one
+SELECT COALESCE(SUM(amount), 0)::bigint FROM entries
+WHERE account_id = $1 AND created_at < $2
+`
+
+type GetAccountBalanceAtParams struct {
+	AccountID int64     `json:"account_id"`
+	At        time.Time `json:"at"`
+}
+
+// the balance of an account right before `at`, from its entries
+func (q *Queries) GetAccountBalanceAt(ctx context.Context, arg GetAccountBalanceAtParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getAccountBalanceAt, arg.AccountID, arg.At)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
 const getEntry = `-- name: GetEntry :one
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, prev_hash, hash, transfer_id, description, hash_version FROM entries
 WHERE id = $1 LIMIT 1
 `
 
@@ -48,12 +95,41 @@ func (q *Queries) GetEntry(ctx context.Context, id int64) (Entry, error) {
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.PrevHash,
+		&i.Hash,
+		&i.TransferID,
+		&i.Description,
+		&i.HashVersion,
+	)
+	return i, err
+}
+
+const getLastEntry = `-- name: GetLastEntry :one
+SELECT id, account_id, amount, created_at, prev_hash, hash, transfer_id, description, hash_version FROM entries
+WHERE account_id = $1
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastEntry(ctx context.Context, accountID int64) (Entry, error) {
+	row := q.db.QueryRowContext(ctx, getLastEntry, accountID)
+	var i Entry
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.PrevHash,
+		&i.Hash,
+		&i.TransferID,
+		&i.Description,
+		&i.HashVersion,
 	)
 	return i, err
 }
 
 const listEntries = `-- name: ListEntries :many
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, prev_hash, hash, transfer_id, description, hash_version FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2
@@ -80,6 +156,11 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 			&i.AccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.PrevHash,
+			&i.Hash,
+			&i.TransferID,
+			&i.Description,
+			&i.HashVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -93,3 +174,129 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 	}
 	return items, nil
 }
+
+const listEntriesAfter = `-- name: ListEntriesAfter :many
+SELECT id, account_id, amount, created_at, prev_hash, hash, transfer_id, description, hash_version FROM entries
+WHERE account_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListEntriesAfterParams struct {
+	AccountID int64 `json:"account_id"`
+	AfterID   int64 `json:"after_id"`
+	Limit     int32 `json:"limit"`
+}
+
+// a page of the chain of an account, for its verification
+func (q *Queries) ListEntriesAfter(ctx context.Context, arg ListEntriesAfterParams) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, listEntriesAfter, arg.AccountID, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.PrevHash,
+			&i.Hash,
+			&i.TransferID,
+			&i.Description,
+			&i.HashVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStatementEntries = `-- name: ListStatementEntries :many
+SELECT
+  e.id,
+  e.amount,
+  e.created_at,
+  e.transfer_id,
+  e.description,
+  COALESCE(a.id, 0)::bigint AS counterparty_account_id,
+  COALESCE(a.owner, '')::varchar AS counterparty_owner
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+LEFT JOIN accounts a ON a.id = CASE WHEN t.from_account_id = e.account_id THEN t.to_account_id ELSE t.from_account_id END
+WHERE e.account_id = $1 AND e.created_at >= $2 AND e.created_at < $3
+ORDER BY e.id
+`
+
+type ListStatementEntriesParams struct {
+	AccountID int64     `json:"account_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+type ListStatementEntriesRow struct {
+	ID                    int64         `json:"id"`
+	Amount                int64         `json:"amount"`
+	CreatedAt             time.Time     `json:"created_at"`
+	TransferID            sql.NullInt64 `json:"transfer_id"`
+	Description           string        `json:"description"`
+	CounterpartyAccountID int64         `json:"counterparty_account_id"`
+	CounterpartyOwner     string        `json:"counterparty_owner"`
+}
+
+// the entries of an account in [from_time, to_time), with the other account of their transfer
+func (q *Queries) ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStatementEntries, arg.AccountID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStatementEntriesRow{}
+	for rows.Next() {
+		var i ListStatementEntriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.TransferID,
+			&i.Description,
+			&i.CounterpartyAccountID,
+			&i.CounterpartyOwner,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifyAccountEvent = `-- name: NotifyAccountEvent :exec
+SELECT pg_notify($1::text, $2::text)
+`
+
+type NotifyAccountEventParams struct {
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+}
+
+// delivered to the listeners of `channel` once the transaction commits
+func (q *Queries) NotifyAccountEvent(ctx context.Context, arg NotifyAccountEventParams) error {
+	_, err := q.db.ExecContext(ctx, notifyAccountEvent, arg.Channel, arg.Payload)
+	return err
+}
@@ -15,12 +15,14 @@ func createRandomEntry(t *testing.T, account Account) Entry {
 		Amount: 	 util.RandomMoney(),
 	}
 	
-	entry, err := testQueries.CreateEntry(context.Background(), arg)
+	entry, err := createChainedEntry(context.Background(), testQueries, arg)
 	require.NoError(t, err)
 	require.NotEmpty(t, entry)
 	
 	require.Equal(t, arg.AccountID, entry.AccountID)
 	require.Equal(t, arg.Amount, entry.Amount)
+	require.Equal(t, int16(EntryHashVersion2), entry.HashVersion)
+	require.Equal(t, hashEntry(entry), entry.Hash)
 	
 	require.NotZero(t, entry.ID)
 	require.NotZero(t, entry.CreatedAt)
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+)
+
+// Ping verifies the connection to the database is still alive.
+func (store *SQLStore) Ping(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Ping")
+	defer span.End()
+
+	err := store.db.PingContext(ctx)
+	recordError(span, err)
+	return err
+}
+
+// MigrationVersion returns the current schema version recorded by golang-migrate in `schema_migrations`.
+// `dirty` is true when the last migration failed halfway and needs a manual fix.
+// NOTE: `schema_migrations` isn't part of db/migration, so sqlc cannot generate this query.
+func (store *SQLStore) MigrationVersion(ctx context.Context) (version int64, dirty bool, err error) {
+	row := store.Queries.db.QueryRowContext(ctx, migrationVersion)
+	err = row.Scan(&version, &dirty)
+	return
+}
+
+const migrationVersion = `-- name: MigrationVersion :one
+SELECT version, dirty FROM schema_migrations LIMIT 1
+`
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// statuses of a hold
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusVoided   = "voided"
+	HoldStatusExpired  = "expired"
+)
+
+var (
+	// ErrInsufficientFunds is returned when the available balance of an account is less than the amount to hold or transfer.
+	ErrInsufficientFunds = errors.New("insufficient available balance")
+	// ErrHoldNotActive is returned when capturing or voiding a hold that was already released, or has expired.
+	ErrHoldNotActive = errors.New("hold is not active")
+	// ErrCaptureExceedsHold is returned when capturing more than the amount held.
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds the amount held")
+)
+
+// CreateHoldTx reserves funds on an account: its available balance drops by the amount held, and must stay positive.
+// Transfers check the available balance too, so the funds held can only be moved by capturing the hold.
+func (store *SQLStore) CreateHoldTx(ctx context.Context, arg CreateHoldParams) (Hold, error) {
+	var hold Hold
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		// locks the account, so concurrent holds can't reserve the same funds
+		account, err := q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+			ID:     arg.AccountID,
+			Amount: arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+		if account.AvailableBalance < 0 {
+			return fmt.Errorf("%w: %d available", ErrInsufficientFunds, account.AvailableBalance+arg.Amount)
+		}
+
+		hold, err = q.CreateHold(ctx, arg)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, q, AuditChange{
+			Action: AuditActionHoldCreate,
+			Target: fmt.Sprintf("hold:%d", hold.ID),
+			After:  hold,
+		})
+	})
+	return hold, err
+}
+
+// CaptureHoldTxParams contains the input parameters of the hold capture
+type CaptureHoldTxParams struct {
+	ID     int64 `json:"id"`
+	Amount int64 `json:"amount"` // at most the amount held, the rest is released
+}
+
+// CaptureHoldTxResult is the result of the hold capture
+type CaptureHoldTxResult struct {
+	Hold     Hold             `json:"hold"`
+	Transfer TransferTxResult `json:"transfer"`
+}
+
+// CaptureHoldTx settles a hold: `arg.Amount` is transferred to the account of the hold, like `TransferTx` does,
+// and the whole hold is released in the same transaction.
+func (store *SQLStore) CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (CaptureHoldTxResult, error) {
+	var result CaptureHoldTxResult
+
+	ctx, span := tracer.Start(ctx, "CaptureHoldTx")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("hold.id", arg.ID),
+		attribute.Int64("transfer.amount", arg.Amount),
+	)
+
+	var err error
+	result.Transfer.Attempts, err = store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		hold, err := lockActiveHold(ctx, q, arg.ID)
+		if err != nil {
+			return err
+		}
+		if arg.Amount > hold.Amount {
+			return fmt.Errorf("%w: %d held", ErrCaptureExceedsHold, hold.Amount)
+		}
+
+		// the transfer locks both accounts in ID order, like any other transfer, before the hold is released;
+		// it draws on the funds of the hold, not on the available balance
+		result.Transfer = TransferTxResult{}
+		err = makeTransfer(ctx, q, TransferTxParams{
+			FromAccountID: hold.AccountID,
+			ToAccountID:   hold.ToAccountID,
+			Amount:        arg.Amount,
+			Description:   hold.Description,
+		}, hold.Amount, &result.Transfer)
+		if err != nil {
+			return err
+		}
+
+		result.Transfer.FromAccount, result.Hold, err = releaseLockedHold(ctx, q, hold, ReleaseHoldParams{
+			Status:         HoldStatusCaptured,
+			CapturedAmount: arg.Amount,
+			TransferID:     sql.NullInt64{Int64: result.Transfer.Transfer.ID, Valid: true},
+		})
+		return err
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+// VoidHoldTx releases a hold without any transfer.
+func (store *SQLStore) VoidHoldTx(ctx context.Context, id int64) (Hold, error) {
+	var hold Hold
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		active, err := lockActiveHold(ctx, q, id)
+		if err != nil {
+			return err
+		}
+		_, hold, err = releaseLockedHold(ctx, q, active, ReleaseHoldParams{Status: HoldStatusVoided})
+		return err
+	})
+	return hold, err
+}
+
+// ExpireHoldsTx releases up to `limit` holds past their expiration.
+// Holds being captured or voided meanwhile are skipped. It returns how many holds expired.
+func (store *SQLStore) ExpireHoldsTx(ctx context.Context, limit int32) (int, error) {
+	var holds []Hold
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		var err error
+		holds, err = q.ClaimExpiredHolds(ctx, limit)
+		if err != nil {
+			return err
+		}
+		// the accounts are locked in ID order, like transfers do, so they can't deadlock with one
+		slices.SortFunc(holds, func(a, b Hold) int {
+			return cmp.Or(cmp.Compare(a.AccountID, b.AccountID), cmp.Compare(a.ID, b.ID))
+		})
+		for _, hold := range holds {
+			_, _, err = releaseLockedHold(ctx, q, hold, ReleaseHoldParams{Status: HoldStatusExpired})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(holds), err
+}
+
+// lockActiveHold locks a hold until the end of the transaction, it must be active & not expired.
+func lockActiveHold(ctx context.Context, q *Queries, id int64) (Hold, error) {
+	hold, err := q.GetHoldForUpdate(ctx, id)
+	if err != nil {
+		return hold, err
+	}
+	if hold.Status != HoldStatusActive {
+		return hold, fmt.Errorf("%w: %s", ErrHoldNotActive, hold.Status)
+	}
+	if !hold.ExpiresAt.After(time.Now()) {
+		return hold, fmt.Errorf("%w: expired", ErrHoldNotActive)
+	}
+	return hold, nil
+}
+
+// releaseLockedHold gives the funds of a locked, active hold back to the available balance of its account.
+// It returns the account & the hold, once released.
+func releaseLockedHold(ctx context.Context, q *Queries, hold Hold, arg ReleaseHoldParams) (Account, Hold, error) {
+	account, err := q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+		ID:     hold.AccountID,
+		Amount: -hold.Amount,
+	})
+	if err != nil {
+		return account, hold, err
+	}
+
+	before := hold
+	arg.ID = hold.ID
+	hold, err = q.ReleaseHold(ctx, arg)
+	if err != nil {
+		return account, hold, err
+	}
+
+	action := map[string]string{
+		HoldStatusCaptured: AuditActionHoldCapture,
+		HoldStatusVoided:   AuditActionHoldVoid,
+		HoldStatusExpired:  AuditActionHoldExpire,
+	}[arg.Status]
+	err = recordAuditEvent(ctx, q, AuditChange{
+		Action: action,
+		Target: fmt.Sprintf("hold:%d", hold.ID),
+		Before: before,
+		After:  hold,
+	})
+	return account, hold, err
+}
@@ -0,0 +1,193 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: hold.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const claimExpiredHolds = `-- name: ClaimExpiredHolds :many
+SELECT id, account_id, to_account_id, amount, description, status, expires_at, captured_amount, transfer_id, released_at, created_at FROM holds
+WHERE status = 'active' AND expires_at <= now()
+ORDER BY expires_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+// holds being released by another transaction are skipped, they're locked until it ends
+func (q *Queries) ClaimExpiredHolds(ctx context.Context, limit int32) ([]Hold, error) {
+	rows, err := q.db.QueryContext(ctx, claimExpiredHolds, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Hold{}
+	for rows.Next() {
+		var i Hold
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.Description,
+			&i.Status,
+			&i.ExpiresAt,
+			&i.CapturedAmount,
+			&i.TransferID,
+			&i.ReleasedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createHold = `-- name: CreateHold :one
+INSERT INTO holds (
+  account_id,
+  to_account_id,
+  amount,
+  description,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING id, account_id, to_account_id, amount, description, status, expires_at, captured_amount, transfer_id, released_at, created_at
+`
+
+type CreateHoldParams struct {
+	AccountID   int64     `json:"account_id"`
+	ToAccountID int64     `json:"to_account_id"`
+	Amount      int64     `json:"amount"`
+	Description string    `json:"description"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, createHold,
+		arg.AccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.Description,
+		arg.ExpiresAt,
+	)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Description,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getHold = `-- name: GetHold :one
+SELECT id, account_id, to_account_id, amount, description, status, expires_at, captured_amount, transfer_id, released_at, created_at FROM holds
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetHold(ctx context.Context, id int64) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, getHold, id)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Description,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getHoldForUpdate = `-- name: GetHoldForUpdate :one
+SELECT id, account_id, to_account_id, amount, description, status, expires_at, captured_amount, transfer_id, released_at, created_at FROM holds
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetHoldForUpdate(ctx context.Context, id int64) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, getHoldForUpdate, id)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Description,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const releaseHold = `-- name: ReleaseHold :one
+UPDATE holds
+SET
+  status = $1,
+  captured_amount = $2,
+  transfer_id = $3,
+  released_at = now()
+WHERE id = $4
+RETURNING id, account_id, to_account_id, amount, description, status, expires_at, captured_amount, transfer_id, released_at, created_at
+`
+
+type ReleaseHoldParams struct {
+	Status         string        `json:"status"`
+	CapturedAmount int64         `json:"captured_amount"`
+	TransferID     sql.NullInt64 `json:"transfer_id"`
+	ID             int64         `json:"id"`
+}
+
+// `captured_amount` & `transfer_id` are only set by a capture
+func (q *Queries) ReleaseHold(ctx context.Context, arg ReleaseHoldParams) (Hold, error) {
+	row := q.db.QueryRowContext(ctx, releaseHold,
+		arg.Status,
+		arg.CapturedAmount,
+		arg.TransferID,
+		arg.ID,
+	)
+	var i Hold
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Description,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CapturedAmount,
+		&i.TransferID,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
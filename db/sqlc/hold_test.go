@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomHold(t *testing.T, store Store, account, toAccount Account, amount int64) Hold {
+	hold, err := store.CreateHoldTx(context.Background(), CreateHoldParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      amount,
+		Description: "card payment",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusActive, hold.Status)
+	return hold
+}
+
+func TestCreateHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+	toAccount := createRandomAccountIn(t, account.Currency)
+
+	createRandomHold(t, store, account, toAccount, account.Balance-1)
+
+	updated, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, updated.Balance)
+	require.Equal(t, int64(1), updated.AvailableBalance)
+
+	// the funds are already reserved
+	_, err = store.CreateHoldTx(context.Background(), CreateHoldParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      2,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	updated, err = testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), updated.AvailableBalance)
+}
+
+func TestCaptureHoldTxPartial(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+	toAccount := createRandomAccountIn(t, account.Currency)
+	hold := createRandomHold(t, store, account, toAccount, 10)
+
+	_, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{ID: hold.ID, Amount: 11})
+	require.ErrorIs(t, err, ErrCaptureExceedsHold)
+
+	result, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{ID: hold.ID, Amount: 4})
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusCaptured, result.Hold.Status)
+	require.Equal(t, int64(4), result.Hold.CapturedAmount)
+	require.True(t, result.Hold.TransferID.Valid)
+	require.Equal(t, result.Transfer.Transfer.ID, result.Hold.TransferID.Int64)
+	require.Equal(t, hold.Description, result.Transfer.Transfer.Description)
+
+	// the rest of the hold is released
+	require.Equal(t, account.Balance-4, result.Transfer.FromAccount.Balance)
+	require.Equal(t, account.Balance-4, result.Transfer.FromAccount.AvailableBalance)
+	require.Equal(t, toAccount.Balance+4, result.Transfer.ToAccount.Balance)
+
+	_, err = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{ID: hold.ID, Amount: 4})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+}
+
+func TestVoidHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+	toAccount := createRandomAccountIn(t, account.Currency)
+	hold := createRandomHold(t, store, account, toAccount, 10)
+
+	voided, err := store.VoidHoldTx(context.Background(), hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusVoided, voided.Status)
+	require.True(t, voided.ReleasedAt.Valid)
+	require.False(t, voided.TransferID.Valid)
+
+	updated, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, updated.Balance)
+	require.Equal(t, account.Balance, updated.AvailableBalance)
+
+	_, err = store.VoidHoldTx(context.Background(), hold.ID)
+	require.ErrorIs(t, err, ErrHoldNotActive)
+}
+
+func TestExpireHoldsTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+	toAccount := createRandomAccountIn(t, account.Currency)
+
+	hold, err := store.CreateHoldTx(context.Background(), CreateHoldParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      10,
+		ExpiresAt:   time.Now().Add(time.Second),
+	})
+	require.NoError(t, err)
+	time.Sleep(time.Second)
+
+	// an expired hold can't be captured, even before the sweeper releases it
+	_, err = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{ID: hold.ID, Amount: 10})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+
+	for {
+		n, err := store.ExpireHoldsTx(context.Background(), 100)
+		require.NoError(t, err)
+		if n < 100 {
+			break
+		}
+	}
+
+	expired, err := testQueries.GetHold(context.Background(), hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusExpired, expired.Status)
+
+	updated, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, updated.AvailableBalance)
+}
+
+func TestTransferTxAfterHold(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+	toAccount := createRandomAccountIn(t, account.Currency)
+	hold := createRandomHold(t, store, account, toAccount, account.Balance-5)
+
+	// the funds held can't be moved out by another transfer
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	result, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: account.ID,
+		Currency:      account.Currency,
+		Mode:          BatchModeBestEffort,
+		Items: []BatchTransferItem{
+			{ToAccountID: toAccount.ID, Amount: 5},
+			{ToAccountID: toAccount.ID, Amount: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Succeeded)
+	require.Equal(t, BatchItemStatusFailed, result.Items[1].Status)
+	require.Contains(t, result.Items[1].Error, ErrInsufficientFunds.Error())
+
+	// but the hold can still be captured in full
+	captured, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{ID: hold.ID, Amount: hold.Amount})
+	require.NoError(t, err)
+	require.Zero(t, captured.Transfer.FromAccount.Balance)
+	require.Zero(t, captured.Transfer.FromAccount.AvailableBalance)
+}
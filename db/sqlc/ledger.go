@@ -0,0 +1,183 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// versions of the entry hash, stored with each entry so the entries hashed with an older version still verify
+const (
+	EntryHashVersion1 = 1 // see `EntryHash`
+	EntryHashVersion2 = 2 // see `EntryHashV2`, the version of new entries
+)
+
+// EntryHash is the hash of an entry: it covers its contents and the hash of the previous entry of the account,
+// so editing, removing or reordering an entry breaks the chain from there on.
+// The migration chaining the entries created before hashes existed computes it the same way.
+// It's the version 1, which doesn't cover the transfer & description of the entry, see `EntryHashV2`.
+func EntryHash(accountID int64, amount int64, createdAt time.Time, prevHash []byte) []byte {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d|%d|%d|%x", accountID, amount, createdAt.UnixMicro(), prevHash))
+	return sum[:]
+}
+
+// EntryHashV2 is the version 2 of `EntryHash`: it covers the transfer & description of the entry too,
+// the description hex encoded so it cannot forge the separators. An entry without transfer has an empty `transfer_id`.
+func EntryHashV2(accountID int64, amount int64, createdAt time.Time, transferID sql.NullInt64, description string, prevHash []byte) []byte {
+	var transfer string
+	if transferID.Valid {
+		transfer = strconv.FormatInt(transferID.Int64, 10)
+	}
+	sum := sha256.Sum256(fmt.Appendf(nil, "v2|%d|%d|%d|%s|%x|%x",
+		accountID, amount, createdAt.UnixMicro(), transfer, description, prevHash))
+	return sum[:]
+}
+
+// hashEntry returns the hash `entry` must have, with the version it was hashed with; nil for an unknown version.
+func hashEntry(entry Entry) []byte {
+	switch entry.HashVersion {
+	case EntryHashVersion1:
+		return EntryHash(entry.AccountID, entry.Amount, entry.CreatedAt, entry.PrevHash)
+	case EntryHashVersion2:
+		return EntryHashV2(entry.AccountID, entry.Amount, entry.CreatedAt, entry.TransferID, entry.Description, entry.PrevHash)
+	}
+	return nil
+}
+
+// createChainedEntry appends an entry to the chain of its account: `arg.PrevHash`, `arg.Hash`, `arg.HashVersion`
+// & `arg.CreatedAt` are set here, and the entry becomes the head of the chain, `accounts.last_entry_hash`.
+// The caller must hold the lock of the account row (e.g. by updating its balance first),
+// otherwise a concurrent transaction could chain another entry to the same previous one.
+func createChainedEntry(ctx context.Context, q *Queries, arg CreateEntryParams) (Entry, error) {
+	prev, err := q.GetLastEntry(ctx, arg.AccountID)
+	if err != nil && err != sql.ErrNoRows {
+		return Entry{}, err
+	}
+
+	// the hash covers `created_at`, so it's set here rather than by the db, at the precision Postgres stores
+	arg.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	arg.PrevHash = prev.Hash // nil for the first entry
+	arg.HashVersion = EntryHashVersion2
+	arg.Hash = EntryHashV2(arg.AccountID, arg.Amount, arg.CreatedAt, arg.TransferID, arg.Description, arg.PrevHash)
+	entry, err := q.CreateEntry(ctx, arg)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	err = q.UpdateAccountLastEntryHash(ctx, UpdateAccountLastEntryHashParams{
+		ID:            entry.AccountID,
+		LastEntryHash: entry.Hash,
+	})
+	return entry, err
+}
+
+// LedgerBreak is the first broken link found in the chain of an account.
+type LedgerBreak struct {
+	AccountID int64  `json:"account_id"`
+	EntryID   int64  `json:"entry_id"`
+	Reason    string `json:"reason"`
+}
+
+// VerifyLedgerParams contains the input parameters of the ledger verification
+type VerifyLedgerParams struct {
+	AccountID int64 `json:"account_id"` // 0 verifies every account
+}
+
+// how many rows the verification reads at a time
+const verifyLedgerPageSize = 1000
+
+// VerifyLedger walks the chain of entries of each account, and returns the first broken link of each broken chain.
+// An empty list means no entry was tampered with.
+func (store *SQLStore) VerifyLedger(ctx context.Context, arg VerifyLedgerParams) ([]LedgerBreak, error) {
+	breaks := []LedgerBreak{}
+	if arg.AccountID != 0 {
+		brk, err := store.verifyAccountChain(ctx, arg.AccountID)
+		if err != nil || brk == nil {
+			return breaks, err
+		}
+		return append(breaks, *brk), nil
+	}
+
+	for afterID := int64(0); ; {
+		accountIDs, err := store.ListAccountIDs(ctx, ListAccountIDsParams{
+			AfterID: afterID,
+			Limit:   verifyLedgerPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, accountID := range accountIDs {
+			brk, err := store.verifyAccountChain(ctx, accountID)
+			if err != nil {
+				return nil, err
+			}
+			if brk != nil {
+				breaks = append(breaks, *brk)
+			}
+		}
+
+		if len(accountIDs) < verifyLedgerPageSize {
+			return breaks, nil
+		}
+		afterID = accountIDs[len(accountIDs)-1]
+	}
+}
+
+// verifyAccountChain verifies the chain of an account in a read-only snapshot,
+// so an entry appended meanwhile doesn't look like the chain going past its head.
+func (store *SQLStore) verifyAccountChain(ctx context.Context, accountID int64) (*LedgerBreak, error) {
+	var brk *LedgerBreak
+	_, err := store.execTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(ctx context.Context, q *Queries) error {
+		var err error
+		brk, err = verifyEntryChain(ctx, q, accountID)
+		return err
+	})
+	return brk, err
+}
+
+// verifyEntryChain returns the first broken link of the chain of an account, nil if the chain is intact.
+// The chain must end at the head recorded on the account, so its last entries cannot be removed either.
+// `q` must read from a single snapshot, see `verifyAccountChain`.
+func verifyEntryChain(ctx context.Context, q *Queries, accountID int64) (*LedgerBreak, error) {
+	account, err := q.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var prevHash []byte
+	var lastEntryID int64
+	for afterID := int64(0); ; {
+		entries, err := q.ListEntriesAfter(ctx, ListEntriesAfterParams{
+			AccountID: accountID,
+			AfterID:   afterID,
+			Limit:     verifyLedgerPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if !bytes.Equal(entry.PrevHash, prevHash) {
+				return &LedgerBreak{accountID, entry.ID, "previous hash doesn't match the previous entry"}, nil
+			}
+			if !bytes.Equal(entry.Hash, hashEntry(entry)) {
+				return &LedgerBreak{accountID, entry.ID, "hash doesn't match the entry's contents"}, nil
+			}
+			prevHash = entry.Hash
+			lastEntryID = entry.ID
+		}
+
+		if len(entries) < verifyLedgerPageSize {
+			if !bytes.Equal(prevHash, account.LastEntryHash) {
+				return &LedgerBreak{accountID, lastEntryID, "chain doesn't end at the account's last entry hash"}, nil
+			}
+			return nil, nil
+		}
+		afterID = entries[len(entries)-1].ID
+	}
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryHash(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	hash := EntryHash(1, -100, createdAt, nil)
+	require.Len(t, hash, 32)
+
+	// the same entry in another time zone
+	require.Equal(t, hash, EntryHash(1, -100, createdAt.In(time.FixedZone("UTC+2", 2*3600)), nil))
+
+	require.NotEqual(t, hash, EntryHash(1, 100, createdAt, nil))
+	require.NotEqual(t, hash, EntryHash(2, -100, createdAt, nil))
+	require.NotEqual(t, hash, EntryHash(1, -100, createdAt.Add(time.Microsecond), nil))
+	require.NotEqual(t, hash, EntryHash(1, -100, createdAt, hash))
+}
+
+func TestEntryHashV2(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	transferID := sql.NullInt64{Int64: 7, Valid: true}
+	hash := EntryHashV2(1, -100, createdAt, transferID, "rent", nil)
+	require.Len(t, hash, 32)
+	require.NotEqual(t, hash, EntryHash(1, -100, createdAt, nil))
+
+	require.NotEqual(t, hash, EntryHashV2(1, -100, createdAt, sql.NullInt64{Int64: 8, Valid: true}, "rent", nil))
+	require.NotEqual(t, hash, EntryHashV2(1, -100, createdAt, sql.NullInt64{}, "rent", nil))
+	require.NotEqual(t, hash, EntryHashV2(1, -100, createdAt, transferID, "rent.", nil))
+	// the description cannot shift the other fields
+	require.NotEqual(t,
+		EntryHashV2(1, -100, createdAt, sql.NullInt64{}, "a|", nil),
+		EntryHashV2(1, -100, createdAt, sql.NullInt64{}, "a", []byte("|")))
+}
+
+func TestCreateChainedEntry(t *testing.T) {
+	account := createRandomAccount(t)
+
+	entry1 := createRandomEntry(t, account)
+	require.Nil(t, entry1.PrevHash)
+
+	entry2 := createRandomEntry(t, account)
+	require.Equal(t, entry1.Hash, entry2.PrevHash)
+
+	// the last entry is the head of the chain
+	account, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, entry2.Hash, account.LastEntryHash)
+
+	// read back, the hash still matches
+	entry3, err := testQueries.GetEntry(context.Background(), entry2.ID)
+	require.NoError(t, err)
+	require.Equal(t, entry2.Hash, hashEntry(entry3))
+}
+
+func TestVerifyLedger(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+
+	var entries []Entry
+	for i := 0; i < 3; i++ {
+		entries = append(entries, createRandomEntry(t, account))
+	}
+
+	breaks, err := store.VerifyLedger(context.Background(), VerifyLedgerParams{AccountID: account.ID})
+	require.NoError(t, err)
+	require.Empty(t, breaks)
+
+	// an insider edits an amount
+	_, err = testDB.Exec("UPDATE entries SET amount = amount + 1 WHERE id = $1", entries[1].ID)
+	require.NoError(t, err)
+
+	breaks, err = store.VerifyLedger(context.Background(), VerifyLedgerParams{AccountID: account.ID})
+	require.NoError(t, err)
+	require.Equal(t, []LedgerBreak{{account.ID, entries[1].ID, "hash doesn't match the entry's contents"}}, breaks)
+
+	// and rehashes it: the next entry doesn't point to it anymore
+	edited, err := testQueries.GetEntry(context.Background(), entries[1].ID)
+	require.NoError(t, err)
+	_, err = testDB.Exec("UPDATE entries SET hash = $2 WHERE id = $1", edited.ID, hashEntry(edited))
+	require.NoError(t, err)
+
+	breaks, err = store.VerifyLedger(context.Background(), VerifyLedgerParams{AccountID: account.ID})
+	require.NoError(t, err)
+	require.Equal(t, []LedgerBreak{{account.ID, entries[2].ID, "previous hash doesn't match the previous entry"}}, breaks)
+
+	// found by the verification of every account too
+	breaks, err = store.VerifyLedger(context.Background(), VerifyLedgerParams{})
+	require.NoError(t, err)
+	require.Contains(t, breaks, LedgerBreak{account.ID, entries[2].ID, "previous hash doesn't match the previous entry"})
+}
+
+func TestVerifyLedgerTruncated(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+
+	var entries []Entry
+	for i := 0; i < 3; i++ {
+		entries = append(entries, createRandomEntry(t, account))
+	}
+
+	// an insider removes the last entry: every remaining link is intact, but the chain stops short of its head
+	_, err := testDB.Exec("DELETE FROM entries WHERE id = $1", entries[2].ID)
+	require.NoError(t, err)
+
+	breaks, err := store.VerifyLedger(context.Background(), VerifyLedgerParams{AccountID: account.ID})
+	require.NoError(t, err)
+	require.Equal(t, []LedgerBreak{{account.ID, entries[1].ID, "chain doesn't end at the account's last entry hash"}}, breaks)
+}
+
+func TestVerifyLedgerHashVersions(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountIn(t, account1.Currency)
+
+	// an entry hashed before the version 2, then a transfer's
+	old := createRandomEntry(t, account1)
+	_, err := testDB.Exec("UPDATE entries SET hash_version = $2, hash = $3 WHERE id = $1",
+		old.ID, EntryHashVersion1, EntryHash(old.AccountID, old.Amount, old.CreatedAt, old.PrevHash))
+	require.NoError(t, err)
+	_, err = testDB.Exec("UPDATE accounts SET last_entry_hash = $2 WHERE id = $1",
+		account1.ID, EntryHash(old.AccountID, old.Amount, old.CreatedAt, old.PrevHash))
+	require.NoError(t, err)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        1,
+		Description:   "rent",
+	})
+	require.NoError(t, err)
+
+	breaks, err := store.VerifyLedger(context.Background(), VerifyLedgerParams{AccountID: account1.ID})
+	require.NoError(t, err)
+	require.Empty(t, breaks)
+
+	// an insider edits the description of the transfer's entry
+	_, err = testDB.Exec("UPDATE entries SET description = 'groceries' WHERE id = $1", result.FromEntry.ID)
+	require.NoError(t, err)
+
+	breaks, err = store.VerifyLedger(context.Background(), VerifyLedgerParams{AccountID: account1.ID})
+	require.NoError(t, err)
+	require.Equal(t, []LedgerBreak{{account1.ID, result.FromEntry.ID, "hash doesn't match the entry's contents"}}, breaks)
+}
+
+func TestTransferTxChainsEntries(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	var prev TransferTxResult
+	for i := 0; i < 3; i++ {
+		result, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        util.RandomInt(1, 10),
+		})
+		require.NoError(t, err)
+		if i > 0 {
+			require.Equal(t, prev.FromEntry.Hash, result.FromEntry.PrevHash)
+			require.Equal(t, prev.ToEntry.Hash, result.ToEntry.PrevHash)
+		}
+		prev = result
+	}
+}
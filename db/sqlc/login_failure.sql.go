@@ -0,0 +1,97 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: login_failure.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const getLoginFailure = `-- name: GetLoginFailure :one
+SELECT key, failures, last_failed_at, locked_until FROM login_failures
+WHERE key = $1 LIMIT 1
+`
+
+func (q *Queries) GetLoginFailure(ctx context.Context, key string) (LoginFailure, error) {
+	row := q.db.QueryRowContext(ctx, getLoginFailure, key)
+	var i LoginFailure
+	err := row.Scan(
+		&i.Key,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const lockLogin = `-- name: LockLogin :one
+UPDATE login_failures
+  set locked_until = $1::timestamptz,
+      failures = 0
+WHERE key = $2
+RETURNING key, failures, last_failed_at, locked_until
+`
+
+type LockLoginParams struct {
+	LockedUntil time.Time `json:"locked_until"`
+	Key         string    `json:"key"`
+}
+
+func (q *Queries) LockLogin(ctx context.Context, arg LockLoginParams) (LoginFailure, error) {
+	row := q.db.QueryRowContext(ctx, lockLogin, arg.LockedUntil, arg.Key)
+	var i LoginFailure
+	err := row.Scan(
+		&i.Key,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_failures (
+  key,
+  failures
+) VALUES (
+  $1, 1
+)
+ON CONFLICT (key) DO UPDATE
+  set failures = CASE
+        WHEN login_failures.last_failed_at < $2 THEN 1
+        ELSE login_failures.failures + 1
+      END,
+      last_failed_at = now()
+RETURNING key, failures, last_failed_at, locked_until
+`
+
+type RecordLoginFailureParams struct {
+	Key         string    `json:"key"`
+	WindowStart time.Time `json:"window_start"`
+}
+
+// counting starts over if the last failure is older than `window_start`
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginFailure, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginFailure, arg.Key, arg.WindowStart)
+	var i LoginFailure
+	err := row.Scan(
+		&i.Key,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const resetLoginFailures = `-- name: ResetLoginFailures :exec
+DELETE FROM login_failures
+WHERE key = $1
+`
+
+func (q *Queries) ResetLoginFailures(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, resetLoginFailures, key)
+	return err
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordLoginFailure(t *testing.T) {
+	key := "user:" + util.RandomOwner()
+	arg := RecordLoginFailureParams{
+		Key:         key,
+		WindowStart: time.Now().Add(-time.Minute),
+	}
+
+	for i := 1; i <= 3; i++ {
+		failure, err := testQueries.RecordLoginFailure(context.Background(), arg)
+		require.NoError(t, err)
+		require.Equal(t, key, failure.Key)
+		require.Equal(t, int32(i), failure.Failures)
+		require.False(t, failure.LockedUntil.Valid)
+	}
+
+	// the last failure is older than the window: counting starts over
+	arg.WindowStart = time.Now().Add(time.Minute)
+	failure, err := testQueries.RecordLoginFailure(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), failure.Failures)
+}
+
+func TestLockLogin(t *testing.T) {
+	key := "ip:" + util.RandomString(8)
+	_, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+		Key:         key,
+		WindowStart: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	lockedUntil := time.Now().Add(time.Minute)
+	failure, err := testQueries.LockLogin(context.Background(), LockLoginParams{
+		Key:         key,
+		LockedUntil: lockedUntil,
+	})
+	require.NoError(t, err)
+	require.Zero(t, failure.Failures)
+	require.WithinDuration(t, lockedUntil, failure.LockedUntil.Time, time.Second)
+
+	err = testQueries.ResetLoginFailures(context.Background(), key)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetLoginFailure(context.Background(), key)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
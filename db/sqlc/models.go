@@ -5,23 +5,135 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 )
 
 type Account struct {
-	ID        int64     `json:"id"`
-	Owner     string    `json:"owner"`
-	Balance   int64     `json:"balance"`
-	Currency  string    `json:"currency"`
+	ID                int64     `json:"id"`
+	Owner             string    `json:"owner"`
+	Balance           int64     `json:"balance"`
+	Currency          string    `json:"currency"`
+	CreatedAt         time.Time `json:"created_at"`
+	Status            string    `json:"status"`
+	HeldAmount        int64     `json:"held_amount"`
+	AvailableBalance  int64     `json:"available_balance"`
+	ApprovalThreshold int64     `json:"approval_threshold"`
+	LastEntryHash     []byte    `json:"last_entry_hash"`
+}
+
+type AccountApprover struct {
+	AccountID int64     `json:"account_id"`
+	Username  string    `json:"username"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type ApiKey struct {
+	ID         int64        `json:"id"`
+	Owner      string       `json:"owner"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	HashedKey  string       `json:"hashed_key"`
+	Scopes     []string     `json:"scopes"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type ApprovalPolicyChange struct {
+	ID          int64     `json:"id"`
+	AccountID   int64     `json:"account_id"`
+	Threshold   int64     `json:"threshold"`
+	Approvers   []string  `json:"approvers"`
+	RequestedBy string    `json:"requested_by"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Ip        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	CreatedAt time.Time       `json:"created_at"`
+	RequestID string          `json:"request_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
 type Entry struct {
 	ID        int64 `json:"id"`
 	AccountID int64 `json:"account_id"`
 	// can be positive or negative
-	Amount    int64     `json:"amount"`
-	CreatedAt time.Time `json:"created_at"`
+	Amount      int64         `json:"amount"`
+	CreatedAt   time.Time     `json:"created_at"`
+	PrevHash    []byte        `json:"prev_hash"`
+	Hash        []byte        `json:"hash"`
+	TransferID  sql.NullInt64 `json:"transfer_id"`
+	Description string        `json:"description"`
+	HashVersion int16         `json:"hash_version"`
+}
+
+type Hold struct {
+	ID             int64         `json:"id"`
+	AccountID      int64         `json:"account_id"`
+	ToAccountID    int64         `json:"to_account_id"`
+	Amount         int64         `json:"amount"`
+	Description    string        `json:"description"`
+	Status         string        `json:"status"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+	CapturedAmount int64         `json:"captured_amount"`
+	TransferID     sql.NullInt64 `json:"transfer_id"`
+	ReleasedAt     sql.NullTime  `json:"released_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+type LoginFailure struct {
+	Key          string       `json:"key"`
+	Failures     int32        `json:"failures"`
+	LastFailedAt time.Time    `json:"last_failed_at"`
+	LockedUntil  sql.NullTime `json:"locked_until"`
+}
+
+type OutboxEvent struct {
+	ID           int64           `json:"id"`
+	Owner        string          `json:"owner"`
+	EventType    string          `json:"event_type"`
+	Payload      json.RawMessage `json:"payload"`
+	CreatedAt    time.Time       `json:"created_at"`
+	DispatchedAt sql.NullTime    `json:"dispatched_at"`
+}
+
+type Statement struct {
+	AccountID      int64     `json:"account_id"`
+	Month          time.Time `json:"month"`
+	OpeningBalance int64     `json:"opening_balance"`
+	ClosingBalance int64     `json:"closing_balance"`
+	TotalCredits   int64     `json:"total_credits"`
+	TotalDebits    int64     `json:"total_debits"`
+	Csv            []byte    `json:"csv"`
+	Pdf            []byte    `json:"pdf"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type TotpRecoveryCode struct {
+	ID         int64        `json:"id"`
+	Username   string       `json:"username"`
+	HashedCode string       `json:"hashed_code"`
+	UsedAt     sql.NullTime `json:"used_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type TotpSecret struct {
+	Username        string       `json:"username"`
+	EncryptedSecret []byte       `json:"encrypted_secret"`
+	ConfirmedAt     sql.NullTime `json:"confirmed_at"`
+	LastUsedStep    int64        `json:"last_used_step"`
+	CreatedAt       time.Time    `json:"created_at"`
 }
 
 type Transfer struct {
@@ -29,8 +141,21 @@ type Transfer struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	// must be positive
-	Amount    int64     `json:"amount"`
-	CreatedAt time.Time `json:"created_at"`
+	Amount            int64           `json:"amount"`
+	CreatedAt         time.Time       `json:"created_at"`
+	ExternalReference string          `json:"external_reference"`
+	Description       string          `json:"description"`
+	Metadata          json.RawMessage `json:"metadata"`
+	Status            string          `json:"status"`
+}
+
+type TransferApproval struct {
+	TransferID  int64          `json:"transfer_id"`
+	RequestedBy string         `json:"requested_by"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	DecidedBy   sql.NullString `json:"decided_by"`
+	DecidedAt   sql.NullTime   `json:"decided_at"`
+	CreatedAt   time.Time      `json:"created_at"`
 }
 
 type User struct {
@@ -40,4 +165,26 @@ type User struct {
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	Role              string    `json:"role"`
+}
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Owner     string    `json:"owner"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID               int64          `json:"id"`
+	WebhookID        int64          `json:"webhook_id"`
+	EventID          int64          `json:"event_id"`
+	Status           string         `json:"status"`
+	Attempts         int32          `json:"attempts"`
+	NextAttemptAt    time.Time      `json:"next_attempt_at"`
+	LastResponseCode sql.NullInt32  `json:"last_response_code"`
+	LastError        sql.NullString `json:"last_error"`
+	DeliveredAt      sql.NullTime   `json:"delivered_at"`
+	CreatedAt        time.Time      `json:"created_at"`
 }
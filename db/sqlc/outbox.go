@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// types of the events written to the outbox, in `outbox_events.event_type`
+const (
+	EventTransferCompleted = "transfer.completed"
+	EventAccountCredited   = "account.credited"
+)
+
+// statuses of a webhook delivery
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed" // out of attempts
+)
+
+// TransferCompletedEvent is the payload of `transfer.completed`, sent to the owners of both accounts.
+type TransferCompletedEvent struct {
+	Transfer Transfer `json:"transfer"`
+}
+
+// AccountCreditedEvent is the payload of `account.credited`, sent to the owner of the credited account.
+type AccountCreditedEvent struct {
+	AccountID  int64  `json:"account_id"`
+	TransferID int64  `json:"transfer_id"`
+	Amount     int64  `json:"amount"`
+	Balance    int64  `json:"balance"`
+	Currency   string `json:"currency"`
+}
+
+// enqueueEvent writes an event for the webhooks of `owner` to the outbox.
+// Like `recordAuditEvent`, it must run in the transaction of the change: the event is sent if, and only if, it's committed.
+func enqueueEvent(ctx context.Context, q *Queries, owner string, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("cannot encode event: %w", err)
+	}
+	_, err = q.CreateOutboxEvent(ctx, CreateOutboxEventParams{
+		Owner:     owner,
+		EventType: eventType,
+		Payload:   data,
+	})
+	return err
+}
+
+// enqueueTransferEvents writes the events of a money transfer to the outbox.
+func enqueueTransferEvents(ctx context.Context, q *Queries, transfer Transfer, from Account, to Account) error {
+	completed := TransferCompletedEvent{Transfer: transfer}
+	err := enqueueEvent(ctx, q, from.Owner, EventTransferCompleted, completed)
+	if err != nil {
+		return err
+	}
+	if to.Owner != from.Owner {
+		err = enqueueEvent(ctx, q, to.Owner, EventTransferCompleted, completed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return enqueueEvent(ctx, q, to.Owner, EventAccountCredited, AccountCreditedEvent{
+		AccountID:  to.ID,
+		TransferID: transfer.ID,
+		Amount:     transfer.Amount,
+		Balance:    to.Balance,
+		Currency:   to.Currency,
+	})
+}
+
+// FanOutOutboxTx turns up to `limit` outbox events into deliveries, one per webhook of their owner,
+// and marks them dispatched. Events being fanned out by another dispatcher are skipped.
+// It returns how many events were fanned out.
+func (store *SQLStore) FanOutOutboxTx(ctx context.Context, limit int32) (int, error) {
+	var events []OutboxEvent
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		var err error
+		events, err = q.ClaimOutboxEvents(ctx, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			_, err = q.CreateWebhookDeliveries(ctx, CreateWebhookDeliveriesParams{
+				EventID: event.ID,
+				Owner:   event.Owner,
+			})
+			if err != nil {
+				return err
+			}
+			err = q.MarkOutboxEventDispatched(ctx, event.ID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(events), err
+}
@@ -0,0 +1,91 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: outbox_event.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const claimOutboxEvents = `-- name: ClaimOutboxEvents :many
+SELECT id, owner, event_type, payload, created_at, dispatched_at FROM outbox_events
+WHERE dispatched_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+// the oldest events left to fan out, skipping those another dispatcher is fanning out
+func (q *Queries) ClaimOutboxEvents(ctx context.Context, limit int32) ([]OutboxEvent, error) {
+	rows, err := q.db.QueryContext(ctx, claimOutboxEvents, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []OutboxEvent{}
+	for rows.Next() {
+		var i OutboxEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.EventType,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.DispatchedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createOutboxEvent = `-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (
+  owner,
+  event_type,
+  payload
+) VALUES (
+  $1, $2, $3
+) RETURNING id, owner, event_type, payload, created_at, dispatched_at
+`
+
+type CreateOutboxEventParams struct {
+	Owner     string          `json:"owner"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) (OutboxEvent, error) {
+	row := q.db.QueryRowContext(ctx, createOutboxEvent, arg.Owner, arg.EventType, arg.Payload)
+	var i OutboxEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.EventType,
+		&i.Payload,
+		&i.CreatedAt,
+		&i.DispatchedAt,
+	)
+	return i, err
+}
+
+const markOutboxEventDispatched = `-- name: MarkOutboxEventDispatched :exec
+UPDATE outbox_events
+  set dispatched_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) MarkOutboxEventDispatched(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markOutboxEventDispatched, id)
+	return err
+}
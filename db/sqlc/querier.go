@@ -6,24 +6,132 @@ package db
 
 import (
 	"context"
+	"time"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	AddAccountHeldAmount(ctx context.Context, arg AddAccountHeldAmountParams) (Account, error)
+	// holds being released by another transaction are skipped, they're locked until it ends
+	ClaimExpiredHolds(ctx context.Context, limit int32) ([]Hold, error)
+	// the transfers being approved or rejected by another transaction are skipped, they're locked until it ends
+	ClaimExpiredTransferApprovals(ctx context.Context, limit int32) ([]TransferApproval, error)
+	// the oldest events left to fan out, skipping those another dispatcher is fanning out
+	ClaimOutboxEvents(ctx context.Context, limit int32) ([]OutboxEvent, error)
+	// the deliveries due, leased until `lease_until` so no other dispatcher picks them up meanwhile
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
+	ConfirmTOTPSecret(ctx context.Context, username string) (TotpSecret, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateAccountApprover(ctx context.Context, arg CreateAccountApproverParams) (AccountApprover, error)
+	CreateApprovalPolicyChange(ctx context.Context, arg CreateApprovalPolicyChangeParams) (ApprovalPolicyChange, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
+	// use `createChainedEntry` rather than this query, it computes the hashes
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error)
+	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) (OutboxEvent, error)
+	// a transfer waiting for approval, see `CreateTransferApproval`
+	CreatePendingTransfer(ctx context.Context, arg CreatePendingTransferParams) (Transfer, error)
+	// a statement generated concurrently is kept, they're the same
+	CreateStatement(ctx context.Context, arg CreateStatementParams) error
+	CreateTOTPRecoveryCode(ctx context.Context, arg CreateTOTPRecoveryCodeParams) (TotpRecoveryCode, error)
+	// (re-)start an enrollment, unless TOTP is already enabled: then no row is returned
+	CreateTOTPSecret(ctx context.Context, arg CreateTOTPSecretParams) (TotpSecret, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
+	CreateTransferApproval(ctx context.Context, arg CreateTransferApprovalParams) (TransferApproval, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	// an event is delivered to every webhook of its owner
+	CreateWebhookDeliveries(ctx context.Context, arg CreateWebhookDeliveriesParams) (int64, error)
+	// `decided_by` is NULL when the approval expired
+	DecideTransferApproval(ctx context.Context, arg DecideTransferApprovalParams) (TransferApproval, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	DeleteAccountApprovers(ctx context.Context, accountID int64) error
+	// the pending change of an account, replaced or applied
+	DeleteApprovalPolicyChange(ctx context.Context, accountID int64) error
+	DeleteTOTPRecoveryCodes(ctx context.Context, username string) error
+	// only the owner can delete a webhook, its deliveries are deleted with it
+	DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (Webhook, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	GetAccountApprover(ctx context.Context, arg GetAccountApproverParams) (AccountApprover, error)
+	// the balance of an account right before `at`, from its entries
+	GetAccountBalanceAt(ctx context.Context, arg GetAccountBalanceAtParams) (int64, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetApprovalPolicyChangeForUpdate(ctx context.Context, id int64) (ApprovalPolicyChange, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetHold(ctx context.Context, id int64) (Hold, error)
+	GetHoldForUpdate(ctx context.Context, id int64) (Hold, error)
+	GetLastEntry(ctx context.Context, accountID int64) (Entry, error)
+	GetLoginFailure(ctx context.Context, key string) (LoginFailure, error)
+	GetStatement(ctx context.Context, arg GetStatementParams) (Statement, error)
+	GetTOTPSecret(ctx context.Context, username string) (TotpSecret, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferApproval(ctx context.Context, transferID int64) (TransferApproval, error)
+	GetTransferByExternalReference(ctx context.Context, arg GetTransferByExternalReferenceParams) (Transfer, error)
+	GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	// access tokens issued before are no longer accepted
+	GetUserPasswordChangedAt(ctx context.Context, username string) (time.Time, error)
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	ListAPIKeys(ctx context.Context, arg ListAPIKeysParams) ([]ApiKey, error)
+	ListAccountApprovers(ctx context.Context, accountID int64) ([]AccountApprover, error)
+	// every account, a page at a time
+	ListAccountIDs(ctx context.Context, arg ListAccountIDsParams) ([]int64, error)
+	// the transfer history of an account, latest first;
+	// `search` is a web search query on the descriptions (e.g. `rent -deposit`), ignored if empty
+	ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]Transfer, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	// every filter is optional
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	// a page of the chain of an account, for its verification
+	ListEntriesAfter(ctx context.Context, arg ListEntriesAfterParams) ([]Entry, error)
+	// the changes `username` can approve, oldest first: not expired, and not requested by `username`
+	ListPendingApprovalPolicyChanges(ctx context.Context, arg ListPendingApprovalPolicyChangesParams) ([]ApprovalPolicyChange, error)
+	// the transfers `username` can approve, oldest first: not expired, and not requested by `username`
+	ListPendingTransfersForApprover(ctx context.Context, arg ListPendingTransfersForApproverParams) ([]Transfer, error)
+	// the entries of an account in [from_time, to_time), with the other account of their transfer
+	ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	// the delivery log of a webhook, newest first
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhooks(ctx context.Context, arg ListWebhooksParams) ([]Webhook, error)
+	// locks the accounts in ID order, so transactions locking overlapping sets of accounts can't deadlock;
+	// missing IDs are left out
+	LockAccounts(ctx context.Context, ids []int64) ([]Account, error)
+	LockLogin(ctx context.Context, arg LockLoginParams) (LoginFailure, error)
+	MarkOutboxEventDispatched(ctx context.Context, id int64) error
+	// delivered to the listeners of `channel` once the transaction commits
+	NotifyAccountEvent(ctx context.Context, arg NotifyAccountEventParams) error
+	// every account whose balance doesn't equal the sum of its entries
+	ReconcileLedger(ctx context.Context) ([]ReconcileLedgerRow, error)
+	// counting starts over if the last failure is older than `window_start`
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginFailure, error)
+	// the same password hashed with the current algorithm, skipped if the password changed meanwhile
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error)
+	// `captured_amount` & `transfer_id` are only set by a capture
+	ReleaseHold(ctx context.Context, arg ReleaseHoldParams) (Hold, error)
+	ResetLoginFailures(ctx context.Context, key string) error
+	// only the owner can revoke a key, revoking twice keeps the first timestamp
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error)
+	// all the keys of a user whose password changed, the ones already revoked keep their timestamp
+	RevokeUserAPIKeys(ctx context.Context, owner string) (int64, error)
+	UpdateAPIKeyLastUsed(ctx context.Context, id int64) error
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateAccountApprovalThreshold(ctx context.Context, arg UpdateAccountApprovalThresholdParams) (Account, error)
+	// the head of the chain of entries of the account, see `createChainedEntry`
+	UpdateAccountLastEntryHash(ctx context.Context, arg UpdateAccountLastEntryHashParams) error
+	UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error)
+	UpdateTransferStatus(ctx context.Context, arg UpdateTransferStatusParams) (Transfer, error)
+	// a new password, chosen by the user or reset by an operator
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) (WebhookDelivery, error)
+	// 0 rows if the code doesn't exist or has already been used
+	UseTOTPRecoveryCode(ctx context.Context, arg UseTOTPRecoveryCodeParams) (int64, error)
+	// 0 rows if the step (or a later one) has already been used, i.e. the code is replayed
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
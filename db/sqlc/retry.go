@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrTxConflict is returned when a transaction is still aborted by concurrent transactions
+// (serialization failure or deadlock) after all retry attempts.
+// The request is fine, trying again later may succeed.
+var ErrTxConflict = errors.New("transaction aborted by a concurrent transaction")
+
+// SQLSTATE codes of transactions Postgres aborts only because of concurrent ones,
+// so re-running the whole transaction is safe & likely to succeed.
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// TxRetryPolicy controls how `execTx` retries aborted transactions.
+type TxRetryPolicy struct {
+	MaxAttempts int           // including the 1st one, 1 (or less) disables retries
+	BaseDelay   time.Duration // max backoff before the 2nd attempt, doubled for each next attempt
+	MaxDelay    time.Duration // cap of the backoff
+}
+
+// DefaultTxRetryPolicy is used by `NewStore` unless `WithTxRetryPolicy` is given.
+var DefaultTxRetryPolicy = TxRetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    500 * time.Millisecond,
+}
+
+// backoff returns how long to wait after the `attempt`-th failed attempt.
+// It uses "full jitter" (a random duration up to the exponential backoff),
+// so transactions that deadlocked each other don't retry at the same time & collide again.
+func (policy TxRetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > policy.MaxDelay { // `delay <= 0` on overflow
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay + 1)
+}
+
+// StoreOption configures optional settings of `NewStore`.
+type StoreOption func(store *SQLStore)
+
+// WithTxRetryPolicy overrides `DefaultTxRetryPolicy`.
+func WithTxRetryPolicy(policy TxRetryPolicy) StoreOption {
+	return func(store *SQLStore) {
+		store.retryPolicy = policy
+	}
+}
+
+// isRetryableTxError reports whether Postgres aborted the transaction because of a concurrent one.
+func isRetryableTxError(err error) bool {
+	code := sqlState(err)
+	return code == sqlStateSerializationFailure || code == sqlStateDeadlockDetected
+}
+
+func sqlState(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
+
+// sleepContext waits for `d`, unless `ctx` is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
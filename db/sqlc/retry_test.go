@@ -0,0 +1,40 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	require.True(t, isRetryableTxError(&pq.Error{Code: sqlStateSerializationFailure}))
+	require.True(t, isRetryableTxError(&pq.Error{Code: sqlStateDeadlockDetected}))
+	require.True(t, isRetryableTxError(fmt.Errorf("tx err: %w", &pq.Error{Code: sqlStateDeadlockDetected})))
+
+	require.False(t, isRetryableTxError(nil))
+	require.False(t, isRetryableTxError(errors.New("some error")))
+	require.False(t, isRetryableTxError(&pq.Error{Code: "23505"})) // unique_violation
+}
+
+func TestTxRetryPolicyBackoff(t *testing.T) {
+	policy := TxRetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+	}
+
+	for attempt := 1; attempt < 100; attempt++ {
+		delay := policy.backoff(attempt)
+		require.GreaterOrEqual(t, delay, time.Duration(0))
+		require.LessOrEqual(t, delay, policy.MaxDelay)
+		if attempt == 1 {
+			require.LessOrEqual(t, delay, policy.BaseDelay)
+		}
+	}
+
+	require.Zero(t, TxRetryPolicy{}.backoff(1))
+}
@@ -0,0 +1,79 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: statement.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const createStatement = `-- name: CreateStatement :exec
+INSERT INTO statements (
+  account_id,
+  month,
+  opening_balance,
+  closing_balance,
+  total_credits,
+  total_debits,
+  csv,
+  pdf
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+) ON CONFLICT (account_id, month) DO NOTHING
+`
+
+type CreateStatementParams struct {
+	AccountID      int64     `json:"account_id"`
+	Month          time.Time `json:"month"`
+	OpeningBalance int64     `json:"opening_balance"`
+	ClosingBalance int64     `json:"closing_balance"`
+	TotalCredits   int64     `json:"total_credits"`
+	TotalDebits    int64     `json:"total_debits"`
+	Csv            []byte    `json:"csv"`
+	Pdf            []byte    `json:"pdf"`
+}
+
+// a statement generated concurrently is kept, they're the same
+func (q *Queries) CreateStatement(ctx context.Context, arg CreateStatementParams) error {
+	_, err := q.db.ExecContext(ctx, createStatement,
+		arg.AccountID,
+		arg.Month,
+		arg.OpeningBalance,
+		arg.ClosingBalance,
+		arg.TotalCredits,
+		arg.TotalDebits,
+		arg.Csv,
+		arg.Pdf,
+	)
+	return err
+}
+
+const getStatement = `-- name: GetStatement :one
+SELECT account_id, month, opening_balance, closing_balance, total_credits, total_debits, csv, pdf, created_at FROM statements
+WHERE account_id = $1 AND month = $2 LIMIT 1
+`
+
+type GetStatementParams struct {
+	AccountID int64     `json:"account_id"`
+	Month     time.Time `json:"month"`
+}
+
+func (q *Queries) GetStatement(ctx context.Context, arg GetStatementParams) (Statement, error) {
+	row := q.db.QueryRowContext(ctx, getStatement, arg.AccountID, arg.Month)
+	var i Statement
+	err := row.Scan(
+		&i.AccountID,
+		&i.Month,
+		&i.OpeningBalance,
+		&i.ClosingBalance,
+		&i.TotalCredits,
+		&i.TotalDebits,
+		&i.Csv,
+		&i.Pdf,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListStatementEntries(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+	from := time.Now().Add(-time.Minute)
+
+	result, err := NewStore(testDB).TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	entry := createRandomEntry(t, account1) // not part of a transfer
+
+	entries, err := testQueries.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		FromTime:  from,
+		ToTime:    time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Equal(t, result.FromEntry.ID, entries[0].ID)
+	require.Equal(t, int64(-10), entries[0].Amount)
+	require.Equal(t, result.Transfer.ID, entries[0].TransferID.Int64)
+	require.Equal(t, account2.ID, entries[0].CounterpartyAccountID)
+	require.Equal(t, account2.Owner, entries[0].CounterpartyOwner)
+
+	require.Equal(t, entry.ID, entries[1].ID)
+	require.False(t, entries[1].TransferID.Valid)
+	require.Zero(t, entries[1].CounterpartyAccountID)
+	require.Empty(t, entries[1].CounterpartyOwner)
+
+	// the other side of the transfer
+	entries, err = testQueries.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account2.ID,
+		FromTime:  from,
+		ToTime:    time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, account1.ID, entries[0].CounterpartyAccountID)
+
+	balance, err := testQueries.GetAccountBalanceAt(context.Background(), GetAccountBalanceAtParams{
+		AccountID: account1.ID,
+		At:        time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, entry.Amount-10, balance)
+
+	balance, err = testQueries.GetAccountBalanceAt(context.Background(), GetAccountBalanceAtParams{
+		AccountID: account1.ID,
+		At:        from,
+	})
+	require.NoError(t, err)
+	require.Zero(t, balance)
+}
+
+func TestStatementsAreImmutable(t *testing.T) {
+	account := createRandomAccount(t)
+	arg := CreateStatementParams{
+		AccountID:      account.ID,
+		Month:          time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC),
+		OpeningBalance: util.RandomMoney(),
+		ClosingBalance: util.RandomMoney(),
+		Csv:            []byte(util.RandomString(10)),
+		Pdf:            []byte(util.RandomString(10)),
+	}
+	require.NoError(t, testQueries.CreateStatement(context.Background(), arg))
+
+	// a concurrent generation doesn't replace it
+	again := arg
+	again.Csv = []byte(util.RandomString(10))
+	require.NoError(t, testQueries.CreateStatement(context.Background(), again))
+
+	statement, err := testQueries.GetStatement(context.Background(), GetStatementParams{AccountID: account.ID, Month: arg.Month})
+	require.NoError(t, err)
+	require.Equal(t, arg.Csv, statement.Csv)
+	require.Equal(t, arg.Pdf, statement.Pdf)
+	require.Equal(t, arg.Month, statement.Month.UTC())
+
+	_, err = testDB.ExecContext(context.Background(), "UPDATE statements SET csv = '' WHERE account_id = $1", account.ID)
+	require.Error(t, err)
+}
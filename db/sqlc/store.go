@@ -3,7 +3,13 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Store provides all functions to execute [DB] queries and transaction.
@@ -11,49 +17,121 @@ import (
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	BatchTransferTx(ctx context.Context, arg BatchTransferTxParams) (BatchTransferTxResult, error)
+	ConfirmTOTPTx(ctx context.Context, arg ConfirmTOTPTxParams) (TotpSecret, error)
+	AuditedTx(ctx context.Context, fn func(context.Context, Querier) (AuditChange, error)) error
+	VerifyLedger(ctx context.Context, arg VerifyLedgerParams) ([]LedgerBreak, error)
+	FanOutOutboxTx(ctx context.Context, limit int32) (int, error)
+	CreateHoldTx(ctx context.Context, arg CreateHoldParams) (Hold, error)
+	CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (CaptureHoldTxResult, error)
+	VoidHoldTx(ctx context.Context, id int64) (Hold, error)
+	ExpireHoldsTx(ctx context.Context, limit int32) (int, error)
+	RequestTransferTx(ctx context.Context, arg RequestTransferTxParams) (TransferApprovalTxResult, error)
+	ApproveTransferTx(ctx context.Context, arg DecideTransferTxParams) (ApproveTransferTxResult, error)
+	RejectTransferTx(ctx context.Context, arg DecideTransferTxParams) (TransferApprovalTxResult, error)
+	ExpireTransferApprovalsTx(ctx context.Context, limit int32) (int, error)
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (version int64, dirty bool, err error)
 }
 
 // SQLStore provides all functions to execute [SQL] queries and transaction.
 // SQLStore is a real implementation of `Store` interface that talks to PostgreSQL.
 type SQLStore struct {
-	*Queries // Struct Embedding
-	db       *sql.DB
+	*Queries    // Struct Embedding
+	db          *sql.DB
+	retryPolicy TxRetryPolicy
 }
 
 // NewStore creates a new SQLStore object.
-func NewStore(db *sql.DB) Store {
-	return &SQLStore{
-		db:      db,
-		Queries: New(db),
+func NewStore(db *sql.DB, opts ...StoreOption) Store {
+	store := &SQLStore{
+		db:          db,
+		Queries:     New(tracedDBTX{db}),
+		retryPolicy: DefaultTxRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(store)
 	}
+	return store
 }
 
 // ExecTx executes a function within a database transaction.
-func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+// `opts` sets the isolation level & read-only mode, nil uses the defaults (READ COMMITTED, read-write).
+// `fn` receives the context of the `execTx` span, so queries run by `fn` show up as its children.
+// If Postgres aborts the transaction because of a concurrent one (serialization failure or deadlock),
+// the whole transaction is retried with `fn`, so `fn` must be safe to run again.
+// It returns the number of attempts made.
+func (store *SQLStore) execTx(
+	ctx context.Context,
+	opts *sql.TxOptions,
+	fn func(context.Context, *Queries) error,
+) (attempts int, err error) {
+	ctx, span := tracer.Start(ctx, "execTx")
+	defer span.End()
+
+	for attempts = 1; ; attempts++ {
+		err = store.runTx(ctx, opts, fn)
+		if err == nil || !isRetryableTxError(err) || attempts >= store.retryPolicy.MaxAttempts {
+			break
+		}
+
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("tx.attempt", attempts),
+			attribute.String("db.sqlstate", sqlState(err)),
+		))
+		if sleepErr := sleepContext(ctx, store.retryPolicy.backoff(attempts)); sleepErr != nil {
+			break // keep the tx error, it's more useful than "context canceled"
+		}
+	}
+	span.SetAttributes(attribute.Int("tx.attempts", attempts))
+
+	if isRetryableTxError(err) {
+		err = fmt.Errorf("%w after %d attempt(s): %w", ErrTxConflict, attempts, err)
+	}
+	recordError(span, err)
+	return attempts, err
+}
 
-	tx, err := store.db.BeginTx(ctx, nil) // Start a database transaction with the provided context
+// runTx runs a single attempt of `execTx`.
+func (store *SQLStore) runTx(ctx context.Context, opts *sql.TxOptions, fn func(context.Context, *Queries) error) error {
+	tx, err := store.db.BeginTx(ctx, opts) // Start a database transaction with the provided context
 	if err != nil {
 		return err
 	}
 
-	q := New(tx) // Create a new Queries instance bound to the transaction
+	q := New(tracedDBTX{tx}) // Create a new Queries instance bound to the transaction
 
-	err = fn(q) // Execute the provided function `fn` using the transaction-bound Queries
+	err = fn(ctx, q) // Execute the provided function `fn` using the transaction-bound Queries
 	if err != nil {
 		// rb - rollback
 		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+			return fmt.Errorf("tx err: %w, rb err: %v", err, rbErr)
 		}
 		return err
 	}
-	return tx.Commit() // Commit the transaction if `fn` succeeds
+
+	// trace `COMMIT` separately, it's where we wait for the WAL flush
+	// (and where SERIALIZABLE transactions may fail)
+	_, commitSpan := tracer.Start(ctx, "execTx.Commit")
+	defer commitSpan.End()
+
+	err = tx.Commit() // Commit the transaction if `fn` succeeds
+	recordError(commitSpan, err)
+	return err
 }
 
+// ErrDuplicateTransfer is returned when the from account already made a transfer with the same external reference.
+var ErrDuplicateTransfer = errors.New("duplicate transfer")
+
 // TransferTxParams contains the input parameters of the transfer transaction
 type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	// unique per from account if set, see `ErrDuplicateTransfer`
+	ExternalReference string            `json:"external_reference"`
+	Description       string            `json:"description"` // copied onto both entries
+	Metadata          map[string]string `json:"metadata"`
 }
 
 // TransferTxResult is the result of the transfer transaction
@@ -63,138 +141,237 @@ type TransferTxResult struct {
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+	// how many times the transaction ran, > 1 if it was retried after a deadlock or serialization failure
+	Attempts int `json:"attempts"`
 }
 
 // for DEBUG:
 // var txKey = struct{}{} // txKey is a unique context key for transaction metadata.
 
 // TransferTx performs a money transfer from one account to the other.
-// It creates the transfer, add account entries, and update accounts' balance within a database transaction.
+// It creates the transfer, update accounts' balance, and add account entries within a database transaction.
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult // Initialize the result structure to store transaction details
 
+	ctx, span := tracer.Start(ctx, "TransferTx")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("transfer.from_account_id", arg.FromAccountID),
+		attribute.Int64("transfer.to_account_id", arg.ToAccountID),
+		attribute.Int64("transfer.amount", arg.Amount),
+	)
+
 	// Execute the transaction logic within a database transaction
-	err := store.execTx(ctx, func(q *Queries) error {
-		var err error
+	var err error
+	result.Attempts, err = store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		return makeTransfer(ctx, q, arg, 0, &result)
+	})
+
+	if isDuplicateTransferError(err) {
+		err = fmt.Errorf("%w: %s", ErrDuplicateTransfer, arg.ExternalReference)
+	}
+	recordError(span, err)
+
+	// Return the transaction result and any error encountered
+	return result, err
+}
+
+// makeTransfer runs the transfer of `TransferTx` in the transaction of `q`, so other transactions can make transfers too.
+// `reserved` is the amount already held for the transfer on the from account, see `settleTransfer`.
+func makeTransfer(ctx context.Context, q *Queries, arg TransferTxParams, reserved int64, result *TransferTxResult) error {
+	var err error
+
+	// for DEBUG:
+	// txName := ctx.Value(txKey) // Extract transaction name from the context for logging
+
+	// for DEBUG:
+	// fmt.Println(txName, "create transfer")
+	metadata, err := transferMetadata(arg.Metadata)
+	if err != nil {
+		return err
+	}
+	result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
+		FromAccountID:     arg.FromAccountID,
+		ToAccountID:       arg.ToAccountID,
+		Amount:            arg.Amount,
+		ExternalReference: arg.ExternalReference,
+		Description:       arg.Description,
+		Metadata:          metadata,
+	})
+	if err != nil {
+		return err
+	}
+	return settleTransfer(ctx, q, result, reserved)
+}
 
+// settleTransfer moves the money of `result.Transfer`, once created, and records it.
+// It updates the balances of both accounts & creates the entries, in the transaction of `q`.
+// The available balance of the from account must cover the amount, on top of the `reserved` amount
+// already held for this transfer (which the caller releases), otherwise `ErrInsufficientFunds` is returned.
+func settleTransfer(ctx context.Context, q *Queries, result *TransferTxResult, reserved int64) error {
+	var err error
+	arg := result.Transfer
+
+	// To avoid deadlocks, the order of operations is critical.
+	// Always lock rows in a consistent order based on account IDs.
+	// If FromAccountID < ToAccountID, process FromAccount first, then ToAccount.
+	// Otherwise, process ToAccount first, then FromAccount.
+	// This ensures that transactions accessing the same rows acquire locks in the same order, avoiding circular waits.
+	if arg.FromAccountID < arg.ToAccountID {
+		/* before refactor (old version)
+		// before AddAccountBalance (NO KEY UPDATE), old version
 		// for DEBUG:
-		// txName := ctx.Value(txKey) // Extract transaction name from the context for logging
+		// fmt.Println(txName, "get account 1")
+		// account1, err := q.GetAccountForUpdate(ctx, arg.FromAccountID)
+		// if err != nil {
+		// 	return err
+		// }
 
 		// for DEBUG:
-		// fmt.Println(txName, "create transfer")
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
-			FromAccountID: arg.FromAccountID,
-			ToAccountID:   arg.ToAccountID,
-			Amount:        arg.Amount,
+		// fmt.Println(txName, "update account 1")
+		// result.FromAccount, err = q.UpdateAccount(ctx, UpdateAccountParams{
+		// 	ID:      arg.FromAccountID,
+		// 	Balance: account1.Balance - arg.Amount,
+		// })
+		// if err != nil {
+		// 	return err
+		// }
+		result.FromAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     arg.FromAccountID,
+			Amount: -arg.Amount,
 		})
 		if err != nil {
 			return err
 		}
 
+		// before AddAccountBalance (NO KEY UPDATE). old version
+		// for DEBUG:
+		// fmt.Println(txName, "get account 2")
+		// account2, err := q.GetAccountForUpdate(ctx, arg.ToAccountID)
+		// if err != nil {
+		// 	return err
+		// }
+
 		// for DEBUG:
-		// fmt.Println(txName, "create entry 1")
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
+		// fmt.Println(txName, "update account 2")
+		// result.ToAccount, err = q.UpdateAccount(ctx, UpdateAccountParams{
+		// 	ID:      arg.ToAccountID,
+		// 	Balance: account2.Balance + arg.Amount,
+		// })
+		// if err != nil {
+		// 	return err
+		// }
+		result.ToAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     arg.ToAccountID,
+			Amount: arg.Amount,
+		})
+		if err != nil {
+			return err
+		} */
+		result.FromAccount, result.ToAccount, err =
+			addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
+	} else {
+		/* before refactor (old version)
+		result.ToAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     arg.ToAccountID,
+			Amount: arg.Amount,
 		})
 		if err != nil {
 			return err
 		}
 
-		// for DEBUG:
-		// fmt.Println(txName, "create entry 2")
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
+		result.FromAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     arg.FromAccountID,
+			Amount: -arg.Amount,
 		})
 		if err != nil {
 			return err
-		}
+		} */
+		result.ToAccount, result.FromAccount, err =
+			addMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
+	}
 
-		// To avoid deadlocks, the order of operations is critical.
-		// Always lock rows in a consistent order based on account IDs.
-		// If FromAccountID < ToAccountID, process FromAccount first, then ToAccount.
-		// Otherwise, process ToAccount first, then FromAccount.
-		// This ensures that transactions accessing the same rows acquire locks in the same order, avoiding circular waits.
-		if arg.FromAccountID < arg.ToAccountID {
-			/* before refactor (old version)
-			// before AddAccountBalance (NO KEY UPDATE), old version
-			// for DEBUG:
-			// fmt.Println(txName, "get account 1")
-			// account1, err := q.GetAccountForUpdate(ctx, arg.FromAccountID)
-			// if err != nil {
-			// 	return err
-			// }
-
-			// for DEBUG:
-			// fmt.Println(txName, "update account 1")
-			// result.FromAccount, err = q.UpdateAccount(ctx, UpdateAccountParams{
-			// 	ID:      arg.FromAccountID,
-			// 	Balance: account1.Balance - arg.Amount,
-			// })
-			// if err != nil {
-			// 	return err
-			// }
-			result.FromAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-				ID:     arg.FromAccountID,
-				Amount: -arg.Amount,
-			})
-			if err != nil {
-				return err
-			}
-
-			// before AddAccountBalance (NO KEY UPDATE). old version
-			// for DEBUG:
-			// fmt.Println(txName, "get account 2")
-			// account2, err := q.GetAccountForUpdate(ctx, arg.ToAccountID)
-			// if err != nil {
-			// 	return err
-			// }
-
-			// for DEBUG:
-			// fmt.Println(txName, "update account 2")
-			// result.ToAccount, err = q.UpdateAccount(ctx, UpdateAccountParams{
-			// 	ID:      arg.ToAccountID,
-			// 	Balance: account2.Balance + arg.Amount,
-			// })
-			// if err != nil {
-			// 	return err
-			// }
-			result.ToAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-				ID:     arg.ToAccountID,
-				Amount: arg.Amount,
-			})
-			if err != nil {
-				return err
-			} */
-			result.FromAccount, result.ToAccount, err =
-				addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
-		} else {
-			/* before refactor (old version)
-			result.ToAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-				ID:     arg.ToAccountID,
-				Amount: arg.Amount,
-			})
-			if err != nil {
-				return err
-			}
-
-			result.FromAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-				ID:     arg.FromAccountID,
-				Amount: -arg.Amount,
-			})
-			if err != nil {
-				return err
-			} */
-			result.ToAccount, result.FromAccount, err =
-				addMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
-		}
+	// a deadlock or serialization failure shows up here, return it so the tx is rolled back & retried
+	if err != nil {
+		return err
+	}
+
+	// checked on the updated row, locked until the end of the transaction, so concurrent transfers can't both pass
+	if result.FromAccount.AvailableBalance+reserved < 0 {
+		return fmt.Errorf("%w: %d available", ErrInsufficientFunds, result.FromAccount.AvailableBalance+arg.Amount+reserved)
+	}
+
+	// the entries are created once both accounts are locked by the balance updates,
+	// so concurrent transfers append to the chain of an account one after the other
+	return recordTransfer(ctx, q, result)
+}
 
-		return nil
+// recordTransfer creates the entries of a transfer whose balances are updated, and publishes its events.
+// The caller must hold the locks of both accounts, see `createChainedEntry`.
+func recordTransfer(ctx context.Context, q *Queries, result *TransferTxResult) error {
+	var err error
+	result.FromEntry, err = createChainedEntry(ctx, q, CreateEntryParams{
+		AccountID:   result.Transfer.FromAccountID,
+		Amount:      -result.Transfer.Amount,
+		TransferID:  sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+		Description: result.Transfer.Description,
 	})
+	if err != nil {
+		return err
+	}
 
-	// Return the transaction result and any error encountered
-	return result, err
+	result.ToEntry, err = createChainedEntry(ctx, q, CreateEntryParams{
+		AccountID:   result.Transfer.ToAccountID,
+		Amount:      result.Transfer.Amount,
+		TransferID:  sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+		Description: result.Transfer.Description,
+	})
+	if err != nil {
+		return err
+	}
+	// the entries are the new heads of the chains, see `createChainedEntry`
+	result.FromAccount.LastEntryHash = result.FromEntry.Hash
+	result.ToAccount.LastEntryHash = result.ToEntry.Hash
+
+	// open balance streams are notified, only once the transfer is committed
+	err = publishAccountEvent(ctx, q, result.FromAccount, result.FromEntry)
+	if err != nil {
+		return err
+	}
+	err = publishAccountEvent(ctx, q, result.ToAccount, result.ToEntry)
+	if err != nil {
+		return err
+	}
+
+	// downstream systems are notified through webhooks, only once the transfer is committed
+	err = enqueueTransferEvents(ctx, q, result.Transfer, result.FromAccount, result.ToAccount)
+	if err != nil {
+		return err
+	}
+
+	return recordAuditEvent(ctx, q, AuditChange{
+		Action: AuditActionTransferCreate,
+		Target: fmt.Sprintf("transfer:%d", result.Transfer.ID),
+		After:  result.Transfer,
+	})
+}
+
+// transferMetadata encodes the metadata of a transfer, `{}` if there's none.
+func transferMetadata(metadata map[string]string) (json.RawMessage, error) {
+	if metadata == nil {
+		return json.RawMessage("{}"), nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode metadata: %w", err)
+	}
+	return data, nil
+}
+
+// isDuplicateTransferError reports whether `err` violates the uniqueness of the external references.
+func isDuplicateTransferError(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Constraint == "transfers_from_account_id_external_reference_key"
 }
 
 func addMoney(
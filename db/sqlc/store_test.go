@@ -2,9 +2,13 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
 )
 
@@ -190,3 +194,117 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
 
 }
+
+func TestExecTxRetry(t *testing.T) {
+	store := NewStore(testDB, WithTxRetryPolicy(TxRetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+	})).(*SQLStore)
+
+	// fails with a deadlock twice, then succeeds
+	calls := 0
+	attempts, err := store.execTx(context.Background(), nil, func(ctx context.Context, q *Queries) error {
+		calls++
+		if calls < 3 {
+			return &pq.Error{Code: sqlStateDeadlockDetected}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, 3, calls)
+
+	// always fails with a serialization failure, gives up after `MaxAttempts`
+	attempts, err = store.execTx(context.Background(), nil, func(ctx context.Context, q *Queries) error {
+		return &pq.Error{Code: sqlStateSerializationFailure}
+	})
+	require.ErrorIs(t, err, ErrTxConflict)
+	require.Equal(t, 3, attempts)
+
+	// other errors are not retried
+	attempts, err = store.execTx(context.Background(), nil, func(ctx context.Context, q *Queries) error {
+		return sql.ErrNoRows
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Equal(t, 1, attempts)
+}
+
+func TestExecTxOptions(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+	account := createRandomAccount(t)
+
+	// a read-only tx cannot move money
+	_, err := store.execTx(context.Background(), &sql.TxOptions{ReadOnly: true}, func(ctx context.Context, q *Queries) error {
+		_, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{ID: account.ID, Amount: 10})
+		return err
+	})
+	require.Error(t, err)
+
+	_, err = store.execTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable}, func(ctx context.Context, q *Queries) error {
+		_, err := q.GetAccount(ctx, account.ID)
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func TestTransferTxExternalReference(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	arg := TransferTxParams{
+		FromAccountID:     account1.ID,
+		ToAccountID:       account2.ID,
+		Amount:            10,
+		ExternalReference: util.RandomString(12),
+	}
+	result, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ExternalReference, result.Transfer.ExternalReference)
+	require.JSONEq(t, `{}`, string(result.Transfer.Metadata))
+
+	transfer, err := store.GetTransferByExternalReference(context.Background(), GetTransferByExternalReferenceParams{
+		FromAccountID:     account1.ID,
+		ExternalReference: arg.ExternalReference,
+	})
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer.ID, transfer.ID)
+
+	// the same reference is only paid once from an account
+	_, err = store.TransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrDuplicateTransfer)
+
+	// but may be used by another one
+	arg.FromAccountID, arg.ToAccountID = account2.ID, account1.ID
+	_, err = store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	// transfers without a reference are never duplicates
+	arg.ExternalReference = ""
+	_, err = store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	_, err = store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+}
+
+func TestTransferTxDescription(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Description:   "rent for May",
+		Metadata:      map[string]string{"invoice": "42"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "rent for May", result.Transfer.Description)
+	require.JSONEq(t, `{"invoice": "42"}`, string(result.Transfer.Metadata))
+
+	// copied onto both entries
+	require.Equal(t, "rent for May", result.FromEntry.Description)
+	require.Equal(t, "rent for May", result.ToEntry.Description)
+}
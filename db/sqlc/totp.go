@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ConfirmTOTPTxParams contains the input parameters of the TOTP confirmation transaction
+type ConfirmTOTPTxParams struct {
+	Username string
+	// time step of the first code, so it cannot be replayed to log in
+	Step int64
+	// hashes of the new recovery codes, replacing any previous ones
+	HashedRecoveryCodes []string
+}
+
+// ConfirmTOTPTx enables TOTP of a user once the first code is verified, and stores the recovery codes.
+// It returns `sql.ErrNoRows` if there is no pending enrollment.
+func (store *SQLStore) ConfirmTOTPTx(ctx context.Context, arg ConfirmTOTPTxParams) (TotpSecret, error) {
+	var secret TotpSecret
+
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		var err error
+		secret, err = q.ConfirmTOTPSecret(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+
+		rows, err := q.UseTOTPStep(ctx, UseTOTPStepParams{
+			Username: arg.Username,
+			Step:     arg.Step,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		secret.LastUsedStep = arg.Step
+
+		err = q.DeleteTOTPRecoveryCodes(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+		for _, hashedCode := range arg.HashedRecoveryCodes {
+			_, err = q.CreateTOTPRecoveryCode(ctx, CreateTOTPRecoveryCodeParams{
+				Username:   arg.Username,
+				HashedCode: hashedCode,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// the secret is left out, even encrypted
+		return recordAuditEvent(ctx, q, AuditChange{
+			Action: AuditActionTOTPConfirm,
+			Target: "user:" + arg.Username,
+			After: map[string]any{
+				"username":     secret.Username,
+				"confirmed_at": secret.ConfirmedAt.Time,
+			},
+		})
+	})
+	return secret, err
+}
@@ -0,0 +1,159 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: totp.sql
+
+package db
+
+import (
+	"context"
+)
+
+const confirmTOTPSecret = `-- name: ConfirmTOTPSecret :one
+UPDATE totp_secrets
+  set confirmed_at = now()
+WHERE username = $1 AND confirmed_at IS NULL
+RETURNING username, encrypted_secret, confirmed_at, last_used_step, created_at
+`
+
+func (q *Queries) ConfirmTOTPSecret(ctx context.Context, username string) (TotpSecret, error) {
+	row := q.db.QueryRowContext(ctx, confirmTOTPSecret, username)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createTOTPRecoveryCode = `-- name: CreateTOTPRecoveryCode :one
+INSERT INTO totp_recovery_codes (
+  username,
+  hashed_code
+) VALUES (
+  $1, $2
+) RETURNING id, username, hashed_code, used_at, created_at
+`
+
+type CreateTOTPRecoveryCodeParams struct {
+	Username   string `json:"username"`
+	HashedCode string `json:"hashed_code"`
+}
+
+func (q *Queries) CreateTOTPRecoveryCode(ctx context.Context, arg CreateTOTPRecoveryCodeParams) (TotpRecoveryCode, error) {
+	row := q.db.QueryRowContext(ctx, createTOTPRecoveryCode, arg.Username, arg.HashedCode)
+	var i TotpRecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedCode,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createTOTPSecret = `-- name: CreateTOTPSecret :one
+INSERT INTO totp_secrets (
+  username,
+  encrypted_secret
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (username) DO UPDATE
+  set encrypted_secret = EXCLUDED.encrypted_secret,
+      last_used_step = 0,
+      created_at = now()
+  WHERE totp_secrets.confirmed_at IS NULL
+RETURNING username, encrypted_secret, confirmed_at, last_used_step, created_at
+`
+
+type CreateTOTPSecretParams struct {
+	Username        string `json:"username"`
+	EncryptedSecret []byte `json:"encrypted_secret"`
+}
+
+// (re-)start an enrollment, unless TOTP is already enabled: then no row is returned
+func (q *Queries) CreateTOTPSecret(ctx context.Context, arg CreateTOTPSecretParams) (TotpSecret, error) {
+	row := q.db.QueryRowContext(ctx, createTOTPSecret, arg.Username, arg.EncryptedSecret)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteTOTPRecoveryCodes = `-- name: DeleteTOTPRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE username = $1
+`
+
+func (q *Queries) DeleteTOTPRecoveryCodes(ctx context.Context, username string) error {
+	_, err := q.db.ExecContext(ctx, deleteTOTPRecoveryCodes, username)
+	return err
+}
+
+const getTOTPSecret = `-- name: GetTOTPSecret :one
+SELECT username, encrypted_secret, confirmed_at, last_used_step, created_at FROM totp_secrets
+WHERE username = $1 LIMIT 1
+`
+
+func (q *Queries) GetTOTPSecret(ctx context.Context, username string) (TotpSecret, error) {
+	row := q.db.QueryRowContext(ctx, getTOTPSecret, username)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useTOTPRecoveryCode = `-- name: UseTOTPRecoveryCode :execrows
+UPDATE totp_recovery_codes
+  set used_at = now()
+WHERE username = $1 AND hashed_code = $2 AND used_at IS NULL
+`
+
+type UseTOTPRecoveryCodeParams struct {
+	Username   string `json:"username"`
+	HashedCode string `json:"hashed_code"`
+}
+
+// 0 rows if the code doesn't exist or has already been used
+func (q *Queries) UseTOTPRecoveryCode(ctx context.Context, arg UseTOTPRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPRecoveryCode, arg.Username, arg.HashedCode)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE totp_secrets
+  set last_used_step = $1
+WHERE username = $2 AND last_used_step < $1
+`
+
+type UseTOTPStepParams struct {
+	Step     int64  `json:"step"`
+	Username string `json:"username"`
+}
+
+// 0 rows if the step (or a later one) has already been used, i.e. the code is replayed
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.Step, arg.Username)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomTOTPSecret(t *testing.T, username string) TotpSecret {
+	arg := CreateTOTPSecretParams{
+		Username:        username,
+		EncryptedSecret: []byte(util.RandomString(32)),
+	}
+
+	totpSecret, err := testQueries.CreateTOTPSecret(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, totpSecret.Username)
+	require.Equal(t, arg.EncryptedSecret, totpSecret.EncryptedSecret)
+	require.False(t, totpSecret.ConfirmedAt.Valid)
+	require.Zero(t, totpSecret.LastUsedStep)
+
+	return totpSecret
+}
+
+func TestConfirmTOTPTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	createRandomTOTPSecret(t, user.Username)
+
+	hashedCodes := []string{util.HashRecoveryCode("aaaaa-bbbbb"), util.HashRecoveryCode("ccccc-ddddd")}
+	totpSecret, err := store.ConfirmTOTPTx(context.Background(), ConfirmTOTPTxParams{
+		Username:            user.Username,
+		Step:                100,
+		HashedRecoveryCodes: hashedCodes,
+	})
+	require.NoError(t, err)
+	require.True(t, totpSecret.ConfirmedAt.Valid)
+	require.Equal(t, int64(100), totpSecret.LastUsedStep)
+
+	// a confirmed secret cannot be replaced by a new enrollment
+	_, err = testQueries.CreateTOTPSecret(context.Background(), CreateTOTPSecretParams{
+		Username:        user.Username,
+		EncryptedSecret: []byte(util.RandomString(32)),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// each step is only used once
+	rows, err := testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, Step: 100})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+	rows, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, Step: 101})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	// and so is each recovery code
+	arg := UseTOTPRecoveryCodeParams{Username: user.Username, HashedCode: hashedCodes[0]}
+	rows, err = testQueries.UseTOTPRecoveryCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+	rows, err = testQueries.UseTOTPRecoveryCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+}
+
+func TestConfirmTOTPTxWithoutEnrollment(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	_, err := store.ConfirmTOTPTx(context.Background(), ConfirmTOTPTxParams{
+		Username: user.Username,
+		Step:     1,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Oliver-Zen/simplebank/db/sqlc")
+
+// tracedDBTX wraps a DBTX (either `*sql.DB` or `*sql.Tx`) and starts a span for every query.
+// WHY wrap DBTX instead of `Querier`? Every generated query goes through these 4 methods,
+// so new queries are traced for free after `make sqlc`.
+type tracedDBTX struct {
+	DBTX
+}
+
+func (db tracedDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err := db.DBTX.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (db tracedDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	stmt, err := db.DBTX.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (db tracedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err := db.DBTX.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (db tracedDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	row := db.DBTX.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err()) // `sql.ErrNoRows` is only reported by `Scan`, so it's not an error here
+	return row
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(name),
+		),
+	)
+}
+
+// queryName extracts the sqlc query name from the `-- name: GetAccount :one` header of a generated query.
+func queryName(query string) string {
+	header, ok := strings.CutPrefix(strings.TrimSpace(query), "-- name: ")
+	if !ok {
+		return "sql.query"
+	}
+	fields := strings.Fields(header)
+	if len(fields) == 0 {
+		return "sql.query"
+	}
+	return fields[0]
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
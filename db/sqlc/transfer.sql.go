@@ -7,26 +7,88 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 )
 
+const createPendingTransfer = `-- name: CreatePendingTransfer :one
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  external_reference,
+  description,
+  metadata,
+  status
+) VALUES (
+  $1, $2, $3, $4, $5, $6, 'pending_approval'
+) RETURNING id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status
+`
+
+type CreatePendingTransferParams struct {
+	FromAccountID     int64           `json:"from_account_id"`
+	ToAccountID       int64           `json:"to_account_id"`
+	Amount            int64           `json:"amount"`
+	ExternalReference string          `json:"external_reference"`
+	Description       string          `json:"description"`
+	Metadata          json.RawMessage `json:"metadata"`
+}
+
+// a transfer waiting for approval, see `CreateTransferApproval`
+func (q *Queries) CreatePendingTransfer(ctx context.Context, arg CreatePendingTransferParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, createPendingTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ExternalReference,
+		arg.Description,
+		arg.Metadata,
+	)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ExternalReference,
+		&i.Description,
+		&i.Metadata,
+		&i.Status,
+	)
+	return i, err
+}
+
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  external_reference,
+  description,
+  metadata
 ) VALUES (
-  $1, $2, $3
-) RETURNING id, from_account_id, to_account_id, amount, created_at
+  $1, $2, $3, $4, $5, $6
+) RETURNING id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status
 `
 
 type CreateTransferParams struct {
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
-	Amount        int64 `json:"amount"`
+	FromAccountID     int64           `json:"from_account_id"`
+	ToAccountID       int64           `json:"to_account_id"`
+	Amount            int64           `json:"amount"`
+	ExternalReference string          `json:"external_reference"`
+	Description       string          `json:"description"`
+	Metadata          json.RawMessage `json:"metadata"`
 }
 
 func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
-	row := q.db.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ExternalReference,
+		arg.Description,
+		arg.Metadata,
+	)
 	var i Transfer
 	err := row.Scan(
 		&i.ID,
@@ -34,12 +96,16 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ExternalReference,
+		&i.Description,
+		&i.Metadata,
+		&i.Status,
 	)
 	return i, err
 }
 
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status FROM transfers
 WHERE id = $1 LIMIT 1
 `
 
@@ -52,12 +118,123 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ExternalReference,
+		&i.Description,
+		&i.Metadata,
+		&i.Status,
 	)
 	return i, err
 }
 
+const getTransferByExternalReference = `-- name: GetTransferByExternalReference :one
+SELECT id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status FROM transfers
+WHERE from_account_id = $1 AND external_reference = $2 LIMIT 1
+`
+
+type GetTransferByExternalReferenceParams struct {
+	FromAccountID     int64  `json:"from_account_id"`
+	ExternalReference string `json:"external_reference"`
+}
+
+func (q *Queries) GetTransferByExternalReference(ctx context.Context, arg GetTransferByExternalReferenceParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, getTransferByExternalReference, arg.FromAccountID, arg.ExternalReference)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ExternalReference,
+		&i.Description,
+		&i.Metadata,
+		&i.Status,
+	)
+	return i, err
+}
+
+const getTransferForUpdate = `-- name: GetTransferForUpdate :one
+SELECT id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status FROM transfers
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, getTransferForUpdate, id)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ExternalReference,
+		&i.Description,
+		&i.Metadata,
+		&i.Status,
+	)
+	return i, err
+}
+
+const listAccountTransfers = `-- name: ListAccountTransfers :many
+SELECT id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status FROM transfers
+WHERE
+    (from_account_id = $1 OR to_account_id = $1) AND
+    ($2::text = '' OR to_tsvector('english', description) @@ websearch_to_tsquery('english', $2::text))
+ORDER BY id DESC
+LIMIT $4
+OFFSET $3
+`
+
+type ListAccountTransfersParams struct {
+	AccountID int64  `json:"account_id"`
+	Search    string `json:"search"`
+	Offset    int32  `json:"offset"`
+	Limit     int32  `json:"limit"`
+}
+
+// the transfer history of an account, latest first;
+// `search` is a web search query on the descriptions (e.g. `rent -deposit`), ignored if empty
+func (q *Queries) ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountTransfers,
+		arg.AccountID,
+		arg.Search,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ExternalReference,
+			&i.Description,
+			&i.Metadata,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status FROM transfers
 WHERE 
     from_account_id = $1 OR
     to_account_id = $2
@@ -93,6 +270,10 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ExternalReference,
+			&i.Description,
+			&i.Metadata,
+			&i.Status,
 		); err != nil {
 			return nil, err
 		}
@@ -106,3 +287,32 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 	}
 	return items, nil
 }
+
+const updateTransferStatus = `-- name: UpdateTransferStatus :one
+UPDATE transfers
+  set status = $2
+WHERE id = $1
+RETURNING id, from_account_id, to_account_id, amount, created_at, external_reference, description, metadata, status
+`
+
+type UpdateTransferStatusParams struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+func (q *Queries) UpdateTransferStatus(ctx context.Context, arg UpdateTransferStatusParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, updateTransferStatus, arg.ID, arg.Status)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ExternalReference,
+		&i.Description,
+		&i.Metadata,
+		&i.Status,
+	)
+	return i, err
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// statuses of a transfer
+const (
+	TransferStatusCompleted       = "completed"
+	TransferStatusPendingApproval = "pending_approval"
+	TransferStatusRejected        = "rejected"
+	TransferStatusExpired         = "expired"
+)
+
+var (
+	// ErrTransferNotPending is returned when approving or rejecting a transfer that isn't waiting for approval, or expired.
+	ErrTransferNotPending = errors.New("transfer is not pending approval")
+	// ErrNotApprover is returned when the user isn't an approver of the from account of the transfer.
+	ErrNotApprover = errors.New("user is not an approver of the account")
+	// ErrSelfApproval is returned when the user who requested a transfer tries to approve or reject it.
+	ErrSelfApproval = errors.New("a transfer can't be approved by its requester")
+)
+
+// RequestTransferTxParams contains the input parameters of the transfer request
+type RequestTransferTxParams struct {
+	TransferTxParams
+	RequestedBy string    `json:"requested_by"`
+	ExpiresAt   time.Time `json:"expires_at"` // the transfer expires if it's not approved by then
+}
+
+// TransferApprovalTxResult is a transfer, with its approval
+type TransferApprovalTxResult struct {
+	Transfer Transfer         `json:"transfer"`
+	Approval TransferApproval `json:"approval"`
+}
+
+// RequestTransferTx creates a transfer pending the approval of an approver of its from account.
+// No money moves until `ApproveTransferTx`.
+func (store *SQLStore) RequestTransferTx(ctx context.Context, arg RequestTransferTxParams) (TransferApprovalTxResult, error) {
+	var result TransferApprovalTxResult
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		metadata, err := transferMetadata(arg.Metadata)
+		if err != nil {
+			return err
+		}
+		result.Transfer, err = q.CreatePendingTransfer(ctx, CreatePendingTransferParams{
+			FromAccountID:     arg.FromAccountID,
+			ToAccountID:       arg.ToAccountID,
+			Amount:            arg.Amount,
+			ExternalReference: arg.ExternalReference,
+			Description:       arg.Description,
+			Metadata:          metadata,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Approval, err = q.CreateTransferApproval(ctx, CreateTransferApprovalParams{
+			TransferID:  result.Transfer.ID,
+			RequestedBy: arg.RequestedBy,
+			ExpiresAt:   arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, q, AuditChange{
+			Action: AuditActionTransferRequest,
+			Target: fmt.Sprintf("transfer:%d", result.Transfer.ID),
+			After:  result,
+		})
+	})
+
+	if isDuplicateTransferError(err) {
+		err = fmt.Errorf("%w: %s", ErrDuplicateTransfer, arg.ExternalReference)
+	}
+	return result, err
+}
+
+// DecideTransferTxParams contains the input parameters of the approval or rejection of a transfer
+type DecideTransferTxParams struct {
+	ID       int64  `json:"id"`
+	Approver string `json:"approver"` // an approver of the from account, other than the requester
+}
+
+// ApproveTransferTxResult is the result of the transfer approval
+type ApproveTransferTxResult struct {
+	TransferTxResult
+	Approval TransferApproval `json:"approval"`
+}
+
+// ApproveTransferTx approves a pending transfer, and moves its money like `TransferTx` does, in the same transaction.
+func (store *SQLStore) ApproveTransferTx(ctx context.Context, arg DecideTransferTxParams) (ApproveTransferTxResult, error) {
+	var result ApproveTransferTxResult
+
+	ctx, span := tracer.Start(ctx, "ApproveTransferTx")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("transfer.id", arg.ID))
+
+	var err error
+	result.Attempts, err = store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		transfer, approval, err := lockPendingTransfer(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+
+		result.TransferTxResult = TransferTxResult{}
+		result.Transfer, result.Approval, err = decideTransfer(ctx, q, transfer, approval, TransferStatusCompleted, arg.Approver)
+		if err != nil {
+			return err
+		}
+		return settleTransfer(ctx, q, &result.TransferTxResult, 0)
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+// RejectTransferTx rejects a pending transfer, no money moves.
+func (store *SQLStore) RejectTransferTx(ctx context.Context, arg DecideTransferTxParams) (TransferApprovalTxResult, error) {
+	var result TransferApprovalTxResult
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		transfer, approval, err := lockPendingTransfer(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+		result.Transfer, result.Approval, err = decideTransfer(ctx, q, transfer, approval, TransferStatusRejected, arg.Approver)
+		return err
+	})
+	return result, err
+}
+
+// ExpireTransferApprovalsTx expires up to `limit` pending transfers past their approval deadline.
+// Transfers being approved or rejected meanwhile are skipped. It returns how many transfers expired.
+func (store *SQLStore) ExpireTransferApprovalsTx(ctx context.Context, limit int32) (int, error) {
+	var approvals []TransferApproval
+	_, err := store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		var err error
+		approvals, err = q.ClaimExpiredTransferApprovals(ctx, limit)
+		if err != nil {
+			return err
+		}
+		for _, approval := range approvals {
+			transfer, err := q.GetTransfer(ctx, approval.TransferID)
+			if err != nil {
+				return err
+			}
+			_, _, err = decideTransfer(ctx, q, transfer, approval, TransferStatusExpired, "")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(approvals), err
+}
+
+// lockPendingTransfer locks a transfer until the end of the transaction,
+// it must be pending & not expired, and `arg.Approver` must be allowed to decide on it.
+func lockPendingTransfer(ctx context.Context, q *Queries, arg DecideTransferTxParams) (Transfer, TransferApproval, error) {
+	var approval TransferApproval
+	transfer, err := q.GetTransferForUpdate(ctx, arg.ID)
+	if err != nil {
+		return transfer, approval, err
+	}
+	if transfer.Status != TransferStatusPendingApproval {
+		return transfer, approval, fmt.Errorf("%w: %s", ErrTransferNotPending, transfer.Status)
+	}
+
+	// the approval is only updated along with the status of its transfer, which is locked
+	approval, err = q.GetTransferApproval(ctx, transfer.ID)
+	if err != nil {
+		return transfer, approval, err
+	}
+	if !approval.ExpiresAt.After(time.Now()) {
+		return transfer, approval, fmt.Errorf("%w: expired", ErrTransferNotPending)
+	}
+	if approval.RequestedBy == arg.Approver {
+		return transfer, approval, ErrSelfApproval
+	}
+
+	_, err = q.GetAccountApprover(ctx, GetAccountApproverParams{
+		AccountID: transfer.FromAccountID,
+		Username:  arg.Approver,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return transfer, approval, fmt.Errorf("%w: account [%d]", ErrNotApprover, transfer.FromAccountID)
+	}
+	return transfer, approval, err
+}
+
+// decideTransfer sets the status of a locked, pending transfer, and records who decided it, if anyone.
+func decideTransfer(
+	ctx context.Context,
+	q *Queries,
+	transfer Transfer,
+	approval TransferApproval,
+	status string,
+	decidedBy string,
+) (Transfer, TransferApproval, error) {
+	before := TransferApprovalTxResult{Transfer: transfer, Approval: approval}
+
+	transfer, err := q.UpdateTransferStatus(ctx, UpdateTransferStatusParams{
+		ID:     transfer.ID,
+		Status: status,
+	})
+	if err != nil {
+		return transfer, approval, err
+	}
+	approval, err = q.DecideTransferApproval(ctx, DecideTransferApprovalParams{
+		TransferID: transfer.ID,
+		DecidedBy:  sql.NullString{String: decidedBy, Valid: decidedBy != ""},
+	})
+	if err != nil {
+		return transfer, approval, err
+	}
+
+	action := map[string]string{
+		TransferStatusCompleted: AuditActionTransferApprove,
+		TransferStatusRejected:  AuditActionTransferReject,
+		TransferStatusExpired:   AuditActionTransferExpire,
+	}[status]
+	err = recordAuditEvent(ctx, q, AuditChange{
+		Action: action,
+		Target: fmt.Sprintf("transfer:%d", transfer.ID),
+		Before: before,
+		After:  TransferApprovalTxResult{Transfer: transfer, Approval: approval},
+	})
+	return transfer, approval, err
+}
@@ -0,0 +1,184 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: transfer_approval.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const claimExpiredTransferApprovals = `-- name: ClaimExpiredTransferApprovals :many
+SELECT ta.transfer_id, ta.requested_by, ta.expires_at, ta.decided_by, ta.decided_at, ta.created_at FROM transfer_approvals ta
+JOIN transfers t ON t.id = ta.transfer_id
+WHERE ta.decided_at IS NULL AND ta.expires_at <= now()
+ORDER BY ta.expires_at
+LIMIT $1
+FOR UPDATE OF t, ta SKIP LOCKED
+`
+
+// the transfers being approved or rejected by another transaction are skipped, they're locked until it ends
+func (q *Queries) ClaimExpiredTransferApprovals(ctx context.Context, limit int32) ([]TransferApproval, error) {
+	rows, err := q.db.QueryContext(ctx, claimExpiredTransferApprovals, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TransferApproval{}
+	for rows.Next() {
+		var i TransferApproval
+		if err := rows.Scan(
+			&i.TransferID,
+			&i.RequestedBy,
+			&i.ExpiresAt,
+			&i.DecidedBy,
+			&i.DecidedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createTransferApproval = `-- name: CreateTransferApproval :one
+INSERT INTO transfer_approvals (
+  transfer_id,
+  requested_by,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING transfer_id, requested_by, expires_at, decided_by, decided_at, created_at
+`
+
+type CreateTransferApprovalParams struct {
+	TransferID  int64     `json:"transfer_id"`
+	RequestedBy string    `json:"requested_by"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateTransferApproval(ctx context.Context, arg CreateTransferApprovalParams) (TransferApproval, error) {
+	row := q.db.QueryRowContext(ctx, createTransferApproval, arg.TransferID, arg.RequestedBy, arg.ExpiresAt)
+	var i TransferApproval
+	err := row.Scan(
+		&i.TransferID,
+		&i.RequestedBy,
+		&i.ExpiresAt,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const decideTransferApproval = `-- name: DecideTransferApproval :one
+UPDATE transfer_approvals
+SET
+  decided_by = $1,
+  decided_at = now()
+WHERE transfer_id = $2
+RETURNING transfer_id, requested_by, expires_at, decided_by, decided_at, created_at
+`
+
+type DecideTransferApprovalParams struct {
+	DecidedBy  sql.NullString `json:"decided_by"`
+	TransferID int64          `json:"transfer_id"`
+}
+
+// `decided_by` is NULL when the approval expired
+func (q *Queries) DecideTransferApproval(ctx context.Context, arg DecideTransferApprovalParams) (TransferApproval, error) {
+	row := q.db.QueryRowContext(ctx, decideTransferApproval, arg.DecidedBy, arg.TransferID)
+	var i TransferApproval
+	err := row.Scan(
+		&i.TransferID,
+		&i.RequestedBy,
+		&i.ExpiresAt,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTransferApproval = `-- name: GetTransferApproval :one
+SELECT transfer_id, requested_by, expires_at, decided_by, decided_at, created_at FROM transfer_approvals
+WHERE transfer_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetTransferApproval(ctx context.Context, transferID int64) (TransferApproval, error) {
+	row := q.db.QueryRowContext(ctx, getTransferApproval, transferID)
+	var i TransferApproval
+	err := row.Scan(
+		&i.TransferID,
+		&i.RequestedBy,
+		&i.ExpiresAt,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listPendingTransfersForApprover = `-- name: ListPendingTransfersForApprover :many
+SELECT t.id, t.from_account_id, t.to_account_id, t.amount, t.created_at, t.external_reference, t.description, t.metadata, t.status FROM transfers t
+JOIN transfer_approvals ta ON ta.transfer_id = t.id
+JOIN account_approvers aa ON aa.account_id = t.from_account_id
+WHERE
+    aa.username = $1 AND
+    t.status = 'pending_approval' AND
+    ta.expires_at > now() AND
+    ta.requested_by <> $1
+ORDER BY t.id
+LIMIT $3
+OFFSET $2
+`
+
+type ListPendingTransfersForApproverParams struct {
+	Username string `json:"username"`
+	Offset   int32  `json:"offset"`
+	Limit    int32  `json:"limit"`
+}
+
+// the transfers `username` can approve, oldest first: not expired, and not requested by `username`
+func (q *Queries) ListPendingTransfersForApprover(ctx context.Context, arg ListPendingTransfersForApproverParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listPendingTransfersForApprover, arg.Username, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ExternalReference,
+			&i.Description,
+			&i.Metadata,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
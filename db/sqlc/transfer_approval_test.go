@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomPendingTransfer(t *testing.T, store Store, expiresAt time.Time) (TransferApprovalTxResult, User) {
+	from := createRandomAccount(t)
+	to := createRandomAccountIn(t, from.Currency)
+
+	approver := createRandomUser(t)
+	_, err := testQueries.CreateAccountApprover(context.Background(), CreateAccountApproverParams{
+		AccountID: from.ID,
+		Username:  approver.Username,
+	})
+	require.NoError(t, err)
+
+	result, err := store.RequestTransferTx(context.Background(), RequestTransferTxParams{
+		TransferTxParams: TransferTxParams{
+			FromAccountID: from.ID,
+			ToAccountID:   to.ID,
+			Amount:        10,
+			Description:   "invoice",
+		},
+		RequestedBy: from.Owner,
+		ExpiresAt:   expiresAt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusPendingApproval, result.Transfer.Status)
+	require.Equal(t, from.Owner, result.Approval.RequestedBy)
+	require.False(t, result.Approval.DecidedAt.Valid)
+	return result, approver
+}
+
+func TestApproveTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+	pending, approver := createRandomPendingTransfer(t, store, time.Now().Add(time.Hour))
+	transfer := pending.Transfer
+
+	// no money moves before the approval
+	from, err := testQueries.GetAccount(context.Background(), transfer.FromAccountID)
+	require.NoError(t, err)
+
+	pendings, err := testQueries.ListPendingTransfersForApprover(context.Background(), ListPendingTransfersForApproverParams{
+		Username: approver.Username,
+		Limit:    5,
+	})
+	require.NoError(t, err)
+	require.Len(t, pendings, 1)
+	require.Equal(t, transfer.ID, pendings[0].ID)
+
+	_, err = store.ApproveTransferTx(context.Background(), DecideTransferTxParams{ID: transfer.ID, Approver: from.Owner})
+	require.ErrorIs(t, err, ErrSelfApproval)
+
+	other := createRandomUser(t)
+	_, err = store.ApproveTransferTx(context.Background(), DecideTransferTxParams{ID: transfer.ID, Approver: other.Username})
+	require.ErrorIs(t, err, ErrNotApprover)
+
+	result, err := store.ApproveTransferTx(context.Background(), DecideTransferTxParams{ID: transfer.ID, Approver: approver.Username})
+	require.NoError(t, err)
+	require.Equal(t, transfer.ID, result.Transfer.ID)
+	require.Equal(t, TransferStatusCompleted, result.Transfer.Status)
+	require.Equal(t, approver.Username, result.Approval.DecidedBy.String)
+	require.True(t, result.Approval.DecidedAt.Valid)
+	require.Equal(t, from.Balance-10, result.FromAccount.Balance)
+	require.Equal(t, transfer.ID, result.FromEntry.TransferID.Int64)
+	require.Equal(t, "invoice", result.ToEntry.Description)
+
+	_, err = store.RejectTransferTx(context.Background(), DecideTransferTxParams{ID: transfer.ID, Approver: approver.Username})
+	require.ErrorIs(t, err, ErrTransferNotPending)
+}
+
+func TestRejectTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+	pending, approver := createRandomPendingTransfer(t, store, time.Now().Add(time.Hour))
+
+	result, err := store.RejectTransferTx(context.Background(), DecideTransferTxParams{ID: pending.Transfer.ID, Approver: approver.Username})
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusRejected, result.Transfer.Status)
+	require.Equal(t, approver.Username, result.Approval.DecidedBy.String)
+
+	entries, err := testQueries.ListEntries(context.Background(), ListEntriesParams{
+		AccountID: pending.Transfer.FromAccountID,
+		Limit:     5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	_, err = store.ApproveTransferTx(context.Background(), DecideTransferTxParams{ID: pending.Transfer.ID, Approver: approver.Username})
+	require.ErrorIs(t, err, ErrTransferNotPending)
+}
+
+func TestExpireTransferApprovalsTx(t *testing.T) {
+	store := NewStore(testDB)
+	pending, approver := createRandomPendingTransfer(t, store, time.Now().Add(time.Second))
+	time.Sleep(time.Second)
+
+	// an expired transfer can't be approved, even before the sweeper expires it
+	_, err := store.ApproveTransferTx(context.Background(), DecideTransferTxParams{ID: pending.Transfer.ID, Approver: approver.Username})
+	require.ErrorIs(t, err, ErrTransferNotPending)
+
+	for {
+		n, err := store.ExpireTransferApprovalsTx(context.Background(), 100)
+		require.NoError(t, err)
+		if n < 100 {
+			break
+		}
+	}
+
+	transfer, err := testQueries.GetTransfer(context.Background(), pending.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusExpired, transfer.Status)
+
+	approval, err := testQueries.GetTransferApproval(context.Background(), transfer.ID)
+	require.NoError(t, err)
+	require.True(t, approval.DecidedAt.Valid)
+	require.False(t, approval.DecidedBy.Valid)
+}
+
+func TestListPendingApprovalPolicyChanges(t *testing.T) {
+	account := createRandomAccount(t)
+	approver := createRandomUser(t)
+	_, err := testQueries.CreateAccountApprover(context.Background(), CreateAccountApproverParams{
+		AccountID: account.ID,
+		Username:  approver.Username,
+	})
+	require.NoError(t, err)
+
+	change, err := testQueries.CreateApprovalPolicyChange(context.Background(), CreateApprovalPolicyChangeParams{
+		AccountID:   account.ID,
+		Threshold:   0,
+		Approvers:   []string{},
+		RequestedBy: account.Owner,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Empty(t, change.Approvers)
+
+	// the approver sees it, the owner who requested it doesn't
+	changes, err := testQueries.ListPendingApprovalPolicyChanges(context.Background(), ListPendingApprovalPolicyChangesParams{
+		Username: approver.Username,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, change.ID, changes[0].ID)
+
+	changes, err = testQueries.ListPendingApprovalPolicyChanges(context.Background(), ListPendingApprovalPolicyChangesParams{
+		Username: account.Owner,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	// one pending change per account
+	_, err = testQueries.CreateApprovalPolicyChange(context.Background(), CreateApprovalPolicyChangeParams{
+		AccountID:   account.ID,
+		Approvers:   []string{approver.Username},
+		RequestedBy: account.Owner,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.Error(t, err)
+}
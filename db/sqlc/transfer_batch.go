@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// modes of a batch transfer
+const (
+	// BatchModeAllOrNothing makes no transfer at all if any item of the batch fails.
+	BatchModeAllOrNothing = "all_or_nothing"
+	// BatchModeBestEffort makes the transfers that can be made, and reports the others as failed.
+	BatchModeBestEffort = "best_effort"
+)
+
+// statuses of an item of a batch transfer
+const (
+	BatchItemStatusSucceeded = "succeeded"
+	BatchItemStatusFailed    = "failed"
+	BatchItemStatusSkipped   = "skipped" // valid, but not made because another item of an all-or-nothing batch failed
+)
+
+// ErrBatchRejected is returned when an all-or-nothing batch is rolled back because some of its items failed.
+// The result of `BatchTransferTx` still tells which ones.
+var ErrBatchRejected = errors.New("batch rejected, some transfers cannot be made")
+
+// BatchTransferItem is a transfer of a batch, from the source account of the batch.
+type BatchTransferItem struct {
+	ToAccountID int64  `json:"to_account_id"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+}
+
+// BatchTransferTxParams contains the input parameters of the batch transfer transaction
+type BatchTransferTxParams struct {
+	FromAccountID int64               `json:"from_account_id"`
+	Currency      string              `json:"currency"` // of every account of the batch
+	Mode          string              `json:"mode"`
+	Items         []BatchTransferItem `json:"items"`
+}
+
+// BatchTransferItemResult is the result of an item of the batch, in the order of the items.
+type BatchTransferItemResult struct {
+	ToAccountID int64     `json:"to_account_id"`
+	Amount      int64     `json:"amount"`
+	Status      string    `json:"status"`
+	Transfer    *Transfer `json:"transfer,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// BatchTransferTxResult is the result of the batch transfer transaction
+type BatchTransferTxResult struct {
+	FromAccount Account                   `json:"from_account"` // after the batch
+	Items       []BatchTransferItemResult `json:"items"`
+	Succeeded   int                       `json:"succeeded"`
+	Failed      int                       `json:"failed"`
+	// how many times the transaction ran, > 1 if it was retried after a deadlock or serialization failure
+	Attempts int `json:"attempts"`
+}
+
+// BatchTransferTx makes transfers from one account to many in a single transaction.
+// Every account of the batch is locked upfront in ID order, like `TransferTx` does for its 2 accounts,
+// so batches & transfers touching the same accounts cannot deadlock.
+// An item fails if its destination doesn't exist, is frozen, is the source, or doesn't use the batch's currency,
+// or if the available balance left by the items before it doesn't cover it.
+// In `BatchModeAllOrNothing`, a single failed item rolls back the batch and `ErrBatchRejected` is returned.
+func (store *SQLStore) BatchTransferTx(ctx context.Context, arg BatchTransferTxParams) (BatchTransferTxResult, error) {
+	var result BatchTransferTxResult
+
+	ctx, span := tracer.Start(ctx, "BatchTransferTx")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("transfer.from_account_id", arg.FromAccountID),
+		attribute.Int("transfer.batch_size", len(arg.Items)),
+		attribute.String("transfer.batch_mode", arg.Mode),
+	)
+
+	var err error
+	result.Attempts, err = store.execTx(ctx, nil, func(ctx context.Context, q *Queries) error {
+		result = BatchTransferTxResult{} // a retry starts over
+
+		ids := []int64{arg.FromAccountID}
+		for _, item := range arg.Items {
+			ids = append(ids, item.ToAccountID)
+		}
+		locked, err := q.LockAccounts(ctx, ids)
+		if err != nil {
+			return err
+		}
+		accounts := make(map[int64]Account, len(locked))
+		for _, account := range locked {
+			accounts[account.ID] = account
+		}
+
+		from, ok := accounts[arg.FromAccountID]
+		switch {
+		case !ok:
+			return fmt.Errorf("account [%d] not found", arg.FromAccountID)
+		case from.Status == AccountStatusFrozen:
+			return fmt.Errorf("account [%d] is frozen", from.ID)
+		case from.Currency != arg.Currency:
+			return fmt.Errorf("account [%d] currency mismatch: %s vs %s", from.ID, from.Currency, arg.Currency)
+		}
+
+		// the items are funded in order, from the available balance of the locked from account
+		available := from.AvailableBalance
+		result.Items = make([]BatchTransferItemResult, len(arg.Items))
+		for i, item := range arg.Items {
+			result.Items[i] = BatchTransferItemResult{ToAccountID: item.ToAccountID, Amount: item.Amount}
+			err := checkBatchDestination(accounts, arg, item)
+			if err == nil && item.Amount > available {
+				err = fmt.Errorf("%w: %d available", ErrInsufficientFunds, available)
+			}
+			if err != nil {
+				result.Items[i].Status = BatchItemStatusFailed
+				result.Items[i].Error = err.Error()
+				result.Failed++
+				continue
+			}
+			available -= item.Amount
+		}
+		result.FromAccount = from
+		if result.Failed > 0 && arg.Mode == BatchModeAllOrNothing {
+			for i := range result.Items {
+				if result.Items[i].Status == "" {
+					result.Items[i].Status = BatchItemStatusSkipped
+				}
+			}
+			return ErrBatchRejected
+		}
+
+		for i, item := range arg.Items {
+			if result.Items[i].Status == BatchItemStatusFailed {
+				continue
+			}
+
+			transfer := TransferTxResult{}
+			transfer.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
+				FromAccountID: arg.FromAccountID,
+				ToAccountID:   item.ToAccountID,
+				Amount:        item.Amount,
+				Description:   item.Description,
+				Metadata:      json.RawMessage("{}"),
+			})
+			if err != nil {
+				return err
+			}
+			// every account is locked already, the order of the updates doesn't matter anymore
+			transfer.FromAccount, transfer.ToAccount, err =
+				addMoney(ctx, q, arg.FromAccountID, -item.Amount, item.ToAccountID, item.Amount)
+			if err != nil {
+				return err
+			}
+			err = recordTransfer(ctx, q, &transfer)
+			if err != nil {
+				return err
+			}
+
+			result.FromAccount = transfer.FromAccount
+			result.Items[i].Status = BatchItemStatusSucceeded
+			result.Items[i].Transfer = &transfer.Transfer
+			result.Succeeded++
+		}
+		return nil
+	})
+
+	recordError(span, err)
+	return result, err
+}
+
+// checkBatchDestination returns why the transfer of `item` cannot be made, nil if it can.
+func checkBatchDestination(accounts map[int64]Account, arg BatchTransferTxParams, item BatchTransferItem) error {
+	to, ok := accounts[item.ToAccountID]
+	switch {
+	case item.Amount <= 0:
+		return errors.New("amount must be positive")
+	case item.ToAccountID == arg.FromAccountID:
+		return errors.New("cannot transfer to the source account")
+	case !ok:
+		return fmt.Errorf("account [%d] not found", item.ToAccountID)
+	case to.Status == AccountStatusFrozen:
+		return fmt.Errorf("account [%d] is frozen", to.ID)
+	case to.Currency != arg.Currency:
+		return fmt.Errorf("account [%d] currency mismatch: %s vs %s", to.ID, to.Currency, arg.Currency)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomAccountIn(t *testing.T, currency string) Account {
+	account := createRandomAccount(t)
+	if account.Currency == currency {
+		return account
+	}
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account.Owner,
+		Balance:  account.Balance,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestBatchTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+	from := createRandomAccount(t)
+	to1 := createRandomAccountIn(t, from.Currency)
+	to2 := createRandomAccountIn(t, from.Currency)
+
+	result, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: from.ID,
+		Currency:      from.Currency,
+		Mode:          BatchModeAllOrNothing,
+		Items: []BatchTransferItem{
+			{ToAccountID: to1.ID, Amount: 10},
+			{ToAccountID: to2.ID, Amount: 20},
+			{ToAccountID: to1.ID, Amount: 30},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Succeeded)
+	require.Zero(t, result.Failed)
+	require.Equal(t, from.Balance-60, result.FromAccount.Balance)
+
+	for _, item := range result.Items {
+		require.Equal(t, BatchItemStatusSucceeded, item.Status)
+		require.NotNil(t, item.Transfer)
+		require.Equal(t, from.ID, item.Transfer.FromAccountID)
+		require.Equal(t, item.ToAccountID, item.Transfer.ToAccountID)
+		require.Equal(t, item.Amount, item.Transfer.Amount)
+	}
+
+	updated, err := testQueries.GetAccount(context.Background(), to1.ID)
+	require.NoError(t, err)
+	require.Equal(t, to1.Balance+40, updated.Balance)
+
+	// the entries are chained like those of single transfers
+	breaks, err := store.VerifyLedger(context.Background(), VerifyLedgerParams{AccountID: from.ID})
+	require.NoError(t, err)
+	require.Empty(t, breaks)
+}
+
+func TestBatchTransferTxAllOrNothing(t *testing.T) {
+	store := NewStore(testDB)
+	from := createRandomAccount(t)
+	to := createRandomAccountIn(t, from.Currency)
+
+	result, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: from.ID,
+		Currency:      from.Currency,
+		Mode:          BatchModeAllOrNothing,
+		Items: []BatchTransferItem{
+			{ToAccountID: to.ID, Amount: 10},
+			{ToAccountID: from.ID, Amount: 10},
+		},
+	})
+	require.True(t, errors.Is(err, ErrBatchRejected))
+	require.Len(t, result.Items, 2)
+	require.Equal(t, BatchItemStatusSkipped, result.Items[0].Status)
+	require.Equal(t, BatchItemStatusFailed, result.Items[1].Status)
+	require.NotEmpty(t, result.Items[1].Error)
+
+	// nothing was transferred
+	unchanged, err := testQueries.GetAccount(context.Background(), from.ID)
+	require.NoError(t, err)
+	require.Equal(t, from.Balance, unchanged.Balance)
+}
+
+func TestBatchTransferTxBestEffort(t *testing.T) {
+	store := NewStore(testDB)
+	from := createRandomAccount(t)
+	to := createRandomAccountIn(t, from.Currency)
+	frozen := createRandomAccountIn(t, from.Currency)
+	_, err := testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:     frozen.ID,
+		Status: AccountStatusFrozen,
+	})
+	require.NoError(t, err)
+
+	result, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: from.ID,
+		Currency:      from.Currency,
+		Mode:          BatchModeBestEffort,
+		Items: []BatchTransferItem{
+			{ToAccountID: frozen.ID, Amount: 10},
+			{ToAccountID: to.ID, Amount: 20},
+			{ToAccountID: 0, Amount: 30}, // doesn't exist
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Succeeded)
+	require.Equal(t, 2, result.Failed)
+	require.Equal(t, BatchItemStatusFailed, result.Items[0].Status)
+	require.Equal(t, BatchItemStatusSucceeded, result.Items[1].Status)
+	require.Equal(t, BatchItemStatusFailed, result.Items[2].Status)
+	require.Equal(t, from.Balance-20, result.FromAccount.Balance)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -14,6 +15,8 @@ func createRandomTransfer(t *testing.T, account1 Account, account2 Account) Tran
 		FromAccountID: account1.ID,
 		ToAccountID: account2.ID,
 		Amount: 	 util.RandomMoney(),
+		Description:   util.RandomString(20),
+		Metadata:      json.RawMessage(`{"invoice": "42"}`),
 	}
 	
 	transfer, err := testQueries.CreateTransfer(context.Background(), arg)
@@ -23,6 +26,8 @@ func createRandomTransfer(t *testing.T, account1 Account, account2 Account) Tran
 	require.Equal(t, arg.FromAccountID, transfer.FromAccountID)
 	require.Equal(t, arg.ToAccountID, transfer.ToAccountID)
 	require.Equal(t, arg.Amount, transfer.Amount)
+	require.Equal(t, arg.Description, transfer.Description)
+	require.JSONEq(t, string(arg.Metadata), string(transfer.Metadata))
 	
 	require.NotZero(t, transfer.ID)
 	require.NotZero(t, transfer.CreatedAt)
@@ -75,3 +80,38 @@ func TestListTransfers(t *testing.T) {
 	}
 }
 
+
+func TestListAccountTransfersSearch(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	for _, description := range []string{"Rent for May", "rent deposit", "groceries"} {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+			Description:   description,
+			Metadata:      map[string]string{"category": "home"},
+		})
+		require.NoError(t, err)
+	}
+
+	arg := ListAccountTransfersParams{AccountID: account2.ID, Limit: 10}
+	transfers, err := testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 3)
+	require.Equal(t, "groceries", transfers[0].Description) // latest first
+	require.JSONEq(t, `{"category": "home"}`, string(transfers[0].Metadata))
+
+	arg.Search = "renting" // stemmed
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+
+	arg.Search = "rent -deposit"
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	require.Equal(t, "Rent for May", transfers[0].Description)
+}
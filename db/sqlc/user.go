@@ -0,0 +1,7 @@
+package db
+
+// Possible values of `users.role`, see the `users_role_check` constraint.
+const (
+	UserRoleDepositor = "depositor"
+	UserRoleBanker    = "banker" // employee, may read the audit log
+)
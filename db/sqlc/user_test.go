@@ -53,3 +53,42 @@ func TestGetUser(t *testing.T) {
 	require.WithinDuration(t, user1.PasswordChangedAt, user2.PasswordChangedAt, time.Second)
 	require.WithinDuration(t, user1.CreatedAt, user2.CreatedAt, time.Second)
 }
+
+func TestUpdateUserPassword(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	hashedPassword, err := util.HashPassword(util.RandomString(12))
+	require.NoError(t, err)
+
+	user2, err := testQueries.UpdateUserPassword(context.Background(), UpdateUserPasswordParams{
+		Username:       user1.Username,
+		HashedPassword: hashedPassword,
+	})
+	require.NoError(t, err)
+	require.Equal(t, hashedPassword, user2.HashedPassword)
+	require.WithinDuration(t, time.Now(), user2.PasswordChangedAt, time.Second)
+}
+
+func TestRehashUserPassword(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	arg := RehashUserPasswordParams{
+		Username:          user1.Username,
+		OldHashedPassword: user1.HashedPassword,
+		NewHashedPassword: "$argon2id$rehashed",
+	}
+	rows, err := testQueries.RehashUserPassword(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	// a rehash doesn't count as a password change
+	user2, err := testQueries.GetUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, arg.NewHashedPassword, user2.HashedPassword)
+	require.Equal(t, user1.PasswordChangedAt, user2.PasswordChangedAt)
+
+	// the old hash doesn't match anymore
+	rows, err = testQueries.RehashUserPassword(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+}
@@ -0,0 +1,312 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: webhook.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+  set next_attempt_at = $1
+FROM webhooks w, outbox_events e
+WHERE d.webhook_id = w.id AND d.event_id = e.id AND d.id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending' AND next_attempt_at <= now()
+  ORDER BY next_attempt_at
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.attempts, w.url, w.secret, e.id AS event_id, e.event_type, e.payload, e.created_at AS event_created_at
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil time.Time `json:"lease_until"`
+	Limit      int32     `json:"limit"`
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID             int64           `json:"id"`
+	Attempts       int32           `json:"attempts"`
+	Url            string          `json:"url"`
+	Secret         string          `json:"secret"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	EventCreatedAt time.Time       `json:"event_created_at"`
+}
+
+// the deliveries due, leased until `lease_until` so no other dispatcher picks them up meanwhile
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ClaimWebhookDeliveriesRow{}
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.EventCreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (
+  owner,
+  url,
+  secret
+) VALUES (
+  $1, $2, $3
+) RETURNING id, owner, url, secret, created_at
+`
+
+type CreateWebhookParams struct {
+	Owner  string `json:"owner"`
+	Url    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook, arg.Owner, arg.Url, arg.Secret)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Url,
+		&i.Secret,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createWebhookDeliveries = `-- name: CreateWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (
+  webhook_id,
+  event_id
+)
+SELECT id, $1::bigint FROM webhooks
+WHERE owner = $2
+ON CONFLICT (webhook_id, event_id) DO NOTHING
+`
+
+type CreateWebhookDeliveriesParams struct {
+	EventID int64  `json:"event_id"`
+	Owner   string `json:"owner"`
+}
+
+// an event is delivered to every webhook of its owner
+func (q *Queries) CreateWebhookDeliveries(ctx context.Context, arg CreateWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createWebhookDeliveries, arg.EventID, arg.Owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :one
+DELETE FROM webhooks
+WHERE id = $1 AND owner = $2
+RETURNING id, owner, url, secret, created_at
+`
+
+type DeleteWebhookParams struct {
+	ID    int64  `json:"id"`
+	Owner string `json:"owner"`
+}
+
+// only the owner can delete a webhook, its deliveries are deleted with it
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, deleteWebhook, arg.ID, arg.Owner)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Url,
+		&i.Secret,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, owner, url, secret, created_at FROM webhooks
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Url,
+		&i.Secret,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event_id, status, attempts, next_attempt_at, last_response_code, last_error, delivered_at, created_at FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2
+OFFSET $3
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID int64 `json:"webhook_id"`
+	Limit     int32 `json:"limit"`
+	Offset    int32 `json:"offset"`
+}
+
+// the delivery log of a webhook, newest first
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries, arg.WebhookID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastResponseCode,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooks = `-- name: ListWebhooks :many
+SELECT id, owner, url, secret, created_at FROM webhooks
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListWebhooksParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+func (q *Queries) ListWebhooks(ctx context.Context, arg ListWebhooksParams) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhooks, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Webhook{}
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Url,
+			&i.Secret,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :one
+UPDATE webhook_deliveries
+  set status = $2,
+      attempts = $3,
+      next_attempt_at = $4,
+      last_response_code = $5,
+      last_error = $6,
+      delivered_at = $7
+WHERE id = $1
+RETURNING id, webhook_id, event_id, status, attempts, next_attempt_at, last_response_code, last_error, delivered_at, created_at
+`
+
+type UpdateWebhookDeliveryParams struct {
+	ID               int64          `json:"id"`
+	Status           string         `json:"status"`
+	Attempts         int32          `json:"attempts"`
+	NextAttemptAt    time.Time      `json:"next_attempt_at"`
+	LastResponseCode sql.NullInt32  `json:"last_response_code"`
+	LastError        sql.NullString `json:"last_error"`
+	DeliveredAt      sql.NullTime   `json:"delivered_at"`
+}
+
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, updateWebhookDelivery,
+		arg.ID,
+		arg.Status,
+		arg.Attempts,
+		arg.NextAttemptAt,
+		arg.LastResponseCode,
+		arg.LastError,
+		arg.DeliveredAt,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.EventID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastResponseCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
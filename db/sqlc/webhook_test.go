@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomWebhook(t *testing.T, owner string) Webhook {
+	arg := CreateWebhookParams{
+		Owner:  owner,
+		Url:    "https://example.com/hooks/" + util.RandomString(8),
+		Secret: "whsec_" + util.RandomString(32),
+	}
+
+	hook, err := testQueries.CreateWebhook(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Owner, hook.Owner)
+	require.Equal(t, arg.Url, hook.Url)
+	require.Equal(t, arg.Secret, hook.Secret)
+	require.NotZero(t, hook.CreatedAt)
+	return hook
+}
+
+// fanOutAll fans out every pending event, including those of other tests
+func fanOutAll(t *testing.T, store Store) {
+	for {
+		n, err := store.FanOutOutboxTx(context.Background(), 1000)
+		require.NoError(t, err)
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func TestTransferTxEnqueuesEvents(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+	hook1 := createRandomWebhook(t, account1.Owner)
+	hook2 := createRandomWebhook(t, account2.Owner)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	fanOutAll(t, store)
+
+	// the sender is told the transfer completed, the receiver too, and that the account was credited
+	deliveries1, err := testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{WebhookID: hook1.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries1, 1)
+	deliveries2, err := testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{WebhookID: hook2.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries2, 2)
+
+	claimed, err := testQueries.ClaimWebhookDeliveries(context.Background(), ClaimWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		Limit:      1000,
+	})
+	require.NoError(t, err)
+
+	var credited *ClaimWebhookDeliveriesRow
+	for i, delivery := range claimed {
+		if delivery.Url == hook2.Url && delivery.EventType == EventAccountCredited {
+			credited = &claimed[i]
+		}
+	}
+	require.NotNil(t, credited)
+	require.Equal(t, hook2.Secret, credited.Secret)
+
+	var event AccountCreditedEvent
+	require.NoError(t, json.Unmarshal(credited.Payload, &event))
+	require.Equal(t, AccountCreditedEvent{
+		AccountID:  account2.ID,
+		TransferID: result.Transfer.ID,
+		Amount:     10,
+		Balance:    result.ToAccount.Balance,
+		Currency:   account2.Currency,
+	}, event)
+
+	// leased: not claimed again until the lease expires
+	claimed, err = testQueries.ClaimWebhookDeliveries(context.Background(), ClaimWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		Limit:      1000,
+	})
+	require.NoError(t, err)
+	for _, delivery := range claimed {
+		require.NotEqual(t, credited.ID, delivery.ID)
+	}
+}
+
+func TestUpdateWebhookDelivery(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+	hook := createRandomWebhook(t, account1.Owner)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	fanOutAll(t, store)
+
+	deliveries, err := testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, DeliveryStatusPending, deliveries[0].Status)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery, err := testQueries.UpdateWebhookDelivery(context.Background(), UpdateWebhookDeliveryParams{
+		ID:               deliveries[0].ID,
+		Status:           DeliveryStatusSucceeded,
+		Attempts:         1,
+		NextAttemptAt:    now,
+		LastResponseCode: sql.NullInt32{Int32: 200, Valid: true},
+		DeliveredAt:      sql.NullTime{Time: now, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, DeliveryStatusSucceeded, delivery.Status)
+	require.Equal(t, int32(1), delivery.Attempts)
+	require.WithinDuration(t, now, delivery.DeliveredAt.Time, time.Second)
+
+	// the log goes away with the webhook
+	_, err = testQueries.DeleteWebhook(context.Background(), DeleteWebhookParams{ID: hook.ID, Owner: hook.Owner})
+	require.NoError(t, err)
+	deliveries, err = testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+}
+
+func TestDeleteWebhookOfAnotherUser(t *testing.T) {
+	hook := createRandomWebhook(t, createRandomUser(t).Username)
+
+	_, err := testQueries.DeleteWebhook(context.Background(), DeleteWebhookParams{ID: hook.ID, Owner: util.RandomOwner()})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/lib/pq" // For database/sql package to use the driver (`pq`) internally to connect to PostgreSQL.
 
 	"github.com/Oliver-Zen/simplebank/api"
 	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/telemetry"
 	"github.com/Oliver-Zen/simplebank/util"
 )
 
@@ -23,20 +28,69 @@ func main() {
 		log.Fatal("cannot load config:", err)
 	}
 
+	// `main migrate up|down|version` manages the schema instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(config, os.Args[2:])
+		return
+	}
+
+	// refuse to serve with a schema we don't know how to handle
+	err = runDBMigration(config)
+	if err != nil {
+		log.Fatal("cannot migrate db:", err)
+	}
+
+	shutdownTracer, err := telemetry.InitTracer(context.Background(), config)
+	if err != nil {
+		log.Fatal("cannot init tracer:", err)
+	}
+	defer shutdownTracer(context.Background())
+
 	// to create a server, need to connect to DB first
 	conn, err := sql.Open(config.DBDriver, config.DBSource)
 	if err != nil {
 		log.Fatal("cannot connect to the db:", err)
 	}
 
-	store := db.NewStore(conn)
+	store := db.NewStore(conn, db.WithTxRetryPolicy(db.TxRetryPolicy{
+		MaxAttempts: config.TxMaxAttempts,
+		BaseDelay:   config.TxRetryBaseDelay,
+		MaxDelay:    config.TxRetryMaxDelay,
+	}))
 	server, err := api.NewServer(config, store)
 	if err != nil {
 		log.Fatal("cannot create server:", err)
 	}
 
-	err = server.Start(config.ServerAddress)
+	// stop on Ctrl+C (SIGINT) or `docker stop` / k8s pod termination (SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Start(config.ServerAddress)
+	}()
+
+	select {
+	case err = <-serverErr:
+		if err != nil {
+			log.Fatal("cannot start server:", err)
+		}
+	case <-ctx.Done():
+		log.Println("shutting down server...")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownDrainDelay+config.ShutdownTimeout)
+		defer cancel()
+
+		err = server.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Println("cannot shut down server gracefully:", err)
+		}
+	}
+
+	// only close the db after in-flight requests & workers are done with it
+	err = conn.Close()
 	if err != nil {
-		log.Fatal("cannot start server:", err)
+		log.Println("cannot close db connection:", err)
 	}
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/Oliver-Zen/simplebank/db/migration"
+	"github.com/Oliver-Zen/simplebank/util"
+)
+
+const migrateUsage = `usage: main migrate <command>
+
+commands:
+  up [N]     apply all (or the next N) pending migrations
+  down [N]   revert the last N migrations (default 1)
+  version    print the current migration version`
+
+// runDBMigration runs on every server start.
+// It applies pending migrations if AUTO_MIGRATE is set, and always fails on a dirty schema.
+func runDBMigration(config util.Config) error {
+	migrator, err := migration.New(config.MigrationURL, config.DBSource)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if config.AutoMigrate {
+		err = migrator.Up() // `Up` refuses to run on a dirty schema
+		if err != nil {
+			return err
+		}
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d", migration.ErrDirty, version)
+	}
+
+	log.Println("db migrated to version", version)
+	return nil
+}
+
+// runMigrateCommand implements `main migrate up|down|version`.
+func runMigrateCommand(config util.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	migrator, err := migration.New(config.MigrationURL, config.DBSource)
+	if err != nil {
+		log.Fatal("cannot create migrator:", err)
+	}
+	defer migrator.Close()
+
+	// the optional step count, e.g. `migrate down 2`
+	steps := 0
+	if len(args) > 1 {
+		steps, err = strconv.Atoi(args[1])
+		if err != nil || steps <= 0 {
+			log.Fatal("invalid number of steps: ", args[1])
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		if steps == 0 {
+			err = migrator.Up()
+		} else {
+			err = migrator.Steps(steps)
+		}
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		err = migrator.Steps(-steps)
+	case "version":
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		log.Fatal("cannot run migration:", err)
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		log.Fatal("cannot get migration version:", err)
+	}
+	fmt.Fprintf(os.Stdout, "version: %d, dirty: %t\n", version, dirty)
+}
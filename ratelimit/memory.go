@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// how often idle buckets are removed
+const sweepInterval = time.Minute
+
+// MemoryStore is a `Store` keeping the buckets in memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // replaced by tests
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full again at this time, then the bucket can be forgotten
+	fullAt time.Time
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take takes a token from the bucket of `key`.
+func (store *MemoryStore) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+	store.sweep(now)
+
+	interval := policy.interval()
+	b, ok := store.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Limit), updated: now}
+		store.buckets[key] = b
+	}
+
+	// refill the tokens since the last request
+	b.tokens = min(float64(policy.Limit), b.tokens+float64(now.Sub(b.updated))/float64(interval))
+	b.updated = now
+
+	result := Result{Limit: policy.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(interval))
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration((float64(policy.Limit) - b.tokens) * float64(interval))
+	b.fullAt = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// sweep removes the buckets that are full again: they behave exactly like new ones.
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < sweepInterval {
+		return
+	}
+	store.lastSweep = now
+
+	for key, b := range store.buckets {
+		if !now.Before(b.fullAt) {
+			delete(store.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	policy := Policy{Limit: 3, Period: 3 * time.Second}
+
+	// a burst of `Limit` requests
+	for i := 2; i >= 0; i-- {
+		result, err := store.Take(context.Background(), "a", policy)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, 3, result.Limit)
+		require.Equal(t, i, result.Remaining)
+	}
+
+	result, err := store.Take(context.Background(), "a", policy)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, time.Second, result.RetryAfter)
+	require.Equal(t, 3*time.Second, result.ResetAfter)
+
+	// other keys have their own bucket
+	result, err = store.Take(context.Background(), "b", policy)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// one token is refilled per second
+	now = now.Add(time.Second)
+	result, err = store.Take(context.Background(), "a", policy)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Zero(t, result.Remaining)
+
+	result, err = store.Take(context.Background(), "a", policy)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	policy := Policy{Limit: 10, Period: time.Second}
+	_, err := store.Take(context.Background(), "a", policy)
+	require.NoError(t, err)
+	require.Len(t, store.buckets, 1)
+
+	// the bucket is full again, it's removed on the next sweep
+	now = now.Add(sweepInterval)
+	_, err = store.Take(context.Background(), "b", policy)
+	require.NoError(t, err)
+	require.Len(t, store.buckets, 1)
+	require.Contains(t, store.buckets, "b")
+}
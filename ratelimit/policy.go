@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows `Limit` requests per `Period`, as a token bucket:
+// bursts of up to `Limit` requests are allowed, then the bucket refills steadily over `Period`.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParsePolicy parses a policy written as "<limit>/<period>", e.g. "60/1m".
+// An empty string returns the zero policy, which disables rate limiting.
+func ParsePolicy(s string) (Policy, error) {
+	if s == "" {
+		return Policy{}, nil
+	}
+
+	limit, period, found := strings.Cut(s, "/")
+	if !found {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: must be <limit>/<period>", s)
+	}
+
+	var policy Policy
+	var err error
+	policy.Limit, err = strconv.Atoi(limit)
+	if err != nil || policy.Limit <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: limit must be a positive integer", s)
+	}
+	policy.Period, err = time.ParseDuration(period)
+	if err != nil || policy.Period <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+	return policy, nil
+}
+
+// Enabled returns false for the zero policy.
+func (policy Policy) Enabled() bool {
+	return policy.Limit > 0 && policy.Period > 0
+}
+
+func (policy Policy) String() string {
+	return fmt.Sprintf("%d/%s", policy.Limit, policy.Period)
+}
+
+// interval is the time to refill one token.
+func (policy Policy) interval() time.Duration {
+	return policy.Period / time.Duration(policy.Limit)
+}
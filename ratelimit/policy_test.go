@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("60/1m")
+	require.NoError(t, err)
+	require.Equal(t, Policy{Limit: 60, Period: time.Minute}, policy)
+	require.True(t, policy.Enabled())
+	require.Equal(t, time.Second, policy.interval())
+
+	policy, err = ParsePolicy("")
+	require.NoError(t, err)
+	require.False(t, policy.Enabled())
+
+	for _, s := range []string{"60", "0/1m", "-1/1m", "x/1m", "60/0s", "60/minute"} {
+		_, err = ParsePolicy(s)
+		require.Error(t, err, s)
+	}
+}
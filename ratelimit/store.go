@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps the token buckets. The in-memory store only limits a single server,
+// a shared store (e.g. Redis) is needed to limit across replicas.
+type Store interface {
+	// Take takes a token from the bucket of `key`, which is created full the first time
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// Result is the state of a bucket after `Take`.
+type Result struct {
+	Allowed bool
+	Limit   int
+	// tokens left in the bucket
+	Remaining int
+	// time until a token is available again, only set if the request is not allowed
+	RetryAfter time.Duration
+	// time until the bucket is full again
+	ResetAfter time.Duration
+}
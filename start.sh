@@ -2,9 +2,7 @@
 
 set -e
 
-echo "run db migration"
-source /app/app.env
-/app/migrate -path /app/migration -database "$DB_SOURCE" -verbose up
+# db migrations are applied by the app itself on startup (AUTO_MIGRATE in app.env)
 
 echo "start the app"
 exec "$@"
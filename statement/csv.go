@@ -0,0 +1,70 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV renders the statement: a row per entry, between an "opening balance" and a "closing balance" row,
+// followed by the totals.
+func (statement Statement) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	itoa := func(i int64) string { return strconv.FormatInt(i, 10) }
+
+	w.Write([]string{"date", "entry_id", "description", "transfer_id", "counterparty_account_id", "counterparty_owner", "amount", "balance", "currency"})
+	w.Write([]string{statement.Month.Format(time.RFC3339), "", "opening balance", "", "", "", "", itoa(statement.OpeningBalance), statement.Currency})
+	for _, line := range statement.Lines {
+		transferID, counterpartyID := "", ""
+		if line.TransferID != 0 {
+			transferID = itoa(line.TransferID)
+		}
+		if line.CounterpartyAccountID != 0 {
+			counterpartyID = itoa(line.CounterpartyAccountID)
+		}
+		w.Write([]string{
+			line.Date.Format(time.RFC3339),
+			itoa(line.EntryID),
+			csvText(line.description()),
+			transferID,
+			counterpartyID,
+			csvText(line.CounterpartyOwner),
+			itoa(line.Amount),
+			itoa(line.Balance),
+			statement.Currency,
+		})
+	}
+	_, end := MonthRange(statement.Month)
+	w.Write([]string{end.Format(time.RFC3339), "", "closing balance", "", "", "", "", itoa(statement.ClosingBalance), statement.Currency})
+	w.Write([]string{"", "", "total credits", "", "", "", itoa(statement.TotalCredits), "", statement.Currency})
+	w.Write([]string{"", "", "total debits", "", "", "", itoa(statement.TotalDebits), "", statement.Currency})
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvText escapes a cell written by users, e.g. a description: a spreadsheet would run a cell starting
+// with "=", "+", "-", "@", a tab or a carriage return as a formula, so it's prefixed with "'" to stay text.
+func csvText(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// description is the one of the entry, or says what the entry is if it has none.
+func (line Line) description() string {
+	switch {
+	case line.Description != "":
+		return line.Description
+	case line.TransferID == 0:
+		return "entry"
+	case line.Amount >= 0:
+		return "transfer in"
+	default:
+		return "transfer out"
+	}
+}
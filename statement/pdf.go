@@ -0,0 +1,108 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// layout of the PDF: A4 pages in points, with a monospaced font so the columns line up
+const (
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 50
+	pdfFontSize     = 9
+	pdfLineHeight   = 12
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+)
+
+// PDF renders the statement as a plain text document, a line per entry.
+// The PDF is written by hand: it only needs text in a standard font, which every viewer has.
+func (statement Statement) PDF() ([]byte, error) {
+	_, end := MonthRange(statement.Month)
+	lines := []string{
+		fmt.Sprintf("Statement of account #%d - %s", statement.AccountID, statement.Month.Format("January 2006")),
+		fmt.Sprintf("Owner: %s    Currency: %s", statement.Owner, statement.Currency),
+		"",
+		fmt.Sprintf("%-16s %8s  %-22s %14s %14s", "Date (UTC)", "Entry", "Counterparty", "Amount", "Balance"),
+		fmt.Sprintf("%-16s %8s  %-22s %14s %14d", statement.Month.Format("2006-01-02 15:04"), "", "Opening balance", "", statement.OpeningBalance),
+	}
+	for _, line := range statement.Lines {
+		lines = append(lines, fmt.Sprintf("%-16s %8d  %-22.22s %14d %14d",
+			line.Date.Format("2006-01-02 15:04"), line.EntryID, line.counterparty(), line.Amount, line.Balance))
+	}
+	lines = append(lines,
+		fmt.Sprintf("%-16s %8s  %-22s %14s %14d", end.Format("2006-01-02 15:04"), "", "Closing balance", "", statement.ClosingBalance),
+		"",
+		fmt.Sprintf("Total credits: %d    Total debits: %d", statement.TotalCredits, statement.TotalDebits),
+	)
+
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+	return writePDF(pages), nil
+}
+
+// writePDF lays out pages of text lines: a catalog (1), the page tree (2), the font (3),
+// then a page and its content stream for each page.
+func writePDF(pages [][]string) []byte {
+	var buf bytes.Buffer
+	var offsets []int // of each object, for the cross-reference table
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+
+	for i, lines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	// no creation date: the same statement always renders to the same bytes
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfEscape makes `s` a valid PDF string literal: the standard fonts only cover ASCII,
+// anything else is replaced with "?".
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
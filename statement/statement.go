@@ -0,0 +1,91 @@
+// Package statement renders the monthly statements of accounts, as CSV & PDF.
+package statement
+
+import (
+	"fmt"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+)
+
+// MonthFormat is the format of a statement month, e.g. "2024-05".
+const MonthFormat = "2006-01"
+
+// Statement lists the entries of an account in a month (UTC), with the balances before & after it.
+type Statement struct {
+	AccountID      int64
+	Owner          string
+	Currency       string
+	Month          time.Time // 1st day of the month, 00:00 UTC
+	OpeningBalance int64
+	ClosingBalance int64
+	TotalCredits   int64
+	TotalDebits    int64 // negative, or 0
+	Lines          []Line
+}
+
+// Line is an entry of the statement.
+type Line struct {
+	EntryID               int64
+	Date                  time.Time
+	Amount                int64
+	Balance               int64 // right after the entry
+	TransferID            int64 // 0 if the entry isn't part of a transfer
+	Description           string
+	CounterpartyAccountID int64
+	CounterpartyOwner     string
+}
+
+// MonthRange returns the start of `month` and of the month after, in UTC.
+func MonthRange(month time.Time) (from time.Time, to time.Time) {
+	from = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 1, 0)
+}
+
+// New builds the statement of `account` for `month`, from the balance at the start of the month and the entries of the month.
+func New(account db.Account, month time.Time, openingBalance int64, entries []db.ListStatementEntriesRow) Statement {
+	from, _ := MonthRange(month)
+	statement := Statement{
+		AccountID:      account.ID,
+		Owner:          account.Owner,
+		Currency:       account.Currency,
+		Month:          from,
+		OpeningBalance: openingBalance,
+		Lines:          make([]Line, 0, len(entries)),
+	}
+
+	balance := openingBalance
+	for _, entry := range entries {
+		balance += entry.Amount
+		if entry.Amount > 0 {
+			statement.TotalCredits += entry.Amount
+		} else {
+			statement.TotalDebits += entry.Amount
+		}
+		statement.Lines = append(statement.Lines, Line{
+			EntryID:               entry.ID,
+			Date:                  entry.CreatedAt.UTC(),
+			Amount:                entry.Amount,
+			Balance:               balance,
+			TransferID:            entry.TransferID.Int64,
+			Description:           entry.Description,
+			CounterpartyAccountID: entry.CounterpartyAccountID,
+			CounterpartyOwner:     entry.CounterpartyOwner,
+		})
+	}
+	statement.ClosingBalance = balance
+	return statement
+}
+
+// Filename returns the name of the statement file with extension `ext`, e.g. "statement-42-2024-05.pdf".
+func (statement Statement) Filename(ext string) string {
+	return fmt.Sprintf("statement-%d-%s.%s", statement.AccountID, statement.Month.Format(MonthFormat), ext)
+}
+
+// counterparty describes the other side of a line, e.g. "#12 alice".
+func (line Line) counterparty() string {
+	if line.CounterpartyAccountID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("#%d %s", line.CounterpartyAccountID, line.CounterpartyOwner)
+}
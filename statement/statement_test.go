@@ -0,0 +1,165 @@
+package statement
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomStatement(t *testing.T, n int) Statement {
+	account := db.Account{
+		ID:       util.RandomInt(1, 1000),
+		Owner:    util.RandomOwner(),
+		Currency: util.USD,
+	}
+	month := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := make([]db.ListStatementEntriesRow, n)
+	for i := range entries {
+		amount := util.RandomMoney()
+		if i%2 == 1 {
+			amount = -amount
+		}
+		entries[i] = db.ListStatementEntriesRow{
+			ID:                    int64(i + 1),
+			Amount:                amount,
+			CreatedAt:             month.Add(time.Duration(i) * time.Hour),
+			TransferID:            sql.NullInt64{Int64: int64(100 + i), Valid: true},
+			CounterpartyAccountID: account.ID + 1,
+			CounterpartyOwner:     util.RandomOwner(),
+		}
+	}
+	if n > 0 {
+		entries[0].Description = "rent for May"
+	}
+	return New(account, month.Add(15*24*time.Hour), 1000, entries)
+}
+
+func TestNew(t *testing.T) {
+	statement := randomStatement(t, 4)
+	require.Equal(t, time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC), statement.Month)
+	require.Len(t, statement.Lines, 4)
+
+	balance, credits, debits := statement.OpeningBalance, int64(0), int64(0)
+	for _, line := range statement.Lines {
+		balance += line.Amount
+		require.Equal(t, balance, line.Balance)
+		if line.Amount > 0 {
+			credits += line.Amount
+		} else {
+			debits += line.Amount
+		}
+	}
+	require.Equal(t, balance, statement.ClosingBalance)
+	require.Equal(t, credits, statement.TotalCredits)
+	require.Equal(t, debits, statement.TotalDebits)
+	require.Negative(t, statement.TotalDebits)
+}
+
+func TestNewEmptyMonth(t *testing.T) {
+	statement := New(db.Account{ID: 1}, time.Date(2024, time.December, 31, 23, 0, 0, 0, time.UTC), 500, nil)
+	require.Empty(t, statement.Lines)
+	require.Equal(t, int64(500), statement.OpeningBalance)
+	require.Equal(t, int64(500), statement.ClosingBalance)
+
+	from, to := MonthRange(statement.Month)
+	require.Equal(t, time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC), from)
+	require.Equal(t, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), to)
+}
+
+func TestCSV(t *testing.T) {
+	statement := randomStatement(t, 3)
+	data, err := statement.CSV()
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	// header, opening balance, entries, closing balance, totals
+	require.Len(t, records, 1+1+3+1+2)
+	require.Equal(t, "opening balance", records[1][2])
+	require.Equal(t, "rent for May", records[2][2])
+	require.Equal(t, strconv.FormatInt(statement.OpeningBalance, 10), records[1][7])
+
+	for i, line := range statement.Lines {
+		record := records[2+i]
+		require.Equal(t, strconv.FormatInt(line.EntryID, 10), record[1])
+		require.Equal(t, line.description(), record[2])
+		require.Equal(t, strconv.FormatInt(line.TransferID, 10), record[3])
+		require.Equal(t, line.CounterpartyOwner, record[5])
+		require.Equal(t, strconv.FormatInt(line.Amount, 10), record[6])
+		require.Equal(t, strconv.FormatInt(line.Balance, 10), record[7])
+	}
+
+	require.Equal(t, "closing balance", records[5][2])
+	require.Equal(t, strconv.FormatInt(statement.ClosingBalance, 10), records[5][7])
+	require.Equal(t, strconv.FormatInt(statement.TotalCredits, 10), records[6][6])
+	require.Equal(t, strconv.FormatInt(statement.TotalDebits, 10), records[7][6])
+}
+
+func TestPDF(t *testing.T) {
+	statement := randomStatement(t, 2*pdfLinesPerPage) // spans 3 pages
+	data, err := statement.PDF()
+	require.NoError(t, err)
+
+	require.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	require.Contains(t, string(data), "/Count 3")
+	require.Contains(t, string(data), fmt.Sprintf("Statement of account #%d - May 2024", statement.AccountID))
+	require.Regexp(t, fmt.Sprintf(`\(\S+ \S+ +Closing balance +%d\) Tj`, statement.ClosingBalance), string(data))
+
+	// every offset of the cross-reference table points to its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(string(startxref[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data[xref:], []byte("xref\n")))
+
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	require.Len(t, offsets, 3+2*3)
+	for i, offset := range offsets {
+		n, err := strconv.Atoi(string(offset[1]))
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(data[n:], fmt.Appendf(nil, "%d 0 obj\n", i+1)))
+	}
+
+	// the same statement always renders to the same bytes
+	again, err := statement.PDF()
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+}
+
+func TestCSVFormulaInjection(t *testing.T) {
+	statement := randomStatement(t, 3)
+	statement.Lines[0].Description = `=HYPERLINK("https://example.com","refund")`
+	statement.Lines[1].Description = "-2+3"
+	statement.Lines[2].Description = "rent - May"
+
+	data, err := statement.CSV()
+	require.NoError(t, err)
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+
+	require.Equal(t, `'=HYPERLINK("https://example.com","refund")`, records[2][2])
+	require.Equal(t, "'-2+3", records[3][2])
+	require.Equal(t, "rent - May", records[4][2])
+	// the amounts stay numbers
+	require.Equal(t, strconv.FormatInt(statement.Lines[0].Amount, 10), records[2][6])
+
+	for _, cell := range []string{"+1", "@SUM(A1)", "\tx", "\rx"} {
+		require.Equal(t, "'"+cell, csvText(cell))
+	}
+	require.Equal(t, "", csvText(""))
+}
+
+func TestPDFEscape(t *testing.T) {
+	require.Equal(t, `a\(b\)c\\d?`, pdfEscape("a(b)c\\dé"))
+}
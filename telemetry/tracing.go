@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Supported values of `TRACING_EXPORTER`
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// ShutdownFunc flushes pending spans and releases the exporter.
+type ShutdownFunc func(ctx context.Context) error
+
+// InitTracer installs the global OpenTelemetry tracer provider selected by `config`.
+// Gin handlers, `Querier` calls and `execTx` all pick up the global provider through `otel.Tracer`,
+// so with the "none" exporter every span is a cheap no-op.
+func InitTracer(ctx context.Context, config util.Config) (ShutdownFunc, error) {
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	serviceName := config.TracingServiceName
+	if serviceName == "" {
+		serviceName = "simplebank"
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	// propagate the trace context in & out of HTTP headers (W3C `traceparent`)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// newExporter returns nil (and no error) when tracing is disabled.
+func newExporter(ctx context.Context, config util.Config) (sdktrace.SpanExporter, error) {
+	switch config.TracingExporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if config.TracingOTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.TracingOTLPEndpoint))
+		}
+		if config.TracingOTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("unsupported tracing exporter: %s", config.TracingExporter)
+}
@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExporter(t *testing.T) {
+	testCases := []struct {
+		name          string
+		exporter      string
+		checkExporter func(t *testing.T, isNil bool, err error)
+	}{
+		{
+			name:     "Disabled",
+			exporter: "",
+			checkExporter: func(t *testing.T, isNil bool, err error) {
+				require.NoError(t, err)
+				require.True(t, isNil)
+			},
+		},
+		{
+			name:     "None",
+			exporter: ExporterNone,
+			checkExporter: func(t *testing.T, isNil bool, err error) {
+				require.NoError(t, err)
+				require.True(t, isNil)
+			},
+		},
+		{
+			name:     "Stdout",
+			exporter: ExporterStdout,
+			checkExporter: func(t *testing.T, isNil bool, err error) {
+				require.NoError(t, err)
+				require.False(t, isNil)
+			},
+		},
+		{
+			name:     "OTLP",
+			exporter: ExporterOTLP,
+			checkExporter: func(t *testing.T, isNil bool, err error) {
+				require.NoError(t, err)
+				require.False(t, isNil)
+			},
+		},
+		{
+			name:     "Unsupported",
+			exporter: "zipkin",
+			checkExporter: func(t *testing.T, isNil bool, err error) {
+				require.Error(t, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			config := util.Config{
+				TracingExporter:     tc.exporter,
+				TracingOTLPEndpoint: "localhost:4318",
+				TracingOTLPInsecure: true,
+			}
+
+			exporter, err := newExporter(context.Background(), config)
+			tc.checkExporter(t, exporter == nil, err)
+		})
+	}
+}
+
+func TestInitTracerDisabled(t *testing.T) {
+	shutdown, err := InitTracer(context.Background(), util.Config{TracingExporter: ExporterNone})
+	require.NoError(t, err)
+	require.NotNil(t, shutdown)
+	require.NoError(t, shutdown(context.Background()))
+}
@@ -0,0 +1,119 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// PublicKeyMaker is a Maker signing tokens with a private key,
+// so other services can verify them with the published public keys, without any secret.
+type PublicKeyMaker interface {
+	Maker
+
+	// JWKS returns the public keys tokens can currently be verified with
+	JWKS() JWKS
+}
+
+// JWKS is a JSON Web Key Set (RFC 7517), served at `/.well-known/jwks.json`.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the public part of a signing key, as a JSON Web Key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// OKP (Ed25519)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// publicJWKS lists the non-retired public keys of the keyring, sorted by kid.
+func publicJWKS(keyring *Keyring, algorithm string) JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range keyring.Keys() {
+		if key.Retired {
+			continue
+		}
+
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: algorithm}
+		switch publicKey := key.PublicKey.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID
+	})
+	return jwks
+}
+
+// ParsePEMKey parses a PEM encoded key into a Key with the given kid.
+// It accepts a PKCS #8 or PKCS #1 private key, or a PKIX public key for a verify-only Key.
+func ParsePEMKey(keyID string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("key %q is not PEM encoded", keyID)
+	}
+
+	key := Key{ID: keyID}
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("cannot parse private key %q: %w", keyID, err)
+		}
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return Key{}, fmt.Errorf("unsupported private key type %T of %q", privateKey, keyID)
+		}
+		key.PrivateKey = signer
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("cannot parse private key %q: %w", keyID, err)
+		}
+		key.PrivateKey = privateKey
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("cannot parse public key %q: %w", keyID, err)
+		}
+		key.PublicKey = publicKey
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q of %q", block.Type, keyID)
+	}
+
+	if key.PublicKey == nil {
+		key.PublicKey = key.PrivateKey.Public()
+	}
+	return key, nil
+}
+
+// ErrNoPrivateKey is returned when a verify-only maker, e.g. of a service only checking tokens, is asked to create one.
+var ErrNoPrivateKey = errors.New("active key has no private key: this maker can only verify tokens")
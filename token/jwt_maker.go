@@ -1,81 +1,158 @@
 package token
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const minSecretKeysize = 32
+const (
+	minSecretKeysize = 32
+	minRSAKeyBits    = 2048
+)
+
+// Supported JWT signing algorithms
+const (
+	JWTAlgorithmHS256 = "HS256" // symmetric, default
+	JWTAlgorithmEdDSA = "EdDSA" // Ed25519 key pairs
+	JWTAlgorithmRS256 = "RS256" // RSA key pairs
+)
 
 // JWTMaker is a JSON Web Token maker. It implements the `Maker` interface.
 type JWTMaker struct {
-	secretKey string // use symmetric algorithm
+	method  jwt.SigningMethod
+	keyring *Keyring
+	options options
 }
 
 // NewJWTMaker creates a JWTMaker
-func NewJWTMaker(secretKey string) (Maker, error) {
-	if len(secretKey) < minSecretKeysize {
-		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeysize)
+func NewJWTMaker(secretKey string, opts ...Option) (Maker, error) {
+	return NewJWTMakerWithKeyring(newSingleKeyring(secretKey), opts...)
+}
+
+// NewJWTMakerWithKeyring creates a JWTMaker supporting key rotation
+func NewJWTMakerWithKeyring(keyring *Keyring, opts ...Option) (Maker, error) {
+	for _, key := range keyring.Keys() {
+		if len(key.Secret) < minSecretKeysize {
+			return nil, fmt.Errorf("invalid key size of %q: must be at least %d characters", key.ID, minSecretKeysize)
+		}
+	}
+	return &JWTMaker{jwt.SigningMethodHS256, keyring, newOptions(opts)}, nil
+}
+
+// NewJWTPublicKeyMaker creates a JWTMaker signing with the private keys of the keyring,
+// using `algorithm` EdDSA (Ed25519 keys) or RS256 (RSA keys).
+func NewJWTPublicKeyMaker(algorithm string, keyring *Keyring, opts ...Option) (PublicKeyMaker, error) {
+	var method jwt.SigningMethod
+	switch algorithm {
+	case JWTAlgorithmEdDSA:
+		method = jwt.SigningMethodEdDSA
+	case JWTAlgorithmRS256:
+		method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", algorithm)
 	}
-	return &JWTMaker{secretKey}, nil
+
+	for _, key := range keyring.Keys() {
+		switch publicKey := key.PublicKey.(type) {
+		case ed25519.PublicKey:
+			if algorithm != JWTAlgorithmEdDSA {
+				return nil, fmt.Errorf("key %q is an Ed25519 key: cannot be used with %s", key.ID, algorithm)
+			}
+		case *rsa.PublicKey:
+			if algorithm != JWTAlgorithmRS256 {
+				return nil, fmt.Errorf("key %q is an RSA key: cannot be used with %s", key.ID, algorithm)
+			}
+			if publicKey.N.BitLen() < minRSAKeyBits {
+				return nil, fmt.Errorf("invalid key size of %q: must be at least %d bits", key.ID, minRSAKeyBits)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported key type %T of %q", key.PublicKey, key.ID)
+		}
+	}
+	return &jwtPublicKeyMaker{&JWTMaker{method, keyring, newOptions(opts)}}, nil
+}
+
+// jwtPublicKeyMaker is a JWTMaker with an asymmetric algorithm, publishing its public keys
+type jwtPublicKeyMaker struct {
+	*JWTMaker
+}
+
+// JWKS implements PublicKeyMaker.
+func (maker *jwtPublicKeyMaker) JWKS() JWKS {
+	return publicJWKS(maker.keyring, maker.method.Alg())
+}
+
+func (maker *JWTMaker) isSymmetric() bool {
+	_, ok := maker.method.(*jwt.SigningMethodHMAC)
+	return ok
 }
 
 // CreateToken creates a new token for a specific username and duration
-func (maker *JWTMaker) CreateToken(username string, duration time.Duration) (string, error) {
+func (maker *JWTMaker) CreateToken(username string, duration time.Duration, scopes ...string) (string, error) {
 	//  JWT = Header + Payload (Claim) + Signature
 
-	payload, err := NewPayload(username, duration)
+	payload, err := maker.options.newPayload(username, duration, scopes)
 	if err != nil {
 		return "", err
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload) // `HS256` -> Header
-	return jwtToken.SignedString([]byte(maker.secretKey))          // -> Signature
-	// WHY convert `maker.secretKey` to byte slice?
-	// Because signing process requires a byte slice as input for cryptographic algorithms.
+	key := maker.keyring.Active()
+	jwtToken := jwt.NewWithClaims(maker.method, payload) // e.g. `HS256` -> Header
+	jwtToken.Header["kid"] = key.ID                      // which key to verify with -> Header
+
+	if maker.isSymmetric() {
+		return jwtToken.SignedString(key.Secret) // -> Signature
+		// WHY is `key.Secret` a byte slice?
+		// Because signing process requires a byte slice as input for cryptographic algorithms.
+	}
+	if key.PrivateKey == nil {
+		return "", ErrNoPrivateKey
+	}
+	return jwtToken.SignedString(key.PrivateKey)
 }
 
 // VerifyToken checks if the token is valid or not
 func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
 	// `keyFunc` retrieves the key for verifying the token's signature.
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		// pick the key by the `kid` header, tokens without it are verified with the active key
+		keyID, _ := token.Header["kid"].(string)
+		key, err := maker.keyring.verificationKey(keyID)
+		if err != nil {
+			return nil, err
+		}
+
 		// Check if the signing method is valid (i.e, whether the algorithm matches or not)
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
 		// HMAC: a generic method supporting all HMAC algorithms (HS256, HS384, HS512, etc.) for validation.
 		// Verification can use SigningMethodHMAC to allow flexibility, but signing must use a specific method like HS256.
-		if !ok {
+		// WHY check it at all? Otherwise a public key could be used as an HMAC secret (algorithm confusion).
+		if maker.isSymmetric() {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, ErrInvalidToken
+				// return nil, jwt.ErrTokenInvalidClaims
+			}
+			return key.Secret, nil
+		}
+
+		if token.Method.Alg() != maker.method.Alg() {
 			return nil, ErrInvalidToken
-			// return nil, jwt.ErrTokenInvalidClaims
 		}
-		return []byte(maker.secretKey), nil
+		return key.PublicKey, nil
 	}
 
-	// Parse and validate the token
+	// Parse the token & verify its signature
 	// WHY `&Payload{}` instead of `Payload`?
 	// `&Payload{}` passes a pointer.
 	// `Payload` passes a value copy.
 	// `ParseWithClaims` writes the parsed token data into the provided <jwt.Cliams>.
-	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
+	// WHY skip the claims validation of `jwt`? Its errors cannot be unwrapped into ours (see `errors.Unwrap`),
+	// so the claims are validated by `maker.options`, the same way as for PASETO.
+	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc, jwt.WithoutClaimsValidation())
 	if err != nil {
-		// 2 possible scenarios: (1) invalid token & (2) expired
-		// to figure out which error
-		// Unwrap the original error
-
-		// FIXME:
-		// fmt.Printf("Error: %v\n", err)
-		// fmt.Printf("Cause: %v\n", errors.Unwrap(err)) // Output: Cause: <nil>
-		// WHY nil? (ChatGPT:) Because newError (<- ParseWithClaims)
-		// Cannot wrap: current `newError` implementation
-		// Can wrap: `wrapped = fmt.Errorf("%w: %w", wrapped, e)`
-
-		// Match error message directly for expiration
-		if strings.Contains(err.Error(), jwt.ErrTokenExpired.Error()) {
-			return nil, ErrExpiredToken
-			// return nil, jwt.ErrTokenExpired
-		}
 		return nil, ErrInvalidToken
 		// return nil, jwt.ErrTokenInvalidClaims
 	}
@@ -86,6 +163,10 @@ func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
 		// return nil, jwt.ErrTokenInvalidClaims
 	}
 
-	// no errors so far, token is valid
+	// signature is valid, check expiration, issuer & audience
+	err = maker.options.validate(payload)
+	if err != nil {
+		return nil, err
+	}
 	return payload, nil
 }
@@ -0,0 +1,101 @@
+package token
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+)
+
+// DefaultKeyID is the key ID of a maker created from a single secret key.
+const DefaultKeyID = "default"
+
+// Key is a secret key identified by its key ID (kid).
+// The kid is sent along with the token (JWT header, PASETO footer) to pick the verification key.
+//
+// Symmetric makers use `Secret`, asymmetric makers use the key pair:
+// `PrivateKey` is only needed to sign, so a service which only verifies tokens holds the `PublicKey`.
+type Key struct {
+	ID         string
+	Secret     []byte
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey // derived from `PrivateKey` if not set
+	Retired    bool             // tokens signed with a retired key are rejected
+}
+
+// Keyring holds the keys of a maker, so keys can be rotated without logging everyone out:
+// new tokens are signed with the active key, tokens signed with any non-retired key are accepted.
+//
+// To rotate: add a new key, make it active once every instance knows it,
+// then retire the old key after the longest token duration has passed.
+type Keyring struct {
+	active Key
+	keys   map[string]Key
+}
+
+// NewKeyring creates a keyring signing new tokens with the key `activeKeyID`.
+func NewKeyring(activeKeyID string, keys ...Key) (*Keyring, error) {
+	keyring := &Keyring{
+		keys: make(map[string]Key, len(keys)),
+	}
+
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key ID must not be empty")
+		}
+		if _, ok := keyring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		if key.PublicKey == nil && key.PrivateKey != nil {
+			key.PublicKey = key.PrivateKey.Public()
+		}
+		keyring.keys[key.ID] = key
+	}
+
+	active, ok := keyring.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeKeyID)
+	}
+	if active.Retired {
+		return nil, fmt.Errorf("active key %q is retired", activeKeyID)
+	}
+	keyring.active = active
+
+	return keyring, nil
+}
+
+// newSingleKeyring wraps a single secret key, used by the constructors taking one key.
+func newSingleKeyring(secretKey string) *Keyring {
+	key := Key{ID: DefaultKeyID, Secret: []byte(secretKey)}
+	return &Keyring{
+		active: key,
+		keys:   map[string]Key{key.ID: key},
+	}
+}
+
+// Active returns the key new tokens are signed with.
+func (keyring *Keyring) Active() Key {
+	return keyring.active
+}
+
+// Keys returns all the keys of the keyring, including the retired ones.
+func (keyring *Keyring) Keys() []Key {
+	keys := make([]Key, 0, len(keyring.keys))
+	for _, key := range keyring.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// verificationKey returns the key to verify a token signed with `keyID`.
+// Tokens without a kid (created before key rotation was introduced) are verified with the active key.
+func (keyring *Keyring) verificationKey(keyID string) (Key, error) {
+	if keyID == "" {
+		return keyring.active, nil
+	}
+
+	key, ok := keyring.keys[keyID]
+	if !ok || key.Retired {
+		return Key{}, ErrInvalidToken
+	}
+	return key, nil
+}
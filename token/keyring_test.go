@@ -0,0 +1,113 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomKey(id string) Key {
+	return Key{ID: id, Secret: []byte(util.RandomString(32))}
+}
+
+func TestNewKeyring(t *testing.T) {
+	key1 := randomKey("key1")
+	key2 := randomKey("key2")
+	retiredKey := randomKey("retired")
+	retiredKey.Retired = true
+
+	keyring, err := NewKeyring(key2.ID, key1, key2, retiredKey)
+	require.NoError(t, err)
+	require.Equal(t, key2, keyring.Active())
+	require.Len(t, keyring.Keys(), 3)
+
+	key, err := keyring.verificationKey(key1.ID)
+	require.NoError(t, err)
+	require.Equal(t, key1, key)
+
+	key, err = keyring.verificationKey("") // no kid
+	require.NoError(t, err)
+	require.Equal(t, key2, key)
+
+	_, err = keyring.verificationKey(retiredKey.ID)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = keyring.verificationKey("unknown")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	key := randomKey("key")
+	retiredKey := randomKey("retired")
+	retiredKey.Retired = true
+
+	_, err := NewKeyring("unknown", key)
+	require.Error(t, err)
+
+	_, err = NewKeyring(retiredKey.ID, key, retiredKey)
+	require.Error(t, err)
+
+	_, err = NewKeyring(key.ID, key, key)
+	require.Error(t, err)
+
+	_, err = NewKeyring("", Key{Secret: key.Secret})
+	require.Error(t, err)
+}
+
+// TestKeyRotation checks both makers keep accepting tokens of the previous key until it's retired.
+func TestKeyRotation(t *testing.T) {
+	makers := map[string]func(keyring *Keyring, opts ...Option) (Maker, error){
+		"JWT":    NewJWTMakerWithKeyring,
+		"PASETO": NewPasetoMakerWithKeyring,
+	}
+
+	for name, newMaker := range makers {
+		t.Run(name, func(t *testing.T) {
+			oldKey := randomKey("old")
+			newKey := randomKey("new")
+
+			// before rotation: only the old key
+			keyring, err := NewKeyring(oldKey.ID, oldKey)
+			require.NoError(t, err)
+			oldMaker, err := newMaker(keyring)
+			require.NoError(t, err)
+
+			token, err := oldMaker.CreateToken(util.RandomOwner(), time.Minute)
+			require.NoError(t, err)
+
+			// during rotation: sign with the new key, still accept the old one
+			keyring, err = NewKeyring(newKey.ID, oldKey, newKey)
+			require.NoError(t, err)
+			maker, err := newMaker(keyring)
+			require.NoError(t, err)
+
+			_, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+
+			newToken, err := maker.CreateToken(util.RandomOwner(), time.Minute)
+			require.NoError(t, err)
+			_, err = maker.VerifyToken(newToken)
+			require.NoError(t, err)
+
+			// the old instances don't know the new key
+			_, err = oldMaker.VerifyToken(newToken)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+
+			// after rotation: the old key is retired
+			oldKey.Retired = true
+			keyring, err = NewKeyring(newKey.ID, oldKey, newKey)
+			require.NoError(t, err)
+			maker, err = newMaker(keyring)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+			require.Nil(t, payload)
+
+			_, err = maker.VerifyToken(newToken)
+			require.NoError(t, err)
+		})
+	}
+}
@@ -4,8 +4,8 @@ import "time"
 
 // Maker is an interface of managing tokens.
 type Maker interface {
-	// CreateToken creates a new token for a specific username and duration
-	CreateToken(username string, duration time.Duration) (string, error)
+	// CreateToken creates a new token for a specific username and duration, granting `scopes`
+	CreateToken(username string, duration time.Duration, scopes ...string) (string, error)
 
 	// VerifyToken checks if the token is valid or not
 	VerifyToken(token string) (*Payload, error)
@@ -0,0 +1,80 @@
+package token
+
+import (
+	"slices"
+	"time"
+)
+
+// Option configures the claims a maker puts in its tokens, and requires from the tokens it verifies.
+type Option func(options *options)
+
+type options struct {
+	issuer   string
+	audience []string
+	leeway   time.Duration
+}
+
+// WithIssuer sets the `iss` claim of new tokens.
+// Only tokens issued by `issuer` are accepted.
+func WithIssuer(issuer string) Option {
+	return func(options *options) {
+		options.issuer = issuer
+	}
+}
+
+// WithAudience sets the `aud` claim of new tokens.
+// Only tokens intended for at least one of `audience` are accepted.
+func WithAudience(audience ...string) Option {
+	return func(options *options) {
+		options.audience = audience
+	}
+}
+
+// WithLeeway tolerates a clock skew of `leeway` between the issuer and the verifier
+// when checking the `exp` and `nbf` claims.
+func WithLeeway(leeway time.Duration) Option {
+	return func(options *options) {
+		options.leeway = leeway
+	}
+}
+
+func newOptions(opts []Option) options {
+	options := options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// newPayload creates the payload of a new token, with the issuer & audience of the maker.
+func (options options) newPayload(username string, duration time.Duration, scopes []string) (*Payload, error) {
+	payload, err := NewPayload(username, duration, scopes...)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.Issuer = options.issuer
+	payload.Audience = options.audience
+	return payload, nil
+}
+
+// validate checks the time window, issuer & audience of a verified token.
+func (options options) validate(payload *Payload) error {
+	now := time.Now()
+
+	if now.Add(options.leeway).Before(payload.NotBefore) {
+		return ErrTokenNotYetValid
+	}
+	if now.Add(-options.leeway).After(payload.ExpiredAt) {
+		return ErrExpiredToken
+	}
+	if options.issuer != "" && payload.Issuer != options.issuer {
+		return ErrInvalidIssuer
+	}
+	if len(options.audience) > 0 && !slices.ContainsFunc(payload.Audience, func(audience string) bool {
+		return slices.Contains(options.audience, audience)
+	}) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package token
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMakerOptions checks a token is only accepted by makers expecting its issuer & audience.
+func TestMakerOptions(t *testing.T) {
+	makers := map[string]func(secretKey string, opts ...Option) (Maker, error){
+		"JWT":    NewJWTMaker,
+		"PASETO": NewPasetoMaker,
+	}
+
+	for name, newMaker := range makers {
+		t.Run(name, func(t *testing.T) {
+			secretKey := util.RandomString(32)
+			maker, err := newMaker(secretKey, WithIssuer("simplebank"), WithAudience("simplebank-api", "admin"))
+			require.NoError(t, err)
+
+			username := util.RandomOwner()
+			token, err := maker.CreateToken(username, time.Minute)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, "simplebank", payload.Issuer)
+			require.Equal(t, []string{"simplebank-api", "admin"}, payload.Audience)
+			require.Equal(t, username, payload.Subject)
+			require.WithinDuration(t, time.Now(), payload.NotBefore, time.Second)
+
+			// one matching audience is enough
+			verifier, err := newMaker(secretKey, WithIssuer("simplebank"), WithAudience("admin"))
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(token)
+			require.NoError(t, err)
+
+			verifier, err = newMaker(secretKey, WithIssuer("simplebank-staging"))
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(token)
+			require.EqualError(t, err, ErrInvalidIssuer.Error())
+
+			verifier, err = newMaker(secretKey, WithAudience("other-service"))
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(token)
+			require.EqualError(t, err, ErrInvalidAudience.Error())
+
+			// a maker without options accepts any issuer & audience
+			verifier, err = newMaker(secretKey)
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(token)
+			require.NoError(t, err)
+
+			// leeway tolerates a token which just expired
+			token, err = maker.CreateToken(username, -time.Second)
+			require.NoError(t, err)
+			_, err = maker.VerifyToken(token)
+			require.EqualError(t, err, ErrExpiredToken.Error())
+
+			verifier, err = newMaker(secretKey, WithLeeway(time.Minute))
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(token)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateNotBefore(t *testing.T) {
+	payload, err := NewPayload(util.RandomOwner(), time.Minute)
+	require.NoError(t, err)
+	payload.NotBefore = time.Now().Add(10 * time.Second)
+
+	err = newOptions(nil).validate(payload)
+	require.ErrorIs(t, err, ErrTokenNotYetValid)
+
+	err = newOptions([]Option{WithLeeway(30 * time.Second)}).validate(payload)
+	require.NoError(t, err)
+}
+
+// TestPayloadJSON checks the claims are encoded as other JWT libraries expect them.
+func TestPayloadJSON(t *testing.T) {
+	payload, err := NewPayload(util.RandomOwner(), time.Minute)
+	require.NoError(t, err)
+	payload.Audience = []string{"simplebank-api"}
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	var claims map[string]any
+	require.NoError(t, json.Unmarshal(data, &claims))
+	require.Equal(t, float64(payload.NotBefore.Unix()), claims["nbf"])
+
+	decoded := &Payload{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.Equal(t, payload.Audience, decoded.Audience)
+	require.Equal(t, payload.NotBefore.Unix(), decoded.NotBefore.Unix())
+
+	// a single audience may be a string
+	require.NoError(t, json.Unmarshal([]byte(`{"aud": "simplebank-api", "nbf": 1700000000}`), decoded))
+	require.Equal(t, []string{"simplebank-api"}, decoded.Audience)
+	require.Equal(t, time.Unix(1700000000, 0), decoded.NotBefore)
+
+	// the tokens created before `nbf` was a NumericDate
+	require.NoError(t, json.Unmarshal([]byte(`{"nbf": "2023-11-14T22:13:20Z"}`), decoded))
+	require.True(t, time.Unix(1700000000, 0).Equal(decoded.NotBefore))
+	require.Nil(t, decoded.Audience)
+}
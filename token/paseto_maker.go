@@ -10,45 +10,73 @@ import (
 
 // PasetoMaker is a PASETO token maker
 type PasetoMaker struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto  *paseto.V2
+	keyring *Keyring
+	options options
+}
+
+// pasetoFooter is the (unencrypted but authenticated) footer of the token
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
 }
 
 // NewPasetoMaker creates a new PasetoMaker
-func NewPasetoMaker(symmetricKey string) (Maker, error) {
-	if len(symmetricKey) != chacha20poly1305.KeySize {
-		return nil, fmt.Errorf("invalid key size: must be exactly %d characters", chacha20poly1305.KeySize)
+func NewPasetoMaker(symmetricKey string, opts ...Option) (Maker, error) {
+	return NewPasetoMakerWithKeyring(newSingleKeyring(symmetricKey), opts...)
+}
+
+// NewPasetoMakerWithKeyring creates a new PasetoMaker supporting key rotation
+func NewPasetoMakerWithKeyring(keyring *Keyring, opts ...Option) (Maker, error) {
+	for _, key := range keyring.Keys() {
+		if len(key.Secret) != chacha20poly1305.KeySize {
+			return nil, fmt.Errorf("invalid key size of %q: must be exactly %d characters", key.ID, chacha20poly1305.KeySize)
+		}
 	}
 
 	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(symmetricKey),
+		paseto:  paseto.NewV2(),
+		keyring: keyring,
+		options: newOptions(opts),
 	}
 	return maker, nil
 }
 
 // CreateToken implements Maker.
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, error) {
-	payload, err := NewPayload(username, duration)
+func (maker *PasetoMaker) CreateToken(username string, duration time.Duration, scopes ...string) (string, error) {
+	payload, err := maker.options.newPayload(username, duration, scopes)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
-	return maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+
+	key := maker.keyring.Active()
+	return maker.paseto.Encrypt(key.Secret, payload, pasetoFooter{KeyID: key.ID})
 }
 
 // VerifyToken implements Maker.
 func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
 	payload := &Payload{}
-	
-	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
+
+	// the footer is readable without the key, it tells which key to decrypt with
+	footer := pasetoFooter{}
+	err := paseto.ParseFooter(token, &footer)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := maker.keyring.verificationKey(footer.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = maker.paseto.Decrypt(token, key.Secret, payload, nil)
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
-	
-	err = payload.Valid()
+
+	err = maker.options.validate(payload)
 	if err != nil {
-		return nil, ErrExpiredToken
+		return nil, err
 	}
-	
+
 	return payload, nil
-}
\ No newline at end of file
+}
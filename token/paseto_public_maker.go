@@ -0,0 +1,111 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	paseto4 "aidanwoods.dev/go-paseto"
+)
+
+// PasetoPublicMaker is a PASETO v4.public token maker.
+// Tokens are signed (not encrypted) with Ed25519, so they can be verified with the public key only.
+type PasetoPublicMaker struct {
+	keyring *Keyring
+	options options
+}
+
+// NewPasetoPublicMaker creates a new PasetoPublicMaker with the Ed25519 keys of the keyring
+func NewPasetoPublicMaker(keyring *Keyring, opts ...Option) (PublicKeyMaker, error) {
+	for _, key := range keyring.Keys() {
+		if _, ok := key.PublicKey.(ed25519.PublicKey); !ok {
+			return nil, fmt.Errorf("invalid key %q: v4.public requires an Ed25519 key", key.ID)
+		}
+	}
+	return &PasetoPublicMaker{keyring, newOptions(opts)}, nil
+}
+
+// CreateToken implements Maker.
+func (maker *PasetoPublicMaker) CreateToken(username string, duration time.Duration, scopes ...string) (string, error) {
+	payload, err := maker.options.newPayload(username, duration, scopes)
+	if err != nil {
+		return "", err
+	}
+
+	key := maker.keyring.Active()
+	privateKey, ok := key.PrivateKey.(ed25519.PrivateKey)
+	if !ok {
+		return "", ErrNoPrivateKey
+	}
+	secretKey, err := paseto4.NewV4AsymmetricSecretKeyFromEd25519(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	footer, err := json.Marshal(pasetoFooter{KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+
+	token, err := paseto4.NewTokenFromClaimsJSON(claims, footer)
+	if err != nil {
+		return "", err
+	}
+	return token.V4Sign(secretKey, nil), nil
+}
+
+// VerifyToken implements Maker.
+func (maker *PasetoPublicMaker) VerifyToken(token string) (*Payload, error) {
+	// no parser rules: the expiration is checked by `payload.Valid()`, like the other makers
+	parser := paseto4.MakeParser(nil)
+
+	// the footer is readable without the key, it tells which key to verify with
+	footer := pasetoFooter{}
+	rawFooter, err := parser.UnsafeParseFooter(paseto4.V4Public, token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if len(rawFooter) > 0 {
+		if err := json.Unmarshal(rawFooter, &footer); err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	key, err := maker.keyring.verificationKey(footer.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := paseto4.NewV4AsymmetricPublicKeyFromEd25519(key.PublicKey.(ed25519.PublicKey))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	parsed, err := parser.ParseV4Public(publicKey, token, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	err = json.Unmarshal(parsed.ClaimsJSON(), payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	err = maker.options.validate(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// JWKS implements PublicKeyMaker.
+// There is no registered JWK algorithm for PASETO, verifiers must know the tokens are v4.public.
+func (maker *PasetoPublicMaker) JWKS() JWKS {
+	return publicJWKS(maker.keyring, "")
+}
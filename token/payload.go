@@ -1,6 +1,8 @@
 package token
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -10,8 +12,11 @@ import (
 
 // Different types of error returned by the VerifyToken function
 var (
-	ErrInvalidToken = errors.New("token is invalid")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidToken     = errors.New("token is invalid")
+	ErrExpiredToken     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token has an invalid issuer")
+	ErrInvalidAudience  = errors.New("token has an invalid audience")
 )
 
 // Payload contains the payload data of the token.
@@ -23,12 +28,65 @@ type Payload struct {
 
 	// add `ID` to invalidate some specific tokens in case they are leaked
 	ID uuid.UUID `json:"id"`
+
+	// standard claims, so a token is only accepted by the services it was issued for.
+	// `aud` may be a single string, and `nbf` is in seconds since the epoch, see `MarshalJSON`
+	Issuer    string    `json:"iss,omitempty"`
+	Audience  []string  `json:"aud,omitempty"`
+	Subject   string    `json:"sub,omitempty"`
+	NotBefore time.Time `json:"-"`
+
+	// what the token may be used for, e.g. `accounts:read`, see `HasScopes`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// payloadJSON is the JSON of a Payload, without its methods, so it doesn't call `MarshalJSON` again.
+type payloadJSON Payload
+
+// MarshalJSON encodes `nbf` as a NumericDate, like other JWT libraries expect it.
+func (payload Payload) MarshalJSON() ([]byte, error) {
+	var notBefore *jwt.NumericDate
+	if !payload.NotBefore.IsZero() {
+		notBefore = jwt.NewNumericDate(payload.NotBefore)
+	}
+	return json.Marshal(struct {
+		payloadJSON
+		NotBefore *jwt.NumericDate `json:"nbf,omitempty"`
+	}{payloadJSON(payload), notBefore})
+}
+
+// UnmarshalJSON accepts a single string as `aud`, and decodes `nbf` as a NumericDate,
+// or as a RFC 3339 string in the tokens created before it was one.
+func (payload *Payload) UnmarshalJSON(data []byte) error {
+	var claims struct {
+		*payloadJSON
+		Audience  jwt.ClaimStrings `json:"aud"`
+		NotBefore json.RawMessage  `json:"nbf"`
+	}
+	claims.payloadJSON = (*payloadJSON)(payload)
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+
+	payload.Audience = claims.Audience
+	payload.NotBefore = time.Time{}
+	switch {
+	case len(claims.NotBefore) == 0 || bytes.Equal(claims.NotBefore, []byte("null")):
+	case claims.NotBefore[0] == '"':
+		return json.Unmarshal(claims.NotBefore, &payload.NotBefore)
+	default:
+		var notBefore jwt.NumericDate
+		if err := json.Unmarshal(claims.NotBefore, &notBefore); err != nil {
+			return err
+		}
+		payload.NotBefore = notBefore.Time
+	}
+	return nil
 }
 
 // GetAudience implements jwt.Claims.
 func (payload *Payload) GetAudience() (jwt.ClaimStrings, error) {
-	// panic("unimplemented")
-	return nil, nil // No audience used; return nil
+	return payload.Audience, nil
 }
 
 // GetExpirationTime implements jwt.Claims.
@@ -45,44 +103,45 @@ func (payload *Payload) GetIssuedAt() (*jwt.NumericDate, error) {
 
 // GetIssuer implements jwt.Claims.
 func (payload *Payload) GetIssuer() (string, error) {
-	// panic("unimplemented")
-	return "", nil // No issuer; return empty string
+	return payload.Issuer, nil
 }
 
 // GetNotBefore implements jwt.Claims.
 func (payload *Payload) GetNotBefore() (*jwt.NumericDate, error) {
-	// panic("unimplemented")
-	return nil, nil // NotBefore not used; return nil
+	if payload.NotBefore.IsZero() {
+		return nil, nil // tokens created before `nbf` was added
+	}
+	return jwt.NewNumericDate(payload.NotBefore), nil
 }
 
 // GetSubject implements jwt.Claims.
 func (payload *Payload) GetSubject() (string, error) {
-	// panic("unimplemented")
-	return "", nil // No subject; return empty string
+	return payload.Subject, nil
 }
 
-// NewPayload creates a new token payload with a specific username and duration.
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+// NewPayload creates a new token payload with a specific username, duration and scopes.
+func NewPayload(username string, duration time.Duration, scopes ...string) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	payload := &Payload{
 		ID:        tokenID,
 		Username:  username,
-		IssuedAt:  time.Now(),
-		ExpiredAt: time.Now().Add(duration),
+		IssuedAt:  now,
+		ExpiredAt: now.Add(duration),
+		Subject:   username,
+		NotBefore: now,
+		Scopes:    scopes,
 	}
 	return payload, err
 }
 
 // Valid checks if the token payload is valid or not.
-// Specficially, it checks whether the token is expeired or not.
+// Specficially, it checks whether the token is expeired or not yet valid, without any leeway.
+// Makers also check the issuer & audience, see `WithIssuer` and `WithAudience`.
 func (payload *Payload) Valid() error {
-	if time.Now().After(payload.ExpiredAt) {
-		return ErrExpiredToken
-		// return jwt.ErrTokenExpired
-	}
-	return nil
+	return options{}.validate(payload)
 }
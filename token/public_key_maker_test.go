@@ -0,0 +1,182 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func randomEd25519Key(t *testing.T, id string) Key {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return Key{ID: id, PrivateKey: privateKey, PublicKey: privateKey.Public()}
+}
+
+func randomRSAKey(t *testing.T, id string) Key {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return Key{ID: id, PrivateKey: privateKey, PublicKey: privateKey.Public()}
+}
+
+func TestPublicKeyMakers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		newKey   func(t *testing.T, id string) Key
+		newMaker func(keyring *Keyring, opts ...Option) (PublicKeyMaker, error)
+		kty      string
+		alg      string
+	}{
+		{
+			name:     "PASETOv4Public",
+			newKey:   randomEd25519Key,
+			newMaker: NewPasetoPublicMaker,
+			kty:      "OKP",
+		},
+		{
+			name:   "JWTEdDSA",
+			newKey: randomEd25519Key,
+			newMaker: func(keyring *Keyring, opts ...Option) (PublicKeyMaker, error) {
+				return NewJWTPublicKeyMaker(JWTAlgorithmEdDSA, keyring, opts...)
+			},
+			kty: "OKP",
+			alg: JWTAlgorithmEdDSA,
+		},
+		{
+			name:   "JWTRS256",
+			newKey: randomRSAKey,
+			newMaker: func(keyring *Keyring, opts ...Option) (PublicKeyMaker, error) {
+				return NewJWTPublicKeyMaker(JWTAlgorithmRS256, keyring, opts...)
+			},
+			kty: "RSA",
+			alg: JWTAlgorithmRS256,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			key := tc.newKey(t, "key")
+			keyring, err := NewKeyring(key.ID, key)
+			require.NoError(t, err)
+			maker, err := tc.newMaker(keyring)
+			require.NoError(t, err)
+
+			username := util.RandomOwner()
+			token, err := maker.CreateToken(username, time.Minute)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, username, payload.Username)
+
+			// a verifier holding only the public key accepts the token, but cannot create one
+			publicOnly, err := NewKeyring(key.ID, Key{ID: key.ID, PublicKey: key.PublicKey})
+			require.NoError(t, err)
+			verifier, err := tc.newMaker(publicOnly)
+			require.NoError(t, err)
+
+			payload, err = verifier.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, username, payload.Username)
+
+			_, err = verifier.CreateToken(username, time.Minute)
+			require.Error(t, err)
+
+			// expired
+			token, err = maker.CreateToken(username, -time.Minute)
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(token)
+			require.EqualError(t, err, ErrExpiredToken.Error())
+
+			// signed with another key pair using the same kid
+			otherKey := tc.newKey(t, key.ID)
+			otherKeyring, err := NewKeyring(otherKey.ID, otherKey)
+			require.NoError(t, err)
+			otherMaker, err := tc.newMaker(otherKeyring)
+			require.NoError(t, err)
+
+			token, err = otherMaker.CreateToken(username, time.Minute)
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(token)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+
+			// JWKS
+			jwks := maker.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			require.Equal(t, key.ID, jwks.Keys[0].KeyID)
+			require.Equal(t, tc.kty, jwks.Keys[0].KeyType)
+			require.Equal(t, tc.alg, jwks.Keys[0].Algorithm)
+			require.Equal(t, "sig", jwks.Keys[0].Use)
+		})
+	}
+}
+
+func TestPublicKeyMakerWrongKeyType(t *testing.T) {
+	ed25519Keyring, err := NewKeyring("key", randomEd25519Key(t, "key"))
+	require.NoError(t, err)
+	rsaKeyring, err := NewKeyring("key", randomRSAKey(t, "key"))
+	require.NoError(t, err)
+	symmetricKeyring, err := NewKeyring("key", randomKey("key"))
+	require.NoError(t, err)
+
+	_, err = NewPasetoPublicMaker(rsaKeyring)
+	require.Error(t, err)
+	_, err = NewPasetoPublicMaker(symmetricKeyring)
+	require.Error(t, err)
+	_, err = NewJWTPublicKeyMaker(JWTAlgorithmRS256, ed25519Keyring)
+	require.Error(t, err)
+	_, err = NewJWTPublicKeyMaker(JWTAlgorithmEdDSA, rsaKeyring)
+	require.Error(t, err)
+	_, err = NewJWTPublicKeyMaker("HS256", symmetricKeyring)
+	require.Error(t, err)
+}
+
+// TestJWTAlgorithmConfusion checks an HS256 token using the public key as the secret is rejected.
+func TestJWTAlgorithmConfusion(t *testing.T) {
+	key := randomEd25519Key(t, "key")
+	keyring, err := NewKeyring(key.ID, key)
+	require.NoError(t, err)
+	maker, err := NewJWTPublicKeyMaker(JWTAlgorithmEdDSA, keyring)
+	require.NoError(t, err)
+
+	payload, err := NewPayload(util.RandomOwner(), time.Minute)
+	require.NoError(t, err)
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	jwtToken.Header["kid"] = key.ID
+	token, err := jwtToken.SignedString([]byte(key.PublicKey.(ed25519.PublicKey)))
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestParsePEMKey(t *testing.T) {
+	key := randomEd25519Key(t, "key")
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	require.NoError(t, err)
+	parsed, err := ParsePEMKey("key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.Equal(t, key.PrivateKey, parsed.PrivateKey)
+	require.Equal(t, key.PublicKey, parsed.PublicKey)
+
+	der, err = x509.MarshalPKIXPublicKey(key.PublicKey)
+	require.NoError(t, err)
+	parsed, err = ParsePEMKey("key", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.Nil(t, parsed.PrivateKey)
+	require.Equal(t, key.PublicKey, parsed.PublicKey)
+
+	_, err = ParsePEMKey("key", []byte("not a pem"))
+	require.Error(t, err)
+}
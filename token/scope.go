@@ -0,0 +1,50 @@
+package token
+
+import "slices"
+
+// Constants for all supported scopes, `<resource>:<action>`
+const (
+	ScopeAccountsRead   = "accounts:read"
+	ScopeAccountsWrite  = "accounts:write"
+	ScopeTransfersWrite = "transfers:write"
+	ScopeAPIKeysRead    = "api_keys:read"
+	ScopeAPIKeysWrite   = "api_keys:write"
+	ScopeUsersWrite     = "users:write" // security settings of the user, e.g. two-factor authentication
+	ScopeWebhooksRead   = "webhooks:read"
+	ScopeWebhooksWrite  = "webhooks:write"
+	ScopeAuditRead      = "audit:read" // only useful to bankers, the audit log also checks the user's role
+
+	// ScopeMFAChallenge is the only scope of the intermediate token of a two-step login:
+	// it can only be exchanged for an access token with a second factor, it's never requested.
+	ScopeMFAChallenge = "mfa:challenge"
+)
+
+// AllScopes returns every supported scope, granted to the tokens of a regular login.
+func AllScopes() []string {
+	return []string{
+		ScopeAccountsRead,
+		ScopeAccountsWrite,
+		ScopeTransfersWrite,
+		ScopeAPIKeysRead,
+		ScopeAPIKeysWrite,
+		ScopeUsersWrite,
+		ScopeWebhooksRead,
+		ScopeWebhooksWrite,
+		ScopeAuditRead,
+	}
+}
+
+// IsSupportedScope returns true if `scope` is supported
+func IsSupportedScope(scope string) bool {
+	return slices.Contains(AllScopes(), scope)
+}
+
+// HasScopes returns true if the token grants all of `scopes`.
+func (payload *Payload) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(payload.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
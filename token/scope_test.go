@@ -0,0 +1,28 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenScopes(t *testing.T) {
+	maker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(util.RandomOwner(), time.Minute, ScopeAccountsRead)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, []string{ScopeAccountsRead}, payload.Scopes)
+
+	require.True(t, payload.HasScopes())
+	require.True(t, payload.HasScopes(ScopeAccountsRead))
+	require.False(t, payload.HasScopes(ScopeAccountsRead, ScopeTransfersWrite))
+
+	require.True(t, IsSupportedScope(ScopeTransfersWrite))
+	require.False(t, IsSupportedScope("transfers:*"))
+}
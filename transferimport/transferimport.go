@@ -0,0 +1,225 @@
+// Package transferimport imports transfers from a CSV of payment instructions, for the API & the CLI.
+// Each row is identified by its reference: a row whose reference was already imported is skipped,
+// so a file can be imported again after a partial failure.
+package transferimport
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+)
+
+// MaxRows is the most rows a file may contain.
+const MaxRows = 1000
+
+// maxReferenceLength is the longest reference accepted.
+const maxReferenceLength = 64
+
+// Columns are the columns of the CSV, its first line must name them (in any order).
+var Columns = []string{"from_account_id", "to_account_id", "amount", "currency", "reference"}
+
+// statuses of a row
+const (
+	StatusValid       = "valid"       // dry run only: the transfer would be made
+	StatusTransferred = "transferred" // the transfer was made
+	StatusDuplicate   = "duplicate"   // a transfer with the same reference exists already, nothing was done
+	StatusInvalid     = "invalid"     // the row breaks a rule, nothing was done
+	StatusFailed      = "failed"      // the transfer failed, nothing was done
+)
+
+// Row is a payment instruction of the file.
+type Row struct {
+	Line          int // in the file, the header being line 1
+	FromAccountID int64
+	ToAccountID   int64
+	Amount        int64
+	Currency      string
+	Reference     string
+	err           error // why the row cannot be parsed
+}
+
+// RowResult is what was done with a row.
+type RowResult struct {
+	Line      int          `json:"line"`
+	Reference string       `json:"reference"`
+	Status    string       `json:"status"`
+	Error     string       `json:"error,omitempty"`
+	Transfer  *db.Transfer `json:"transfer,omitempty"`
+}
+
+// Parse reads the rows of a CSV. Rows that can't be parsed are still returned, and reported invalid by `Import`;
+// an error is only returned if the file itself is invalid.
+func Parse(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(Columns)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range Columns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing column %q, the columns are %s", name, strings.Join(Columns, ","))
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err // e.g. a wrong number of fields, the file is malformed
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("too many rows, at most %d", MaxRows)
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, parseRow(line, func(name string) string {
+			return strings.TrimSpace(record[index[name]])
+		}))
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no rows")
+	}
+	return rows, nil
+}
+
+func parseRow(line int, field func(name string) string) Row {
+	row := Row{
+		Line:      line,
+		Currency:  field("currency"),
+		Reference: field("reference"),
+	}
+	var err error
+	if row.FromAccountID, err = strconv.ParseInt(field("from_account_id"), 10, 64); err != nil || row.FromAccountID <= 0 {
+		row.err = errors.New("from_account_id must be a positive integer")
+	} else if row.ToAccountID, err = strconv.ParseInt(field("to_account_id"), 10, 64); err != nil || row.ToAccountID <= 0 {
+		row.err = errors.New("to_account_id must be a positive integer")
+	} else if row.Amount, err = strconv.ParseInt(field("amount"), 10, 64); err != nil || row.Amount <= 0 {
+		row.err = errors.New("amount must be a positive integer")
+	} else if row.FromAccountID == row.ToAccountID {
+		row.err = errors.New("from_account_id and to_account_id must be different accounts")
+	} else if row.Currency == "" {
+		row.err = errors.New("currency is required")
+	} else if row.Reference == "" || len(row.Reference) > maxReferenceLength {
+		row.err = fmt.Errorf("reference is required, at most %d characters", maxReferenceLength)
+	}
+	return row
+}
+
+// Importer imports the rows of a file.
+type Importer struct {
+	Store db.Store
+	// Check applies the rules of the caller to a transfer, e.g. the same as a single transfer.
+	// Its error is reported as the reason the row is invalid.
+	Check func(ctx context.Context, row Row) error
+}
+
+// Import checks each row, and makes the transfers of the valid ones unless `dryRun` is set.
+// Each transfer is made in its own transaction: a failed row doesn't undo the others,
+// and importing the file again only makes the transfers that are missing.
+// An error is only returned if the import cannot go on, e.g. the context is done.
+func (importer Importer) Import(ctx context.Context, rows []Row, dryRun bool) ([]RowResult, error) {
+	results := make([]RowResult, len(rows))
+	seen := make(map[[2]string]bool, len(rows)) // (from account, reference) of the file
+	for i, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return results[:i], err
+		}
+		results[i] = importer.importRow(ctx, row, dryRun, seen)
+	}
+	return results, nil
+}
+
+func (importer Importer) importRow(ctx context.Context, row Row, dryRun bool, seen map[[2]string]bool) RowResult {
+	result := RowResult{Line: row.Line, Reference: row.Reference}
+	invalid := func(err error) RowResult {
+		result.Status = StatusInvalid
+		result.Error = err.Error()
+		return result
+	}
+	if row.err != nil {
+		return invalid(row.err)
+	}
+
+	key := [2]string{strconv.FormatInt(row.FromAccountID, 10), row.Reference}
+	if seen[key] {
+		result.Status = StatusDuplicate
+		result.Error = "reference already used on a previous line"
+		return result
+	}
+	seen[key] = true
+
+	transfer, err := importer.Store.GetTransferByExternalReference(ctx, db.GetTransferByExternalReferenceParams{
+		FromAccountID:     row.FromAccountID,
+		ExternalReference: row.Reference,
+	})
+	if err == nil {
+		result.Status = StatusDuplicate
+		result.Transfer = &transfer
+		return result
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	if err := importer.Check(ctx, row); err != nil {
+		return invalid(err)
+	}
+	if dryRun {
+		result.Status = StatusValid
+		return result
+	}
+
+	transferResult, err := importer.Store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID:     row.FromAccountID,
+		ToAccountID:       row.ToAccountID,
+		Amount:            row.Amount,
+		ExternalReference: row.Reference,
+	})
+	switch {
+	case errors.Is(err, db.ErrDuplicateTransfer): // imported concurrently
+		result.Status = StatusDuplicate
+	case err != nil:
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	default:
+		result.Status = StatusTransferred
+		result.Transfer = &transferResult.Transfer
+	}
+	return result
+}
+
+// Summary counts the rows of each status.
+func Summary(results []RowResult) map[string]int {
+	summary := map[string]int{
+		StatusValid:       0,
+		StatusTransferred: 0,
+		StatusDuplicate:   0,
+		StatusInvalid:     0,
+		StatusFailed:      0,
+	}
+	for _, result := range results {
+		summary[result.Status]++
+	}
+	return summary
+}
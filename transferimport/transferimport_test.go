@@ -0,0 +1,128 @@
+package transferimport
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	rows, err := Parse(strings.NewReader(
+		"reference, from_account_id,to_account_id,amount,currency\n" + // columns in any order
+			"pay-1,1,2,100,USD\n" +
+			"pay-2,1,1,100,USD\n" +
+			"pay-3,1,2,-5,USD\n" +
+			",1,2,100,USD\n" +
+			"pay-5,x,2,100,USD\n",
+	))
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+
+	require.Equal(t, Row{Line: 2, FromAccountID: 1, ToAccountID: 2, Amount: 100, Currency: "USD", Reference: "pay-1"}, rows[0])
+	require.ErrorContains(t, rows[1].err, "different accounts")
+	require.ErrorContains(t, rows[2].err, "amount")
+	require.ErrorContains(t, rows[3].err, "reference")
+	require.ErrorContains(t, rows[4].err, "from_account_id")
+	require.Equal(t, 6, rows[4].Line)
+}
+
+func TestParseInvalidFile(t *testing.T) {
+	testCases := []struct {
+		name string
+		csv  string
+	}{
+		{"Empty", ""},
+		{"HeaderOnly", "from_account_id,to_account_id,amount,currency,reference\n"},
+		{"MissingColumn", "from_account_id,to_account_id,amount,currency,ref\n1,2,3,USD,a\n"},
+		{"WrongFieldCount", "from_account_id,to_account_id,amount,currency,reference\n1,2,3,USD\n"},
+		{"TooManyRows", "from_account_id,to_account_id,amount,currency,reference\n" + strings.Repeat("1,2,3,USD,a\n", MaxRows+1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tc.csv))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	rows := []Row{
+		{Line: 2, FromAccountID: 1, ToAccountID: 2, Amount: 10, Currency: "USD", Reference: "new"},
+		{Line: 3, FromAccountID: 1, ToAccountID: 2, Amount: 10, Currency: "USD", Reference: "imported"},
+		{Line: 4, FromAccountID: 1, ToAccountID: 3, Amount: 10, Currency: "USD", Reference: "frozen"},
+		{Line: 5, FromAccountID: 1, ToAccountID: 2, Amount: 10, Currency: "USD", Reference: "new"},
+		{Line: 6, err: errors.New("amount must be a positive integer")},
+		{Line: 7, FromAccountID: 1, ToAccountID: 2, Amount: 10, Currency: "USD", Reference: "concurrent"},
+	}
+	imported := db.Transfer{ID: 7, FromAccountID: 1, ToAccountID: 2, Amount: 10, ExternalReference: "imported"}
+	check := func(ctx context.Context, row Row) error {
+		if row.ToAccountID == 3 {
+			return errors.New("account [3] is frozen")
+		}
+		return nil
+	}
+
+	byReference := func(reference string) gomock.Matcher {
+		return gomock.Eq(db.GetTransferByExternalReferenceParams{FromAccountID: 1, ExternalReference: reference})
+	}
+	expectLookups := func(store *mockdb.MockStore) {
+		store.EXPECT().GetTransferByExternalReference(gomock.Any(), byReference("new")).Times(1).Return(db.Transfer{}, sql.ErrNoRows)
+		store.EXPECT().GetTransferByExternalReference(gomock.Any(), byReference("imported")).Times(1).Return(imported, nil)
+		store.EXPECT().GetTransferByExternalReference(gomock.Any(), byReference("frozen")).Times(1).Return(db.Transfer{}, sql.ErrNoRows)
+		store.EXPECT().GetTransferByExternalReference(gomock.Any(), byReference("concurrent")).Times(1).Return(db.Transfer{}, sql.ErrNoRows)
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		expectLookups(store)
+		store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+		results, err := Importer{Store: store, Check: check}.Import(context.Background(), rows, true)
+		require.NoError(t, err)
+		requireStatuses(t, results, StatusValid, StatusDuplicate, StatusInvalid, StatusDuplicate, StatusInvalid, StatusValid)
+		require.Equal(t, imported.ID, results[1].Transfer.ID)
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		expectLookups(store)
+		store.EXPECT().
+			TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{FromAccountID: 1, ToAccountID: 2, Amount: 10, ExternalReference: "new"})).
+			Times(1).
+			Return(db.TransferTxResult{Transfer: db.Transfer{ID: 8}}, nil)
+		store.EXPECT().
+			TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{FromAccountID: 1, ToAccountID: 2, Amount: 10, ExternalReference: "concurrent"})).
+			Times(1).
+			Return(db.TransferTxResult{}, fmt.Errorf("%w: concurrent", db.ErrDuplicateTransfer))
+
+		results, err := Importer{Store: store, Check: check}.Import(context.Background(), rows, false)
+		require.NoError(t, err)
+		requireStatuses(t, results, StatusTransferred, StatusDuplicate, StatusInvalid, StatusDuplicate, StatusInvalid, StatusDuplicate)
+		require.Equal(t, int64(8), results[0].Transfer.ID)
+		require.Equal(t, "account [3] is frozen", results[2].Error)
+
+		summary := Summary(results)
+		require.Equal(t, 1, summary[StatusTransferred])
+		require.Equal(t, 3, summary[StatusDuplicate])
+		require.Equal(t, 2, summary[StatusInvalid])
+		require.Zero(t, summary[StatusFailed])
+	})
+}
+
+func requireStatuses(t *testing.T, results []RowResult, statuses ...string) {
+	require.Len(t, results, len(statuses))
+	for i, status := range statuses {
+		require.Equal(t, status, results[i].Status, "line %d", results[i].Line)
+	}
+}
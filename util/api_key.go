@@ -0,0 +1,68 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// API keys look like `sb_<prefix>_<secret>`.
+// The prefix is stored in clear to look the key up, only a hash of the whole key is stored.
+const (
+	apiKeyTag          = "sb"
+	apiKeyPrefixLength = 8
+	apiKeySecretLength = 32
+)
+
+// base32 without padding, lower case: URL & header safe, and no `_` to split on
+var apiKeyEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// GenerateAPIKey returns a new API key with its lookup prefix.
+// WHY `crypto/rand` and not `RandomString`? The key is a credential, it must be unpredictable.
+func GenerateAPIKey() (apiKey string, prefix string, err error) {
+	prefix, err = randomAPIKeyPart(apiKeyPrefixLength)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := randomAPIKeyPart(apiKeySecretLength)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%s_%s_%s", apiKeyTag, prefix, secret), prefix, nil
+}
+
+// ParseAPIKey returns the lookup prefix of an API key, or false if it's malformed.
+func ParseAPIKey(apiKey string) (prefix string, ok bool) {
+	parts := strings.Split(apiKey, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTag ||
+		len(parts[1]) != apiKeyPrefixLength || len(parts[2]) != apiKeySecretLength {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// HashAPIKey returns the SHA-256 hash of the API key.
+// WHY not bcrypt like passwords? The key is long & random, it cannot be brute-forced,
+// and it's checked on every request, so it must be fast.
+func HashAPIKey(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// CheckAPIKey checks if the provided API key matches the hash, in constant time.
+func CheckAPIKey(apiKey string, hashedKey string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(apiKey)), []byte(hashedKey)) == 1
+}
+
+func randomAPIKeyPart(length int) (string, error) {
+	// 5 bits per base32 character
+	buf := make([]byte, (length*5+7)/8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyEncoding.EncodeToString(buf)[:length], nil
+}
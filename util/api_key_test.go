@@ -0,0 +1,33 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	apiKey, prefix, err := GenerateAPIKey()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(apiKey, "sb_"+prefix+"_"))
+
+	parsedPrefix, ok := ParseAPIKey(apiKey)
+	require.True(t, ok)
+	require.Equal(t, prefix, parsedPrefix)
+
+	hashedKey := HashAPIKey(apiKey)
+	require.NotContains(t, hashedKey, apiKey)
+	require.True(t, CheckAPIKey(apiKey, hashedKey))
+
+	otherKey, otherPrefix, err := GenerateAPIKey()
+	require.NoError(t, err)
+	require.NotEqual(t, apiKey, otherKey)
+	require.NotEqual(t, prefix, otherPrefix)
+	require.False(t, CheckAPIKey(otherKey, hashedKey))
+
+	for _, malformed := range []string{"", "sb_", prefix, "xx_" + prefix + "_" + strings.Repeat("a", 32), apiKey + "_a"} {
+		_, ok := ParseAPIKey(malformed)
+		require.False(t, ok, malformed)
+	}
+}
@@ -14,6 +14,113 @@ type Config struct {
 	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
 	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
 	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+
+	// token maker & key rotation:
+	// TOKEN_MAKER is "paseto" or "jwt" (symmetric), "paseto_public", "jwt_eddsa" or "jwt_rs256" (asymmetric),
+	// TOKEN_KEYS is a comma-separated list of "<kid>:<secret>", TOKEN_SYMMETRIC_KEY is used if it's empty,
+	// for asymmetric makers it's "<kid>:<path of a PEM key file>",
+	// TOKEN_RETIRED_KEY_IDS is a comma-separated list of kids whose tokens are rejected
+	TokenMaker         string `mapstructure:"TOKEN_MAKER"`
+	TokenKeys          string `mapstructure:"TOKEN_KEYS"`
+	TokenActiveKeyID   string `mapstructure:"TOKEN_ACTIVE_KEY_ID"`
+	TokenRetiredKeyIDs string `mapstructure:"TOKEN_RETIRED_KEY_IDS"`
+
+	// standard claims: tokens are issued by TOKEN_ISSUER for TOKEN_AUDIENCE (comma-separated),
+	// and only tokens matching them are accepted, TOKEN_LEEWAY tolerates clock skew between servers
+	TokenIssuer   string        `mapstructure:"TOKEN_ISSUER"`
+	TokenAudience string        `mapstructure:"TOKEN_AUDIENCE"`
+	TokenLeeway   time.Duration `mapstructure:"TOKEN_LEEWAY"`
+
+	// two-factor authentication: TOTP_ENCRYPTION_KEY (32 characters) encrypts the TOTP secrets at rest,
+	// TOTP is disabled if it's empty. Transfers of at least TRANSFER_MFA_THRESHOLD need a TOTP code, 0 disables it
+	TOTPEncryptionKey    string        `mapstructure:"TOTP_ENCRYPTION_KEY"`
+	TOTPIssuer           string        `mapstructure:"TOTP_ISSUER"`
+	MFAChallengeDuration time.Duration `mapstructure:"MFA_CHALLENGE_DURATION"`
+	TransferMFAThreshold int64         `mapstructure:"TRANSFER_MFA_THRESHOLD"`
+
+	// brute-force protection of `/users/login`: after LOGIN_MAX_FAILURES failures for a username
+	// (or LOGIN_MAX_FAILURES_PER_IP for a client IP) within LOGIN_FAILURE_WINDOW, logins are locked for
+	// LOGIN_LOCKOUT_DURATION. Each failure is delayed by LOGIN_FAILURE_DELAY, doubled per previous failure.
+	// LOGIN_MAX_FAILURES=0 disables the tracking
+	LoginMaxFailures      int32         `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginMaxFailuresPerIP int32         `mapstructure:"LOGIN_MAX_FAILURES_PER_IP"`
+	LoginFailureWindow    time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginLockoutDuration  time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginFailureDelay     time.Duration `mapstructure:"LOGIN_FAILURE_DELAY"`
+
+	// passwords: new passwords are hashed with PASSWORD_HASHER ("bcrypt" or "argon2id"), older hashes are
+	// upgraded at login. The policy applies to created, changed & reset passwords, PASSWORD_BLOCKLIST_FILE
+	// adds a newline-separated list (e.g. of breached passwords) to the embedded list of common passwords
+	PasswordHasher              string `mapstructure:"PASSWORD_HASHER"`
+	PasswordBcryptCost          int    `mapstructure:"PASSWORD_BCRYPT_COST"`
+	PasswordArgon2Memory        uint32 `mapstructure:"PASSWORD_ARGON2_MEMORY"` // KiB
+	PasswordArgon2Iterations    uint32 `mapstructure:"PASSWORD_ARGON2_ITERATIONS"`
+	PasswordArgon2Parallelism   uint8  `mapstructure:"PASSWORD_ARGON2_PARALLELISM"`
+	PasswordMinLength           int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMinCharacterClasses int    `mapstructure:"PASSWORD_MIN_CHARACTER_CLASSES"`
+	PasswordBlocklistFile       string `mapstructure:"PASSWORD_BLOCKLIST_FILE"`
+
+	// rate limits of each route group, as "<limit>/<period>" (e.g. "60/1m"), empty disables it:
+	// RATE_LIMIT_PUBLIC is per client IP, RATE_LIMIT_AUTHENTICATED & RATE_LIMIT_TRANSFERS are per user
+	RateLimitPublic        string `mapstructure:"RATE_LIMIT_PUBLIC"`
+	RateLimitAuthenticated string `mapstructure:"RATE_LIMIT_AUTHENTICATED"`
+	RateLimitTransfers     string `mapstructure:"RATE_LIMIT_TRANSFERS"`
+	// RATE_LIMIT_CLIENT_IP is per client IP on the authenticated routes, checked before the credentials
+	// (which cost a DB lookup for API keys)
+	RateLimitClientIP string `mapstructure:"RATE_LIMIT_CLIENT_IP"`
+
+	// TRUSTED_PROXIES are the IPs/CIDRs (comma-separated) whose X-Forwarded-For is trusted to find the client IP,
+	// empty trusts none: the client IP is the address of the connection
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	// webhooks: the outbox is dispatched every WEBHOOK_DISPATCH_INTERVAL (0 disables the dispatcher),
+	// WEBHOOK_BATCH_SIZE events & deliveries at a time. A failed delivery is retried up to WEBHOOK_MAX_ATTEMPTS
+	// times in total, after WEBHOOK_RETRY_BASE_DELAY doubled per previous attempt, up to WEBHOOK_RETRY_MAX_DELAY.
+	// Each attempt times out after WEBHOOK_TIMEOUT; unset durations get a default, see `webhook.NewDispatcher`
+	WebhookDispatchInterval time.Duration `mapstructure:"WEBHOOK_DISPATCH_INTERVAL"`
+	WebhookBatchSize        int32         `mapstructure:"WEBHOOK_BATCH_SIZE"`
+	WebhookTimeout          time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`
+	WebhookMaxAttempts      int32         `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookRetryBaseDelay   time.Duration `mapstructure:"WEBHOOK_RETRY_BASE_DELAY"`
+	WebhookRetryMaxDelay    time.Duration `mapstructure:"WEBHOOK_RETRY_MAX_DELAY"`
+
+	// holds: HOLD_DEFAULT_DURATION is used when a hold is created without an expiration, HOLD_MAX_DURATION is the longest allowed;
+	// expired holds are released every HOLD_SWEEP_INTERVAL (0 disables the sweeper)
+	HoldDefaultDuration time.Duration `mapstructure:"HOLD_DEFAULT_DURATION"`
+	HoldMaxDuration     time.Duration `mapstructure:"HOLD_MAX_DURATION"`
+	HoldSweepInterval   time.Duration `mapstructure:"HOLD_SWEEP_INTERVAL"`
+
+	// transfers above the approval threshold of their account wait for an approver for TRANSFER_APPROVAL_DURATION (72h if not set);
+	// they're expired every TRANSFER_APPROVAL_SWEEP_INTERVAL (0 disables the sweeper, they can't be approved anyway)
+	TransferApprovalDuration      time.Duration `mapstructure:"TRANSFER_APPROVAL_DURATION"`
+	TransferApprovalSweepInterval time.Duration `mapstructure:"TRANSFER_APPROVAL_SWEEP_INTERVAL"`
+
+	// migrations: an empty MIGRATION_URL uses the migrations embedded in the binary,
+	// AUTO_MIGRATE applies pending migrations when the server starts
+	MigrationURL string `mapstructure:"MIGRATION_URL"`
+	AutoMigrate  bool   `mapstructure:"AUTO_MIGRATE"`
+
+	// retries of transactions aborted by a deadlock or serialization failure
+	TxMaxAttempts    int           `mapstructure:"TX_MAX_ATTEMPTS"`
+	TxRetryBaseDelay time.Duration `mapstructure:"TX_RETRY_BASE_DELAY"`
+	TxRetryMaxDelay  time.Duration `mapstructure:"TX_RETRY_MAX_DELAY"`
+
+	// HTTP server timeouts, 0 means no timeout
+	HTTPReadTimeout       time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPReadHeaderTimeout time.Duration `mapstructure:"HTTP_READ_HEADER_TIMEOUT"`
+	HTTPWriteTimeout      time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout       time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	// how long graceful shutdown waits for in-flight requests & background workers
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	// how long the server keeps accepting requests once `/readyz` fails, before SHUTDOWN_TIMEOUT starts,
+	// so the load balancer stops routing to it first
+	ShutdownDrainDelay time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY"`
+
+	// tracing: TRACING_EXPORTER is one of "none", "stdout" or "otlp"
+	TracingExporter     string `mapstructure:"TRACING_EXPORTER"`
+	TracingServiceName  string `mapstructure:"TRACING_SERVICE_NAME"`
+	TracingOTLPEndpoint string `mapstructure:"TRACING_OTLP_ENDPOINT"`
+	TracingOTLPInsecure bool   `mapstructure:"TRACING_OTLP_INSECURE"`
 }
 
 // LoakConfig reads configuration from the file or environment variables.
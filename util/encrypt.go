@@ -0,0 +1,56 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// EncryptionKeySize is the size of the keys of `Encrypt` & `Decrypt`, i.e. AES-256.
+const EncryptionKeySize = 32
+
+var errCiphertextTooShort = errors.New("ciphertext too short")
+
+// Encrypt encrypts `plaintext` with AES-256-GCM, for secrets stored in the DB.
+// `associatedData` isn't encrypted but must be the same to decrypt,
+// e.g. the owner of the secret, so a ciphertext cannot be copied to another row.
+// The random nonce is prepended to the ciphertext.
+func Encrypt(key []byte, plaintext []byte, associatedData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// Decrypt decrypts a ciphertext created by `Encrypt`.
+func Decrypt(key []byte, ciphertext []byte, associatedData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errCiphertextTooShort
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, associatedData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("invalid key size: must be exactly %d characters", EncryptionKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncrypt(t *testing.T) {
+	key := []byte(RandomString(EncryptionKeySize))
+	plaintext := []byte(RandomString(20))
+	owner := []byte(RandomOwner())
+
+	ciphertext1, err := Encrypt(key, plaintext, owner)
+	require.NoError(t, err)
+	require.NotContains(t, string(ciphertext1), string(plaintext))
+
+	decrypted, err := Decrypt(key, ciphertext1, owner)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, decrypted)
+
+	// a random nonce: the same plaintext is never encrypted twice the same way
+	ciphertext2, err := Encrypt(key, plaintext, owner)
+	require.NoError(t, err)
+	require.NotEqual(t, ciphertext1, ciphertext2)
+
+	// wrong key, or copied to another owner
+	_, err = Decrypt([]byte(RandomString(EncryptionKeySize)), ciphertext1, owner)
+	require.Error(t, err)
+	_, err = Decrypt(key, ciphertext1, []byte("someone-else"))
+	require.Error(t, err)
+
+	_, err = Decrypt(key, ciphertext1[:5], owner)
+	require.Error(t, err)
+	_, err = Encrypt([]byte("short"), plaintext, owner)
+	require.Error(t, err)
+}
@@ -1,21 +1,176 @@
 package util
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword returns the bcrypt hash of the password
+// supported password hashing algorithms, for PASSWORD_HASHER
+const (
+	PasswordHasherBcrypt   = "bcrypt"
+	PasswordHasherArgon2id = "argon2id"
+)
+
+// default argon2id parameters, the second recommended option of RFC 9106
+const (
+	DefaultArgon2Memory      = 64 * 1024 // KiB
+	DefaultArgon2Iterations  = 3
+	DefaultArgon2Parallelism = 4
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	argon2Prefix     = "$argon2id$"
+)
+
+// ErrMismatchedPassword is returned by `CheckPassword` if the password is wrong, whatever the algorithm.
+var ErrMismatchedPassword = bcrypt.ErrMismatchedHashAndPassword
+
+// PasswordHasher hashes new passwords.
+// The algorithm & its parameters are encoded in the hash, so `CheckPassword` checks the hashes of every hasher.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password
+	Hash(password string) (string, error)
+
+	// NeedsRehash returns true if the hash was made by another algorithm or with other parameters,
+	// e.g. a bcrypt hash after moving to argon2id, or a lower bcrypt cost
+	NeedsRehash(hashedPassword string) bool
+}
+
+// NewPasswordHasher creates the hasher configured by PASSWORD_HASHER, bcrypt if it's empty.
+func NewPasswordHasher(config Config) (PasswordHasher, error) {
+	switch config.PasswordHasher {
+	case "", PasswordHasherBcrypt:
+		cost := config.PasswordBcryptCost
+		if cost == 0 {
+			cost = bcrypt.DefaultCost
+		}
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost: must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return BcryptHasher{Cost: cost}, nil
+	case PasswordHasherArgon2id:
+		hasher := Argon2idHasher{
+			Memory:      config.PasswordArgon2Memory,
+			Iterations:  config.PasswordArgon2Iterations,
+			Parallelism: config.PasswordArgon2Parallelism,
+		}
+		if hasher.Memory == 0 {
+			hasher.Memory = DefaultArgon2Memory
+		}
+		if hasher.Iterations == 0 {
+			hasher.Iterations = DefaultArgon2Iterations
+		}
+		if hasher.Parallelism == 0 {
+			hasher.Parallelism = DefaultArgon2Parallelism
+		}
+		return hasher, nil
+	}
+	return nil, fmt.Errorf("unsupported password hasher: %s", config.PasswordHasher)
+}
+
+// HashPassword returns the bcrypt hash of the password, at the default cost
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return BcryptHasher{Cost: bcrypt.DefaultCost}.Hash(password)
+}
+
+// CheckPassword checks if the provided password is correct or not, for a hash of any supported algorithm
+func CheckPassword(password string, hashedPassword string) error {
+	if strings.HasPrefix(hashedPassword, argon2Prefix) {
+		return checkArgon2id(password, hashedPassword)
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+}
+
+// BcryptHasher hashes passwords with bcrypt.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (hasher BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
 	return string(hashedPassword), nil
 }
 
-// CheckPassword checks if the provided password is correct or not
-func CheckPassword(password string, hashedPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+func (hasher BcryptHasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != hasher.Cost
+}
+
+// Argon2idHasher hashes passwords with argon2id, encoded in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (hasher Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Iterations, hasher.Memory, hasher.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix,
+		argon2.Version,
+		hasher.Memory,
+		hasher.Iterations,
+		hasher.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (hasher Argon2idHasher) NeedsRehash(hashedPassword string) bool {
+	params, _, key, err := decodeArgon2id(hashedPassword)
+	return err != nil || params != hasher || len(key) != argon2KeyLength
+}
+
+// decodeArgon2id parses a hash made by `Argon2idHasher`.
+func decodeArgon2id(hashedPassword string) (params Argon2idHasher, salt []byte, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != PasswordHasherArgon2id {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version: %s", parts[2])
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	return params, salt, key, nil
+}
+
+func checkArgon2id(password string, hashedPassword string) error {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	// the parameters of the hash, not the current ones: old hashes must still be checked
+	otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
 }
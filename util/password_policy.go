@@ -0,0 +1,107 @@
+package util
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// bcrypt ignores anything after 72 bytes, so longer passwords are rejected whatever the hasher
+const maxPasswordLength = 72
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy is checked when a password is chosen: at creation, change or reset.
+type PasswordPolicy struct {
+	MinLength int
+	// among lower case letters, upper case letters, digits & symbols
+	MinCharacterClasses int
+	// lower-cased passwords that are rejected, e.g. the most common or breached ones
+	blocklist map[string]struct{}
+}
+
+// NewPasswordPolicy creates the policy configured by PASSWORD_MIN_LENGTH, PASSWORD_MIN_CHARACTER_CLASSES
+// & PASSWORD_BLOCKLIST_FILE. The embedded list of common passwords is always blocked.
+func NewPasswordPolicy(config Config) (PasswordPolicy, error) {
+	policy := PasswordPolicy{
+		MinLength:           config.PasswordMinLength,
+		MinCharacterClasses: config.PasswordMinCharacterClasses,
+		blocklist:           make(map[string]struct{}),
+	}
+	if policy.MinCharacterClasses > 4 {
+		return policy, errors.New("invalid password policy: there are only 4 character classes")
+	}
+
+	policy.block(strings.NewReader(commonPasswords)) // cannot fail: read from memory
+	if config.PasswordBlocklistFile != "" {
+		file, err := os.Open(config.PasswordBlocklistFile)
+		if err != nil {
+			return policy, fmt.Errorf("cannot open password blocklist: %w", err)
+		}
+		defer file.Close()
+
+		err = policy.block(file)
+		if err != nil {
+			return policy, fmt.Errorf("cannot read password blocklist: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// block adds the passwords of a newline-separated list, lines starting with "#" are comments.
+func (policy PasswordPolicy) block(list io.Reader) error {
+	scanner := bufio.NewScanner(list)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate returns an error explaining why the password is rejected, nil if it's acceptable.
+func (policy PasswordPolicy) Validate(password string, username string) error {
+	if len(password) < policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters", policy.MinLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+	if classes := characterClasses(password); classes < policy.MinCharacterClasses {
+		return fmt.Errorf("password must contain %d of: lower case letters, upper case letters, digits, symbols", policy.MinCharacterClasses)
+	}
+
+	lower := strings.ToLower(password)
+	if _, blocked := policy.blocklist[lower]; blocked {
+		return errors.New("password is too common")
+	}
+	if username != "" && lower == strings.ToLower(username) {
+		return errors.New("password must not be the username")
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	blocklistFile := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(blocklistFile, []byte("# breached\nCorrect-Horse-1\n"), 0o600)
+	require.NoError(t, err)
+
+	policy, err := NewPasswordPolicy(Config{
+		PasswordMinLength:           10,
+		PasswordMinCharacterClasses: 3,
+		PasswordBlocklistFile:       blocklistFile,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"OK", "Tr0ub4dor&3x", true},
+		{"TooShort", "Ab1!", false},
+		{"TooLong", "Ab1!" + RandomString(maxPasswordLength), false},
+		{"TwoClasses", "abcdefghij12", false},
+		{"Common", "PASSWORD123!", false},
+		{"Breached", "correct-horse-1", false},
+		{"Username", "Alice-12345", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.password, "alice-12345")
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestDefaultPasswordPolicy(t *testing.T) {
+	// without configuration only the common passwords are rejected
+	policy, err := NewPasswordPolicy(Config{})
+	require.NoError(t, err)
+	require.NoError(t, policy.Validate("abc", ""))
+	require.Error(t, policy.Validate("qwerty", ""))
+
+	_, err = NewPasswordPolicy(Config{PasswordMinCharacterClasses: 5})
+	require.Error(t, err)
+
+	_, err = NewPasswordPolicy(Config{PasswordBlocklistFile: "/does/not/exist"})
+	require.Error(t, err)
+}
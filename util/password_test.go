@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -27,3 +28,65 @@ func TestPassword(t *testing.T) {
 	require.NotEmpty(t, hashedPassword2)
 	require.NotEqual(t, hashedPassword1, hashedPassword2)
 }
+
+func TestPasswordHasher(t *testing.T) {
+	password := RandomString(12)
+
+	argon2Config := Config{
+		PasswordHasher:            PasswordHasherArgon2id,
+		PasswordArgon2Memory:      1024,
+		PasswordArgon2Iterations:  1,
+		PasswordArgon2Parallelism: 1,
+	}
+	argon2Hasher, err := NewPasswordHasher(argon2Config)
+	require.NoError(t, err)
+
+	bcryptHasher, err := NewPasswordHasher(Config{PasswordHasher: PasswordHasherBcrypt, PasswordBcryptCost: bcrypt.MinCost})
+	require.NoError(t, err)
+
+	argon2Hash, err := argon2Hasher.Hash(password)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(argon2Hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	bcryptHash, err := bcryptHasher.Hash(password)
+	require.NoError(t, err)
+
+	// both hashes are checked whatever the configured hasher
+	for _, hashedPassword := range []string{argon2Hash, bcryptHash} {
+		require.NoError(t, CheckPassword(password, hashedPassword))
+		require.ErrorIs(t, CheckPassword(RandomString(12), hashedPassword), ErrMismatchedPassword)
+	}
+
+	// an outdated algorithm or parameter needs a rehash
+	require.False(t, argon2Hasher.NeedsRehash(argon2Hash))
+	require.True(t, argon2Hasher.NeedsRehash(bcryptHash))
+	require.False(t, bcryptHasher.NeedsRehash(bcryptHash))
+	require.True(t, bcryptHasher.NeedsRehash(argon2Hash))
+
+	argon2Config.PasswordArgon2Iterations = 2
+	strongerHasher, err := NewPasswordHasher(argon2Config)
+	require.NoError(t, err)
+	require.True(t, strongerHasher.NeedsRehash(argon2Hash))
+	// the old parameters are still checked
+	require.NoError(t, CheckPassword(password, argon2Hash))
+
+	defaultHasher, err := NewPasswordHasher(Config{})
+	require.NoError(t, err)
+	require.Equal(t, BcryptHasher{Cost: bcrypt.DefaultCost}, defaultHasher)
+
+	_, err = NewPasswordHasher(Config{PasswordHasher: "md5"})
+	require.Error(t, err)
+}
+
+func TestCheckInvalidArgon2idHash(t *testing.T) {
+	for _, hashedPassword := range []string{
+		"$argon2id$",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5",
+	} {
+		err := CheckPassword("password", hashedPassword)
+		require.Error(t, err, hashedPassword)
+		require.NotErrorIs(t, err, ErrMismatchedPassword)
+	}
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238), the defaults every authenticator app supports
+const (
+	totpSecretSize = 20 // bytes, the size of an HMAC-SHA1 key
+	totpPeriod     = 30 * time.Second
+	totpDigits     = 6
+	// codes of the previous & next period are also accepted, for clock drift & slow typing
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new random TOTP secret.
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+// EncodeTOTPSecret returns the secret in base32, the form users type into an authenticator app.
+func EncodeTOTPSecret(secret []byte) string {
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPURI returns the `otpauth://` URI of the secret, usually shown as a QR code.
+func TOTPURI(issuer string, accountName string, secret []byte) string {
+	label := url.PathEscape(issuer + ":" + accountName)
+	params := url.Values{}
+	params.Set("secret", EncodeTOTPSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPStep returns the time step of `t`, i.e. the counter of HOTP.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+// TOTPCode returns the code of the secret for the time step.
+func TOTPCode(secret []byte, step int64) string {
+	// HOTP (RFC 4226): HMAC-SHA1 of the big-endian counter, then dynamic truncation
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// ValidateTOTP checks the code against the secret at time `t`.
+// It returns the time step the code belongs to, so the caller can refuse to accept it twice.
+func ValidateTOTP(secret []byte, code string, t time.Time) (step int64, ok bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(TOTPCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns `n` single-use recovery codes, formatted as `xxxxx-xxxxx`.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		part, err := randomAPIKeyPart(10)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = part[:5] + "-" + part[5:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the SHA-256 hash of a recovery code, ignoring case, spaces & dashes.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashAPIKey(code)
+}
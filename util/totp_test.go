@@ -0,0 +1,75 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// test vectors of RFC 6238 (SHA1), truncated to 6 digits
+	secret := []byte("12345678901234567890")
+	testCases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, code := range testCases {
+		require.Equal(t, code, TOTPCode(secret, TOTPStep(time.Unix(unix, 0))))
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code := TOTPCode(secret, TOTPStep(now))
+
+	step, ok := ValidateTOTP(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, TOTPStep(now), step)
+
+	// one period of clock drift is tolerated, not more
+	_, ok = ValidateTOTP(secret, code, now.Add(30*time.Second))
+	require.True(t, ok)
+	_, ok = ValidateTOTP(secret, code, now.Add(90*time.Second))
+	require.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now)
+	require.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	uri, err := url.Parse(TOTPURI("Simple Bank", "alice", secret))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, "/Simple Bank:alice", uri.Path)
+	require.Equal(t, EncodeTOTPSecret(secret), uri.Query().Get("secret"))
+	require.Equal(t, "Simple Bank", uri.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		require.Len(t, code, 11)
+		require.False(t, seen[code])
+		seen[code] = true
+
+		// users may type them in upper case, or without the dash
+		require.Equal(t, HashRecoveryCode(code), HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	}
+}
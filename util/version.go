@@ -0,0 +1,16 @@
+package util
+
+import "runtime"
+
+// Build information of the binary.
+// The defaults are overwritten at build time with ldflags, see `make build`:
+// go build -ldflags "-X github.com/Oliver-Zen/simplebank/util.GitCommit=<sha> -X github.com/Oliver-Zen/simplebank/util.BuildTime=<time>"
+var (
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
+
+// GoVersion returns the Go version the binary was built with.
+func GoVersion() string {
+	return runtime.Version()
+}
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs reaching inside our network, e.g. the cloud metadata service:
+// the dispatcher calls them from there, and the deliveries tell the owner how they went.
+var ErrForbiddenAddress = errors.New("webhook address is not allowed")
+
+// special-purpose ranges not covered by the methods of `net.IP`, which aren't on the internet
+// or may reach inside our network
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),     // "this network", 0.0.0.0 may connect to the local host
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+	mustParseCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustParseCIDR("198.18.0.0/15"), // benchmarking
+	mustParseCIDR("240.0.0.0/4"),   // reserved, with the broadcast address
+	mustParseCIDR("64:ff9b::/96"),  // NAT64, embeds any IPv4 address, private ones too
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// Resolver looks up the addresses of a host, `net.DefaultResolver` in production.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// CheckURL returns why `rawURL` can't be registered as a webhook, nil if it can:
+// it must be https, and every address of its host must be public.
+// It's checked again when dialing, see `NewDispatcher`, since the host may resolve to another address by then.
+func CheckURL(ctx context.Context, resolver Resolver, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("url must be an https URL")
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr.IP)
+		}
+	}
+	return nil
+}
+
+// publicIP reports whether `ip` is routable on the internet.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		reserved(ip))
+}
+
+func reserved(ip net.IP) bool {
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialControl refuses connections to non-public addresses, once resolved:
+// a host can't pass `CheckURL` then resolve to an internal address (DNS rebinding).
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// newTransport is the transport of the deliveries, only dialing public addresses.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // the dialer must see the address of the receiver, not of a proxy
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl,
+	}).DialContext
+	return transport
+}
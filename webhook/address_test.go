@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver resolves the hosts of the tests without DNS.
+type fakeResolver map[string][]string
+
+func (resolver fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := resolver[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+func TestCheckURL(t *testing.T) {
+	resolver := fakeResolver{
+		"example.com":  {"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"},
+		"rebind.test":  {"93.184.216.34", "10.0.0.1"},
+		"metadata.aws": {"169.254.169.254"},
+	}
+
+	testCases := []struct {
+		url       string
+		forbidden bool
+		ok        bool
+	}{
+		{url: "https://example.com/hooks", ok: true},
+		{url: "http://example.com/hooks"},
+		{url: "https://unknown.test/hooks"},
+		{url: "https://rebind.test/hooks", forbidden: true},
+		{url: "https://metadata.aws/latest", forbidden: true},
+		{url: "https://127.0.0.1/hooks", forbidden: true},
+		{url: "https://[::1]:8080/hooks", forbidden: true},
+		{url: "https://0.0.0.0/hooks", forbidden: true},
+		{url: "https://100.64.0.1/hooks", forbidden: true},
+		{url: "https://192.168.1.1/hooks", forbidden: true},
+	}
+	// IP literals resolve to themselves
+	for _, ip := range []string{"127.0.0.1", "::1", "0.0.0.0", "100.64.0.1", "192.168.1.1"} {
+		resolver[ip] = []string{ip}
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			err := CheckURL(context.Background(), resolver, tc.url)
+			switch {
+			case tc.ok:
+				require.NoError(t, err)
+			case tc.forbidden:
+				require.ErrorIs(t, err, ErrForbiddenAddress)
+			default:
+				require.Error(t, err)
+				require.NotErrorIs(t, err, ErrForbiddenAddress)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	require.NoError(t, dialControl("tcp", "93.184.216.34:443", nil))
+	require.ErrorIs(t, dialControl("tcp", "127.0.0.1:443", nil), ErrForbiddenAddress)
+	require.ErrorIs(t, dialControl("tcp", "[fe80::1]:443", nil), ErrForbiddenAddress)
+	require.ErrorIs(t, dialControl("tcp", "10.1.2.3:80", nil), ErrForbiddenAddress)
+}
+
+func TestPublicIP(t *testing.T) {
+	testCases := []struct {
+		ip     string
+		public bool
+	}{
+		{ip: "93.184.216.34", public: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{ip: "0.1.2.3"},         // 0.0.0.0/8
+		{ip: "100.127.255.254"}, // 100.64.0.0/10
+		{ip: "192.0.0.8"},       // 192.0.0.0/24
+		{ip: "198.19.0.1"},      // 198.18.0.0/15
+		{ip: "255.255.255.255"}, // 240.0.0.0/4
+		{ip: "64:ff9b::a00:1"},  // 64:ff9b::/96, i.e. 10.0.0.1
+		{ip: "::ffff:10.0.0.1"}, // IPv4-mapped private address
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ip, func(t *testing.T) {
+			ip := net.ParseIP(tc.ip)
+			require.NotNil(t, ip)
+			require.Equal(t, tc.public, publicIP(ip))
+		})
+	}
+}
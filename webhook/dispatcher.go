@@ -0,0 +1,225 @@
+// Package webhook delivers the events of the outbox to the URLs registered by users.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+)
+
+// headers of a delivery, besides `SignatureHeader`
+const (
+	EventTypeHeader = "Simplebank-Event"
+	DeliveryHeader  = "Simplebank-Delivery" // the same for every attempt, so receivers can drop duplicates
+)
+
+// errors are stored in `webhook_deliveries.last_error`, cut to this length
+const maxErrorLength = 255
+
+// Event is the body of a delivery.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher fans the outbox events out to webhooks, and delivers them with retries.
+// Several dispatchers (e.g. one per replica) can run at the same time: events & deliveries are claimed with
+// `SKIP LOCKED` and leased, so each one is only attempted by one dispatcher at a time.
+type Dispatcher struct {
+	store  db.Store
+	client *http.Client
+
+	interval    time.Duration // between 2 rounds
+	batchSize   int32         // events fanned out & deliveries attempted per round
+	maxAttempts int32
+	baseDelay   time.Duration // before the 2nd attempt, doubled for each next attempt
+	maxDelay    time.Duration
+
+	now func() time.Time
+}
+
+// NewDispatcher creates a dispatcher configured by the WEBHOOK_* settings, with defaults for the unset ones.
+func NewDispatcher(store db.Store, config util.Config) *Dispatcher {
+	batchSize := config.WebhookBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	maxAttempts := config.WebhookMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	// without a timeout, a receiver that never responds would stall every round,
+	// and outlive the lease of its delivery
+	timeout := config.WebhookTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	baseDelay := config.WebhookRetryBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = 30 * time.Second
+	}
+	maxDelay := config.WebhookRetryMaxDelay
+	if maxDelay < baseDelay {
+		maxDelay = baseDelay
+	}
+
+	return &Dispatcher{
+		store: store,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: newTransport(),
+			// a redirect is a failed delivery: the URL to call is the registered one, not another
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		interval:    config.WebhookDispatchInterval,
+		batchSize:   batchSize,
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		now:         time.Now,
+	}
+}
+
+// Run dispatches every `interval` until `ctx` is cancelled, e.g. as a worker of the server.
+func (dispatcher *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatcher.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := dispatcher.DispatchOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Println("cannot dispatch webhooks:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce runs one round: it fans out new events, then attempts the deliveries due, concurrently.
+func (dispatcher *Dispatcher) DispatchOnce(ctx context.Context) error {
+	_, err := dispatcher.store.FanOutOutboxTx(ctx, dispatcher.batchSize)
+	if err != nil {
+		return fmt.Errorf("cannot fan out events: %w", err)
+	}
+
+	// the lease outlasts the attempts, so a delivery is only picked up again if this dispatcher died
+	deliveries, err := dispatcher.store.ClaimWebhookDeliveries(ctx, db.ClaimWebhookDeliveriesParams{
+		LeaseUntil: dispatcher.now().Add(2*dispatcher.client.Timeout + time.Minute),
+		Limit:      dispatcher.batchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot claim deliveries: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := dispatcher.deliver(ctx, delivery); err != nil && ctx.Err() == nil {
+				log.Printf("cannot record delivery %d: %v", delivery.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// deliver makes one attempt of a delivery, and records its outcome.
+func (dispatcher *Dispatcher) deliver(ctx context.Context, delivery db.ClaimWebhookDeliveriesRow) error {
+	body, err := json.Marshal(Event{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.EventCreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	responseCode, err := dispatcher.post(ctx, delivery, body)
+	if ctx.Err() != nil {
+		return nil // shutting down: the attempt doesn't count, the delivery is retried once its lease expires
+	}
+
+	now := dispatcher.now()
+	arg := db.UpdateWebhookDeliveryParams{
+		ID:               delivery.ID,
+		Status:           db.DeliveryStatusPending,
+		Attempts:         delivery.Attempts + 1,
+		NextAttemptAt:    now,
+		LastResponseCode: sql.NullInt32{Int32: int32(responseCode), Valid: responseCode != 0},
+	}
+	switch {
+	case err == nil && responseCode >= 200 && responseCode < 300:
+		arg.Status = db.DeliveryStatusSucceeded
+		arg.DeliveredAt = sql.NullTime{Time: now, Valid: true}
+	case arg.Attempts >= dispatcher.maxAttempts:
+		arg.Status = db.DeliveryStatusFailed
+	default:
+		arg.NextAttemptAt = now.Add(dispatcher.backoff(arg.Attempts))
+	}
+	if err == nil && arg.Status != db.DeliveryStatusSucceeded {
+		err = fmt.Errorf("unexpected response status %d", responseCode)
+	}
+	if err != nil {
+		message := err.Error()
+		if len(message) > maxErrorLength {
+			message = message[:maxErrorLength]
+		}
+		arg.LastError = sql.NullString{String: message, Valid: true}
+	}
+
+	_, err = dispatcher.store.UpdateWebhookDelivery(ctx, arg)
+	return err
+}
+
+// post sends the signed event, and returns the response status code (0 if there's no response).
+func (dispatcher *Dispatcher) post(ctx context.Context, delivery db.ClaimWebhookDeliveriesRow, body []byte) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "simplebank-webhook")
+	request.Header.Set(EventTypeHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, dispatcher.now(), body))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	// read (some of) the body, so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	return response.StatusCode, nil
+}
+
+// backoff returns how long to wait after the `attempt`-th failed attempt.
+func (dispatcher *Dispatcher) backoff(attempt int32) time.Duration {
+	delay := dispatcher.baseDelay << (attempt - 1)
+	if delay <= 0 || delay > dispatcher.maxDelay { // `delay <= 0` on overflow
+		delay = dispatcher.maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/Oliver-Zen/simplebank/db/mock"
+	db "github.com/Oliver-Zen/simplebank/db/sqlc"
+	"github.com/Oliver-Zen/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDispatcher(store db.Store) *Dispatcher {
+	dispatcher := NewDispatcher(store, util.Config{
+		WebhookTimeout:        time.Second,
+		WebhookMaxAttempts:    3,
+		WebhookRetryBaseDelay: time.Minute,
+		WebhookRetryMaxDelay:  time.Hour,
+	})
+	// the receivers of the tests listen on loopback
+	dispatcher.client.Transport = http.DefaultTransport
+	now := time.Now().Truncate(time.Second)
+	dispatcher.now = func() time.Time { return now }
+	return dispatcher
+}
+
+func randomDelivery(url string, attempts int32) db.ClaimWebhookDeliveriesRow {
+	return db.ClaimWebhookDeliveriesRow{
+		ID:             util.RandomInt(1, 1000),
+		Attempts:       attempts,
+		Url:            url,
+		Secret:         "whsec_" + util.RandomString(32),
+		EventID:        util.RandomInt(1, 1000),
+		EventType:      db.EventAccountCredited,
+		Payload:        json.RawMessage(`{"account_id":1,"amount":10}`),
+		EventCreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func TestDispatchOnce(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+
+	testCases := []struct {
+		name         string
+		attempts     int32 // before this one
+		responseCode int
+		check        func(t *testing.T, dispatcher *Dispatcher, arg db.UpdateWebhookDeliveryParams)
+	}{
+		{
+			name:         "Delivered",
+			responseCode: http.StatusNoContent,
+			check: func(t *testing.T, dispatcher *Dispatcher, arg db.UpdateWebhookDeliveryParams) {
+				require.Equal(t, db.DeliveryStatusSucceeded, arg.Status)
+				require.Equal(t, int32(1), arg.Attempts)
+				require.Equal(t, int32(http.StatusNoContent), arg.LastResponseCode.Int32)
+				require.False(t, arg.LastError.Valid)
+				require.Equal(t, dispatcher.now(), arg.DeliveredAt.Time)
+			},
+		},
+		{
+			name:         "Retried",
+			attempts:     1,
+			responseCode: http.StatusServiceUnavailable,
+			check: func(t *testing.T, dispatcher *Dispatcher, arg db.UpdateWebhookDeliveryParams) {
+				require.Equal(t, db.DeliveryStatusPending, arg.Status)
+				require.Equal(t, int32(2), arg.Attempts)
+				require.Equal(t, int32(http.StatusServiceUnavailable), arg.LastResponseCode.Int32)
+				require.True(t, arg.LastError.Valid)
+				// the 2nd failure waits twice the base delay
+				require.Equal(t, dispatcher.now().Add(2*time.Minute), arg.NextAttemptAt)
+				require.False(t, arg.DeliveredAt.Valid)
+			},
+		},
+		{
+			name:         "OutOfAttempts",
+			attempts:     2,
+			responseCode: http.StatusInternalServerError,
+			check: func(t *testing.T, dispatcher *Dispatcher, arg db.UpdateWebhookDeliveryParams) {
+				require.Equal(t, db.DeliveryStatusFailed, arg.Status)
+				require.Equal(t, int32(3), arg.Attempts)
+			},
+		},
+		{
+			name:         "RedirectIsNotFollowed",
+			responseCode: http.StatusFound,
+			check: func(t *testing.T, dispatcher *Dispatcher, arg db.UpdateWebhookDeliveryParams) {
+				require.Equal(t, db.DeliveryStatusPending, arg.Status)
+				require.Equal(t, int32(http.StatusFound), arg.LastResponseCode.Int32)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			requests := make(chan received, 1)
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				requests <- received{r.Header, body}
+				if tc.responseCode == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tc.responseCode)
+			}))
+			defer receiver.Close()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			dispatcher := newTestDispatcher(store)
+			delivery := randomDelivery(receiver.URL, tc.attempts)
+
+			store.EXPECT().FanOutOutboxTx(gomock.Any(), gomock.Eq(int32(100))).Times(1).Return(1, nil)
+			store.EXPECT().
+				ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return([]db.ClaimWebhookDeliveriesRow{delivery}, nil)
+			store.EXPECT().
+				UpdateWebhookDelivery(gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ any, arg db.UpdateWebhookDeliveryParams) (db.WebhookDelivery, error) {
+					require.Equal(t, delivery.ID, arg.ID)
+					tc.check(t, dispatcher, arg)
+					return db.WebhookDelivery{}, nil
+				})
+
+			err := dispatcher.DispatchOnce(context.Background())
+			require.NoError(t, err)
+
+			// what the receiver got is signed with the secret of the webhook
+			req := <-requests
+			require.Equal(t, delivery.EventType, req.header.Get(EventTypeHeader))
+			require.NoError(t, Verify(delivery.Secret, req.header.Get(SignatureHeader), req.body, time.Minute, dispatcher.now()))
+
+			var event Event
+			require.NoError(t, json.Unmarshal(req.body, &event))
+			require.Equal(t, delivery.EventID, event.ID)
+			require.JSONEq(t, string(delivery.Payload), string(event.Data))
+		})
+	}
+}
+
+func TestDispatchUnreachable(t *testing.T) {
+	// nothing listens there anymore
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	receiver.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	dispatcher := newTestDispatcher(store)
+
+	store.EXPECT().FanOutOutboxTx(gomock.Any(), gomock.Any()).Times(1).Return(0, nil)
+	store.EXPECT().
+		ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.ClaimWebhookDeliveriesRow{randomDelivery(receiver.URL, 0)}, nil)
+	store.EXPECT().
+		UpdateWebhookDelivery(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.UpdateWebhookDeliveryParams) (db.WebhookDelivery, error) {
+			require.Equal(t, db.DeliveryStatusPending, arg.Status)
+			require.False(t, arg.LastResponseCode.Valid)
+			require.True(t, arg.LastError.Valid)
+			return db.WebhookDelivery{}, nil
+		})
+
+	err := dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+}
+
+func TestDispatchInternalAddress(t *testing.T) {
+	received := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer receiver.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the transport of a real dispatcher refuses loopback, whatever URL was registered
+	store := mockdb.NewMockStore(ctrl)
+	dispatcher := NewDispatcher(store, util.Config{WebhookTimeout: time.Second})
+
+	store.EXPECT().FanOutOutboxTx(gomock.Any(), gomock.Any()).Times(1).Return(0, nil)
+	store.EXPECT().
+		ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.ClaimWebhookDeliveriesRow{randomDelivery(receiver.URL, 0)}, nil)
+	store.EXPECT().
+		UpdateWebhookDelivery(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.UpdateWebhookDeliveryParams) (db.WebhookDelivery, error) {
+			require.False(t, arg.LastResponseCode.Valid)
+			require.Contains(t, arg.LastError.String, ErrForbiddenAddress.Error())
+			return db.WebhookDelivery{}, nil
+		})
+
+	require.NoError(t, dispatcher.DispatchOnce(context.Background()))
+	require.False(t, received)
+}
+
+func TestNewDispatcherDefaults(t *testing.T) {
+	dispatcher := NewDispatcher(nil, util.Config{})
+
+	// a receiver that never responds can't stall the dispatcher
+	require.Equal(t, 10*time.Second, dispatcher.client.Timeout)
+	// and retries are never immediate
+	require.Equal(t, 30*time.Second, dispatcher.backoff(1))
+	require.Equal(t, 30*time.Second, dispatcher.backoff(5))
+
+	dispatcher = NewDispatcher(nil, util.Config{WebhookRetryBaseDelay: time.Minute})
+	require.Equal(t, time.Minute, dispatcher.backoff(3))
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the signature of a delivery, as "t=<unix timestamp>,v1=<hex HMAC-SHA256>".
+// The HMAC covers "<timestamp>.<body>" so a captured delivery cannot be replayed later with a new timestamp.
+const SignatureHeader = "Simplebank-Signature"
+
+const secretPrefix = "whsec_"
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredSignature = errors.New("webhook signature is too old")
+)
+
+// GenerateSecret returns a new signing secret of a webhook.
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(secret), nil
+}
+
+// Sign returns the value of `SignatureHeader` for `body` sent at `timestamp`.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, signature(secret, t, body))
+}
+
+// Verify checks the value of `SignatureHeader` received with `body`, as receivers should.
+// Signatures older than `tolerance` are rejected, 0 accepts any age.
+func Verify(secret string, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+	timestamp, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || v1 == "" {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, t, body))) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && now.Sub(time.Unix(timestamp, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+func signature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
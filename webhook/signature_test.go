@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(secret, secretPrefix))
+
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	header := Sign(secret, now, body)
+
+	require.NoError(t, Verify(secret, header, body, 5*time.Minute, now))
+
+	// tampered body, wrong secret, malformed header
+	require.ErrorIs(t, Verify(secret, header, []byte(`{"id":2}`), 0, now), ErrInvalidSignature)
+	require.ErrorIs(t, Verify(secret+"x", header, body, 0, now), ErrInvalidSignature)
+	require.ErrorIs(t, Verify(secret, "v1=abc", body, 0, now), ErrInvalidSignature)
+
+	// a replayed delivery
+	require.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, now.Add(time.Hour)), ErrExpiredSignature)
+	require.NoError(t, Verify(secret, header, body, 0, now.Add(time.Hour)))
+}